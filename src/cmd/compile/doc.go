@@ -229,6 +229,16 @@ It specifies that calls to the function should not be inlined, overriding
 the compiler's usual optimization rules. This is typically only needed
 for special runtime functions or when debugging the compiler.
 
+	//go:noalloc
+
+The //go:noalloc directive must be followed by a function declaration.
+It specifies that the function must not allocate heap memory. The compiler
+reports an error at each operation in the function that may allocate,
+such as a variable moved to the heap, an append, a string concatenation,
+or a conversion to an interface. The check does not follow calls: a call
+to a function that allocates is not reported, unless the call is inlined,
+in which case the allocations of the inlined body are reported at the call.
+
 	//go:norace
 
 The //go:norace directive must be followed by a function declaration.
@@ -243,6 +253,18 @@ It specifies that the function must omit its usual stack overflow check.
 This is most commonly used by low-level runtime code invoked
 at times when it is unsafe for the calling goroutine to be preempted.
 
+	//go:noreflectmethods
+
+The //go:noreflectmethods directive must be followed by a type declaration.
+It specifies that the linker should not keep the exported methods of the
+type, and of its pointer type, just because the program calls
+reflect.Type.Method or reflect.Value.Method (or MethodByName with a
+non-constant name). Methods that are called directly, that may be called
+through an interface, or whose name is passed to MethodByName as a constant
+are kept as usual. Calling a method that was removed through reflection
+crashes the program, so the directive should only be used for types whose
+methods are known not to be called that way.
+
 	//go:linkname localname [importpath.name]
 
 This special directive does not apply to the Go code that follows it.
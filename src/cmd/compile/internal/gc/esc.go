@@ -5,6 +5,7 @@
 package gc
 
 import (
+	"cmd/compile/internal/logopt"
 	"cmd/compile/internal/types"
 	"fmt"
 )
@@ -362,6 +363,9 @@ func moveToHeap(n *Node) {
 	if Debug.m != 0 {
 		Warnl(n.Pos, "moved to heap: %v", n)
 	}
+	if logopt.Enabled() {
+		logopt.LogOpt(n.Pos, "movedToHeap", "escape", Curfn.funcname(), n.Sym.Name)
+	}
 }
 
 // This special tag is applied to uintptr variables
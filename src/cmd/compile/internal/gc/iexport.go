@@ -217,9 +217,10 @@ import (
 )
 
 // Current indexed export format version. Increase with each format change.
+// 2: added label names to break and continue statements
 // 1: added column details to Pos
 // 0: Go1.11 encoding
-const iexportVersion = 1
+const iexportVersion = 2
 
 // predeclReserved is the number of type offsets reserved for types
 // implicitly declared in the universe block.
@@ -973,7 +974,7 @@ func (w *exportWriter) funcExt(n *Node) {
 	}
 
 	// Inline body.
-	if n.Func.Inl != nil {
+	if n.Func.Inl != nil && !inlLocalOnly(n) {
 		w.uint64(1 + uint64(n.Func.Inl.Cost))
 		if n.Func.ExportInline() {
 			w.p.doInline(n)
@@ -1150,7 +1151,11 @@ func (w *exportWriter) stmt(n *Node) {
 	case OBREAK, OCONTINUE:
 		w.op(op)
 		w.pos(n.Pos)
-		w.exprsOrNil(n.Left, nil)
+		label := ""
+		if n.Sym != nil {
+			label = n.Sym.Name
+		}
+		w.string(label)
 
 	case OEMPTY:
 		// nothing to emit
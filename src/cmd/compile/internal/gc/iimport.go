@@ -781,6 +781,28 @@ func (r *importReader) stmtList() []*Node {
 		}
 
 	}
+	// Associate each label with the control statement it labels.
+	// The exporter writes the statement after the label, preceded
+	// only by the declarations in its init list.
+	for i, n := range list {
+		if n.Op != OLABEL {
+			continue
+		}
+	Next:
+		for _, ctl := range list[i+1:] {
+			switch ctl.Op {
+			case ODCL, OAS:
+				if ctl.Op == OAS && ctl.Right != nil {
+					break Next
+				}
+			case OFOR, OFORUNTIL, ORANGE, OSWITCH, OSELECT:
+				n.Name.Defn = ctl
+				break Next
+			default:
+				break Next
+			}
+		}
+	}
 	return list
 }
 
@@ -1066,12 +1088,11 @@ func (r *importReader) node() *Node {
 		return n
 
 	case OBREAK, OCONTINUE:
-		pos := r.pos()
-		left, _ := r.exprsOrNil()
-		if left != nil {
-			left = newname(left.Sym)
+		n := nodl(r.pos(), op, nil, nil)
+		if label := r.string(); label != "" {
+			n.Sym = lookup(label)
 		}
-		return nodl(pos, op, left, nil)
+		return n
 
 	// case OEMPTY:
 	// 	unreachable - not emitted by exporter
@@ -43,6 +43,7 @@ const (
 	inlineExtraCallCost  = 57              // 57 was benchmarked to provided most benefit with no bad surprises; see https://github.com/golang/go/issues/19348#issuecomment-439370742
 	inlineExtraPanicCost = 1               // do not penalize inlining panics.
 	inlineExtraThrowCost = inlineMaxBudget // with current (2018-05/1.11) code, inlining runtime.throw does not help.
+	inlineClosureCost    = 15              // cost of creating a closure, in addition to its body.
 
 	inlineBigFunctionNodes   = 5000 // Functions with this many nodes are considered "big".
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
@@ -205,6 +206,7 @@ func caninl(fn *Node) {
 		budget:        inlineMaxBudget,
 		extraCallCost: cc,
 		usedLocals:    make(map[*Node]bool),
+		closure:       fn.Func.Closure != nil,
 	}
 	if visitor.visitList(fn.Nbody) {
 		reason = visitor.reason
@@ -247,7 +249,7 @@ func inlFlood(n *Node) {
 	if n.Func == nil {
 		Fatalf("inlFlood: missing Func on %v", n)
 	}
-	if n.Func.Inl == nil {
+	if n.Func.Inl == nil || inlLocalOnly(n) {
 		return
 	}
 
@@ -283,19 +285,37 @@ func inlFlood(n *Node) {
 		case OCALLPART:
 			// Okay, because we don't yet inline indirect
 			// calls to method values.
-		case OCLOSURE:
-			// If the closure is inlinable, we'll need to
-			// flood it too. But today we don't support
-			// inlining functions that contain closures.
-			//
-			// When we do, we'll probably want:
-			//     inlFlood(n.Func.Closure.Func.Nname)
-			Fatalf("unexpected closure in inlinable function")
+		case OCLOSURE, OSELECT:
+			// Such bodies are not exported; see inlLocalOnly.
+			Fatalf("unexpected %v in inlinable function", n.Op)
 		}
 		return true
 	})
 }
 
+// inlLocalOnly reports whether the inline body of fn contains a
+// function literal or a select statement. The export data cannot
+// represent these, so such functions are only inlined within their
+// own package.
+func inlLocalOnly(fn *Node) bool {
+	return inlBodyHas(fn, OCLOSURE, OSELECT)
+}
+
+// inlBodyHas reports whether the inline body of fn contains a node
+// with one of the given ops.
+func inlBodyHas(fn *Node, ops ...Op) bool {
+	found := false
+	inspectList(asNodes(fn.Func.Inl.Body), func(n *Node) bool {
+		for _, op := range ops {
+			if n.Op == op {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
 // hairyVisitor visits a function body to determine its inlining
 // hairiness and whether or not it can be inlined.
 type hairyVisitor struct {
@@ -303,11 +323,23 @@ type hairyVisitor struct {
 	reason        string
 	extraCallCost int32
 	usedLocals    map[*Node]bool
+	closure       bool // whether the function being visited is a closure
+	inClosure     bool // whether visiting the body of a closure within it
 }
 
 // Look for anything we want to punt on.
 func (v *hairyVisitor) visitList(ll Nodes) bool {
-	for _, n := range ll.Slice() {
+	s := ll.Slice()
+	for i, n := range s {
+		// A labeled control statement must follow its label
+		// in the same statement list, so that relinkLabels
+		// can find it in the copied body.
+		if n != nil && n.Op == OLABEL {
+			if ctl := n.labeledControl(); ctl != nil && indexNode(s[i+1:], ctl) < 0 {
+				v.reason = "labeled control"
+				return true
+			}
+		}
 		if v.visit(n) {
 			return true
 		}
@@ -345,7 +377,11 @@ func (v *hairyVisitor) visit(n *Node) bool {
 		}
 
 		if fn := inlCallee(n.Left); fn != nil && fn.Func.Inl != nil {
-			v.budget -= fn.Func.Inl.Cost
+			// The body of a function literal called where it
+			// is defined is charged when visiting the literal.
+			if n.Left.Op != OCLOSURE {
+				v.budget -= fn.Func.Inl.Cost
+			}
 			break
 		}
 
@@ -393,12 +429,45 @@ func (v *hairyVisitor) visit(n *Node) bool {
 		v.reason = "call to recover"
 		return true
 
-	case OCLOSURE,
-		ORANGE,
-		OSELECT,
-		OGO,
-		ODEFER,
-		ODCLTYPE, // can't print yet
+	case OCLOSURE:
+		// The captured variables of a closure within a
+		// closure would have to be reached through the
+		// captured variables of the enclosing closure,
+		// which mkinlcall does not handle.
+		if v.closure {
+			v.reason = "closure within closure"
+			return true
+		}
+		v.budget -= inlineClosureCost
+
+		// The closure body is copied along with the function,
+		// so it counts against the budget, and the variables
+		// it captures must be kept in the inline Dcl list.
+		xfunc := n.Func.Closure
+		for _, cv := range xfunc.Func.Cvars.Slice() {
+			if cv.Op == ONAME {
+				v.usedLocals[cv.Name.Param.Outer] = true
+			}
+		}
+		inClosure := v.inClosure
+		v.inClosure = true
+		hairy := v.visitList(xfunc.Nbody)
+		v.inClosure = inClosure
+		if hairy {
+			return true
+		}
+
+	case ODEFER:
+		// A deferred call in an inlined body would run when
+		// the caller returns, not when the inlined call does.
+		// Closures remain functions of their own, so they
+		// may defer calls.
+		if !v.inClosure {
+			v.reason = "unhandled op " + n.Op.String()
+			return true
+		}
+
+	case ODCLTYPE, // can't print yet
 		ORETJMP:
 		v.reason = "unhandled op " + n.Op.String()
 		return true
@@ -410,19 +479,6 @@ func (v *hairyVisitor) visit(n *Node) bool {
 		// These nodes don't produce code; omit from inlining budget.
 		return false
 
-	case OLABEL:
-		// TODO(mdempsky): Add support for inlining labeled control statements.
-		if n.labeledControl() != nil {
-			v.reason = "labeled control"
-			return true
-		}
-
-	case OBREAK, OCONTINUE:
-		if n.Sym != nil {
-			// Should have short-circuited due to labeledControl above.
-			Fatalf("unexpected labeled break/continue: %v", n)
-		}
-
 	case OIF:
 		if Isconst(n.Left, CTBOOL) {
 			// This if and the condition cost nothing.
@@ -457,9 +513,43 @@ func inlcopylist(ll []*Node) []*Node {
 	for _, n := range ll {
 		s = append(s, inlcopy(n))
 	}
+	relinkLabels(ll, s)
 	return s
 }
 
+// relinkLabels updates the labels in the statement list s, a copy of
+// the list orig, to refer to the copies of their labeled control
+// statements instead of the originals. A labeled control statement
+// always follows its label in the same list (see hairyVisitor.visitList).
+func relinkLabels(orig, s []*Node) {
+	for i, n := range orig {
+		if n == nil || n.Op != OLABEL || s[i] == nil || s[i].Op != OLABEL {
+			continue
+		}
+		ctl := n.labeledControl()
+		if ctl == nil {
+			continue
+		}
+		j := indexNode(orig[i+1:], ctl)
+		if j < 0 {
+			Fatalf("labeled control %v does not follow label %v", ctl, n)
+		}
+		name := *n.Name
+		name.Defn = s[i+1+j]
+		s[i].Name = &name
+	}
+}
+
+// indexNode returns the index of n in list, or -1.
+func indexNode(list []*Node, n *Node) int {
+	for i, m := range list {
+		if m == n {
+			return i
+		}
+	}
+	return -1
+}
+
 func inlcopy(n *Node) *Node {
 	if n == nil {
 		return nil
@@ -468,6 +558,8 @@ func inlcopy(n *Node) *Node {
 	switch n.Op {
 	case ONAME, OTYPE, OLITERAL:
 		return n
+	case OCLOSURE:
+		return inlcopyclosure(n)
 	}
 
 	m := n.copy()
@@ -484,6 +576,29 @@ func inlcopy(n *Node) *Node {
 	return m
 }
 
+// inlcopyclosure copies the function literal n along with the body of
+// its function, so that the copy is unaffected when calls within the
+// closure are inlined later on.
+func inlcopyclosure(n *Node) *Node {
+	xfunc := n.Func.Closure
+
+	m := n.copy()
+	clo := *n.Func
+	m.Func = &clo
+	m.Func.Enter.Set(inlcopylist(n.Func.Enter.Slice()))
+
+	x := xfunc.copy()
+	f := *xfunc.Func
+	x.Func = &f
+	x.Func.Cvars.Set(append([]*Node(nil), xfunc.Func.Cvars.Slice()...))
+	x.Func.Dcl = append([]*Node(nil), xfunc.Func.Dcl...)
+	x.Nbody.Set(inlcopylist(xfunc.Nbody.Slice()))
+
+	m.Func.Closure = x
+	x.Func.Closure = m
+	return m
+}
+
 func countNodes(n *Node) int {
 	if n == nil {
 		return 0
@@ -937,6 +1052,16 @@ func mkinlcall(n, fn *Node, maxCost int32, inlMap map[*Node]bool) *Node {
 		return n
 	}
 
+	if Curfn.Func.Wrapper() && inlBodyHas(fn, OCLOSURE) {
+		// Wrappers are generated after closures have been
+		// captured, analyzed and transformed, which is too
+		// late for the closures copied by inlining.
+		if Debug.m > 1 {
+			fmt.Printf("%v: cannot inline %v into %v: closure in wrapper\n", n.Line(), fn, Curfn.funcname())
+		}
+		return n
+	}
+
 	if inlMap[fn] {
 		if Debug.m > 1 {
 			fmt.Printf("%v: cannot inline %v into %v: repeated recursive cycle\n", n.Line(), fn, Curfn.funcname())
@@ -957,6 +1082,9 @@ func mkinlcall(n, fn *Node, maxCost int32, inlMap map[*Node]bool) *Node {
 	} else if Debug.m != 0 {
 		fmt.Printf("%v: inlining call to %v\n", n.Line(), fn)
 	}
+	if logopt.Enabled() {
+		logopt.LogOpt(n.Pos, "inlineCall", "inline", Curfn.funcname(), fn.pkgFuncName())
+	}
 	if Debug.m > 2 {
 		fmt.Printf("%v: Before inlining: %+v\n", n.Line(), n)
 	}
@@ -980,6 +1108,12 @@ func mkinlcall(n, fn *Node, maxCost int32, inlMap map[*Node]bool) *Node {
 		if callee.Op != ONAME && callee.Op != OCLOSURE {
 			Fatalf("unexpected callee expression: %v", callee)
 		}
+
+		// A function literal called where it is defined has no
+		// other uses, so once inlined its function is dead.
+		if callee.Op == OCLOSURE {
+			callee.Func.Closure.Func.SetIsDeadcodeClosure(true)
+		}
 	}
 
 	// Make temp names to use instead of the originals.
@@ -1232,6 +1366,21 @@ func mkinlcall(n, fn *Node, maxCost int32, inlMap map[*Node]bool) *Node {
 		}
 	}
 
+	// The closures copied by subst are new functions that
+	// caninl and inlcalls never visit, so handle them here.
+	for _, x := range subst.closures {
+		caninl(x)
+		savefn := Curfn
+		Curfn = x
+		inlnodelist(x.Nbody, maxCost, inlMap)
+		for _, n := range x.Nbody.Slice() {
+			if n.Op == OINLCALL {
+				inlconv2stmt(n)
+			}
+		}
+		Curfn = savefn
+	}
+
 	if Debug.m > 2 {
 		fmt.Printf("%v: After inlining %+v\n\n", call.Line(), call)
 	}
@@ -1303,6 +1452,13 @@ type inlsubst struct {
 	// newInlIndex is the index of the inlined call frame to
 	// insert for inlined nodes.
 	newInlIndex int
+
+	// clofn is the function of the closure whose body is being
+	// copied, if any.
+	clofn *Node
+
+	// closures lists the functions of the copied closures.
+	closures []*Node
 }
 
 // list inlines a list of nodes.
@@ -1311,6 +1467,7 @@ func (subst *inlsubst) list(ll Nodes) []*Node {
 	for _, n := range ll.Slice() {
 		s = append(s, subst.node(n))
 	}
+	relinkLabels(ll.Slice(), s)
 	return s
 }
 
@@ -1345,10 +1502,13 @@ func (subst *inlsubst) node(n *Node) *Node {
 			return n
 		}
 
-		// Since we don't handle bodies with closures, this return is guaranteed to belong to the current inlined function.
-
 	//		dump("Return before substitution", n);
 	case ORETURN:
+		if subst.clofn != nil {
+			// This return belongs to the closure, not to
+			// the inlined function.
+			break
+		}
 		m := nodSym(OGOTO, nil, subst.retlabel)
 		m.Ninit.Set(subst.list(n.Ninit))
 
@@ -1384,20 +1544,28 @@ func (subst *inlsubst) node(n *Node) *Node {
 		m := n.copy()
 		m.Pos = subst.updatedPos(m.Pos)
 		m.Ninit.Set(nil)
-		p := fmt.Sprintf("%s·%d", n.Sym.Name, inlgen)
-		m.Sym = lookup(p)
+		m.Sym = subst.label(n.Sym)
+
+		return m
+
+	case OBREAK, OCONTINUE:
+		m := n.copy()
+		m.Pos = subst.updatedPos(m.Pos)
+		m.Ninit.Set(nil)
+		if n.Sym != nil {
+			m.Sym = subst.label(n.Sym)
+		}
 
 		return m
+
+	case OCLOSURE:
+		return subst.closure(n)
 	}
 
 	m := n.copy()
 	m.Pos = subst.updatedPos(m.Pos)
 	m.Ninit.Set(nil)
 
-	if n.Op == OCLOSURE {
-		Fatalf("cannot inline function containing closure: %+v", n)
-	}
-
 	m.Left = subst.node(n.Left)
 	m.Right = subst.node(n.Right)
 	m.List.Set(subst.list(n.List))
@@ -1408,7 +1576,134 @@ func (subst *inlsubst) node(n *Node) *Node {
 	return m
 }
 
+// closure copies the function literal n from the inlined body. The
+// closure gets a new function, declared in the function being inlined
+// into, with new names for its parameters, locals and captured
+// variables.
+func (subst *inlsubst) closure(n *Node) *Node {
+	xfunc := n.Func.Closure
+
+	m := n.copy()
+	m.Pos = subst.updatedPos(m.Pos)
+	clo := *n.Func
+	m.Func = &clo
+	m.Func.Enter.Set(subst.list(n.Func.Enter))
+
+	x := nodl(xfunc.Pos, ODCLFUNC, nil, nil)
+	x.Func.SetIsHiddenClosure(true)
+	x.Func.Nname = newfuncnamel(xfunc.Func.Nname.Pos, closurename(Curfn))
+	x.Func.Nname.Name.Defn = x
+	setNodeNameFunc(x.Func.Nname)
+	x.Func.Parents = xfunc.Func.Parents
+	x.Func.Marks = xfunc.Func.Marks
+	x.Func.Label = xfunc.Func.Label
+	x.Func.Endlineno = xfunc.Func.Endlineno
+	x.Func.Closure = m
+	m.Func.Closure = x
+
+	for _, ln := range xfunc.Func.Dcl {
+		if ln.Op != ONAME {
+			x.Func.Dcl = append(x.Func.Dcl, ln)
+			continue
+		}
+		v := closurevar(ln, x)
+		v.Name.Defn = nil
+		subst.inlvars[ln] = v
+		x.Func.Dcl = append(x.Func.Dcl, v)
+	}
+
+	// Captured variables now refer to the variables of the
+	// function being inlined into.
+	for _, cv := range xfunc.Func.Cvars.Slice() {
+		if cv.Op != ONAME {
+			x.Func.Cvars.Append(cv)
+			continue
+		}
+		outer := subst.inlvars[cv.Name.Param.Outer]
+		outermost := subst.inlvars[cv.Name.Defn]
+		if outer == nil || outer.Op != ONAME || outermost == nil || outermost.Op != ONAME {
+			Fatalf("%v: unresolvable capture %v", n.Line(), cv)
+		}
+		v := closurevar(cv, x)
+		v.Name.Param.Outer = outer
+		v.Name.Param.Innermost = nil
+		v.Name.Defn = outermost
+		outermost.Name.SetCaptured(true)
+		subst.inlvars[cv] = v
+		x.Func.Cvars.Append(v)
+	}
+
+	// transformclosure rewrites the signature of a closure in
+	// place, so the new function needs its own.
+	t := xfunc.Func.Nname.Type
+	x.Type = functypefield(nil, subst.fields(t.Params(), x), subst.fields(t.Results(), x))
+	x.Type.FuncType().Nname = asTypesNode(x.Func.Nname)
+	x.Func.Nname.Type = x.Type
+	x.Func.Nname.SetTypecheck(1)
+	x.SetTypecheck(1)
+
+	xtop = append(xtop, x)
+	subst.closures = append(subst.closures, x)
+
+	clofn, savefn := subst.clofn, Curfn
+	subst.clofn = x
+	Curfn = x
+	x.Nbody.Set(subst.list(xfunc.Nbody))
+	subst.clofn = clofn
+	Curfn = savefn
+
+	return m
+}
+
+// fields returns copies of the parameter fields of t that refer to
+// the new names of the parameters of the closure function x.
+func (subst *inlsubst) fields(t *types.Type, x *Node) []*types.Field {
+	var fields []*types.Field
+	for _, f := range t.FieldSlice() {
+		f = f.Copy()
+		if n := asNode(f.Nname); n != nil {
+			m := subst.inlvars[n]
+			if m == nil {
+				if !n.isBlank() {
+					Fatalf("missing inlvar for %v", n)
+				}
+				// Blank parameters are not declared.
+				m = closurevar(n, x)
+			}
+			f.Nname = asTypesNode(m)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// closurevar returns a copy of the parameter, local or captured
+// variable n of a closure, declared in the closure function x.
+func closurevar(n, x *Node) *Node {
+	m := n.copy()
+	name := *n.Name
+	m.Name = &name
+	if n.Name.Param != nil {
+		p := *n.Name.Param
+		m.Name.Param = &p
+	}
+	m.Name.Curfn = x
+	return m
+}
+
+// label returns the symbol to use in place of the label sym
+// in the inlined copy of the body, so that labels from different
+// inlined calls do not collide.
+func (subst *inlsubst) label(sym *types.Sym) *types.Sym {
+	return lookup(fmt.Sprintf("%s·%d", sym.Name, inlgen))
+}
+
 func (subst *inlsubst) updatedPos(xpos src.XPos) src.XPos {
+	if subst.clofn != nil {
+		// The closure body is compiled as its own function,
+		// not as part of the inlined call.
+		return xpos
+	}
 	pos := Ctxt.PosTable.Pos(xpos)
 	oldbase := pos.Base() // can be nil
 	newbase := subst.bases[oldbase]
@@ -28,7 +28,7 @@ func isQuoted(s string) bool {
 	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
 }
 
-type PragmaFlag int16
+type PragmaFlag int32
 
 const (
 	// Func pragmas.
@@ -40,6 +40,7 @@ const (
 	NoCheckPtr                // func should not be instrumented by checkptr
 	CgoUnsafeArgs             // treat a pointer to one arg as a pointer to them all
 	UintptrEscapes            // pointers converted to uintptr escape
+	Noalloc                   // func must not allocate heap memory
 
 	// Runtime-only func pragmas.
 	// See ../../../../runtime/README.md for detailed descriptions.
@@ -51,6 +52,9 @@ const (
 	// Runtime and cgo type pragmas
 	NotInHeap // values of this type must not be heap allocated
 
+	// Type pragmas
+	NoReflectMethods // methods of this type are not kept for calls through reflection
+
 	// Go command pragmas
 	GoBuildPragma
 )
@@ -64,12 +68,13 @@ const (
 		NoCheckPtr |
 		CgoUnsafeArgs |
 		UintptrEscapes |
+		Noalloc |
 		Systemstack |
 		Nowritebarrier |
 		Nowritebarrierrec |
 		Yeswritebarrierrec
 
-	TypePragmas = NotInHeap
+	TypePragmas = NotInHeap | NoReflectMethods
 )
 
 func pragmaFlag(verb string) PragmaFlag {
@@ -88,6 +93,8 @@ func pragmaFlag(verb string) PragmaFlag {
 		return Nosplit | NoCheckPtr // implies NoCheckPtr (see #34972)
 	case "go:noinline":
 		return Noinline
+	case "go:noalloc":
+		return Noalloc
 	case "go:nocheckptr":
 		return NoCheckPtr
 	case "go:systemstack":
@@ -115,6 +122,8 @@ func pragmaFlag(verb string) PragmaFlag {
 		return UintptrEscapes
 	case "go:notinheap":
 		return NotInHeap
+	case "go:noreflectmethods":
+		return NoReflectMethods
 	}
 	return 0
 }
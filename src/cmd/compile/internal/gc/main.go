@@ -758,7 +758,7 @@ func Main(archInit func(*Arch)) {
 	fcount = 0
 	for i := 0; i < len(xtop); i++ {
 		n := xtop[i]
-		if n.Op == ODCLFUNC {
+		if n.Op == ODCLFUNC && !n.Func.IsDeadcodeClosure() {
 			funccompile(n)
 			fcount++
 		}
@@ -774,6 +774,9 @@ func Main(archInit func(*Arch)) {
 		nowritebarrierrecCheck = nil
 	}
 
+	// Heap allocations are now known. Check go:noalloc functions.
+	checkNoalloc()
+
 	// Finalize DWARF inline routine DIEs, then explicitly turn off
 	// DWARF inlining gen so as to avoid problems with generated
 	// method wrappers.
@@ -698,7 +698,15 @@ func typePkg(t *types.Type) *types.Pkg {
 // dextratypeData dumps the backing array for the []method field of
 // runtime.uncommontype.
 func dextratypeData(lsym *obj.LSym, ot int, t *types.Type) int {
-	for _, a := range methods(t) {
+	m := methods(t)
+	if len(m) > 0 && (t.NoReflectMethods() || t.IsPtr() && t.Elem().NoReflectMethods()) {
+		// Tell the linker not to keep the methods of t
+		// just because reflection might call them.
+		r := obj.Addrel(lsym)
+		r.Sym = lsym
+		r.Type = objabi.R_NOREFLECTMETHODS
+	}
+	for _, a := range m {
 		// ../../../../runtime/type.go:/method
 		exported := types.IsExported(a.name.Name)
 		var pkg *types.Pkg
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"bufio"
 	"bytes"
@@ -4532,6 +4533,76 @@ func (s *state) callAddr(n *Node, k callKind) *ssa.Value {
 	return s.call(n, k, true)
 }
 
+// allocFuncs are the runtime functions that may allocate heap memory.
+// Calls to them, whether written by the user or introduced when
+// lowering Go statements and expressions, are reported in functions
+// marked go:noalloc. Each maps to a description of the allocation.
+var allocFuncs = map[string]string{
+	"newobject":           "new object",
+	"mallocgc":            "new object",
+	"makeslice":           "make slice",
+	"makeslice64":         "make slice",
+	"makeslicecopy":       "make slice",
+	"growslice":           "append",
+	"makemap":             "make map",
+	"makemap64":           "make map",
+	"makemap_small":       "make map",
+	"mapassign":           "map assignment",
+	"mapassign_fast32":    "map assignment",
+	"mapassign_fast32ptr": "map assignment",
+	"mapassign_fast64":    "map assignment",
+	"mapassign_fast64ptr": "map assignment",
+	"mapassign_faststr":   "map assignment",
+	"makechan":            "make chan",
+	"makechan64":          "make chan",
+	"concatstring2":       "string concatenation",
+	"concatstring3":       "string concatenation",
+	"concatstring4":       "string concatenation",
+	"concatstring5":       "string concatenation",
+	"concatstrings":       "string concatenation",
+	"intstring":           "conversion to string",
+	"slicebytetostring":   "conversion to string",
+	"slicerunetostring":   "conversion to string",
+	"stringtoslicebyte":   "conversion to []byte",
+	"stringtoslicerune":   "conversion to []rune",
+	"convT16":             "conversion to interface",
+	"convT32":             "conversion to interface",
+	"convT64":             "conversion to interface",
+	"convTstring":         "conversion to interface",
+	"convTslice":          "conversion to interface",
+	"convT2E":             "conversion to interface",
+	"convT2Enoptr":        "conversion to interface",
+	"convT2I":             "conversion to interface",
+	"convT2Inoptr":        "conversion to interface",
+	"deferproc":           "defer",
+	"newproc":             "go statement",
+}
+
+// checkAlloc records a call to the runtime function name
+// if it may allocate and the current function is marked go:noalloc.
+func (s *state) checkAlloc(name string) {
+	if s.curfn.Func.Pragma&Noalloc == 0 {
+		return
+	}
+	if what, ok := allocFuncs[name]; ok {
+		s.curfn.Func.addAlloc(s.peekPos(), what)
+	}
+}
+
+// checkNoalloc reports an error for each heap allocation in the
+// functions marked go:noalloc. It must run after the functions have
+// been compiled.
+func checkNoalloc() {
+	for _, n := range xtop {
+		if n.Op != ODCLFUNC || n.Func.Pragma&Noalloc == 0 {
+			continue
+		}
+		for _, a := range n.Func.Allocs {
+			yyerrorl(a.pos, "heap allocation (%s) in go:noalloc function %v", a.what, n.Func.Nname)
+		}
+	}
+}
+
 // Calls the function n using the specified call type.
 // Returns the address of the return value (or nil if none).
 func (s *state) call(n *Node, k callKind, returnResultAddr bool) *ssa.Value {
@@ -4555,11 +4626,21 @@ func (s *state) call(n *Node, k callKind, returnResultAddr bool) *ssa.Value {
 
 	testLateExpansion := false
 
+	switch k {
+	case callDefer:
+		s.checkAlloc("deferproc")
+	case callGo:
+		s.checkAlloc("newproc")
+	}
+
 	switch n.Op {
 	case OCALLFUNC:
 		testLateExpansion = k != callDeferStack && ssa.LateCallExpansionEnabledWithin(s.f)
 		if k == callNormal && fn.Op == ONAME && fn.Class() == PFUNC {
 			sym = fn.Sym
+			if sym.Pkg == Runtimepkg || isRuntimePkg(sym.Pkg) {
+				s.checkAlloc(sym.Name)
+			}
 			break
 		}
 		closure = s.expr(fn)
@@ -5221,6 +5302,7 @@ func (s *state) intDivide(n *Node, a, b *ssa.Value) *ssa.Value {
 // If returns is false, the block is marked as an exit block.
 func (s *state) rtcall(fn *obj.LSym, returns bool, results []*types.Type, args ...*ssa.Value) []*ssa.Value {
 	s.prevCall = nil
+	s.checkAlloc(strings.TrimPrefix(fn.Name, "runtime."))
 	// Write args to the stack
 	off := Ctxt.FixedFrameSize()
 	testLateExpansion := ssa.LateCallExpansionEnabledWithin(s.f)
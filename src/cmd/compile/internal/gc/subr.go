@@ -931,7 +931,7 @@ func (n *Node) backingArrayPtrLen() (ptr, len *Node) {
 	return ptr, len
 }
 
-// labeledControl returns the control flow Node (for, switch, select)
+// labeledControl returns the control flow Node (for, range, switch, select)
 // associated with the label n, if any.
 func (n *Node) labeledControl() *Node {
 	if n.Op != OLABEL {
@@ -942,7 +942,7 @@ func (n *Node) labeledControl() *Node {
 		return nil
 	}
 	switch ctl.Op {
-	case OFOR, OFORUNTIL, OSWITCH, OSELECT:
+	case OFOR, OFORUNTIL, ORANGE, OSWITCH, OSELECT:
 		return ctl
 	}
 	return nil
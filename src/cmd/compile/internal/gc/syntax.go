@@ -653,7 +653,8 @@ type Func struct {
 	Label int32 // largest auto-generated label in this function
 
 	Endlineno src.XPos
-	WBPos     src.XPos // position of first write barrier; see SetWBPos
+	WBPos     src.XPos    // position of first write barrier; see SetWBPos
+	Allocs    []allocSite // heap allocations in a go:noalloc function; see addAlloc
 
 	Pragma PragmaFlag // go:xxx function annotations
 
@@ -703,6 +704,7 @@ const (
 	funcExportInline             // include inline body in export data
 	funcInstrumentBody           // add race/msan instrumentation during SSA construction
 	funcOpenCodedDeferDisallowed // can't do open-coded defers
+	funcIsDeadcodeClosure        // closure was inlined where it is defined and called, and need not be compiled
 )
 
 func (f *Func) Dupok() bool                    { return f.flags&funcDupok != 0 }
@@ -716,6 +718,7 @@ func (f *Func) InlinabilityChecked() bool      { return f.flags&funcInlinability
 func (f *Func) ExportInline() bool             { return f.flags&funcExportInline != 0 }
 func (f *Func) InstrumentBody() bool           { return f.flags&funcInstrumentBody != 0 }
 func (f *Func) OpenCodedDeferDisallowed() bool { return f.flags&funcOpenCodedDeferDisallowed != 0 }
+func (f *Func) IsDeadcodeClosure() bool        { return f.flags&funcIsDeadcodeClosure != 0 }
 
 func (f *Func) SetDupok(b bool)                    { f.flags.set(funcDupok, b) }
 func (f *Func) SetWrapper(b bool)                  { f.flags.set(funcWrapper, b) }
@@ -728,6 +731,7 @@ func (f *Func) SetInlinabilityChecked(b bool)      { f.flags.set(funcInlinabilit
 func (f *Func) SetExportInline(b bool)             { f.flags.set(funcExportInline, b) }
 func (f *Func) SetInstrumentBody(b bool)           { f.flags.set(funcInstrumentBody, b) }
 func (f *Func) SetOpenCodedDeferDisallowed(b bool) { f.flags.set(funcOpenCodedDeferDisallowed, b) }
+func (f *Func) SetIsDeadcodeClosure(b bool)        { f.flags.set(funcIsDeadcodeClosure, b) }
 
 func (f *Func) setWBPos(pos src.XPos) {
 	if Debug_wb != 0 {
@@ -738,6 +742,24 @@ func (f *Func) setWBPos(pos src.XPos) {
 	}
 }
 
+// An allocSite is a heap allocation found by the go:noalloc check.
+type allocSite struct {
+	pos  src.XPos
+	what string // description of the allocation
+}
+
+// addAlloc records a heap allocation at pos, described by what,
+// for the go:noalloc check. Several runtime calls made for a single
+// operation are recorded once.
+func (f *Func) addAlloc(pos src.XPos, what string) {
+	for _, a := range f.Allocs {
+		if a.pos == pos && a.what == what {
+			return
+		}
+	}
+	f.Allocs = append(f.Allocs, allocSite{pos, what})
+}
+
 //go:generate stringer -type=Op -trimprefix=O
 
 type Op uint8
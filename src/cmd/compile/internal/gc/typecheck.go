@@ -3492,6 +3492,11 @@ func setUnderlying(t, underlying *types.Type) {
 		t.SetNotInHeap(true)
 	}
 
+	// Likewise for go:noreflectmethods.
+	if n.Name != nil && n.Name.Param != nil && n.Name.Param.Pragma()&NoReflectMethods != 0 {
+		t.SetNoReflectMethods(true)
+	}
+
 	// Update types waiting on this type.
 	for _, w := range ft.Copyto {
 		setUnderlying(w, t)
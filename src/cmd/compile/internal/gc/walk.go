@@ -554,6 +554,9 @@ opswitch:
 			usemethod(n)
 			markUsedIfaceMethod(n)
 		}
+		if n.Op == OCALLMETH {
+			usemethod(n)
+		}
 
 		if n.Op == OCALLFUNC && n.Left.Op == OCLOSURE {
 			// Transform direct call of a closure to call of a normal function.
@@ -3666,16 +3669,37 @@ func bounded(n *Node, max int64) bool {
 	return false
 }
 
-// usemethod checks interface method calls for uses of reflect.Type.Method.
+// usemethod checks interface method calls for uses of reflect.Type.Method
+// and reflect.Type.MethodByName, and method calls for uses of
+// reflect.Value.Method and reflect.Value.MethodByName. These can call
+// any exported method of any reachable type, so it tells the linker to
+// keep them. If MethodByName is called with a constant name, only the
+// methods with that name need to be kept.
 func usemethod(n *Node) {
+	// Don't mark the reflect methods themselves,
+	// or every use of them would look dynamic.
+	if myimportpath == "reflect" {
+		switch Curfn.funcname() {
+		case "(*rtype).Method", "(*rtype).MethodByName",
+			"(*interfaceType).Method", "(*interfaceType).MethodByName",
+			"Value.Method", "Value.MethodByName":
+			return
+		}
+	}
+
 	t := n.Left.Type
+	if n.Op == OCALLMETH {
+		// Only reflect.Value's Method and MethodByName.
+		if s := n.Left.Sym; s.Name != "Value.Method" && s.Name != "Value.MethodByName" || !isReflectPkg(s.Pkg) {
+			return
+		}
+	}
 
-	// Looking for either of:
+	// Looking for any of:
 	//	Method(int) reflect.Method
 	//	MethodByName(string) (reflect.Method, bool)
-	//
-	// TODO(crawshaw): improve precision of match by working out
-	//                 how to check the method name.
+	//	Method(int) reflect.Value
+	//	MethodByName(string) reflect.Value
 	if n := t.NumParams(); n != 1 {
 		return
 	}
@@ -3689,27 +3713,50 @@ func usemethod(n *Node) {
 		res1 = t.Results().Field(1)
 	}
 
-	if res1 == nil {
+	// Note: Don't rely on res0.Type.String() since its formatting depends on multiple factors
+	//       (including global variables such as numImports - was issue #19028).
+	// Also need to check for reflect package itself (see Issue #38515).
+	s := res0.Type.Sym
+	if s == nil || !isReflectPkg(s.Pkg) {
+		return
+	}
+	byName := p0.Type.IsString()
+	switch {
+	case s.Name == "Method" && res1 == nil:
 		if p0.Type.Etype != TINT {
 			return
 		}
-	} else {
-		if !p0.Type.IsString() {
+	case s.Name == "Method":
+		if !byName || !res1.Type.IsBoolean() {
 			return
 		}
-		if !res1.Type.IsBoolean() {
+	case s.Name == "Value" && res1 == nil:
+		if !byName && p0.Type.Etype != TINT {
 			return
 		}
+	default:
+		return
 	}
 
-	// Note: Don't rely on res0.Type.String() since its formatting depends on multiple factors
-	//       (including global variables such as numImports - was issue #19028).
-	// Also need to check for reflect package itself (see Issue #38515).
-	if s := res0.Type.Sym; s != nil && s.Name == "Method" && isReflectPkg(s.Pkg) {
-		Curfn.Func.SetReflectMethod(true)
-		// The LSym is initialized at this point. We need to set the attribute on the LSym.
-		Curfn.Func.lsym.Set(obj.AttrReflectMethod, true)
+	if byName {
+		if arg := n.List.First(); Isconst(arg, CTSTR) {
+			name := arg.StringVal()
+			if !types.IsExported(name) {
+				// MethodByName only finds exported methods.
+				return
+			}
+			// Emit a marker relocation. The linker will keep
+			// the methods with this name if Curfn is reachable.
+			r := obj.Addrel(Curfn.Func.lsym)
+			r.Sym = stringsym(n.Pos, name)
+			r.Type = objabi.R_USENAMEDMETHOD
+			return
+		}
 	}
+
+	Curfn.Func.SetReflectMethod(true)
+	// The LSym is initialized at this point. We need to set the attribute on the LSym.
+	Curfn.Func.lsym.Set(obj.AttrReflectMethod, true)
 }
 
 func usefield(n *Node) {
@@ -58,6 +58,7 @@ import (
 // Severity: (always) SeverityInformation (3)
 // Source: (always) "go compiler"
 // Code: a string describing the missed optimization, e.g., "nilcheck", "cannotInline", "isInBounds", "escape"
+//    or the optimization decision, e.g., "canInlineFunction", "inlineCall", "movedToHeap".
 // Message: depending on code, additional information, e.g., the reason a function cannot be inlined.
 // RelatedInformation: if the missed optimization actually occurred at a function inlined at Range,
 //    then the sequence of inlined locations appears here, from (second) outermost to innermost,
@@ -67,6 +68,16 @@ import (
 //    the lines of the explanation appear, each potentially followed with its own inlining
 //    location if the escape flow occurred within an inlined function.
 //
+// Escape analysis and inlining decisions are reported with these codes:
+//
+//    "escape", "escapes": a value escapes to the heap; the explanation is the flow path.
+//    "leak": a parameter leaks to the heap or to a result; the explanation is the flow path.
+//    "movedToHeap": a variable is allocated on the heap; the message is its name.
+//    "canInlineFunction", "cannotInlineFunction": whether a function can be inlined,
+//       with its cost or the reason it cannot.
+//    "inlineCall", "cannotInlineCall": whether a call is inlined; the message is the
+//       callee or the reason the call is not inlined.
+//
 // For example <destination>/cmd%2Fcompile%2Finternal%2Fssa/prove.json
 // might begin with the following line (wrapped for legibility):
 //
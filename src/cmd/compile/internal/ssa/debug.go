@@ -467,10 +467,11 @@ func (state *debugState) liveness() []*BlockDebug {
 		for _, v := range b.Values {
 			slots := state.valueNames[v.ID]
 
-			// Loads and stores inherit the names of their sources.
+			// Loads, stores and register copies inherit the names
+			// of their sources.
 			var source *Value
 			switch v.Op {
-			case OpStoreReg:
+			case OpStoreReg, OpCopy:
 				source = v.Args[0]
 			case OpLoadReg:
 				switch a := v.Args[0]; a.Op {
@@ -1077,6 +1078,10 @@ func (state *debugState) writePendingEntry(varID VarID, endBlock, endValue ID) {
 
 // PutLocationList adds list (a location list in its intermediate representation) to listSym.
 func (debugInfo *FuncDebug) PutLocationList(list []byte, ctxt *obj.Link, listSym, startPC *obj.LSym) {
+	if ctxt.DwarfVersion >= 5 {
+		debugInfo.putLoclist(list, ctxt, listSym, startPC)
+		return
+	}
 	getPC := debugInfo.GetPC
 
 	if ctxt.UseBASEntries {
@@ -1117,6 +1122,46 @@ func (debugInfo *FuncDebug) PutLocationList(list []byte, ctxt *obj.Link, listSym
 	listSym.WriteInt(ctxt, listSym.Size, ctxt.Arch.PtrSize, 0)
 }
 
+// putLoclist is the DWARF 5 version of PutLocationList. Entries are
+// self-describing, so PC offsets and expression lengths are ULEB128
+// encoded instead of using fixed-size fields, and there is no need to
+// avoid an empty range at the start of the function.
+func (debugInfo *FuncDebug) putLoclist(list []byte, ctxt *obj.Link, listSym, startPC *obj.LSym) {
+	getPC := debugInfo.GetPC
+
+	if ctxt.UseBASEntries {
+		listSym.WriteInt(ctxt, listSym.Size, 1, dwarf.DW_LLE_base_addressx)
+		listSym.WriteDwTxtAddrx(ctxt, listSym.Size, startPC, dwarf.AddrxSize)
+	}
+
+	var buf []byte
+	for i := 0; i < len(list); {
+		begin := getPC(decodeValue(ctxt, readPtr(ctxt, list[i:])))
+		end := getPC(decodeValue(ctxt, readPtr(ctxt, list[i+ctxt.Arch.PtrSize:])))
+		i += 2 * ctxt.Arch.PtrSize
+
+		if ctxt.UseBASEntries {
+			buf = append(buf[:0], dwarf.DW_LLE_offset_pair)
+			buf = dwarf.AppendUleb128(buf, uint64(begin))
+			buf = dwarf.AppendUleb128(buf, uint64(end))
+			listSym.WriteBytes(ctxt, listSym.Size, buf)
+		} else {
+			listSym.WriteInt(ctxt, listSym.Size, 1, dwarf.DW_LLE_start_end)
+			listSym.WriteAddr(ctxt, listSym.Size, ctxt.Arch.PtrSize, startPC, int64(begin))
+			listSym.WriteAddr(ctxt, listSym.Size, ctxt.Arch.PtrSize, startPC, int64(end))
+		}
+
+		datalen := int(ctxt.Arch.ByteOrder.Uint16(list[i:]))
+		i += 2
+		buf = dwarf.AppendUleb128(buf[:0], uint64(datalen))
+		listSym.WriteBytes(ctxt, listSym.Size, buf)
+		listSym.WriteBytes(ctxt, listSym.Size, list[i:i+datalen])
+		i += datalen
+	}
+
+	listSym.WriteInt(ctxt, listSym.Size, 1, dwarf.DW_LLE_end_of_list)
+}
+
 // Pack a value and block ID into an address-sized uint, returning ~0 if they
 // don't fit.
 func encodeValue(ctxt *obj.Link, b, v ID) (uint64, bool) {
@@ -0,0 +1,170 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import "sort"
+
+// licm hoists loop-invariant values out of loops.
+//
+// A value is loop invariant if all of its arguments are defined
+// outside the loop. It computes the same result on every iteration,
+// so it can instead be computed once, in the loop's preheader: the
+// unique block outside the loop that jumps to the loop header.
+//
+// The loop body may not run at all, so only values that cannot fault
+// and have no side effects are hoisted. These include the conditions
+// of bounds and nil checks on loop-invariant indexes and pointers,
+// leaving only a well-predicted branch in the loop.
+//
+// Nil checks are themselves hoisted if they are in the loop header,
+// which runs at least once whenever the loop is entered.
+func licm(f *Func) {
+	ln := f.loopnest()
+	if ln.hasIrreducible || len(ln.loops) == 0 {
+		return
+	}
+	ln.calculateDepths()
+
+	// Visit inner loops first, so that values can be hoisted
+	// through several levels of nesting.
+	loops := make([]*loop, len(ln.loops))
+	copy(loops, ln.loops)
+	sort.SliceStable(loops, func(i, j int) bool {
+		return loops[i].depth > loops[j].depth
+	})
+
+	inLoop := func(b *Block, l *loop) bool {
+		bl := ln.b2l[b.ID]
+		return bl != nil && bl.isWithinOrEq(l)
+	}
+
+	for _, l := range loops {
+		pre, idx := preheader(l, inLoop)
+		if pre == nil {
+			continue
+		}
+		invariant := func(v *Value) bool {
+			for _, a := range v.Args {
+				if inLoop(a.Block, l) {
+					return false
+				}
+			}
+			return true
+		}
+
+		// Hoisting a value may make others invariant,
+		// so iterate until nothing changes.
+		for changed := true; changed; {
+			changed = false
+			for _, b := range f.Blocks {
+				if !inLoop(b, l) {
+					continue
+				}
+				for i := 0; i < len(b.Values); i++ {
+					v := b.Values[i]
+					switch {
+					case canHoist(v) && invariant(v):
+					case b == l.header && v.Op == OpNilCheck && hoistNilCheck(v, l, idx, inLoop):
+					default:
+						continue
+					}
+					if f.pass.debug > 0 {
+						f.Warnl(v.Pos, "hoisted %s out of loop", v.Op)
+					}
+					last := len(b.Values) - 1
+					b.Values[i] = b.Values[last]
+					b.Values[last] = nil
+					b.Values = b.Values[:last]
+					i--
+					v.Block = pre
+					pre.Values = append(pre.Values, v)
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// preheader returns the preheader of loop l, and the index of
+// the preheader among the predecessors of the loop header.
+// It returns nil if l has no preheader.
+func preheader(l *loop, inLoop func(*Block, *loop) bool) (*Block, int) {
+	var pre *Block
+	idx := -1
+	for i, e := range l.header.Preds {
+		if inLoop(e.b, l) {
+			continue
+		}
+		if pre != nil {
+			return nil, -1
+		}
+		pre, idx = e.b, i
+	}
+	if pre == nil || pre.Kind != BlockPlain {
+		return nil, -1
+	}
+	return pre, idx
+}
+
+// canHoist reports whether v may be computed earlier than it is,
+// on paths where it was not computed before.
+func canHoist(v *Value) bool {
+	if len(v.Args) == 0 || v.Op == OpPhi || v.Op == OpCopy {
+		// Constants and the like are already cheap to rematerialize.
+		return false
+	}
+	if v.MemoryArg() != nil || v.Type.IsMemory() || v.Type.IsTuple() || v.Type.IsFlags() || v.Type.IsVoid() {
+		return false
+	}
+	// A pointer computed before the bounds check that guards it
+	// might point outside its object, which the garbage collector
+	// does not allow.
+	if v.Type.IsPtrShaped() || v.Type.HasPointers() {
+		return false
+	}
+	if opcodeTable[v.Op].hasSideEffects || opcodeTable[v.Op].call {
+		return false
+	}
+	switch v.Op {
+	case OpDiv8, OpDiv8u, OpDiv16, OpDiv16u, OpDiv32, OpDiv32u, OpDiv64, OpDiv64u,
+		OpMod8, OpMod8u, OpMod16, OpMod16u, OpMod32, OpMod32u, OpMod64, OpMod64u:
+		// Integer division faults when dividing by zero.
+		return false
+	case OpSelect0, OpSelect1:
+		// Tuple selectors must stay with the tuple generator.
+		return false
+	}
+	return true
+}
+
+// hoistNilCheck reports whether nil check v, in the header of loop l,
+// can be moved to the loop preheader, and if so updates its memory
+// argument to the memory state on entry to the loop. idx is the index
+// of the preheader among the predecessors of the header.
+func hoistNilCheck(v *Value, l *loop, idx int, inLoop func(*Block, *loop) bool) bool {
+	ptr, mem := v.Args[0], v.Args[1]
+	if inLoop(ptr.Block, l) {
+		return false
+	}
+	// If the pointer is also used as an address in the header,
+	// the nil check will be folded into that memory access,
+	// which is cheaper than an explicit check before the loop.
+	for _, w := range l.header.Values {
+		if w == v || w.Op == OpPhi || w.MemoryArg() == nil {
+			continue
+		}
+		if a := w.Args[0]; a == ptr || a.Op == OpOffPtr && a.Args[0] == ptr {
+			return false
+		}
+	}
+	switch {
+	case !inLoop(mem.Block, l):
+	case mem.Op == OpPhi && mem.Block == l.header:
+		v.SetArg(1, mem.Args[idx])
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import (
+	"cmd/compile/internal/types"
+	"testing"
+)
+
+func TestLICM(t *testing.T) {
+	c := testConfig(t)
+	i64 := c.config.Types.Int64
+	ptrType := c.config.Types.BytePtr
+
+	fun := c.Fun("entry",
+		Bloc("entry",
+			Valu("mem", OpInitMem, types.TypeMem, 0, nil),
+			Valu("sb", OpSB, c.config.Types.Uintptr, 0, nil),
+			Valu("n", OpArg, i64, 0, nil),
+			Valu("k", OpArg, i64, 0, nil),
+			Valu("ptr", OpLoad, ptrType, 0, nil, "sb", "mem"),
+			Valu("zero", OpConst64, i64, 0, nil),
+			Valu("one", OpConst64, i64, 1, nil),
+			Goto("header")),
+		Bloc("header",
+			Valu("i", OpPhi, i64, 0, nil, "zero", "inc"),
+			Valu("loopmem", OpPhi, types.TypeMem, 0, nil, "mem", "store"),
+			Valu("nilcheck", OpNilCheck, types.TypeVoid, 0, nil, "ptr", "loopmem"),
+			Valu("cmp", OpLess64, c.config.Types.Bool, 0, nil, "i", "n"),
+			If("cmp", "body", "exit")),
+		Bloc("body",
+			Valu("k1", OpAdd64, i64, 0, nil, "k", "one"),
+			Valu("inbounds", OpIsInBounds, c.config.Types.Bool, 0, nil, "k1", "n"),
+			Valu("div", OpDiv64, i64, 0, nil, "n", "k"),
+			Valu("sum", OpAdd64, i64, 0, nil, "i", "div"),
+			Valu("store", OpStore, types.TypeMem, 0, i64, "ptr", "sum", "loopmem"),
+			Valu("inc", OpAdd64, i64, 0, nil, "i", "one"),
+			If("inbounds", "header", "exit")),
+		Bloc("exit",
+			Exit("loopmem")))
+
+	CheckFunc(fun.f)
+	licm(fun.f)
+	CheckFunc(fun.f)
+
+	for _, name := range []string{"k1", "inbounds", "nilcheck"} {
+		if b := fun.values[name].Block; b != fun.blocks["entry"] {
+			t.Errorf("%s in %s, want hoisted to entry", name, b)
+		}
+	}
+	if got, want := fun.values["nilcheck"].Args[1], fun.values["mem"]; got != want {
+		t.Errorf("hoisted nil check uses memory %s, want %s", got, want)
+	}
+	for _, name := range []string{"div", "sum", "store", "inc"} {
+		if b := fun.values[name].Block; b != fun.blocks["body"] {
+			t.Errorf("%s in %s, want it to stay in body", name, b)
+		}
+	}
+}
@@ -20,6 +20,7 @@ type indVar struct {
 	ind   *Value // induction variable
 	min   *Value // minimum value, inclusive/exclusive depends on flags
 	max   *Value // maximum value, inclusive/exclusive depends on flags
+	off   int64  // constant added to ind before comparing it with max
 	entry *Block // entry block in the loop.
 	flags indVarFlags
 	// Invariant: for all blocks strictly dominated by entry:
@@ -27,8 +28,16 @@ type indVar struct {
 	//	min <  ind <  max    [if flags == indVarMinExc]
 	//	min <= ind <= max    [if flags == indVarMaxInc]
 	//	min <  ind <= max    [if flags == indVarMinExc|indVarMaxInc]
+	// If off is not zero, ind+off replaces ind in the upper bound,
+	// and ind+off does not overflow.
 }
 
+// maxObjectSize is an upper bound on the size in bytes of any object,
+// and so on the length of any string and on the length and capacity
+// of any slice with non-zero-sized elements. It is far larger than the
+// address space of any supported 64-bit platform.
+const maxObjectSize = 1 << 60
+
 // parseIndVar checks whether the SSA value passed as argument is a valid induction
 // variable, and, if so, extracts:
 //   * the minimum bound
@@ -109,7 +118,18 @@ func findIndVar(f *Func) []indVar {
 
 		// See if this is really an induction variable
 		less := true
+		off := int64(0)
 		min, inc, nxt := parseIndVar(ind)
+		if min == nil {
+			// Check for a comparison of the induction variable plus
+			// a constant, as in
+			//     for i := 0; i+1 < len(n); i += 2
+			if x, k := dropAdd64(ind); k != 0 {
+				if min, inc, nxt = parseIndVar(x); min != nil {
+					ind, off = x, k
+				}
+			}
+		}
 		if min == nil {
 			// We failed to parse the induction variable. Before punting, we want to check
 			// whether the control op was written with arguments in non-idiomatic order,
@@ -148,6 +168,18 @@ func findIndVar(f *Func) []indVar {
 			continue
 		}
 
+		// An offset induction variable must start at a constant, so
+		// that ind+off does not overflow on entry, and its limit must be
+		// bounded, so that ind+off does not overflow when incremented.
+		if off != 0 {
+			if min.Op != OpConst64 || !addNoOverflow(min.AuxInt, off) || off >= maxObjectSize || off <= -maxObjectSize {
+				continue
+			}
+			if bound, ok := maxBound(max); !ok || bound > math.MaxInt64-step {
+				continue
+			}
+		}
+
 		// If the increment is negative, swap min/max and their flags
 		if step < 0 {
 			min, max = max, min
@@ -194,8 +226,14 @@ func findIndVar(f *Func) []indVar {
 		// (3) loop is of the form k0 upto Known_not_negative-k inclusive, step <= k
 		// (4) loop is of the form k0 upto Known_not_negative-k exclusive, step <= k+1
 		// (5) loop is of the form Known_not_negative downto k0, minint+step < k0
+		// (6) loop is of the form upto bounded, bound+step does not overflow
 		if step > 1 {
 			ok := false
+			if bound, has := maxBound(max); has && inc.AuxInt > 0 && bound <= math.MaxInt64-step {
+				// The loop body is only entered when ind+off <= bound,
+				// so incrementing it cannot overflow.
+				ok = true
+			}
 			if min.Op == OpConst64 && max.Op == OpConst64 {
 				if max.AuxInt > min.AuxInt && max.AuxInt%step == min.AuxInt%step { // handle overflow
 					ok = true
@@ -295,6 +333,7 @@ func findIndVar(f *Func) []indVar {
 			ind:   ind,
 			min:   min,
 			max:   max,
+			off:   off,
 			entry: b.Succs[0].b,
 			flags: flags,
 		})
@@ -304,6 +343,37 @@ func findIndVar(f *Func) []indVar {
 	return iv
 }
 
+// maxBound returns an upper bound on the value of v, if one is known.
+// It understands constants and the lengths and capacities of strings
+// and slices, possibly reduced by a non-negative constant.
+func maxBound(v *Value) (int64, bool) {
+	switch v.Op {
+	case OpConst64:
+		return v.AuxInt, true
+	case OpStringLen:
+		if v.Type.Size() == 8 {
+			return maxObjectSize, true
+		}
+	case OpSliceLen, OpSliceCap:
+		if t := v.Args[0].Type; v.Type.Size() == 8 && t.IsSlice() && t.Elem().Size() > 0 {
+			return maxObjectSize / t.Elem().Size(), true
+		}
+	case OpAdd64, OpSub64:
+		if x, k := isConstDelta(v); x != nil && k <= 0 && x.Op != OpConst64 {
+			return maxBound(x)
+		}
+	}
+	return 0, false
+}
+
+// addNoOverflow reports whether x+y does not overflow an int64.
+func addNoOverflow(x, y int64) bool {
+	if y >= 0 {
+		return x <= math.MaxInt64-y
+	}
+	return x >= math.MinInt64-y
+}
+
 func dropAdd64(v *Value) (*Value, int64) {
 	if v.Op == OpAdd64 && v.Args[0].Op == OpConst64 {
 		return v.Args[1], v.Args[0].AuxInt
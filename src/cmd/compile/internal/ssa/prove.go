@@ -177,6 +177,9 @@ type factsTable struct {
 	lens map[ID]*Value
 	caps map[ID]*Value
 
+	// For each value x, the values x+c for constants c (if any).
+	deltas map[ID][]*Value
+
 	// zero is a zero-valued constant
 	zero *Value
 }
@@ -467,6 +470,24 @@ func (ft *factsTable) update(parent *Block, v, w *Value, d domain, r relation) {
 					if (x.Type.Size() == 8 && l.min >= math.MinInt64-delta) ||
 						(x.Type.Size() == 4 && l.min >= math.MinInt32-delta) {
 						ft.update(parent, x, w, signed, r)
+
+						// Similarly, x > w+k for 0 < k <= -delta, provided
+						// that w+k does not overflow. This is useful for
+						// indexing a slice s with i+K when i < len(s[K:]).
+						if lw, has := ft.limits[w.ID]; has {
+							for _, wk := range ft.deltas[w.ID] {
+								y, k := isConstDelta(wk)
+								if y != w || k <= 0 || k > -delta || lw.max > opMax[wk.Op]-k {
+									continue
+								}
+								if k < -delta {
+									// x >= w-delta > w+k
+									ft.update(parent, x, wk, signed, gt)
+								} else {
+									ft.update(parent, x, wk, signed, r)
+								}
+							}
+						}
 					}
 				}
 			} else {
@@ -532,6 +553,23 @@ func (ft *factsTable) update(parent *Block, v, w *Value, d domain, r relation) {
 		}
 	}
 
+	// Process: v > x+delta (with delta constant and positive)
+	// If x+delta does not overflow, then v > x.
+	// This is useful for loops with bounds like i+K < len(slice).
+	if (r == gt || r == gt|eq) && d == signed {
+		if x, delta := isConstDelta(w); x != nil && delta > 0 && !v.isGenericIntConst() {
+			if l, has := ft.limits[x.ID]; has && l.max <= opMax[w.Op]-delta {
+				ft.update(parent, v, x, signed, gt)
+				// Likewise v > x+k for 0 < k < delta.
+				for _, xk := range ft.deltas[x.ID] {
+					if y, k := isConstDelta(xk); y == x && k > 0 && k < delta && xk.Op == w.Op {
+						ft.update(parent, v, xk, signed, gt)
+					}
+				}
+			}
+		}
+	}
+
 	// Look through value-preserving extensions.
 	// If the domain is appropriate for the pre-extension Type,
 	// repeat the update with the pre-extension Value.
@@ -771,6 +809,19 @@ func prove(f *Func) {
 				// (There can be some that are CSEd but not removed yet.)
 				continue
 			}
+			if x, delta := isConstDelta(v); x != nil && delta != 0 {
+				if ft.deltas == nil {
+					ft.deltas = map[ID][]*Value{}
+				}
+				ft.deltas[x.ID] = append(ft.deltas[x.ID], v)
+			}
+			switch v.Op {
+			case OpStringLen, OpSliceLen, OpSliceCap:
+				// No object is larger than the address space.
+				if bound, ok := maxBound(v); ok {
+					ft.update(b, v, f.ConstInt64(f.Config.Types.Int64, bound), signed, lt|eq)
+				}
+			}
 			switch v.Op {
 			case OpStringLen:
 				ft.update(b, v, ft.zero, signed, gt|eq)
@@ -948,16 +999,38 @@ func addIndVarRestrictions(ft *factsTable, b *Block, iv indVar) {
 		d |= unsigned
 	}
 
+	// If max is bounded, so is ind. This lets us prove
+	// that small constants can be added to ind without overflow.
+	if bound, ok := maxBound(iv.max); ok {
+		bound -= iv.off
+		if iv.flags&indVarMaxInc == 0 {
+			bound--
+		}
+		ft.update(b, iv.ind, b.Func.ConstInt64(b.Func.Config.Types.Int64, bound), signed, lt|eq)
+	}
+
 	if iv.flags&indVarMinExc == 0 {
 		addRestrictions(b, ft, d, iv.min, iv.ind, lt|eq)
 	} else {
 		addRestrictions(b, ft, d, iv.min, iv.ind, lt)
 	}
 
-	if iv.flags&indVarMaxInc == 0 {
-		addRestrictions(b, ft, d, iv.ind, iv.max, lt)
-	} else {
-		addRestrictions(b, ft, d, iv.ind, iv.max, lt|eq)
+	// If ind is offset, the relation between ind+off and max comes
+	// from the branch condition.
+	if iv.off == 0 {
+		r := lt
+		if iv.flags&indVarMaxInc != 0 {
+			r |= eq
+		}
+		addRestrictions(b, ft, d, iv.ind, iv.max, r)
+
+		// If max is the length of a slice s[k:], also relate ind to the
+		// computed length len(s)-k, so that we can prove ind+k < len(s).
+		// This is only valid where max has been computed, which is the
+		// case in the loop.
+		if l := sliceMakeLen(iv.max); l != iv.max {
+			addRestrictions(b, ft, d, iv.ind, l, r)
+		}
 	}
 }
 
@@ -1408,6 +1481,23 @@ func isConstDelta(v *Value) (w *Value, delta int64) {
 	return nil, 0
 }
 
+// sliceMakeLen returns the length (or capacity) operand of s
+// if v is len(s) (or cap(s)) for a slice s built by SliceMake,
+// as in s[k:]. Otherwise it returns v.
+func sliceMakeLen(v *Value) *Value {
+	switch v.Op {
+	case OpSliceLen:
+		if v.Args[0].Op == OpSliceMake {
+			return v.Args[0].Args[1]
+		}
+	case OpSliceCap:
+		if v.Args[0].Op == OpSliceMake {
+			return v.Args[0].Args[2]
+		}
+	}
+	return v
+}
+
 // isCleanExt reports whether v is the result of a value-preserving
 // sign or zero extension
 func isCleanExt(v *Value) bool {
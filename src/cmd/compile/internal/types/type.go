@@ -165,19 +165,22 @@ const (
 	typeNoalg                  // suppress hash and eq algorithm generation
 	typeDeferwidth             // width computation has been deferred and type is on deferredTypeStack
 	typeRecur
+	typeNoReflectMethods // methods are not kept for calls through reflection
 )
 
-func (t *Type) NotInHeap() bool  { return t.flags&typeNotInHeap != 0 }
-func (t *Type) Broke() bool      { return t.flags&typeBroke != 0 }
-func (t *Type) Noalg() bool      { return t.flags&typeNoalg != 0 }
-func (t *Type) Deferwidth() bool { return t.flags&typeDeferwidth != 0 }
-func (t *Type) Recur() bool      { return t.flags&typeRecur != 0 }
-
-func (t *Type) SetNotInHeap(b bool)  { t.flags.set(typeNotInHeap, b) }
-func (t *Type) SetBroke(b bool)      { t.flags.set(typeBroke, b) }
-func (t *Type) SetNoalg(b bool)      { t.flags.set(typeNoalg, b) }
-func (t *Type) SetDeferwidth(b bool) { t.flags.set(typeDeferwidth, b) }
-func (t *Type) SetRecur(b bool)      { t.flags.set(typeRecur, b) }
+func (t *Type) NotInHeap() bool        { return t.flags&typeNotInHeap != 0 }
+func (t *Type) Broke() bool            { return t.flags&typeBroke != 0 }
+func (t *Type) Noalg() bool            { return t.flags&typeNoalg != 0 }
+func (t *Type) Deferwidth() bool       { return t.flags&typeDeferwidth != 0 }
+func (t *Type) Recur() bool            { return t.flags&typeRecur != 0 }
+func (t *Type) NoReflectMethods() bool { return t.flags&typeNoReflectMethods != 0 }
+
+func (t *Type) SetNotInHeap(b bool)        { t.flags.set(typeNotInHeap, b) }
+func (t *Type) SetBroke(b bool)            { t.flags.set(typeBroke, b) }
+func (t *Type) SetNoalg(b bool)            { t.flags.set(typeNoalg, b) }
+func (t *Type) SetDeferwidth(b bool)       { t.flags.set(typeDeferwidth, b) }
+func (t *Type) SetRecur(b bool)            { t.flags.set(typeRecur, b) }
+func (t *Type) SetNoReflectMethods(b bool) { t.flags.set(typeNoReflectMethods, b) }
 
 // Pkg returns the package that t appeared in.
 //
@@ -0,0 +1,299 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: go tool covdata [merge|subtract|textfmt] -i=dir1,dir2,... -o=output\n")
+	fmt.Fprintf(os.Stderr, "Run 'go doc cmd/covdata' for details.\n")
+	os.Exit(2)
+}
+
+var (
+	inFlag  = flag.String("i", "", "comma-separated list of input directories")
+	outFlag = flag.String("o", "", "output directory (merge, subtract) or file (textfmt)")
+)
+
+func main() {
+	log.SetPrefix("covdata: ")
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	mode := os.Args[1]
+	flag.Usage = usage
+	flag.CommandLine.Parse(os.Args[2:])
+	if flag.NArg() != 0 || *inFlag == "" || *outFlag == "" {
+		usage()
+	}
+
+	var profiles []*profile
+	for _, dir := range strings.Split(*inFlag, ",") {
+		p, err := readDir(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profiles = append(profiles, p)
+	}
+
+	var err error
+	switch mode {
+	case "merge":
+		var m *profile
+		if m, err = merge(profiles); err == nil {
+			err = writeDir(*outFlag, m)
+		}
+	case "subtract":
+		var q, d *profile
+		if q, err = merge(profiles[1:]); err == nil {
+			if d, err = subtract(profiles[0], q); err == nil {
+				err = writeDir(*outFlag, d)
+			}
+		}
+	case "textfmt":
+		var m *profile
+		if m, err = merge(profiles); err == nil {
+			err = writeFile(*outFlag, m)
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// A profile is a set of coverage counters, keyed by block.
+type profile struct {
+	mode   string // "set", "count" or "atomic"; "" if empty
+	blocks map[block]*counter
+}
+
+// A block identifies a block of code: a file name and the
+// position of the block, in the form "line0.col0,line1.col1".
+type block struct {
+	file string
+	pos  string
+}
+
+// A counter is the data recorded for a single block.
+type counter struct {
+	numStmt int
+	count   int
+}
+
+func newProfile() *profile {
+	return &profile{blocks: make(map[block]*counter)}
+}
+
+// add adds the counter c for block b to p.
+func (p *profile) add(b block, c counter) {
+	old := p.blocks[b]
+	if old == nil {
+		p.blocks[b] = &c
+		return
+	}
+	if p.mode == "set" {
+		if c.count > 0 {
+			old.count = 1
+		}
+	} else {
+		old.count += c.count
+	}
+}
+
+// setMode sets p's mode to mode, which must match any mode
+// seen previously.
+func (p *profile) setMode(mode, where string) error {
+	if p.mode != "" && p.mode != mode {
+		return fmt.Errorf("%s: mode %s does not match mode %s of other inputs", where, mode, p.mode)
+	}
+	p.mode = mode
+	return nil
+}
+
+// readDir reads all the counter files in dir.
+func readDir(dir string) (*profile, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "covcounters.*"))
+	if err != nil {
+		return nil, err
+	}
+	p := newProfile()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		err = p.read(f, file)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// read reads counters in text profile format from r, adding them to p.
+// The name is used in error messages.
+func (p *profile) read(r io.Reader, name string) error {
+	s := bufio.NewScanner(r)
+	lineno := 0
+	for s.Scan() {
+		line := s.Text()
+		lineno++
+		if lineno == 1 {
+			mode := strings.TrimPrefix(line, "mode: ")
+			if mode == line {
+				return fmt.Errorf("%s:1: missing mode line", name)
+			}
+			if err := p.setMode(mode, name); err != nil {
+				return err
+			}
+			continue
+		}
+		b, c, ok := parseLine(line)
+		if !ok {
+			return fmt.Errorf("%s:%d: malformed counter line: %q", name, lineno, line)
+		}
+		p.add(b, c)
+	}
+	return s.Err()
+}
+
+// parseLine parses a counter line of the form
+//	file:line0.col0,line1.col1 numStmt count
+func parseLine(line string) (block, counter, bool) {
+	colon := strings.LastIndexByte(line, ':')
+	if colon < 0 {
+		return block{}, counter{}, false
+	}
+	f := strings.Fields(line[colon+1:])
+	if len(f) != 3 {
+		return block{}, counter{}, false
+	}
+	numStmt, err1 := strconv.Atoi(f[1])
+	count, err2 := strconv.Atoi(f[2])
+	if err1 != nil || err2 != nil {
+		return block{}, counter{}, false
+	}
+	return block{line[:colon], f[0]}, counter{numStmt, count}, true
+}
+
+// merge combines the counters of all the profiles.
+func merge(profiles []*profile) (*profile, error) {
+	m := newProfile()
+	for _, p := range profiles {
+		if p.mode == "" {
+			continue
+		}
+		if err := m.setMode(p.mode, "merge"); err != nil {
+			return nil, err
+		}
+		for b, c := range p.blocks {
+			m.add(b, *c)
+		}
+	}
+	return m, nil
+}
+
+// subtract returns a copy of p in which the blocks covered in q
+// are reported as not covered.
+func subtract(p, q *profile) (*profile, error) {
+	if p.mode != "" && q.mode != "" && p.mode != q.mode {
+		return nil, fmt.Errorf("subtract: mode %s does not match mode %s", q.mode, p.mode)
+	}
+	d := newProfile()
+	d.mode = p.mode
+	for b, c := range p.blocks {
+		c := *c
+		if qc := q.blocks[b]; qc != nil && qc.count > 0 {
+			c.count = 0
+		}
+		d.blocks[b] = &c
+	}
+	return d, nil
+}
+
+// write writes p to w in text profile format, sorted by file and position.
+func (p *profile) write(w io.Writer) error {
+	mode := p.mode
+	if mode == "" {
+		mode = "set"
+	}
+	keys := make([]block, 0, len(p.blocks))
+	for b := range p.blocks {
+		keys = append(keys, b)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].file != keys[j].file {
+			return keys[i].file < keys[j].file
+		}
+		return posLess(keys[i].pos, keys[j].pos)
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, b := range keys {
+		c := p.blocks[b]
+		fmt.Fprintf(bw, "%s:%s %d %d\n", b.file, b.pos, c.numStmt, c.count)
+	}
+	return bw.Flush()
+}
+
+// posLess reports whether block position x sorts before y,
+// comparing their numeric components in order.
+func posLess(x, y string) bool {
+	xs := strings.FieldsFunc(x, isPosSep)
+	ys := strings.FieldsFunc(y, isPosSep)
+	for i := 0; i < len(xs) && i < len(ys); i++ {
+		xn, _ := strconv.Atoi(xs[i])
+		yn, _ := strconv.Atoi(ys[i])
+		if xn != yn {
+			return xn < yn
+		}
+	}
+	return len(xs) < len(ys)
+}
+
+func isPosSep(r rune) bool { return r == '.' || r == ',' }
+
+// writeFile writes p to the named file in text profile format.
+func writeFile(name string, p *profile) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = p.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeDir writes p to a new counter file in dir,
+// creating dir if necessary.
+func writeDir(dir string, p *profile) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("covcounters.%d.%d", os.Getpid(), time.Now().UnixNano())
+	return writeFile(filepath.Join(dir, name), p)
+}
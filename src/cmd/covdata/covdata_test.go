@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func readString(t *testing.T, s string) *profile {
+	t.Helper()
+	p := newProfile()
+	if err := p.read(strings.NewReader(s), "test"); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func writeString(t *testing.T, p *profile) string {
+	t.Helper()
+	var b strings.Builder
+	if err := p.write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+var mergeTests = []struct {
+	name string
+	in   []string
+	out  string
+}{
+	{
+		"set",
+		[]string{
+			"mode: set\na/b.go:10.2,12.3 2 1\na/b.go:3.1,4.2 1 0\n",
+			"mode: set\na/b.go:10.2,12.3 2 1\na/b.go:3.1,4.2 1 1\nc:/x/y.go:1.1,2.2 1 0\n",
+		},
+		"mode: set\na/b.go:3.1,4.2 1 1\na/b.go:10.2,12.3 2 1\nc:/x/y.go:1.1,2.2 1 0\n",
+	},
+	{
+		"count",
+		[]string{
+			"mode: count\na/b.go:3.1,4.2 1 5\n",
+			"mode: count\na/b.go:3.1,4.2 1 7\na/b.go:3.1,3.20 1 1\n",
+		},
+		"mode: count\na/b.go:3.1,3.20 1 1\na/b.go:3.1,4.2 1 12\n",
+	},
+}
+
+func TestMerge(t *testing.T) {
+	for _, tt := range mergeTests {
+		var profiles []*profile
+		for _, in := range tt.in {
+			profiles = append(profiles, readString(t, in))
+		}
+		m, err := merge(profiles)
+		if err != nil {
+			t.Errorf("%s: merge: %v", tt.name, err)
+			continue
+		}
+		if out := writeString(t, m); out != tt.out {
+			t.Errorf("%s: merge:\nhave:\n%s\nwant:\n%s", tt.name, out, tt.out)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	p := readString(t, "mode: count\na.go:1.1,2.2 1 3\na.go:3.1,4.2 1 2\na.go:5.1,6.2 1 0\n")
+	q := readString(t, "mode: count\na.go:1.1,2.2 1 1\na.go:3.1,4.2 1 0\n")
+	want := "mode: count\na.go:1.1,2.2 1 0\na.go:3.1,4.2 1 2\na.go:5.1,6.2 1 0\n"
+	d, err := subtract(p, q)
+	if err != nil {
+		t.Fatalf("subtract: %v", err)
+	}
+	if out := writeString(t, d); out != want {
+		t.Errorf("subtract:\nhave:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestModeMismatch(t *testing.T) {
+	p := readString(t, "mode: count\na.go:1.1,2.2 1 3\n")
+	q := readString(t, "mode: set\na.go:1.1,2.2 1 1\n")
+	if _, err := merge([]*profile{p, q}); err == nil {
+		t.Errorf("merge of count and set profiles succeeded")
+	}
+	if _, err := subtract(p, q); err == nil {
+		t.Errorf("subtract of count and set profiles succeeded")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, in := range []string{
+		"a.go:1.1,2.2 1 3\n",
+		"mode: set\na.go 1 3\n",
+		"mode: set\na.go:1.1,2.2 x 3\n",
+		"mode: set\na.go:1.1,2.2 1\n",
+	} {
+		if err := newProfile().read(strings.NewReader(in), "test"); err == nil {
+			t.Errorf("read(%q): unexpected success", in)
+		}
+	}
+
+	p := readString(t, "mode: set\n")
+	if err := p.read(strings.NewReader("mode: count\n"), "test"); err == nil {
+		t.Errorf("read of mismatched modes: unexpected success")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata manipulates the coverage data written by programs built with
+'go build -cover'.
+
+Usage:
+	go tool covdata merge -i=dir1,dir2,... -o=outdir
+	go tool covdata subtract -i=dir1,dir2,... -o=outdir
+	go tool covdata textfmt -i=dir1,dir2,... -o=profile.txt
+
+Each input directory is typically the value of GOCOVERDIR used when
+running an instrumented program. All files in it whose names begin with
+covcounters. are read.
+
+The merge mode combines the coverage counters found in the input
+directories into a single file in the output directory, which can in
+turn be used as an input directory.
+
+The subtract mode writes to the output directory the counters found in
+the first input directory, except that blocks covered in any of the
+other input directories are reported as not covered. This is useful for
+finding the code that one set of tests exercises but another does not.
+
+The textfmt mode combines the coverage counters found in the input
+directories into a single profile in the format written by
+'go test -coverprofile', for use with 'go tool cover'.
+
+Counters for the same block of code are combined by adding them,
+or, in set mode, by recording whether any of them is set.
+All inputs must use the same coverage mode.
+*/
+package main
@@ -53,6 +53,7 @@ var (
 	output  = flag.String("o", "", "file for output; default: stdout")
 	htmlOut = flag.String("html", "", "generate HTML representation of coverage profile")
 	funcOut = flag.String("func", "", "output coverage profile information for each function")
+	regName = flag.String("register", "", "register counters with runtime/coverage under this file name (used by go build -cover)")
 )
 
 var profile string // The profile to read; the value of -html or -func
@@ -60,8 +61,10 @@ var profile string // The profile to read; the value of -html or -func
 var counterStmt func(*File, string) string
 
 const (
-	atomicPackagePath = "sync/atomic"
-	atomicPackageName = "_cover_atomic_"
+	atomicPackagePath   = "sync/atomic"
+	atomicPackageName   = "_cover_atomic_"
+	coveragePackagePath = "runtime/coverage"
+	coveragePackageName = "_cover_coverage_"
 )
 
 func main() {
@@ -115,6 +118,10 @@ func parseFlags() error {
 		return fmt.Errorf("too many options")
 	}
 
+	if *regName != "" && *mode == "" {
+		return fmt.Errorf("-register requires -mode")
+	}
+
 	if *varVar != "" && !token.IsIdentifier(*varVar) {
 		return fmt.Errorf("-var: %q is not a valid identifier", *varVar)
 	}
@@ -328,6 +335,12 @@ func annotate(name string) {
 		file.edit.Insert(file.offset(file.astFile.Name.End()),
 			fmt.Sprintf("; import %s %q", atomicPackageName, atomicPackagePath))
 	}
+	if *regName != "" {
+		// Likewise for runtime/coverage, which records the counters
+		// so that they can be written out when the program exits.
+		file.edit.Insert(file.offset(file.astFile.Name.End()),
+			fmt.Sprintf("; import %s %q", coveragePackageName, coveragePackagePath))
+	}
 
 	ast.Walk(file, file.astFile)
 	newContent := file.edit.Bytes()
@@ -689,6 +702,14 @@ func (f *File) addVariables(w io.Writer) {
 	if *mode == "atomic" {
 		fmt.Fprintf(w, "var _ = %s.LoadUint32\n", atomicPackageName)
 	}
+
+	// Register the counters, if requested.
+	if *regName != "" {
+		fmt.Fprintf(w, "\nfunc init() {\n")
+		fmt.Fprintf(w, "\t%s.RegisterFile(%q, %q, %s.Count[:], %s.Pos[:], %s.NumStmt[:])\n",
+			coveragePackageName, *mode, *regName, *varVar, *varVar, *varVar)
+		fmt.Fprintf(w, "}\n")
+	}
 }
 
 // It is possible for positions to repeat when there is a line
@@ -72,7 +72,7 @@
 //
 // Usage:
 //
-// 	go build [-o output] [build flags] [packages]
+// 	go build [-o output] [-json] [build flags] [packages]
 //
 // Build compiles the packages named by the import paths,
 // along with their dependencies, but it does not install the results.
@@ -101,6 +101,64 @@
 // The -i flag installs the packages that are dependencies of the target.
 // The -i flag is deprecated. Compiled packages are cached automatically.
 //
+// The -cover flag builds the executable with coverage instrumentation.
+// When the instrumented program exits, by returning from main.main or by
+// calling os.Exit, it writes its coverage counters to the directory named
+// by the GOCOVERDIR environment variable. Long-running programs can write
+// the counters at other times using package runtime/coverage.
+// Use 'go tool covdata' to merge the resulting files and to convert them
+// into a profile for 'go tool cover'. By default, the packages named on
+// the command line and their dependencies in the main module are
+// instrumented; packages in the standard library are never instrumented.
+// The related flags are:
+//
+// 	-covermode set,count,atomic
+// 		the coverage mode, as for 'go test -covermode'.
+// 		The default is "set", or "atomic" if -race is enabled.
+// 		Implies -cover.
+// 	-coverpkg pattern1,pattern2,pattern3
+// 		instrument the packages matching the patterns,
+// 		instead of the default set of packages.
+// 		Implies -cover.
+//
+// The install and run commands accept the same coverage flags.
+//
+// The -json flag prints the progress of the build and its diagnostics
+// to standard output as a stream of JSON events, one per line,
+// instead of as free-form text on standard error. The events have the
+// fields of those printed by 'go test -json' (see 'go doc test2json'),
+// with an additional Diagnostic field:
+//
+// 	type BuildEvent struct {
+// 		Time       time.Time // encodes as an RFC3339-format string
+// 		Action     string
+// 		Package    string
+// 		Elapsed    float64 // seconds
+// 		Output     string
+// 		Diagnostic *Diagnostic
+// 	}
+//
+// 	type Diagnostic struct {
+// 		File           string
+// 		Line           int
+// 		Column         int
+// 		Message        string
+// 		Analyzer       string
+// 		SuggestedFixes []SuggestedFix
+// 	}
+//
+// The Action names the step of the build that the event is about,
+// "build" for compiling a package, "link" for linking an executable,
+// and "vet" for vetting a package (see 'go help vet'), followed by
+// "-start" when the step begins, "-output" for each line printed by the
+// compiler, assembler, linker or vet tool, and "-pass" or "-fail" when
+// the step is done: for example, "build-output" or "link-fail".
+// These actions are distinct from those of 'go test -json', so that a
+// consumer of both streams cannot mistake a build for a test result.
+// When an output line is a diagnostic of the form
+// file:line[:column]: message, the Diagnostic field holds its parsed form.
+// Errors loading packages are still reported as text on standard error.
+//
 // The build flags are shared by the build, clean, get, install, list, run,
 // and test commands:
 //
@@ -1446,7 +1504,7 @@
 // The rule for a match in the cache is that the run involves the same
 // test binary and the flags on the command line come entirely from a
 // restricted set of 'cacheable' test flags, defined as -cpu, -list,
-// -parallel, -run, -short, and -v. If a run of go test has any test
+// -parallel, -run, -shard, -short, and -v. If a run of go test has any test
 // or non-test flags outside this set, the result is not cached. To
 // disable test caching, use any test flag or argument other than the
 // cacheable flags. The idiomatic way to disable test caching explicitly
@@ -1542,7 +1600,7 @@
 //
 // Usage:
 //
-// 	go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]
+// 	go vet [-n] [-x] [-jsonevents] [-vettool prog] [build flags] [vet flags] [packages]
 //
 // Vet runs the Go vet command on the packages named by the import paths.
 //
@@ -1554,6 +1612,28 @@
 // The -n flag prints commands that would be executed.
 // The -x flag prints commands as they are executed.
 //
+// The -jsonevents flag prints the progress of vet and its findings to
+// standard output as a stream of JSON events, in the format printed by
+// 'go build -json' (see 'go help build'). Each finding is reported in the
+// Diagnostic field of a "vet-output" event, together with the name of the
+// analyzer that reported it and any fixes it suggests. In -jsonevents
+// mode, findings do not cause vet to exit with a failure status.
+// Suggested fixes have the form:
+//
+// 	type SuggestedFix struct {
+// 		Message string
+// 		Edits   []struct {
+// 			Filename string
+// 			Start    int // byte offset
+// 			End      int // byte offset
+// 			New      string
+// 		}
+// 	}
+//
+// The -jsonevents flag is unrelated to the vet tool's own -json flag,
+// which go vet passes to the tool like its other flags: the tool then
+// prints its findings to standard error as a single JSON object.
+//
 // The -vettool=prog flag selects a different analysis tool with alternative
 // or additional checks.
 // For example, the 'shadow' analyzer can be built and run using these commands:
@@ -3227,6 +3307,11 @@
 //
 // 	-failfast
 // 	    Do not start new tests after the first test failure.
+// 	    When testing multiple packages, also do not start the tests
+// 	    of further packages after a package's tests fail. Packages
+// 	    that fail to build or vet do not stop the others.
+// 	    Packages that are not tested are reported as
+// 	    "[not run: -failfast]".
 //
 // 	-list regexp
 // 	    List tests, benchmarks, or examples matching the regular expression.
@@ -3252,12 +3337,27 @@
 // 	    of all tests matching X, even those without sub-tests matching Y,
 // 	    because it must run them to look for those sub-tests.
 //
+// 	-shard i/n
+// 	    Run only the top-level tests and examples in shard i of n,
+// 	    where 0 <= i < n. Tests are assigned to shards deterministically,
+// 	    so running the same tests with each of -shard=0/n through
+// 	    -shard=n-1/n runs every test exactly once. Benchmarks are not
+// 	    affected. This is useful for splitting a long-running test suite
+// 	    across several machines.
+//
 // 	-short
 // 	    Tell long-running tests to shorten their run time.
 // 	    It is off by default but set during all.bash so that installing
 // 	    the Go tree can run a sanity check but not spend time running
 // 	    exhaustive tests.
 //
+// 	-shuffle off,on,N
+// 	    Randomize the execution order of tests and benchmarks.
+// 	    It is off by default. If -shuffle is set to on, then it will seed
+// 	    the randomizer using the system clock. If -shuffle is set to an
+// 	    integer N, then N will be used as the seed value. In both cases,
+// 	    the seed will be reported for reproducibility.
+//
 // 	-timeout d
 // 	    If a test binary runs longer than duration d, panic.
 // 	    If d is 0, the timeout is disabled.
@@ -145,6 +145,8 @@ func SetExitStatus(n int) {
 }
 
 func GetExitStatus() int {
+	exitMu.Lock()
+	defer exitMu.Unlock()
 	return exitStatus
 }
 
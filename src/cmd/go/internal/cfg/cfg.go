@@ -25,13 +25,17 @@ import (
 
 // These are general "build flags" used by build and other commands.
 var (
-	BuildA                 bool   // -a flag
-	BuildBuildmode         string // -buildmode flag
+	BuildA                 bool     // -a flag
+	BuildBuildmode         string   // -buildmode flag
+	BuildCover             bool     // -cover flag (go build, go install and go run only)
+	BuildCoverMode         string   // -covermode flag
+	BuildCoverPkg          []string // -coverpkg flag
 	BuildContext           = defaultContext()
 	BuildMod               string             // -mod flag
 	BuildModExplicit       bool               // whether -mod was set explicitly
 	BuildModReason         string             // reason -mod was set, if set by default
 	BuildI                 bool               // -i flag
+	BuildJSON              bool               // go build -json and go vet -jsonevents flags
 	BuildLinkshared        bool               // -linkshared flag
 	BuildMSan              bool               // -msan flag
 	BuildN                 bool               // -n flag
@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package load
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+)
+
+// DeclareCoverVars attaches the required cover variables names
+// to the files, to be used when annotating the files.
+func DeclareCoverVars(p *Package, files ...string) map[string]*CoverVar {
+	coverVars := make(map[string]*CoverVar)
+	coverIndex := 0
+	// We create the cover counters as new top-level variables in the package.
+	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
+	// and more importantly with dot imports of other covered packages,
+	// so we append 12 hex digits from the SHA-256 of the import path.
+	// The point is only to avoid accidents, not to defeat users determined to
+	// break things.
+	sum := sha256.Sum256([]byte(p.ImportPath))
+	h := fmt.Sprintf("%x", sum[:6])
+	for _, file := range files {
+		if base.IsTestFile(file) {
+			continue
+		}
+		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
+		// we record the full path to the file name.
+		// Otherwise we record the import path, then a forward slash, then the file name.
+		// This makes profiles within GOPATH file system-independent.
+		// These names appear in the cmd/cover HTML interface.
+		var longFile string
+		if p.Internal.Local {
+			longFile = filepath.Join(p.Dir, file)
+		} else {
+			longFile = path.Join(p.ImportPath, file)
+		}
+		coverVars[file] = &CoverVar{
+			File: longFile,
+			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
+		}
+		coverIndex++
+	}
+	return coverVars
+}
+
+// PrepareForCoverageBuild marks the packages to be instrumented by
+// 'go build -cover' and related commands, which build pkgs.
+//
+// If -coverpkg is set, the packages matching its patterns are instrumented.
+// Otherwise, the packages named on the command line are instrumented,
+// together with their dependencies in the main module.
+// Packages in the standard library are never instrumented: they include
+// the packages that write the coverage data.
+//
+// Each instrumented package registers its counters with runtime/coverage,
+// which writes them out when the program exits.
+func PrepareForCoverageBuild(pkgs []*Package) {
+	var match []func(*Package) bool
+	matched := make([]bool, len(cfg.BuildCoverPkg))
+	for _, pattern := range cfg.BuildCoverPkg {
+		match = append(match, MatchPackage(pattern, base.Cwd))
+	}
+
+	var covered []*Package
+	for _, p := range PackageList(pkgs) {
+		if p.Standard || p.Name == "" || p.ImportPath == "unsafe" {
+			continue
+		}
+		want := false
+		if match != nil {
+			for i, m := range match {
+				if m(p) {
+					matched[i] = true
+					want = true
+				}
+			}
+		} else {
+			want = p.Internal.CmdlinePkg || p.Module != nil && p.Module.Main
+		}
+		if want {
+			covered = append(covered, p)
+		}
+	}
+
+	// Warn about -coverpkg arguments that are not actually used.
+	for i, pattern := range cfg.BuildCoverPkg {
+		if !matched[i] {
+			fmt.Fprintf(os.Stderr, "warning: no packages being built depend on matches for pattern %s\n", pattern)
+		}
+	}
+
+	for _, p := range covered {
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		p.Internal.CoverRegister = true
+		var coverFiles []string
+		coverFiles = append(coverFiles, p.GoFiles...)
+		coverFiles = append(coverFiles, p.CgoFiles...)
+		p.Internal.CoverVars = DeclareCoverVars(p, coverFiles...)
+
+		// The cover tool inserts imports of runtime/coverage
+		// and, in atomic mode, sync/atomic.
+		EnsureImport(p, "runtime/coverage")
+		if cfg.BuildCoverMode == "atomic" {
+			EnsureImport(p, "sync/atomic")
+		}
+	}
+}
+
+// EnsureImport adds the package pkg to p's imports, if not already present.
+func EnsureImport(p *Package, pkg string) {
+	for _, d := range p.Internal.Imports {
+		if d.ImportPath == pkg {
+			return
+		}
+	}
+
+	p1 := LoadImportWithFlags(pkg, p.Dir, p, &ImportStack{}, nil, 0)
+	if p1.Error != nil {
+		base.Fatalf("load %s: %v", pkg, p1.Error)
+	}
+
+	p.Internal.Imports = append(p.Internal.Imports, p1)
+}
@@ -27,7 +27,9 @@ var passFlagToTest = map[string]bool{
 	"outputdir":            true,
 	"parallel":             true,
 	"run":                  true,
+	"shard":                true,
 	"short":                true,
+	"shuffle":              true,
 	"timeout":              true,
 	"trace":                true,
 	"v":                    true,
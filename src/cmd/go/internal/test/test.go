@@ -7,7 +7,6 @@ package test
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"go/build"
@@ -15,7 +14,6 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -118,7 +116,7 @@ elapsed time in the summary line.
 The rule for a match in the cache is that the run involves the same
 test binary and the flags on the command line come entirely from a
 restricted set of 'cacheable' test flags, defined as -cpu, -list,
--parallel, -run, -short, and -v. If a run of go test has any test
+-parallel, -run, -shard, -short, and -v. If a run of go test has any test
 or non-test flags outside this set, the result is not cached. To
 disable test caching, use any test flag or argument other than the
 cacheable flags. The idiomatic way to disable test caching explicitly
@@ -240,6 +238,11 @@ control the execution of any test:
 
 	-failfast
 	    Do not start new tests after the first test failure.
+	    When testing multiple packages, also do not start the tests
+	    of further packages after a package's tests fail. Packages
+	    that fail to build or vet do not stop the others.
+	    Packages that are not tested are reported as
+	    "[not run: -failfast]".
 
 	-list regexp
 	    List tests, benchmarks, or examples matching the regular expression.
@@ -265,12 +268,27 @@ control the execution of any test:
 	    of all tests matching X, even those without sub-tests matching Y,
 	    because it must run them to look for those sub-tests.
 
+	-shard i/n
+	    Run only the top-level tests and examples in shard i of n,
+	    where 0 <= i < n. Tests are assigned to shards deterministically,
+	    so running the same tests with each of -shard=0/n through
+	    -shard=n-1/n runs every test exactly once. Benchmarks are not
+	    affected. This is useful for splitting a long-running test suite
+	    across several machines.
+
 	-short
 	    Tell long-running tests to shorten their run time.
 	    It is off by default but set during all.bash so that installing
 	    the Go tree can run a sanity check but not spend time running
 	    exhaustive tests.
 
+	-shuffle off,on,N
+	    Randomize the execution order of tests and benchmarks.
+	    It is off by default. If -shuffle is set to on, then it will seed
+	    the randomizer using the system clock. If -shuffle is set to an
+	    integer N, then N will be used as the seed value. In both cases,
+	    the seed will be reported for reproducibility.
+
 	-timeout d
 	    If a test binary runs longer than duration d, panic.
 	    If d is 0, the timeout is disabled.
@@ -475,6 +493,7 @@ var (
 	testCoverPaths   []string                          // -coverpkg flag
 	testCoverPkgs    []*load.Package                   // -coverpkg flag
 	testCoverProfile string                            // -coverprofile flag
+	testFailFast     bool                              // -failfast flag
 	testJSON         bool                              // -json flag
 	testList         string                            // -list flag
 	testO            string                            // -o flag
@@ -754,9 +773,9 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 			coverFiles = append(coverFiles, p.GoFiles...)
 			coverFiles = append(coverFiles, p.CgoFiles...)
 			coverFiles = append(coverFiles, p.TestGoFiles...)
-			p.Internal.CoverVars = declareCoverVars(p, coverFiles...)
+			p.Internal.CoverVars = load.DeclareCoverVars(p, coverFiles...)
 			if testCover && testCoverMode == "atomic" {
-				ensureImport(p, "sync/atomic")
+				load.EnsureImport(p, "sync/atomic")
 			}
 		}
 	}
@@ -765,7 +784,7 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 	for _, p := range pkgs {
 		// sync/atomic import is inserted by the cover tool. See #18486
 		if testCover && testCoverMode == "atomic" {
-			ensureImport(p, "sync/atomic")
+			load.EnsureImport(p, "sync/atomic")
 		}
 
 		buildTest, runTest, printTest, err := builderTest(&b, ctx, p)
@@ -812,22 +831,6 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 	b.Do(ctx, root)
 }
 
-// ensures that package p imports the named package
-func ensureImport(p *load.Package, pkg string) {
-	for _, d := range p.Internal.Imports {
-		if d.Name == pkg {
-			return
-		}
-	}
-
-	p1 := load.LoadImportWithFlags(pkg, p.Dir, p, &load.ImportStack{}, nil, 0)
-	if p1.Error != nil {
-		base.Fatalf("load %s: %v", pkg, p1.Error)
-	}
-
-	p.Internal.Imports = append(p.Internal.Imports, p1)
-}
-
 var windowsBadWords = []string{
 	"install",
 	"patch",
@@ -855,7 +858,7 @@ func builderTest(b *work.Builder, ctx context.Context, p *load.Package) (buildAc
 			Local:    testCover && testCoverPaths == nil,
 			Pkgs:     testCoverPkgs,
 			Paths:    testCoverPaths,
-			DeclVars: declareCoverVars,
+			DeclVars: load.DeclareCoverVars,
 		}
 	}
 	pmain, ptest, pxtest, err := load.TestPackagesFor(ctx, p, cover)
@@ -1021,50 +1024,6 @@ func addTestVet(b *work.Builder, p *load.Package, runAction, installAction *work
 	}
 }
 
-// isTestFile reports whether the source file is a set of tests and should therefore
-// be excluded from coverage analysis.
-func isTestFile(file string) bool {
-	// We don't cover tests, only the code they test.
-	return strings.HasSuffix(file, "_test.go")
-}
-
-// declareCoverVars attaches the required cover variables names
-// to the files, to be used when annotating the files.
-func declareCoverVars(p *load.Package, files ...string) map[string]*load.CoverVar {
-	coverVars := make(map[string]*load.CoverVar)
-	coverIndex := 0
-	// We create the cover counters as new top-level variables in the package.
-	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
-	// and more importantly with dot imports of other covered packages,
-	// so we append 12 hex digits from the SHA-256 of the import path.
-	// The point is only to avoid accidents, not to defeat users determined to
-	// break things.
-	sum := sha256.Sum256([]byte(p.ImportPath))
-	h := fmt.Sprintf("%x", sum[:6])
-	for _, file := range files {
-		if isTestFile(file) {
-			continue
-		}
-		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
-		// we record the full path to the file name.
-		// Otherwise we record the import path, then a forward slash, then the file name.
-		// This makes profiles within GOPATH file system-independent.
-		// These names appear in the cmd/cover HTML interface.
-		var longFile string
-		if p.Internal.Local {
-			longFile = filepath.Join(p.Dir, file)
-		} else {
-			longFile = path.Join(p.ImportPath, file)
-		}
-		coverVars[file] = &load.CoverVar{
-			File: longFile,
-			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
-		}
-		coverIndex++
-	}
-	return coverVars
-}
-
 var noTestsToRun = []byte("\ntesting: warning: no tests to run\n")
 
 type runCache struct {
@@ -1090,6 +1049,26 @@ func (lockedStdout) Write(b []byte) (int, error) {
 	return os.Stdout.Write(b)
 }
 
+// testFailed records whether a test binary has failed, for -failfast.
+// Build and vet failures do not count: they are reported for their own
+// package and do not stop the tests of others.
+var (
+	testFailedMu sync.Mutex
+	testFailed   bool
+)
+
+func setTestFailed() {
+	testFailedMu.Lock()
+	testFailed = true
+	testFailedMu.Unlock()
+}
+
+func getTestFailed() bool {
+	testFailedMu.Lock()
+	defer testFailedMu.Unlock()
+	return testFailed
+}
+
 // builderRunTest is the action for running a test binary.
 func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.Action) error {
 	if a.Failed {
@@ -1101,6 +1080,20 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 		return nil
 	}
 
+	if testFailFast && c.buf == nil && getTestFailed() {
+		// An earlier package failed its tests: with -failfast,
+		// don't start any more test binaries.
+		a.TestOutput = new(bytes.Buffer)
+		var stdout io.Writer = a.TestOutput
+		if testJSON {
+			json := test2json.NewConverter(a.TestOutput, a.Package.ImportPath, test2json.Timestamp)
+			defer json.Close()
+			stdout = json
+		}
+		fmt.Fprintf(stdout, "?   \t%s\t[not run: -failfast]\n", a.Package.ImportPath)
+		return nil
+	}
+
 	var stdout io.Writer = os.Stdout
 	var err error
 	if testJSON {
@@ -1266,6 +1259,7 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 		c.saveOutput(a)
 	} else {
 		base.SetExitStatus(1)
+		setTestFailed()
 		// If there was test output, assume we don't need to print the exit status.
 		// Buf there's no test output, do print the exit status.
 		if len(out) == 0 {
@@ -1330,6 +1324,7 @@ func (c *runCache) tryCacheWithID(b *work.Builder, a *work.Action, id string) bo
 			"-test.list",
 			"-test.parallel",
 			"-test.run",
+			"-test.shard",
 			"-test.short",
 			"-test.timeout",
 			"-test.v":
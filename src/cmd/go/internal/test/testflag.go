@@ -55,7 +55,7 @@ func init() {
 	cf.Var(coverFlag{stringFlag{&testCoverProfile}}, "coverprofile", "")
 	cf.String("cpu", "", "")
 	cf.StringVar(&testCPUProfile, "cpuprofile", "", "")
-	cf.Bool("failfast", false, "")
+	cf.BoolVar(&testFailFast, "failfast", false, "")
 	cf.StringVar(&testList, "list", "", "")
 	cf.StringVar(&testMemProfile, "memprofile", "", "")
 	cf.String("memprofilerate", "", "")
@@ -64,7 +64,9 @@ func init() {
 	cf.Var(outputdirFlag{&testOutputDir}, "outputdir", "")
 	cf.Int("parallel", 0, "")
 	cf.String("run", "", "")
+	cf.String("shard", "", "")
 	cf.Bool("short", false, "")
+	cf.String("shuffle", "", "")
 	cf.DurationVar(&testTimeout, "timeout", 10*time.Minute, "")
 	cf.StringVar(&testTrace, "trace", "", "")
 	cf.BoolVar(&testV, "v", false, "")
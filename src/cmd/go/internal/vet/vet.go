@@ -24,7 +24,7 @@ func init() {
 
 var CmdVet = &base.Command{
 	CustomFlags: true,
-	UsageLine:   "go vet [-n] [-x] [-vettool prog] [build flags] [vet flags] [packages]",
+	UsageLine:   "go vet [-n] [-x] [-jsonevents] [-vettool prog] [build flags] [vet flags] [packages]",
 	Short:       "report likely mistakes in packages",
 	Long: `
 Vet runs the Go vet command on the packages named by the import paths.
@@ -37,6 +37,28 @@ For details of a specific checker such as 'printf', see 'go tool vet help printf
 The -n flag prints commands that would be executed.
 The -x flag prints commands as they are executed.
 
+The -jsonevents flag prints the progress of vet and its findings to
+standard output as a stream of JSON events, in the format printed by
+'go build -json' (see 'go help build'). Each finding is reported in the
+Diagnostic field of a "vet-output" event, together with the name of the
+analyzer that reported it and any fixes it suggests. In -jsonevents
+mode, findings do not cause vet to exit with a failure status.
+Suggested fixes have the form:
+
+	type SuggestedFix struct {
+		Message string
+		Edits   []struct {
+			Filename string
+			Start    int // byte offset
+			End      int // byte offset
+			New      string
+		}
+	}
+
+The -jsonevents flag is unrelated to the vet tool's own -json flag,
+which go vet passes to the tool like its other flags: the tool then
+prints its findings to standard error as a single JSON object.
+
 The -vettool=prog flag selects a different analysis tool with alternative
 or additional checks.
 For example, the 'shadow' analyzer can be built and run using these commands:
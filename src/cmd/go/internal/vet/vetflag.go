@@ -17,6 +17,7 @@ import (
 	"strings"
 
 	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
 	"cmd/go/internal/cmdflag"
 	"cmd/go/internal/work"
 )
@@ -41,6 +42,7 @@ var vetTool string // -vettool
 func init() {
 	work.AddBuildFlags(CmdVet, work.DefaultBuildFlags)
 	CmdVet.Flag.StringVar(&vetTool, "vettool", "", "")
+	CmdVet.Flag.BoolVar(&cfg.BuildJSON, "jsonevents", false, "")
 }
 
 func parseVettoolFlag(args []string) {
@@ -105,6 +107,11 @@ func vetFlags(args []string) (passToVet, packageNames []string) {
 	isVetFlag := make(map[string]bool, len(analysisFlags))
 	cf := CmdVet.Flag
 	for _, f := range analysisFlags {
+		if f.Name == "json" {
+			// With -jsonevents, the go command asks the tool
+			// for its findings in JSON.
+			work.VetJSON = true
+		}
 		isVetFlag[f.Name] = true
 		if cf.Lookup(f.Name) == nil {
 			if f.Bool {
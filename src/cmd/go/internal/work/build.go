@@ -31,7 +31,7 @@ import (
 )
 
 var CmdBuild = &base.Command{
-	UsageLine: "go build [-o output] [build flags] [packages]",
+	UsageLine: "go build [-o output] [-json] [build flags] [packages]",
 	Short:     "compile packages and dependencies",
 	Long: `
 Build compiles the packages named by the import paths,
@@ -61,6 +61,64 @@ will be written to that directory.
 The -i flag installs the packages that are dependencies of the target.
 The -i flag is deprecated. Compiled packages are cached automatically.
 
+The -cover flag builds the executable with coverage instrumentation.
+When the instrumented program exits, by returning from main.main or by
+calling os.Exit, it writes its coverage counters to the directory named
+by the GOCOVERDIR environment variable. Long-running programs can write
+the counters at other times using package runtime/coverage.
+Use 'go tool covdata' to merge the resulting files and to convert them
+into a profile for 'go tool cover'. By default, the packages named on
+the command line and their dependencies in the main module are
+instrumented; packages in the standard library are never instrumented.
+The related flags are:
+
+	-covermode set,count,atomic
+		the coverage mode, as for 'go test -covermode'.
+		The default is "set", or "atomic" if -race is enabled.
+		Implies -cover.
+	-coverpkg pattern1,pattern2,pattern3
+		instrument the packages matching the patterns,
+		instead of the default set of packages.
+		Implies -cover.
+
+The install and run commands accept the same coverage flags.
+
+The -json flag prints the progress of the build and its diagnostics
+to standard output as a stream of JSON events, one per line,
+instead of as free-form text on standard error. The events have the
+fields of those printed by 'go test -json' (see 'go doc test2json'),
+with an additional Diagnostic field:
+
+	type BuildEvent struct {
+		Time       time.Time // encodes as an RFC3339-format string
+		Action     string
+		Package    string
+		Elapsed    float64 // seconds
+		Output     string
+		Diagnostic *Diagnostic
+	}
+
+	type Diagnostic struct {
+		File           string
+		Line           int
+		Column         int
+		Message        string
+		Analyzer       string
+		SuggestedFixes []SuggestedFix
+	}
+
+The Action names the step of the build that the event is about,
+"build" for compiling a package, "link" for linking an executable,
+and "vet" for vetting a package (see 'go help vet'), followed by
+"-start" when the step begins, "-output" for each line printed by the
+compiler, assembler, linker or vet tool, and "-pass" or "-fail" when
+the step is done: for example, "build-output" or "link-fail".
+These actions are distinct from those of 'go test -json', so that a
+consumer of both streams cannot mistake a build for a test result.
+When an output line is a diagnostic of the form
+file:line[:column]: message, the Diagnostic field holds its parsed form.
+Errors loading packages are still reported as text on standard error.
+
 The build flags are shared by the build, clean, get, install, list, run,
 and test commands:
 
@@ -197,8 +255,11 @@ func init() {
 
 	CmdBuild.Flag.BoolVar(&cfg.BuildI, "i", false, "")
 	CmdBuild.Flag.StringVar(&cfg.BuildO, "o", "", "output file or directory")
+	CmdBuild.Flag.BoolVar(&cfg.BuildJSON, "json", false, "")
+	AddCoverFlags(CmdBuild)
 
 	CmdInstall.Flag.BoolVar(&cfg.BuildI, "i", false, "")
+	AddCoverFlags(CmdInstall)
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
@@ -300,6 +361,32 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.DebugTrace, "debug-trace", "", "")
 }
 
+// AddCoverFlags adds the coverage flags of the build, install and run commands.
+// They are not build flags proper, because the test command has flags of
+// the same names with different meanings.
+func AddCoverFlags(cmd *base.Command) {
+	cmd.Flag.BoolVar(&cfg.BuildCover, "cover", false, "")
+	cmd.Flag.StringVar(&cfg.BuildCoverMode, "covermode", "", "")
+	cmd.Flag.Var((*commaListFlag)(&cfg.BuildCoverPkg), "coverpkg", "")
+}
+
+// commaListFlag is the implementation of the -coverpkg flag.
+type commaListFlag []string
+
+func (v *commaListFlag) Set(s string) error {
+	*v = nil
+	for _, f := range strings.Split(s, ",") {
+		if f != "" {
+			*v = append(*v, f)
+		}
+	}
+	return nil
+}
+
+func (v *commaListFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
 // tagsFlag is the implementation of the -tags flag.
 type tagsFlag []string
 
@@ -401,6 +488,9 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 	}
 
 	pkgs = omitTestOnly(pkgsFilter(pkgs))
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 
 	// Special case -o /dev/null by not writing at all.
 	if cfg.BuildO == os.DevNull {
@@ -651,6 +741,9 @@ func InstallPackages(ctx context.Context, patterns []string, pkgs []*load.Packag
 	}
 
 	pkgs = omitTestOnly(pkgsFilter(pkgs))
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 	for _, p := range pkgs {
 		if p.Target == "" {
 			switch {
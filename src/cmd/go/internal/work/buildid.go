@@ -462,8 +462,8 @@ func (b *Builder) useCache(a *Action, actionHash cache.ActionID, target string)
 					// If it doesn't work, it doesn't work: reusing the cached binary is more
 					// important than reprinting diagnostic information.
 					if c := cache.Default(); c != nil {
-						showStdout(b, c, a.actionID, "stdout")      // compile output
-						showStdout(b, c, a.actionID, "link-stdout") // link output
+						showStdout(b, c, a, a.actionID, "stdout")      // compile output
+						showStdout(b, c, a, a.actionID, "link-stdout") // link output
 					}
 
 					// Poison a.Target to catch uses later in the build.
@@ -490,8 +490,8 @@ func (b *Builder) useCache(a *Action, actionHash cache.ActionID, target string)
 		// If it doesn't work, it doesn't work: reusing the test result is more
 		// important than reprinting diagnostic information.
 		if c := cache.Default(); c != nil {
-			showStdout(b, c, a.Deps[0].actionID, "stdout")      // compile output
-			showStdout(b, c, a.Deps[0].actionID, "link-stdout") // link output
+			showStdout(b, c, a, a.Deps[0].actionID, "stdout")      // compile output
+			showStdout(b, c, a, a.Deps[0].actionID, "link-stdout") // link output
 		}
 
 		// Poison a.Target to catch uses later in the build.
@@ -535,7 +535,7 @@ func (b *Builder) useCache(a *Action, actionHash cache.ActionID, target string)
 		if !cfg.BuildA {
 			if file, _, err := c.GetFile(actionHash); err == nil {
 				if buildID, err := buildid.ReadFile(file); err == nil {
-					if err := showStdout(b, c, a.actionID, "stdout"); err == nil {
+					if err := showStdout(b, c, a, a.actionID, "stdout"); err == nil {
 						a.built = file
 						a.Target = "DO NOT USE - using cache"
 						a.buildID = buildID
@@ -559,7 +559,7 @@ func (b *Builder) useCache(a *Action, actionHash cache.ActionID, target string)
 	return false
 }
 
-func showStdout(b *Builder, c *cache.Cache, actionID cache.ActionID, key string) error {
+func showStdout(b *Builder, c *cache.Cache, a *Action, actionID cache.ActionID, key string) error {
 	stdout, stdoutEntry, err := c.GetBytes(cache.Subkey(actionID, key))
 	if err != nil {
 		return err
@@ -570,7 +570,13 @@ func showStdout(b *Builder, c *cache.Cache, actionID cache.ActionID, key string)
 			b.Showcmd("", "%s  # internal", joinUnambiguously(str.StringList("cat", c.OutputFile(stdoutEntry.OutputID))))
 		}
 		if !cfg.BuildN {
-			b.Print(string(stdout))
+			if cfg.BuildJSON {
+				b.output.Lock()
+				b.jsonOutput(a, string(stdout))
+				b.output.Unlock()
+			} else {
+				b.Print(string(stdout))
+			}
 		}
 	}
 	return nil
@@ -578,7 +584,13 @@ func showStdout(b *Builder, c *cache.Cache, actionID cache.ActionID, key string)
 
 // flushOutput flushes the output being queued in a.
 func (b *Builder) flushOutput(a *Action) {
-	b.Print(string(a.output))
+	if cfg.BuildJSON {
+		b.output.Lock()
+		b.jsonOutput(a, string(a.output))
+		b.output.Unlock()
+	} else {
+		b.Print(string(a.output))
+	}
 	a.output = nil
 }
 
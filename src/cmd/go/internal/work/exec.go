@@ -123,6 +123,8 @@ func (b *Builder) Do(ctx context.Context, root *Action) {
 		}
 		var err error
 		if a.Func != nil && (!a.Failed || a.IgnoreFail) {
+			b.jsonStart(a)
+			start := time.Now()
 			// TODO(matloob): Better action descriptions
 			desc := "Executing action "
 			if a.Package != nil {
@@ -135,6 +137,12 @@ func (b *Builder) Do(ctx context.Context, root *Action) {
 			}
 			err = a.Func(b, ctx, a)
 			span.Done()
+			if cfg.BuildJSON && err != nil && err != errPrintedOutput {
+				b.output.Lock()
+				b.jsonOutput(a, err.Error()+"\n")
+				b.output.Unlock()
+			}
+			b.jsonDone(a, start, err != nil)
 		}
 		if a.json != nil {
 			a.json.TimeDone = time.Now()
@@ -148,6 +156,9 @@ func (b *Builder) Do(ctx context.Context, root *Action) {
 		if err != nil {
 			if err == errPrintedOutput {
 				base.SetExitStatus(2)
+			} else if cfg.BuildJSON {
+				// Already printed as a JSON event.
+				base.SetExitStatus(1)
 			} else {
 				base.Errorf("%s", err)
 			}
@@ -259,6 +270,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	}
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
+		if p.Internal.CoverRegister {
+			fmt.Fprintf(h, "coverregister\n")
+		}
 	}
 	fmt.Fprintf(h, "modinfo %q\n", p.Internal.BuildInfo)
 
@@ -601,7 +615,7 @@ OverlayLoop:
 				// Not covering this file.
 				continue
 			}
-			if err := b.cover(a, coverFile, sourceFile, cover.Var); err != nil {
+			if err := b.cover(a, coverFile, sourceFile, cover); err != nil {
 				return err
 			}
 			if i < len(gofiles) {
@@ -1064,6 +1078,11 @@ var VetFlags []string
 // VetExplicit records whether the vet flags were set explicitly on the command line.
 var VetExplicit bool
 
+// VetJSON records whether the vet tool accepts the -json flag.
+// If so, in -json mode the go command asks the tool for its findings
+// in JSON rather than parsing its text output.
+var VetJSON bool
+
 func (b *Builder) vet(ctx context.Context, a *Action) error {
 	// a.Deps[0] is the build of the package being vetted.
 	// a.Deps[1] is the build of the "fmt" package.
@@ -1174,7 +1193,14 @@ func (b *Builder) vet(ctx context.Context, a *Action) error {
 	if tool == "" {
 		tool = base.Tool("vet")
 	}
-	runErr := b.run(a, p.Dir, p.ImportPath, env, cfg.BuildToolexec, tool, vetFlags, a.Objdir+"vet.cfg")
+	var runErr error
+	if cfg.BuildJSON && VetJSON && !a.VetxOnly {
+		// Ask vet for its findings in JSON, so that they can be
+		// reported with their analyzer names and suggested fixes.
+		runErr = b.vetJSON(a, env, tool, vetFlags)
+	} else {
+		runErr = b.run(a, p.Dir, p.ImportPath, env, cfg.BuildToolexec, tool, vetFlags, a.Objdir+"vet.cfg")
+	}
 
 	// If vet wrote export data, save it for input to future vets.
 	if f, err := os.Open(vcfg.VetxOutput); err == nil {
@@ -1186,6 +1212,27 @@ func (b *Builder) vet(ctx context.Context, a *Action) error {
 	return runErr
 }
 
+// vetJSON runs the vet tool for action a with the -json flag
+// and prints its findings as JSON events.
+func (b *Builder) vetJSON(a *Action, env []string, tool string, vetFlags []string) error {
+	p := a.Package
+	out, err := b.runOut(a, p.Dir, env, cfg.BuildToolexec, tool, vetFlags, "-json", a.Objdir+"vet.cfg")
+	if len(out) == 0 {
+		return err
+	}
+	if jsonErr := b.vetJSONOutput(a, out); jsonErr != nil {
+		// Not JSON: perhaps the tool crashed. Show its output as is.
+		b.showOutput(a, p.Dir, p.ImportPath, b.processOutput(out))
+		return errPrintedOutput
+	}
+	// Like the vet tool, succeed in -json mode even if there are findings:
+	// they are reported in the output for the caller to act on.
+	if err != nil {
+		return errPrintedOutput
+	}
+	return nil
+}
+
 // linkActionID computes the action ID for a link action.
 func (b *Builder) linkActionID(a *Action) cache.ActionID {
 	p := a.Package
@@ -1821,12 +1868,19 @@ func (b *Builder) installHeader(ctx context.Context, a *Action) error {
 
 // cover runs, in effect,
 //	go tool cover -mode=b.coverMode -var="varName" -o dst.go src.go
-func (b *Builder) cover(a *Action, dst, src string, varName string) error {
+// adding -register="file" if the package's counters are registered
+// with runtime/coverage (go build -cover).
+func (b *Builder) cover(a *Action, dst, src string, cv *load.CoverVar) error {
+	var register []string
+	if a.Package.Internal.CoverRegister {
+		register = []string{"-register", cv.File}
+	}
 	return b.run(a, a.Objdir, "cover "+a.Package.ImportPath, nil,
 		cfg.BuildToolexec,
 		base.Tool("cover"),
 		"-mode", a.Package.Internal.CoverMode,
-		"-var", varName,
+		"-var", cv.Var,
+		register,
 		"-o", dst,
 		src)
 }
@@ -1943,6 +1997,8 @@ func (b *Builder) Showcmd(dir string, format string, args ...interface{}) {
 // If a is not nil and a.output is not nil, showOutput appends to that slice instead of
 // printing to b.Print.
 //
+// In -json mode, showOutput prints the output as a sequence of JSON events.
+//
 func (b *Builder) showOutput(a *Action, dir, desc, out string) {
 	prefix := "# " + desc
 	suffix := "\n" + out
@@ -1960,6 +2016,10 @@ func (b *Builder) showOutput(a *Action, dir, desc, out string) {
 
 	b.output.Lock()
 	defer b.output.Unlock()
+	if cfg.BuildJSON {
+		b.jsonOutput(a, prefix+suffix)
+		return
+	}
 	b.Print(prefix, suffix)
 }
 
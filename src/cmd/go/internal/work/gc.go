@@ -90,7 +90,7 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg, embedcfg
 		switch p.ImportPath {
 		case "bytes", "internal/poll", "net", "os":
 			fallthrough
-		case "runtime/metrics", "runtime/pprof", "runtime/trace":
+		case "runtime/coverage", "runtime/metrics", "runtime/pprof", "runtime/trace":
 			fallthrough
 		case "sync", "syscall", "time":
 			extFiles++
@@ -25,6 +25,7 @@ func BuildInit() {
 	modload.Init()
 	instrumentInit()
 	buildModeInit()
+	coverInit()
 	if err := fsys.Init(base.Cwd); err != nil {
 		base.Fatalf("go: %v", err)
 	}
@@ -284,3 +285,31 @@ func buildModeInit() {
 		}
 	}
 }
+
+// coverInit validates the -cover, -covermode and -coverpkg flags
+// of the build, install and run commands.
+func coverInit() {
+	if cfg.BuildCoverMode != "" || len(cfg.BuildCoverPkg) > 0 {
+		cfg.BuildCover = true
+	}
+	if !cfg.BuildCover {
+		return
+	}
+	switch cfg.BuildCoverMode {
+	case "":
+		cfg.BuildCoverMode = "set"
+		if cfg.BuildRace {
+			// Default coverage mode is atomic when -race is set.
+			cfg.BuildCoverMode = "atomic"
+		}
+	case "set", "count", "atomic":
+	default:
+		base.Fatalf(`go %s: -covermode: valid modes are "set", "count", or "atomic"`, flag.Args()[0])
+	}
+	if cfg.BuildRace && cfg.BuildCoverMode != "atomic" {
+		base.Fatalf(`go %s: -covermode must be "atomic", not %q, when -race is enabled`, flag.Args()[0], cfg.BuildCoverMode)
+	}
+	if cfg.BuildToolchainName == "gccgo" {
+		base.Fatalf("go %s: -cover is not supported by gccgo", flag.Args()[0])
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// JSON output for go build -json and go vet -jsonevents.
+
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"internal/lazyregexp"
+)
+
+// A buildEvent is a single event printed by go build -json or
+// go vet -jsonevents. Its fields are a superset of those of the events
+// printed by cmd/internal/test2json, so that a single consumer can
+// handle the output of go build, go vet and go test. Its actions are
+// prefixed by the step of the build, as in "build-output", and so are
+// distinct from those of test2json.
+type buildEvent struct {
+	Time       *time.Time       `json:",omitempty"`
+	Action     string           // step-"start", "output", "pass" or "fail"
+	Package    string           `json:",omitempty"`
+	Elapsed    *float64         `json:",omitempty"`
+	Output     string           `json:",omitempty"`
+	Diagnostic *buildDiagnostic `json:",omitempty"`
+}
+
+// A buildDiagnostic is a diagnostic reported by the compiler,
+// assembler, linker or vet tool, in structured form.
+type buildDiagnostic struct {
+	File           string
+	Line           int
+	Column         int `json:",omitempty"`
+	Message        string
+	Analyzer       string            `json:",omitempty"` // vet only
+	SuggestedFixes []vetSuggestedFix `json:",omitempty"` // vet only
+}
+
+// A vetSuggestedFix is a fix suggested by a vet analyzer.
+// The field names match those of the vet tool's JSON output
+// (encoding/json matches them case-insensitively).
+type vetSuggestedFix struct {
+	Message string
+	Edits   []vetTextEdit
+}
+
+// A vetTextEdit is a single edit of a vetSuggestedFix.
+// Start and End are byte offsets into Filename.
+type vetTextEdit struct {
+	Filename string
+	Start    int
+	End      int
+	New      string
+}
+
+// jsonMode reports whether a's progress and output is reported
+// in -json mode, returning the step that prefixes the actions
+// of its events.
+func jsonMode(a *Action) (string, bool) {
+	if !cfg.BuildJSON || a == nil || a.Package == nil || a.Func == nil {
+		return "", false
+	}
+	switch a.Mode {
+	case "build", "link":
+		return a.Mode, true
+	case "vet":
+		return a.Mode, !a.VetxOnly
+	}
+	return "", false
+}
+
+// printEvent prints ev as a single line of JSON to standard output.
+// The caller must hold b.output.
+func (b *Builder) printEvent(ev *buildEvent) {
+	if ev.Time == nil {
+		now := time.Now()
+		ev.Time = &now
+	}
+	js, err := json.Marshal(ev)
+	if err != nil {
+		base.Fatalf("go: internal error: marshaling build event: %v", err)
+	}
+	js = append(js, '\n')
+	os.Stdout.Write(js)
+}
+
+// jsonStart prints the "start" event for action a, if any.
+func (b *Builder) jsonStart(a *Action) {
+	mode, ok := jsonMode(a)
+	if !ok {
+		return
+	}
+	b.output.Lock()
+	defer b.output.Unlock()
+	b.printEvent(&buildEvent{Action: mode + "-start", Package: a.Package.ImportPath})
+}
+
+// jsonDone prints the "pass" or "fail" event for action a, if any.
+func (b *Builder) jsonDone(a *Action, start time.Time, failed bool) {
+	mode, ok := jsonMode(a)
+	if !ok {
+		return
+	}
+	action := mode + "-pass"
+	if failed {
+		action = mode + "-fail"
+	}
+	elapsed := time.Since(start).Round(time.Millisecond).Seconds()
+	b.output.Lock()
+	defer b.output.Unlock()
+	b.printEvent(&buildEvent{Action: action, Package: a.Package.ImportPath, Elapsed: &elapsed})
+}
+
+// diagLine matches a diagnostic of the form file:line[:column]: message.
+// The optional drive letter allows for Windows paths.
+var diagLine = lazyregexp.New(`^((?:[A-Za-z]:)?[^:\s][^:]*):([0-9]+)(?::([0-9]+))?: (.*)$`)
+
+// jsonOutput prints out, the text output of action a, as a sequence
+// of "output" events, one per line. Lines that look like diagnostics
+// are also reported in structured form.
+// The caller must hold b.output.
+func (b *Builder) jsonOutput(a *Action, out string) {
+	mode, _ := jsonMode(a)
+	if mode == "" {
+		// Output not attributed to a step is reported as build output.
+		mode = "build"
+	}
+	pkg := ""
+	if a != nil && a.Package != nil {
+		pkg = a.Package.ImportPath
+	}
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if line == "" {
+			continue
+		}
+		ev := &buildEvent{Action: mode + "-output", Package: pkg, Output: line}
+		if m := diagLine.FindStringSubmatch(strings.TrimSuffix(line, "\n")); m != nil {
+			d := &buildDiagnostic{File: m[1], Message: m[4]}
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+			ev.Diagnostic = d
+		}
+		b.printEvent(ev)
+	}
+}
+
+// vetJSONOutput prints the JSON output of a vet tool run with -json,
+// converting each finding into a "vet-output" event.
+// If out is not in the format printed by the vet tool,
+// vetJSONOutput returns a non-nil error and prints nothing.
+//
+// The vet tool's JSON output maps package ID to analyzer name to either
+// a list of diagnostics or an error.
+func (b *Builder) vetJSONOutput(a *Action, out []byte) error {
+	var tree map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(out, &tree); err != nil {
+		return err
+	}
+	type vetDiagnostic struct {
+		Posn           string            `json:"posn"`
+		Message        string            `json:"message"`
+		SuggestedFixes []vetSuggestedFix `json:"suggested_fixes"`
+	}
+	type vetError struct {
+		Err string `json:"error"`
+	}
+
+	var ids []string
+	for id := range tree {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var events []*buildEvent
+	for _, id := range ids {
+		var names []string
+		for name := range tree[id] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			raw := tree[id][name]
+			var diags []vetDiagnostic
+			if err := json.Unmarshal(raw, &diags); err != nil {
+				var verr vetError
+				if err := json.Unmarshal(raw, &verr); err != nil {
+					return err
+				}
+				events = append(events, &buildEvent{Output: fmt.Sprintf("%s: %s\n", name, verr.Err)})
+				continue
+			}
+			for _, diag := range diags {
+				posn := diag.Posn
+				d := &buildDiagnostic{File: posn, Message: diag.Message, Analyzer: name, SuggestedFixes: diag.SuggestedFixes}
+				if m := diagLine.FindStringSubmatch(posn + ": "); m != nil {
+					d.File = base.ShortPath(m[1])
+					d.Line, _ = strconv.Atoi(m[2])
+					d.Column, _ = strconv.Atoi(m[3])
+					posn = d.File + posn[len(m[1]):]
+				}
+				events = append(events, &buildEvent{Output: posn + ": " + diag.Message + "\n", Diagnostic: d})
+			}
+		}
+	}
+
+	b.output.Lock()
+	defer b.output.Unlock()
+	for _, ev := range events {
+		ev.Action = "vet-output"
+		ev.Package = a.Package.ImportPath
+		b.printEvent(ev)
+	}
+	return nil
+}
@@ -95,6 +95,7 @@ type FnState struct {
 	Scopes        []Scope
 	InlCalls      InlCalls
 	UseBASEntries bool
+	DwarfVersion  int
 }
 
 func EnableLogging(doit bool) {
@@ -192,6 +193,7 @@ type Context interface {
 	AddInt(s Sym, size int, i int64)
 	AddBytes(s Sym, b []byte)
 	AddAddress(s Sym, t interface{}, ofs int64)
+	AddIndexedAddress(s Sym, t interface{})
 	AddCURelativeAddress(s Sym, t interface{}, ofs int64)
 	AddSectionOffset(s Sym, size int, t interface{}, ofs int64)
 	AddDWARFAddrSectionOffset(s Sym, t interface{}, ofs int64)
@@ -373,20 +375,32 @@ var abbrevsFinalized bool
 
 // expandPseudoForm takes an input DW_FORM_xxx value and translates it
 // into a platform-appropriate concrete form. Existing concrete/real
-// DW_FORM values are left untouched. For the moment the only
-// pseudo-form is DW_FORM_udata_pseudo, which gets expanded to
-// DW_FORM_data4 on Darwin and DW_FORM_udata everywhere else. See
-// issue #31459 for more context.
+// DW_FORM values are left untouched. DW_FORM_udata_pseudo gets
+// expanded to DW_FORM_data4 on Darwin and DW_FORM_udata everywhere
+// else (see issue #31459 for more context). DW_FORM_addr_pseudo gets
+// expanded to DW_FORM_addrx where DWARF 5 is generated, and to
+// DW_FORM_addr everywhere else.
 func expandPseudoForm(form uint8) uint8 {
-	// Is this a pseudo-form?
-	if form != DW_FORM_udata_pseudo {
-		return form
-	}
-	expandedForm := DW_FORM_udata
-	if objabi.GOOS == "darwin" || objabi.GOOS == "ios" {
-		expandedForm = DW_FORM_data4
+	switch form {
+	case DW_FORM_udata_pseudo:
+		if objabi.GOOS == "darwin" || objabi.GOOS == "ios" {
+			return DW_FORM_data4
+		}
+		return DW_FORM_udata
+	case DW_FORM_addr_pseudo:
+		if targetVersion() >= 5 {
+			return DW_FORM_addrx
+		}
+		return DW_FORM_addr
 	}
-	return uint8(expandedForm)
+	return form
+}
+
+// targetVersion returns the DWARF version generated for objabi.GOOS.
+func targetVersion() int {
+	var h objabi.HeadType
+	h.Set(objabi.GOOS)
+	return Version(h)
 }
 
 // Abbrevs() returns the finalized abbrev array for the platform,
@@ -400,6 +414,12 @@ func Abbrevs() []dwAbbrev {
 			abbrevs[i].attr[j].form = expandPseudoForm(abbrevs[i].attr[j].form)
 		}
 	}
+	if targetVersion() >= 5 {
+		// The DW_FORM_addrx indexes in a compilation unit are
+		// relative to its contribution to .debug_addr.
+		cu := &abbrevs[DW_ABRV_COMPUNIT]
+		cu.attr = append(cu.attr, dwAttrForm{DW_AT_addr_base, DW_FORM_sec_offset})
+	}
 	abbrevsFinalized = true
 	return abbrevs[:]
 }
@@ -420,7 +440,7 @@ var abbrevs = [DW_NABRV]dwAbbrev{
 			{DW_AT_name, DW_FORM_string},
 			{DW_AT_language, DW_FORM_data1},
 			{DW_AT_stmt_list, DW_FORM_sec_offset},
-			{DW_AT_low_pc, DW_FORM_addr},
+			{DW_AT_low_pc, DW_FORM_addr_pseudo}, // pseudo-form
 			{DW_AT_ranges, DW_FORM_sec_offset},
 			{DW_AT_comp_dir, DW_FORM_string},
 			{DW_AT_producer, DW_FORM_string},
@@ -447,7 +467,7 @@ var abbrevs = [DW_NABRV]dwAbbrev{
 		DW_CHILDREN_yes,
 		[]dwAttrForm{
 			{DW_AT_name, DW_FORM_string},
-			{DW_AT_low_pc, DW_FORM_addr},
+			{DW_AT_low_pc, DW_FORM_addr_pseudo}, // pseudo-form
 			{DW_AT_high_pc, DW_FORM_addr},
 			{DW_AT_frame_base, DW_FORM_block1},
 			{DW_AT_decl_file, DW_FORM_data4},
@@ -472,7 +492,7 @@ var abbrevs = [DW_NABRV]dwAbbrev{
 		DW_CHILDREN_yes,
 		[]dwAttrForm{
 			{DW_AT_abstract_origin, DW_FORM_ref_addr},
-			{DW_AT_low_pc, DW_FORM_addr},
+			{DW_AT_low_pc, DW_FORM_addr_pseudo}, // pseudo-form
 			{DW_AT_high_pc, DW_FORM_addr},
 			{DW_AT_frame_base, DW_FORM_block1},
 		},
@@ -915,6 +935,12 @@ func putattr(ctxt Context, s Sym, abbrev int, form int, cls int, value int64, da
 		}
 		ctxt.AddAddress(s, data, value)
 
+	case DW_FORM_addrx: // address
+		if value != 0 {
+			return fmt.Errorf("DW_FORM_addrx with non-zero offset %d", value)
+		}
+		ctxt.AddIndexedAddress(s, data)
+
 	case DW_FORM_block1: // block
 		if cls == DW_CLS_ADDRESS {
 			ctxt.AddInt(s, 1, int64(1+ctxt.PtrSize()))
@@ -1043,6 +1069,23 @@ func PutIntConst(ctxt Context, info, typ Sym, name string, val int64) {
 	putattr(ctxt, info, DW_ABRV_INT_CONSTANT, DW_FORM_sdata, DW_CLS_CONSTANT, val, nil)
 }
 
+// Version returns the DWARF version to generate for the given
+// executable header type. DWARF 5 is only used for ELF targets; the
+// Mach-O, PE and XCOFF toolchains (dsymutil, lldb, the AIX linker)
+// still expect the DWARF 4 .debug_loc and .debug_ranges sections.
+func Version(headtype objabi.HeadType) int {
+	switch headtype {
+	case objabi.Hdragonfly, objabi.Hfreebsd, objabi.Hlinux, objabi.Hnetbsd, objabi.Hopenbsd, objabi.Hsolaris:
+		return 5
+	}
+	return 4
+}
+
+// AddrxSize is the size in bytes of the .debug_addr indexes that the
+// compiler writes for DWARF 5. The linker fills them in as unsigned
+// LEB128 numbers padded to this size.
+const AddrxSize = 4
+
 // PutBasedRanges writes a range table to sym. All addresses in ranges are
 // relative to some base address, which must be arranged by the caller
 // (e.g., with a DW_AT_low_pc attribute, or in a BASE-prefixed range).
@@ -1058,12 +1101,40 @@ func PutBasedRanges(ctxt Context, sym Sym, ranges []Range) {
 	ctxt.AddInt(sym, ps, 0)
 }
 
+// PutBasedRnglist writes a DWARF 5 range list to sym. As with
+// PutBasedRanges, all addresses in ranges are relative to a base
+// address arranged by the caller.
+func PutBasedRnglist(ctxt Context, sym Sym, ranges []Range) {
+	for _, r := range ranges {
+		ctxt.AddInt(sym, 1, DW_RLE_offset_pair)
+		Uleb128put(ctxt, sym, r.Start)
+		Uleb128put(ctxt, sym, r.End)
+	}
+	ctxt.AddInt(sym, 1, DW_RLE_end_of_list)
+}
+
 // PutRanges writes a range table to s.Ranges.
 // All addresses in ranges are relative to s.base.
 func (s *FnState) PutRanges(ctxt Context, ranges []Range) {
 	ps := ctxt.PtrSize()
 	sym, base := s.Ranges, s.StartPC
 
+	if s.DwarfVersion >= 5 {
+		if s.UseBASEntries {
+			ctxt.AddInt(sym, 1, DW_RLE_base_addressx)
+			ctxt.AddIndexedAddress(sym, base)
+			PutBasedRnglist(ctxt, sym, ranges)
+			return
+		}
+		for _, r := range ranges {
+			ctxt.AddInt(sym, 1, DW_RLE_start_end)
+			ctxt.AddAddress(sym, base, r.Start)
+			ctxt.AddAddress(sym, base, r.End)
+		}
+		ctxt.AddInt(sym, 1, DW_RLE_end_of_list)
+		return
+	}
+
 	if s.UseBASEntries {
 		// Using a Base Address Selection Entry reduces the number of relocations, but
 		// this is not done on macOS because it is not supported by dsymutil/dwarfdump/lldb
@@ -1332,7 +1403,7 @@ func PutConcreteFunc(ctxt Context, s *FnState) error {
 	putattr(ctxt, s.Info, abbrev, DW_FORM_ref_addr, DW_CLS_REFERENCE, 0, s.Absfn)
 
 	// Start/end PC.
-	putattr(ctxt, s.Info, abbrev, DW_FORM_addr, DW_CLS_ADDRESS, 0, s.StartPC)
+	putattr(ctxt, s.Info, abbrev, int(expandPseudoForm(DW_FORM_addr_pseudo)), DW_CLS_ADDRESS, 0, s.StartPC)
 	putattr(ctxt, s.Info, abbrev, DW_FORM_addr, DW_CLS_ADDRESS, s.Size, s.StartPC)
 
 	// cfa / frame base
@@ -1375,7 +1446,7 @@ func PutDefaultFunc(ctxt Context, s *FnState) error {
 	}
 
 	putattr(ctxt, s.Info, DW_ABRV_FUNCTION, DW_FORM_string, DW_CLS_STRING, int64(len(name)), name)
-	putattr(ctxt, s.Info, abbrev, DW_FORM_addr, DW_CLS_ADDRESS, 0, s.StartPC)
+	putattr(ctxt, s.Info, abbrev, int(expandPseudoForm(DW_FORM_addr_pseudo)), DW_CLS_ADDRESS, 0, s.StartPC)
 	putattr(ctxt, s.Info, abbrev, DW_FORM_addr, DW_CLS_ADDRESS, s.Size, s.StartPC)
 	putattr(ctxt, s.Info, abbrev, DW_FORM_block1, DW_CLS_BLOCK, 1, []byte{DW_OP_call_frame_cfa})
 	ctxt.AddFileRef(s.Info, s.Filesym)
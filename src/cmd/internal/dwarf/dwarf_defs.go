@@ -187,6 +187,8 @@ const (
 	DW_AT_elemental      = 0x66 // flag
 	DW_AT_pure           = 0x67 // flag
 	DW_AT_recursive      = 0x68 // flag
+	// Dwarf5
+	DW_AT_addr_base = 0x73 // addrptr
 
 	DW_AT_lo_user = 0x2000 // ---
 	DW_AT_hi_user = 0x3fff // ---
@@ -220,8 +222,13 @@ const (
 	DW_FORM_exprloc      = 0x18 // exprloc
 	DW_FORM_flag_present = 0x19 // flag
 	DW_FORM_ref_sig8     = 0x20 // reference
+	// Dwarf5
+	DW_FORM_addrx     = 0x1b // address
+	DW_FORM_line_strp = 0x1f // string
 	// Pseudo-form: expanded to data4 on IOS, udata elsewhere.
 	DW_FORM_udata_pseudo = 0x99
+	// Pseudo-form: expanded to addrx for DWARF 5, addr otherwise.
+	DW_FORM_addr_pseudo = 0x9a
 )
 
 // Table 24 (#operands, notes)
@@ -446,6 +453,45 @@ const (
 	DW_LNE_hi_user      = 0xff
 )
 
+// Dwarf5 Table 7.2: unit header unit type encodings
+const (
+	DW_UT_compile = 0x01
+)
+
+// Dwarf5 Table 7.27: line number header entry format encodings
+const (
+	DW_LNCT_path            = 0x1
+	DW_LNCT_directory_index = 0x2
+	DW_LNCT_timestamp       = 0x3
+	DW_LNCT_size            = 0x4
+	DW_LNCT_MD5             = 0x5
+)
+
+// Dwarf5 Table 7.10: location list entry encoding values
+const (
+	DW_LLE_end_of_list      = 0x00
+	DW_LLE_base_addressx    = 0x01
+	DW_LLE_startx_endx      = 0x02
+	DW_LLE_startx_length    = 0x03
+	DW_LLE_offset_pair      = 0x04
+	DW_LLE_default_location = 0x05
+	DW_LLE_base_address     = 0x06
+	DW_LLE_start_end        = 0x07
+	DW_LLE_start_length     = 0x08
+)
+
+// Dwarf5 Table 7.30: range list entry encoding values
+const (
+	DW_RLE_end_of_list   = 0x00
+	DW_RLE_base_addressx = 0x01
+	DW_RLE_startx_endx   = 0x02
+	DW_RLE_startx_length = 0x03
+	DW_RLE_offset_pair   = 0x04
+	DW_RLE_base_address  = 0x05
+	DW_RLE_start_end     = 0x06
+	DW_RLE_start_length  = 0x07
+)
+
 // Table 39
 const (
 	DW_MACINFO_define     = 0x01
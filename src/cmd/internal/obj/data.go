@@ -143,6 +143,14 @@ func (s *LSym) WriteCURelativeAddr(ctxt *Link, off int64, rsym *LSym, roff int64
 	s.writeAddr(ctxt, off, ctxt.Arch.PtrSize, rsym, roff, objabi.R_ADDRCUOFF)
 }
 
+// WriteDwTxtAddrx writes the index of rsym in the .debug_addr table
+// of its DWARF compilation unit into s at offset off, as an unsigned
+// LEB128 number padded to siz bytes. The index is only known to the
+// linker, which fills it in.
+func (s *LSym) WriteDwTxtAddrx(ctxt *Link, off int64, rsym *LSym, siz int) {
+	s.writeAddr(ctxt, off, siz, rsym, 0, objabi.R_DWTXTADDR)
+}
+
 // WriteOff writes a 4 byte offset to rsym+roff into s at offset off.
 // After linking the 4 bytes stored at s+off will be
 // rsym+roff-(start of section that s is in).
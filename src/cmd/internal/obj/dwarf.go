@@ -237,6 +237,11 @@ func (c dwCtxt) AddAddress(s dwarf.Sym, data interface{}, value int64) {
 		ls.WriteInt(c.Link, ls.Size, size, value)
 	}
 }
+func (c dwCtxt) AddIndexedAddress(s dwarf.Sym, data interface{}) {
+	ls := s.(*LSym)
+	rsym := data.(*LSym)
+	ls.WriteDwTxtAddrx(c.Link, ls.Size, rsym, dwarf.AddrxSize)
+}
 func (c dwCtxt) AddCURelativeAddress(s dwarf.Sym, data interface{}, value int64) {
 	ls := s.(*LSym)
 	rsym := data.(*LSym)
@@ -372,6 +377,7 @@ func (ctxt *Link) populateDWARF(curfn interface{}, s *LSym, myimportpath string)
 		Scopes:        scopes,
 		InlCalls:      inlcalls,
 		UseBASEntries: ctxt.UseBASEntries,
+		DwarfVersion:  ctxt.DwarfVersion,
 	}
 	if absfunc != nil {
 		err = dwarf.PutAbstractFunc(dwctxt, fnstate)
@@ -422,6 +428,7 @@ func (ctxt *Link) DwarfAbstractFunc(curfn interface{}, s *LSym, myimportpath str
 		External:      !s.Static(),
 		Scopes:        scopes,
 		UseBASEntries: ctxt.UseBASEntries,
+		DwarfVersion:  ctxt.DwarfVersion,
 	}
 	if err := dwarf.PutAbstractFunc(dwctxt, &fnstate); err != nil {
 		ctxt.Diag("emitting DWARF for %s failed: %v", s.Name, err)
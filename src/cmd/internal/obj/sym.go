@@ -32,6 +32,7 @@
 package obj
 
 import (
+	"cmd/internal/dwarf"
 	"cmd/internal/goobj"
 	"cmd/internal/objabi"
 	"fmt"
@@ -52,6 +53,7 @@ func Linknew(arch *LinkArch) *Link {
 	if err := ctxt.Headtype.Set(objabi.GOOS); err != nil {
 		log.Fatalf("unknown goos %s", objabi.GOOS)
 	}
+	ctxt.DwarfVersion = dwarf.Version(ctxt.Headtype)
 
 	ctxt.Flag_optimize = true
 	return ctxt
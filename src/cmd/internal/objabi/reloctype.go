@@ -100,6 +100,19 @@ const (
 	// This is a marker relocation (0-sized), for the linker's reachabililty
 	// analysis.
 	R_USEIFACEMETHOD
+	// R_USENAMEDMETHOD marks that the function this relocation is applied
+	// to calls reflect.Type.MethodByName or reflect.Value.MethodByName with
+	// a constant name. The target is a symbol whose content is the name.
+	// This is a marker relocation (0-sized), for the linker's reachabililty
+	// analysis.
+	R_USENAMEDMETHOD
+	// R_NOREFLECTMETHODS marks a type descriptor whose methods are not
+	// to be kept live just because reflect.Type.Method and friends are
+	// reachable (see the go:noreflectmethods directive). The target is
+	// the type descriptor itself.
+	// This is a marker relocation (0-sized), for the linker's reachabililty
+	// analysis.
+	R_NOREFLECTMETHODS
 	// R_METHODOFF resolves to a 32-bit offset from the beginning of the section
 	// holding the data being relocated to the referenced symbol.
 	// It is a variant of R_ADDROFF used when linking from the uncommonType of a
@@ -256,6 +269,13 @@ const (
 	// of a symbol. This isn't a real relocation, it can be placed in anywhere
 	// in a symbol and target any symbols.
 	R_XCOFFREF
+
+	// R_DWTXTADDR resolves to the index of the target text symbol in
+	// the .debug_addr table of its DWARF compilation unit, encoded as
+	// an unsigned LEB128 number padded to the size of the relocation.
+	// It is used for DW_FORM_addrx attributes and for DWARF 5 location
+	// and range list entries, whose index the compiler cannot know.
+	R_DWTXTADDR
 )
 
 // IsDirectCall reports whether r is a relocation for a direct call.
@@ -28,6 +28,16 @@ func openElf(r io.ReaderAt) (rawFile, error) {
 
 func (f *elfFile) symbols() ([]Sym, error) {
 	elfSyms, err := f.elf.Symbols()
+	sections := f.elf.Sections
+	if err == elf.ErrNoSymbols {
+		// The symbol table may have been moved to a separate
+		// debug file. Its section headers match those of f.
+		if df, derr := f.elf.DebugFile(); derr == nil {
+			defer df.Close()
+			elfSyms, err = df.Symbols()
+			sections = df.Sections
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -42,10 +52,10 @@ func (f *elfFile) symbols() ([]Sym, error) {
 			sym.Code = 'B'
 		default:
 			i := int(s.Section)
-			if i < 0 || i >= len(f.elf.Sections) {
+			if i < 0 || i >= len(sections) {
 				break
 			}
-			sect := f.elf.Sections[i]
+			sect := sections[i]
 			switch sect.Flags & (elf.SHF_WRITE | elf.SHF_ALLOC | elf.SHF_EXECINSTR) {
 			case elf.SHF_ALLOC | elf.SHF_EXECINSTR:
 				sym.Code = 'T'
@@ -122,5 +132,13 @@ func (f *elfFile) loadAddress() (uint64, error) {
 }
 
 func (f *elfFile) dwarf() (*dwarf.Data, error) {
+	if f.elf.Section(".debug_info") == nil && f.elf.Section(".zdebug_info") == nil {
+		// The DWARF sections may have been moved to a separate
+		// debug file.
+		if df, err := f.elf.DebugFile(); err == nil {
+			defer df.Close()
+			return df.DWARF()
+		}
+	}
 	return f.elf.DWARF()
 }
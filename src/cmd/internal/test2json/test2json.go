@@ -163,7 +163,10 @@ var (
 	fourSpace = []byte("    ")
 
 	skipLinePrefix = []byte("?   \t")
-	skipLineSuffix = []byte("\t[no test files]\n")
+	skipLineSuffix = [][]byte{
+		[]byte("\t[no test files]\n"),
+		[]byte("\t[not run: -failfast]\n"),
+	}
 )
 
 // handleInputLine handles a single whole test output line.
@@ -184,8 +187,12 @@ func (c *Converter) handleInputLine(line []byte) {
 
 	// Special case for entirely skipped test binary: "?   \tpkgname\t[no test files]\n" is only line.
 	// Report it as plain output but remember to say skip in the final summary.
-	if bytes.HasPrefix(line, skipLinePrefix) && bytes.HasSuffix(line, skipLineSuffix) && len(c.report) == 0 {
-		c.result = "skip"
+	if bytes.HasPrefix(line, skipLinePrefix) && len(c.report) == 0 {
+		for _, suffix := range skipLineSuffix {
+			if bytes.HasSuffix(line, suffix) {
+				c.result = "skip"
+			}
+		}
 	}
 
 	// "=== RUN   "
@@ -64,8 +64,26 @@ Flags:
 		system tools now assume the presence of the header.
 	-debugtramp int
 		Debug trampolines.
+	-debugfile file
+		Move the DWARF debug information and the symbol table out of
+		the ELF output into file, and add a .gnu_debuglink section and
+		a GNU build ID note (if -B is not given) to the output so that
+		debuggers and tools such as objdump and addr2line can find it.
+		Cannot be combined with -s or -w.
 	-dumpdep
 		Dump symbol dependency graph.
+	-dumpdepjson file
+		Write the symbol dependency graph to file as a JSON array.
+		Each element records that symbol "from" keeps symbol "to"
+		alive; "from" is omitted for the roots of the graph. For
+		methods kept by the dead code analysis rather than by a
+		direct reference, "why" says why: "iface" if the
+		method may be called through an interface, "reflect" if it
+		may be called through reflect.Type.Method or
+		reflect.Value.Method (or MethodByName with a non-constant
+		name), and "name" if MethodByName is called with its name.
+		For "reflect" and "name", "via" is the function making the
+		reflective call.
 	-extar ar
 		Set the external archive program (default "ar").
 		Used only for -buildmode=c-archive.
@@ -498,6 +498,17 @@ func (st *relocSymState) relocsym(s loader.Sym, P []byte) {
 			// We don't renumber files in dwarf.go:writelines anymore.
 			continue
 
+		case objabi.R_DWTXTADDR:
+			idx, ok := dwtxtaddrs[rs]
+			if !ok {
+				st.err.Errorf(s, "missing .debug_addr index for relocation target %s", ldr.SymName(rs))
+				continue
+			}
+			if !putAddrx(P[off:off+siz], uint64(idx)) {
+				st.err.Errorf(s, ".debug_addr index %d for %s is too big", idx, ldr.SymName(rs))
+			}
+			continue
+
 		case objabi.R_CONST:
 			o = r.Add()
 
@@ -637,7 +648,7 @@ func extreloc(ctxt *Link, ldr *loader.Loader, s loader.Sym, r loader.Reloc) (loa
 
 	// These reloc types don't need external relocations.
 	case objabi.R_ADDROFF, objabi.R_WEAKADDROFF, objabi.R_METHODOFF, objabi.R_ADDRCUOFF,
-		objabi.R_SIZE, objabi.R_CONST, objabi.R_GOTOFF:
+		objabi.R_SIZE, objabi.R_CONST, objabi.R_GOTOFF, objabi.R_DWTXTADDR:
 		return rr, false
 	}
 	return rr, true
@@ -10,7 +10,9 @@ import (
 	"cmd/internal/sys"
 	"cmd/link/internal/loader"
 	"cmd/link/internal/sym"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"unicode"
 )
 
@@ -21,9 +23,18 @@ type deadcodePass struct {
 	ldr  *loader.Loader
 	wq   heap // work queue, using min-heap for beter locality
 
-	ifaceMethod     map[methodsig]bool // methods declared in reached interfaces
-	markableMethods []methodref        // methods of reached types
-	reflectSeen     bool               // whether we have seen a reflect method call
+	ifaceMethod     map[methodsig]bool    // methods declared in reached interfaces
+	namedMethod     map[string]loader.Sym // method names passed to MethodByName, and the caller
+	noReflect       map[loader.Sym]bool   // types whose methods are not kept for reflection
+	markableMethods []methodref           // methods of reached types
+	reflectSeen     bool                  // whether we have seen a reflect method call
+	reflectCaller   loader.Sym            // the function making the reflect method call, if known
+	dynlink         bool                  // whether exported methods may be called from other modules
+
+	// reflect.Value.Method and reflect.Value.MethodByName
+	methSym, methByNameSym loader.Sym
+
+	deps []depEdge // symbol dependency graph, for -dumpdepjson
 
 	methodsigstmp []methodsig // scratch buffer for decoding method signatures
 }
@@ -31,6 +42,8 @@ type deadcodePass struct {
 func (d *deadcodePass) init() {
 	d.ldr.InitReachable()
 	d.ifaceMethod = make(map[methodsig]bool)
+	d.namedMethod = make(map[string]loader.Sym)
+	d.noReflect = make(map[loader.Sym]bool)
 	if objabi.Fieldtrack_enabled != 0 {
 		d.ldr.Reachparent = make([]loader.Sym, d.ldr.NSym())
 	}
@@ -104,7 +117,9 @@ func (d *deadcodePass) flood() {
 	for !d.wq.empty() {
 		symIdx := d.wq.pop()
 
-		d.reflectSeen = d.reflectSeen || d.ldr.IsReflectMethod(symIdx)
+		if d.ldr.IsReflectMethod(symIdx) {
+			d.setReflectSeen(symIdx)
+		}
 
 		isgotype := d.ldr.IsGoType(symIdx)
 		relocs := d.ldr.Relocs(symIdx)
@@ -173,8 +188,32 @@ func (d *deadcodePass) flood() {
 					d.ifaceMethod[m] = true
 				}
 				continue
+			case objabi.R_USENAMEDMETHOD:
+				// R_USENAMEDMETHOD is a marker relocation that marks a method
+				// name passed to MethodByName as a constant. The target symbol
+				// holds the name.
+				name := string(d.ldr.Data(r.Sym()))
+				if d.ctxt.Debugvlog > 1 {
+					d.ctxt.Logf("reached named method: %s\n", name)
+				}
+				if _, ok := d.namedMethod[name]; !ok {
+					d.namedMethod[name] = symIdx
+				}
+				continue
+			case objabi.R_NOREFLECTMETHODS:
+				// R_NOREFLECTMETHODS is a marker relocation on a type whose
+				// methods are not to be kept just because of reflection.
+				d.noReflect[symIdx] = true
+				continue
 			}
 			rs := r.Sym()
+			if (rs == d.methSym || rs == d.methByNameSym) && !t.IsDirectCall() && !isDwarfSym(d.ldr.SymType(symIdx)) {
+				// The compiler marks direct calls of reflect.Value.Method
+				// and MethodByName (see above). Any other reference, such
+				// as a method value or an itab, may lead to a call with
+				// any method name.
+				d.setReflectSeen(symIdx)
+			}
 			if isgotype && usedInIface && d.ldr.IsGoType(rs) && !d.ldr.AttrUsedInIface(rs) {
 				// If a type is converted to an interface, it is possible to obtain an
 				// interface with a "child" type of it using reflection (e.g. obtain an
@@ -236,6 +275,13 @@ func (d *deadcodePass) flood() {
 }
 
 func (d *deadcodePass) mark(symIdx, parent loader.Sym) {
+	d.markWhy(symIdx, parent, "", 0)
+}
+
+// markWhy is like mark, but for -dumpdepjson also records why
+// parent keeps symIdx alive, if not because it refers to it,
+// and via which function.
+func (d *deadcodePass) markWhy(symIdx, parent loader.Sym, why string, via loader.Sym) {
 	if symIdx != 0 && !d.ldr.AttrReachable(symIdx) {
 		d.wq.push(symIdx)
 		d.ldr.SetAttrReachable(symIdx, true)
@@ -258,14 +304,47 @@ func (d *deadcodePass) mark(symIdx, parent loader.Sym) {
 				fmt.Printf("%s -> %s\n", from, to)
 			}
 		}
+		if *flagDumpDepJSON != "" {
+			if to := d.ldr.SymName(symIdx); to != "" {
+				e := depEdge{To: to, Why: why, UsedInIface: d.ldr.AttrUsedInIface(symIdx)}
+				if parent != 0 {
+					e.From = d.ldr.SymName(parent)
+				}
+				if via != 0 {
+					e.Via = d.ldr.SymName(via)
+				}
+				d.deps = append(d.deps, e)
+			}
+		}
 	}
 }
 
-func (d *deadcodePass) markMethod(m methodref) {
+func (d *deadcodePass) markMethod(m methodref, why string, via loader.Sym) {
 	relocs := d.ldr.Relocs(m.src)
-	d.mark(relocs.At(m.r).Sym(), m.src)
-	d.mark(relocs.At(m.r+1).Sym(), m.src)
-	d.mark(relocs.At(m.r+2).Sym(), m.src)
+	d.markWhy(relocs.At(m.r).Sym(), m.src, why, via)
+	d.markWhy(relocs.At(m.r+1).Sym(), m.src, why, via)
+	d.markWhy(relocs.At(m.r+2).Sym(), m.src, why, via)
+
+	// If the method is reflect.Value.Method or MethodByName,
+	// it may be called with any method name.
+	if tfn := relocs.At(m.r + 2).Sym(); tfn == d.methSym || tfn == d.methByNameSym {
+		d.setReflectSeen(0)
+	}
+}
+
+// setReflectSeen records that any exported method may be called
+// through reflection. caller is the symbol that refers to the
+// reflect method, or 0 if not known.
+func (d *deadcodePass) setReflectSeen(caller loader.Sym) {
+	if !d.reflectSeen {
+		d.reflectSeen = true
+		d.reflectCaller = caller
+	}
+}
+
+// isDwarfSym reports whether a symbol of kind k holds debug information.
+func isDwarfSym(k sym.SymKind) bool {
+	return k >= sym.SDWARFSECT && k <= sym.SDWARFLINES
 }
 
 // deadcode marks all reachable symbols.
@@ -294,43 +373,54 @@ func (d *deadcodePass) markMethod(m methodref) {
 // as reachable. This is extremely conservative, but easy and correct.
 //
 // The third case is handled by looking to see if any of:
-//	- reflect.Value.Method or MethodByName is reachable
-// 	- reflect.Type.Method or MethodByName is called (through the
-// 	  REFLECTMETHOD attribute marked by the compiler).
+//	- reflect.Value.Method or MethodByName is referred to other than
+//	  by a direct call (e.g. as a method value)
+// 	- reflect.Type.Method or MethodByName, or reflect.Value.Method or
+// 	  MethodByName is called (through the REFLECTMETHOD attribute
+// 	  marked by the compiler).
 // If any of these happen, all bets are off and all exported methods
-// of reachable types are marked reachable.
+// of reachable types are marked reachable, except for types marked
+// with the go:noreflectmethods directive (R_NOREFLECTMETHODS).
+// If MethodByName is only called with constant names (marked by the
+// compiler with R_USENAMEDMETHOD relocations), only the methods with
+// those names are marked reachable.
 //
 // Any unreached text symbols are removed from ctxt.Textp.
 func deadcode(ctxt *Link) {
 	ldr := ctxt.loader
 	d := deadcodePass{ctxt: ctxt, ldr: ldr}
+	d.methSym = ldr.Lookup("reflect.Value.Method", sym.SymVerABIInternal)
+	d.methByNameSym = ldr.Lookup("reflect.Value.MethodByName", sym.SymVerABIInternal)
 	d.init()
 	d.flood()
 
-	methSym := ldr.Lookup("reflect.Value.Method", sym.SymVerABIInternal)
-	methByNameSym := ldr.Lookup("reflect.Value.MethodByName", sym.SymVerABIInternal)
-
 	if ctxt.DynlinkingGo() {
 		// Exported methods may satisfy interfaces we don't know
 		// about yet when dynamically linking.
-		d.reflectSeen = true
+		d.dynlink = true
 	}
 
 	for {
-		// Methods might be called via reflection. Give up on
-		// static analysis, mark all exported methods of
-		// all reachable types as reachable.
-		d.reflectSeen = d.reflectSeen || (methSym != 0 && ldr.AttrReachable(methSym)) || (methByNameSym != 0 && ldr.AttrReachable(methByNameSym))
-
 		// Mark all methods that could satisfy a discovered
 		// interface as reachable. We recheck old marked interfaces
 		// as new types (with new methods) may have been discovered
 		// in the last pass.
+		//
+		// Methods might also be called via reflection. If so, give
+		// up on static analysis, mark all exported methods of all
+		// reachable types as reachable, unless the type opted out.
 		rem := d.markableMethods[:0]
 		for _, m := range d.markableMethods {
-			if (d.reflectSeen && m.isExported()) || d.ifaceMethod[m.m] {
-				d.markMethod(m)
-			} else {
+			switch via, named := d.namedMethod[m.m.name]; {
+			case d.ifaceMethod[m.m]:
+				d.markMethod(m, "iface", 0)
+			case d.dynlink && m.isExported():
+				d.markMethod(m, "iface", 0)
+			case d.reflectSeen && m.isExported() && !d.noReflect[m.src]:
+				d.markMethod(m, "reflect", d.reflectCaller)
+			case named:
+				d.markMethod(m, "name", via)
+			default:
 				rem = append(rem, m)
 			}
 		}
@@ -342,6 +432,31 @@ func deadcode(ctxt *Link) {
 		}
 		d.flood()
 	}
+
+	if *flagDumpDepJSON != "" {
+		d.writeDeps(*flagDumpDepJSON)
+	}
+}
+
+// A depEdge is an edge of the symbol dependency graph,
+// as written by -dumpdepjson.
+type depEdge struct {
+	From        string `json:"from,omitempty"` // empty for roots
+	To          string `json:"to"`
+	Why         string `json:"why,omitempty"`         // "iface", "reflect" or "name" for methods
+	Via         string `json:"via,omitempty"`         // the function calling into reflect
+	UsedInIface bool   `json:"usedInIface,omitempty"` // To is a type converted to an interface
+}
+
+// writeDeps writes the symbol dependency graph to file as JSON.
+func (d *deadcodePass) writeDeps(file string) {
+	b, err := json.MarshalIndent(d.deps, "", "\t")
+	if err == nil {
+		err = ioutil.WriteFile(file, append(b, '\n'), 0666)
+	}
+	if err != nil {
+		Exitf("writing dependency graph: %v", err)
+	}
 }
 
 // methodsig is a typed method signature (name + type).
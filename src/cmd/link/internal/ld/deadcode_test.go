@@ -6,6 +6,7 @@ package ld
 
 import (
 	"bytes"
+	"encoding/json"
 	"internal/testenv"
 	"io/ioutil"
 	"os"
@@ -34,6 +35,9 @@ func TestDeadcode(t *testing.T) {
 		{"ifacemethod2", "main.T.M", ""},
 		{"ifacemethod3", "main.S.M", ""},
 		{"ifacemethod4", "", "main.T.M"},
+		{"reflectmethodbyname", "main.T.M", "main.T.N"},
+		{"reflecttypemethodbyname", "main.T.M", "main.T.N"},
+		{"noreflectmethods", "main.U.M", "main.T.M"},
 	}
 	for _, test := range tests {
 		test := test
@@ -55,3 +59,43 @@ func TestDeadcode(t *testing.T) {
 		})
 	}
 }
+
+func TestDeadcodeDumpDepJSON(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	t.Parallel()
+
+	tmpdir, err := ioutil.TempDir("", "TestDeadcodeDumpDepJSON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	src := filepath.Join("testdata", "deadcode", "reflectmethodbyname.go")
+	exe := filepath.Join(tmpdir, "reflectmethodbyname.exe")
+	deps := filepath.Join(tmpdir, "deps.json")
+	cmd := exec.Command(testenv.GoToolPath(t), "build", "-ldflags=-dumpdepjson="+deps, "-o", exe, src)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %v:\n%s", cmd.Args, err, out)
+	}
+	data, err := ioutil.ReadFile(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var edges []depEdge
+	if err := json.Unmarshal(data, &edges); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, e := range edges {
+		if e.To != "main.T.M" {
+			continue
+		}
+		found = true
+		if e.From != "type.main.T" || e.Why != "name" || e.Via != "main.main" {
+			t.Errorf("got %+v, want main.T.M kept by type.main.T because main.main calls MethodByName", e)
+		}
+	}
+	if !found {
+		t.Errorf("main.T.M not found in dependency graph")
+	}
+}
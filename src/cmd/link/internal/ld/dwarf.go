@@ -64,6 +64,12 @@ type dwctxt struct {
 	// Used at various points in that parallel portion of DWARF gen to
 	// protect against conflicting updates to globals (such as "gdbscript")
 	dwmu *sync.Mutex
+
+	// DWARF 5 only: the .debug_line_str section symbol, and the
+	// offsets of the strings it holds. Filled in before the parallel
+	// portion of DWARF gen, and only read during it.
+	lineStrSym loader.Sym
+	lineStrs   map[string]int64
 }
 
 func newdwctxt(linkctxt *Link, forTypeGen bool) dwctxt {
@@ -122,6 +128,13 @@ func (c dwctxt) AddAddress(s dwarf.Sym, data interface{}, value int64) {
 	dsu.AddAddrPlus(c.arch, tgtds, value)
 }
 
+func (c dwctxt) AddIndexedAddress(s dwarf.Sym, data interface{}) {
+	ds := loader.Sym(s.(dwSym))
+	dsu := c.ldr.MakeSymbolUpdater(ds)
+	tgtds := loader.Sym(data.(dwSym))
+	dsu.AddSymRef(c.arch, tgtds, 0, objabi.R_DWTXTADDR, dwarf.AddrxSize)
+}
+
 func (c dwctxt) AddCURelativeAddress(s dwarf.Sym, data interface{}, value int64) {
 	ds := loader.Sym(s.(dwSym))
 	dsu := c.ldr.MakeSymbolUpdater(ds)
@@ -186,6 +199,12 @@ func isDwarf64(ctxt *Link) bool {
 	return ctxt.HeadType == objabi.Haix
 }
 
+// version returns the DWARF version being generated. It must agree
+// with the version the compiler used for location lists and ranges.
+func (d *dwctxt) version() int {
+	return dwarf.Version(d.linkctxt.HeadType)
+}
+
 var gdbscript string
 
 // dwarfSecInfo holds information about a DWARF output section,
@@ -1174,17 +1193,19 @@ func expandFile(fname string) string {
 	return expandGoroot(fname)
 }
 
-// writeDirFileTables emits the portion of the DWARF line table
-// prologue containing the include directories and file names,
-// described in section 6.2.4 of the DWARF 4 standard. It walks the
-// filepaths for the unit to discover any common directories, which
-// are emitted to the directory table first, then the file table is
-// emitted after that.
-func (d *dwctxt) writeDirFileTables(unit *sym.CompilationUnit, lsu *loader.SymbolBuilder) {
-	type fileDir struct {
-		base string
-		dir  int
-	}
+// fileDir is an entry of a DWARF line table file table: the base name
+// of a file, and the index of its directory in the directory table.
+type fileDir struct {
+	base string
+	dir  int
+}
+
+// dirFileTables returns the include directory and file name tables
+// for the line table of the specified compilation unit. It walks the
+// filepaths for the unit to discover any common directories. Entry 0
+// of the directory table is a placeholder for the compilation
+// directory.
+func (d *dwctxt) dirFileTables(unit *sym.CompilationUnit) ([]string, []fileDir) {
 	dirNums := make(map[string]int)
 	dirs := []string{""}
 	files := []fileDir{}
@@ -1223,10 +1244,88 @@ func (d *dwctxt) writeDirFileTables(unit *sym.CompilationUnit, lsu *loader.Symbo
 			d.dwmu.Unlock()
 		}
 	}
+	return dirs, files
+}
+
+// writeLineStrs creates the DWARF 5 .debug_line_str section, which
+// holds the directory and file names of the line tables of all the
+// compilation units, and records the offset of each name in
+// d.lineStrs.
+func (d *dwctxt) writeLineStrs(lineStrSym loader.Sym) {
+	lsu := d.ldr.MakeSymbolUpdater(lineStrSym)
+	d.lineStrSym = lineStrSym
+	d.lineStrs = make(map[string]int64)
+	add := func(str string) {
+		if _, ok := d.lineStrs[str]; !ok {
+			d.lineStrs[str] = lsu.Size()
+			lsu.Addstring(str)
+		}
+	}
+	add(getCompilationDir())
+	for _, u := range d.linkctxt.compUnits {
+		if u.DWInfo.Abbrev == dwarf.DW_ABRV_COMPUNIT_TEXTLESS {
+			continue
+		}
+		dirs, files := d.dirFileTables(u)
+		for _, dir := range dirs[1:] {
+			add(dir)
+		}
+		for _, f := range files {
+			add(f.base)
+		}
+	}
+}
+
+// putLineStr writes a DW_FORM_line_strp reference to str, which must
+// have been added to .debug_line_str by writeLineStrs, to s.
+func (d *dwctxt) putLineStr(s loader.Sym, str string) {
+	d.AddDWARFAddrSectionOffset(dwSym(s), dwSym(d.lineStrSym), d.lineStrs[str])
+}
+
+// writeDirFileTables emits the portion of the DWARF line table
+// prologue containing the include directories and file names,
+// described in section 6.2.4 of the DWARF 4 standard. The common
+// directories are emitted to the directory table first, then the
+// file table is emitted after that.
+func (d *dwctxt) writeDirFileTables(unit *sym.CompilationUnit, lsu *loader.SymbolBuilder) {
+	dirs, files := d.dirFileTables(unit)
+
+	lsDwsym := dwSym(lsu.Sym())
+	if d.version() >= 5 {
+		// DWARF 5 describes the layout of the directory and file
+		// tables in the header (sec 6.2.4.1). Directory 0 is the
+		// compilation directory and file 0 is the primary source
+		// file; we repeat the first file there so that the 1-based
+		// file numbers used by the compiler-generated line programs
+		// keep their meaning. The names themselves are shared by
+		// all the units in .debug_line_str.
+		lsu.AddUint8(1) // directory_entry_format_count
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_LNCT_path)
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_FORM_line_strp)
+		dwarf.Uleb128put(d, lsDwsym, int64(len(dirs)))
+		d.putLineStr(lsu.Sym(), getCompilationDir())
+		for k := 1; k < len(dirs); k++ {
+			d.putLineStr(lsu.Sym(), dirs[k])
+		}
+
+		lsu.AddUint8(2) // file_name_entry_format_count
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_LNCT_path)
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_FORM_line_strp)
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_LNCT_directory_index)
+		dwarf.Uleb128put(d, lsDwsym, dwarf.DW_FORM_udata)
+		if len(files) > 0 {
+			files = append([]fileDir{files[0]}, files...)
+		}
+		dwarf.Uleb128put(d, lsDwsym, int64(len(files)))
+		for _, f := range files {
+			d.putLineStr(lsu.Sym(), f.base)
+			dwarf.Uleb128put(d, lsDwsym, int64(f.dir))
+		}
+		return
+	}
 
 	// Emit directory section. This is a series of nul terminated
 	// strings, followed by a single zero byte.
-	lsDwsym := dwSym(lsu.Sym())
 	for k := 1; k < len(dirs); k++ {
 		d.AddString(lsDwsym, dirs[k])
 	}
@@ -1268,13 +1367,22 @@ func (d *dwctxt) writelines(unit *sym.CompilationUnit, lineProlog loader.Sym) []
 	unitLengthOffset := lsu.Size()
 	d.createUnitLength(lsu, 0) // unit_length (*), filled in at end
 	unitstart = lsu.Size()
-	lsu.AddUint16(d.arch, 2) // dwarf version (appendix F) -- version 3 is incompatible w/ XCode 9.0's dsymutil, latest supported on OSX 10.12 as of 2018-05
+	if d.version() >= 5 {
+		lsu.AddUint16(d.arch, 5)            // dwarf version
+		lsu.AddUint8(uint8(d.arch.PtrSize)) // address_size
+		lsu.AddUint8(0)                     // segment_selector_size
+	} else {
+		lsu.AddUint16(d.arch, 2) // dwarf version (appendix F) -- version 3 is incompatible w/ XCode 9.0's dsymutil, latest supported on OSX 10.12 as of 2018-05
+	}
 	headerLengthOffset := lsu.Size()
 	d.addDwarfAddrField(lsu, 0) // header_length (*), filled in at end
 	headerstart = lsu.Size()
 
 	// cpos == unitstart + 4 + 2 + 4
-	lsu.AddUint8(1)                // minimum_instruction_length
+	lsu.AddUint8(1) // minimum_instruction_length
+	if d.version() >= 5 {
+		lsu.AddUint8(1) // maximum_operations_per_instruction
+	}
 	lsu.AddUint8(is_stmt)          // default_is_stmt
 	lsu.AddUint8(LINE_BASE & 0xFF) // line_base
 	lsu.AddUint8(LINE_RANGE)       // line_range
@@ -1333,11 +1441,18 @@ func (d *dwctxt) writepcranges(unit *sym.CompilationUnit, base loader.Sym, pcs [
 	syms = append(syms, rangeProlog)
 	rsu := d.ldr.MakeSymbolUpdater(rangeProlog)
 	rDwSym := dwSym(rangeProlog)
+	if d.version() >= 5 {
+		d.writeListsHeader(rsu)
+	}
 
 	// Create PC ranges for the compilation unit DIE.
 	newattr(unit.DWInfo, dwarf.DW_AT_ranges, dwarf.DW_CLS_PTR, rsu.Size(), rDwSym)
 	newattr(unit.DWInfo, dwarf.DW_AT_low_pc, dwarf.DW_CLS_ADDRESS, 0, dwSym(base))
-	dwarf.PutBasedRanges(d, rDwSym, pcs)
+	if d.version() >= 5 {
+		dwarf.PutBasedRnglist(d, rDwSym, pcs)
+	} else {
+		dwarf.PutBasedRanges(d, rDwSym, pcs)
+	}
 
 	// Collect up the ranges for functions in the unit.
 	rsize := uint64(rsu.Size())
@@ -1346,6 +1461,9 @@ func (d *dwctxt) writepcranges(unit *sym.CompilationUnit, base loader.Sym, pcs [
 		syms = append(syms, s)
 		rsize += uint64(d.ldr.SymSize(s))
 	}
+	if d.version() >= 5 {
+		d.setUnitLength(rsu, int64(rsize))
+	}
 
 	if d.linkctxt.HeadType == objabi.Haix {
 		addDwsectCUSize(".debug_ranges", unit.Lib.Pkg, rsize)
@@ -1354,6 +1472,62 @@ func (d *dwctxt) writepcranges(unit *sym.CompilationUnit, base loader.Sym, pcs [
 	return syms
 }
 
+// writeListsHeader writes the header of a DWARF 5 .debug_rnglists or
+// .debug_loclists contribution (sec 7.28 and 7.29) to su. The
+// unit_length is filled in later by setUnitLength.
+func (d *dwctxt) writeListsHeader(su *loader.SymbolBuilder) {
+	d.createUnitLength(su, 0)          // unit_length (*)
+	su.AddUint16(d.arch, 5)            // dwarf version
+	su.AddUint8(uint8(d.arch.PtrSize)) // address_size
+	su.AddUint8(0)                     // segment_selector_size
+	su.AddUint32(d.arch, 0)            // offset_entry_count
+}
+
+// setUnitLength sets the unit_length field at the start of su for a
+// unit that is size bytes long, including the length field itself.
+func (d *dwctxt) setUnitLength(su *loader.SymbolBuilder, size int64) {
+	if isDwarf64(d.linkctxt) {
+		su.SetUint(d.arch, 4, uint64(size-12)) // 4 because of 0XFFFFFFFF
+	} else {
+		su.SetUint32(d.arch, 0, uint32(size-4))
+	}
+}
+
+// dwtxtaddrs maps each function symbol to its index in the .debug_addr
+// table of its compilation unit, for resolving R_DWTXTADDR relocations.
+// It is only used for DWARF 5.
+var dwtxtaddrs map[loader.Sym]int
+
+// writeAddrTable writes the contribution of compilation unit u to
+// .debug_addr (DWARF 5 sec 7.27) to addrSym. The table holds the
+// address of each function of the unit, in the order of u.Textp, so
+// the unit's base address is index 0.
+func (d *dwctxt) writeAddrTable(u *sym.CompilationUnit, addrSym loader.Sym) {
+	asu := d.ldr.MakeSymbolUpdater(addrSym)
+	d.createUnitLength(asu, 0)          // unit_length (*)
+	asu.AddUint16(d.arch, 5)            // dwarf version
+	asu.AddUint8(uint8(d.arch.PtrSize)) // address_size
+	asu.AddUint8(0)                     // segment_selector_size
+	newattr(u.DWInfo, dwarf.DW_AT_addr_base, dwarf.DW_CLS_PTR, asu.Size(), dwSym(addrSym))
+	for i, s := range u.Textp {
+		fnSym := loader.Sym(s)
+		dwtxtaddrs[fnSym] = i
+		asu.AddAddr(d.arch, fnSym)
+	}
+	d.setUnitLength(asu, asu.Size())
+}
+
+// putAddrx writes v to b as an unsigned LEB128 number padded to
+// len(b) bytes, reporting whether it fits.
+func putAddrx(b []byte, v uint64) bool {
+	for i := range b {
+		b[i] = byte(v&0x7f) | 0x80
+		v >>= 7
+	}
+	b[len(b)-1] &^= 0x80
+	return v == 0
+}
+
 /*
  *  Emit .debug_frame
  */
@@ -1538,6 +1712,9 @@ func (d *dwctxt) writeframes(fs loader.Sym) dwarfSecInfo {
 
 const (
 	COMPUNITHEADERSIZE = 4 + 2 + 4 + 1
+	// DWARF 5 adds a unit_type byte and moves debug_abbrev_offset
+	// after address_size.
+	COMPUNITHEADERSIZE5 = 4 + 2 + 1 + 1 + 4
 )
 
 // appendSyms appends the syms from 'src' into 'syms' and returns the
@@ -1562,14 +1739,21 @@ func (d *dwctxt) writeUnitInfo(u *sym.CompilationUnit, abbrevsym loader.Sym, inf
 
 	// Write .debug_info Compilation Unit Header (sec 7.5.1)
 	// Fields marked with (*) must be changed for 64-bit dwarf
-	// This must match COMPUNITHEADERSIZE above.
+	// This must match COMPUNITHEADERSIZE (COMPUNITHEADERSIZE5) above.
 	d.createUnitLength(su, 0) // unit_length (*), will be filled in later.
-	su.AddUint16(d.arch, 4)   // dwarf version (appendix F)
+	if d.version() >= 5 {
+		su.AddUint16(d.arch, 5)            // dwarf version
+		su.AddUint8(dwarf.DW_UT_compile)   // unit_type
+		su.AddUint8(uint8(d.arch.PtrSize)) // address_size
+		d.addDwarfAddrRef(su, abbrevsym)   // debug_abbrev_offset (*)
+	} else {
+		su.AddUint16(d.arch, 4) // dwarf version (appendix F)
 
-	// debug_abbrev_offset (*)
-	d.addDwarfAddrRef(su, abbrevsym)
+		// debug_abbrev_offset (*)
+		d.addDwarfAddrRef(su, abbrevsym)
 
-	su.AddUint8(uint8(d.arch.PtrSize)) // address_size
+		su.AddUint8(uint8(d.arch.PtrSize)) // address_size
+	}
 
 	ds := dwSym(s)
 	dwarf.Uleb128put(d, ds, int64(compunit.Abbrev))
@@ -1973,6 +2157,7 @@ type dwUnitSyms struct {
 	// Inputs for a given unit.
 	lineProlog  loader.Sym
 	rangeProlog loader.Sym
+	locProlog   loader.Sym // DWARF 5 only
 	infoEpilog  loader.Sym
 
 	// Outputs for a given unit.
@@ -1993,6 +2178,16 @@ func (d *dwctxt) dwUnitPortion(u *sym.CompilationUnit, abbrevsym loader.Sym, us
 		base := loader.Sym(u.Textp[0])
 		us.rangessyms = d.writepcranges(u, base, u.PCs, us.rangeProlog)
 		us.locsyms = d.collectUnitLocs(u)
+		if us.locProlog != 0 && len(us.locsyms) > 0 {
+			lsu := d.ldr.MakeSymbolUpdater(us.locProlog)
+			d.writeListsHeader(lsu)
+			size := lsu.Size()
+			for _, s := range us.locsyms {
+				size += d.ldr.SymSize(s)
+			}
+			d.setUnitLength(lsu, size)
+			us.locsyms = append([]loader.Sym{us.locProlog}, us.locsyms...)
+		}
 	}
 	us.infosyms = d.writeUnitInfo(u, abbrevsym, us.infoEpilog)
 }
@@ -2026,10 +2221,14 @@ func (d *dwctxt) dwarfGenerateDebugSyms() {
 	}
 
 	// Create the section symbols.
+	locName, rangesName := ".debug_loc", ".debug_ranges"
+	if d.version() >= 5 {
+		locName, rangesName = ".debug_loclists", ".debug_rnglists"
+	}
 	frameSym := mkSecSym(".debug_frame")
-	locSym := mkSecSym(".debug_loc")
+	locSym := mkSecSym(locName)
 	lineSym := mkSecSym(".debug_line")
-	rangesSym := mkSecSym(".debug_ranges")
+	rangesSym := mkSecSym(rangesName)
 	infoSym := mkSecSym(".debug_info")
 
 	// Create the section objects
@@ -2047,9 +2246,33 @@ func (d *dwctxt) dwarfGenerateDebugSyms() {
 		us := &unitSyms[i]
 		us.lineProlog = mkAnonSym(sym.SDWARFLINES)
 		us.rangeProlog = mkAnonSym(sym.SDWARFRANGE)
+		if d.version() >= 5 {
+			us.locProlog = mkAnonSym(sym.SDWARFLOC)
+		}
 		us.infoEpilog = mkAnonSym(sym.SDWARFFCN)
 	}
 
+	// DWARF 5 indexes function addresses through .debug_addr, and
+	// shares the names in the line tables through .debug_line_str.
+	// Both are cross-unit tables, so they are written here, before
+	// the parallel portion below.
+	var addrSec, lineStrSec dwarfSecInfo
+	var addrsyms []loader.Sym
+	if d.version() >= 5 {
+		dwtxtaddrs = make(map[loader.Sym]int)
+		addrSec.syms = []loader.Sym{mkSecSym(".debug_addr")}
+		for _, u := range d.linkctxt.compUnits {
+			if u.DWInfo.Abbrev == dwarf.DW_ABRV_COMPUNIT_TEXTLESS {
+				continue
+			}
+			addrSym := mkAnonSym(sym.SDWARFSECT)
+			d.writeAddrTable(u, addrSym)
+			addrsyms = append(addrsyms, addrSym)
+		}
+		lineStrSec.syms = []loader.Sym{mkSecSym(".debug_line_str")}
+		d.writeLineStrs(lineStrSec.secSym())
+	}
+
 	var wg sync.WaitGroup
 	sema := make(chan struct{}, runtime.GOMAXPROCS(0))
 
@@ -2097,6 +2320,7 @@ func (d *dwctxt) dwarfGenerateDebugSyms() {
 		locSec.syms = append(locSec.syms, markReachable(r.locsyms)...)
 		rangesSec.syms = append(rangesSec.syms, markReachable(r.rangessyms)...)
 	}
+	addrSec.syms = append(addrSec.syms, markReachable(addrsyms)...)
 	dwarfp = append(dwarfp, lineSec)
 	dwarfp = append(dwarfp, frameSec)
 	gdbScriptSec := d.writegdbscript()
@@ -2108,6 +2332,10 @@ func (d *dwctxt) dwarfGenerateDebugSyms() {
 		dwarfp = append(dwarfp, locSec)
 	}
 	dwarfp = append(dwarfp, rangesSec)
+	if d.version() >= 5 {
+		dwarfp = append(dwarfp, addrSec)
+		dwarfp = append(dwarfp, lineStrSec)
+	}
 
 	// Check to make sure we haven't listed any symbols more than once
 	// in the info section. This used to be done by setting and
@@ -2151,6 +2379,9 @@ func dwarfaddshstrings(ctxt *Link, shstrtab *loader.SymbolBuilder) {
 	}
 
 	secs := []string{"abbrev", "frame", "info", "loc", "line", "gdb_scripts", "ranges"}
+	if dwarf.Version(ctxt.HeadType) >= 5 {
+		secs = []string{"abbrev", "frame", "info", "loclists", "line", "gdb_scripts", "rnglists", "addr", "line_str"}
+	}
 	for _, sec := range secs {
 		shstrtab.Addstring(".debug_" + sec)
 		if ctxt.IsExternal() {
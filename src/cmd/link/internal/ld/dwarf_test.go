@@ -6,8 +6,10 @@ package ld
 
 import (
 	intdwarf "cmd/internal/dwarf"
+	"cmd/internal/objabi"
 	objfilepkg "cmd/internal/objfile" // renamed to avoid conflict with objfile function
 	"debug/dwarf"
+	"debug/elf"
 	"debug/pe"
 	"errors"
 	"fmt"
@@ -1619,3 +1621,148 @@ func TestIssue39757(t *testing.T) {
 		}
 	}
 }
+
+func TestDWARF5(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+
+	var headtype objabi.HeadType
+	if err := headtype.Set(runtime.GOOS); err != nil || intdwarf.Version(headtype) < 5 {
+		t.Skipf("skipping on %s; DWARF 5 is not generated", runtime.GOOS)
+	}
+
+	t.Parallel()
+
+	const prog = `
+package main
+
+import "fmt"
+
+//go:noinline
+func sum(x []int) int {
+	s := 0
+	for i, v := range x {
+		if v > 3 {
+			s += i * v
+		}
+	}
+	return s
+}
+
+func main() {
+	fmt.Println(sum([]int{1, 2, 3, 4, 5}))
+}
+`
+	dir, err := ioutil.TempDir("", "TestDWARF5")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := gobuild(t, dir, prog, DefaultOpt)
+	defer f.Close()
+
+	ef, err := elf.Open(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	for _, name := range []string{"rnglists", "loclists", "addr", "line_str"} {
+		if ef.Section(".debug_"+name) == nil && ef.Section(".zdebug_"+name) == nil {
+			t.Errorf("missing .debug_%s section", name)
+		}
+	}
+	for _, name := range []string{"ranges", "loc"} {
+		if ef.Section(".debug_"+name) != nil || ef.Section(".zdebug_"+name) != nil {
+			t.Errorf("unexpected DWARF 4 .debug_%s section", name)
+		}
+	}
+
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatalf("error reading DWARF: %v", err)
+	}
+	rdr := d.Reader()
+	ex := examiner{}
+	if err := ex.populate(rdr); err != nil {
+		t.Fatalf("error reading DWARF: %v", err)
+	}
+
+	sums := ex.Named("main.sum")
+	if len(sums) != 1 {
+		t.Fatalf("found %d DIEs for main.sum, want 1", len(sums))
+	}
+	sumIdx := ex.idxFromOffset(sums[0].Offset)
+	lowpc := sums[0].Val(dwarf.AttrLowpc).(uint64)
+
+	// The function's low PC is an index into .debug_addr, which
+	// must resolve to the address of main.sum.
+	syms, err := ef.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range syms {
+		if s.Name == "main.sum" {
+			found = true
+			if s.Value != lowpc {
+				t.Errorf("main.sum low PC is %#x, want %#x", lowpc, s.Value)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no symbol for main.sum")
+	}
+
+	// The compilation unit's PC ranges come from .debug_rnglists.
+	cu := ex.Parent(sumIdx)
+	ranges, err := d.Ranges(cu)
+	if err != nil {
+		t.Fatalf("error reading ranges of main compilation unit: %v", err)
+	}
+	found = false
+	for _, r := range ranges {
+		if r[0] <= lowpc && lowpc < r[1] {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("main compilation unit ranges %x do not cover main.sum at %#x", ranges, lowpc)
+	}
+
+	// The line table file numbers must still resolve to test.go,
+	// whose name is now in .debug_line_str.
+	lnrdr, err := d.LineReader(cu)
+	if err != nil || lnrdr == nil {
+		t.Fatalf("no line table for main compilation unit: %v", err)
+	}
+	var lne dwarf.LineEntry
+	found = false
+	for {
+		if err := lnrdr.Next(&lne); err != nil {
+			if err != io.EOF {
+				t.Fatalf("error reading line table: %v", err)
+			}
+			break
+		}
+		if lne.Address == lowpc {
+			found = true
+			if !strings.HasSuffix(lne.File.Name, "test.go") {
+				t.Errorf("line table entry for main.sum has file %s, want test.go", lne.File.Name)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no line table entry for main.sum at %#x", lowpc)
+	}
+
+	// At least one of the optimized locals uses a location list.
+	found = false
+	for _, child := range ex.Children(sumIdx) {
+		if f := child.AttrField(dwarf.AttrLocation); f != nil && f.Class == dwarf.ClassLocListPtr {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no location lists found for main.sum variables")
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ld
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// buildIDPlaceholder is set when -debugfile asked for a GNU build ID
+// note that the user did not supply with -B. The note is written as
+// zeros and filled in with a hash of the output by elfSplitDebugFile.
+var buildIDPlaceholder bool
+
+// elfShdr is a section header of either ELF class.
+type elfShdr struct {
+	Name      uint32
+	Type      uint32
+	Flags     uint64
+	Addr      uint64
+	Off       uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+}
+
+// elfSplitFile describes the layout of a linked ELF file being
+// split by elfSplitDebugFile.
+type elfSplitFile struct {
+	data  []byte
+	class elf.Class
+	order binary.ByteOrder
+	shdrs []elfShdr
+	names []string
+}
+
+func (f *elfSplitFile) readShdrs(shoff uint64, shnum int, shentsize int) error {
+	f.shdrs = make([]elfShdr, shnum)
+	for i := range f.shdrs {
+		off := shoff + uint64(i*shentsize)
+		if off+uint64(shentsize) > uint64(len(f.data)) {
+			return fmt.Errorf("section header %d out of range", i)
+		}
+		r := bytes.NewReader(f.data[off:])
+		sh := &f.shdrs[i]
+		if f.class == elf.ELFCLASS64 {
+			var s elf.Section64
+			if err := binary.Read(r, f.order, &s); err != nil {
+				return err
+			}
+			*sh = elfShdr{s.Name, s.Type, s.Flags, s.Addr, s.Off, s.Size, s.Link, s.Info, s.Addralign, s.Entsize}
+		} else {
+			var s elf.Section32
+			if err := binary.Read(r, f.order, &s); err != nil {
+				return err
+			}
+			*sh = elfShdr{s.Name, s.Type, uint64(s.Flags), uint64(s.Addr), uint64(s.Off), uint64(s.Size), s.Link, s.Info, uint64(s.Addralign), uint64(s.Entsize)}
+		}
+	}
+	return nil
+}
+
+func (f *elfSplitFile) writeShdr(buf *bytes.Buffer, sh *elfShdr) {
+	if f.class == elf.ELFCLASS64 {
+		binary.Write(buf, f.order, &elf.Section64{
+			Name: sh.Name, Type: sh.Type, Flags: sh.Flags, Addr: sh.Addr, Off: sh.Off,
+			Size: sh.Size, Link: sh.Link, Info: sh.Info, Addralign: sh.Addralign, Entsize: sh.Entsize,
+		})
+		return
+	}
+	binary.Write(buf, f.order, &elf.Section32{
+		Name: sh.Name, Type: sh.Type, Flags: uint32(sh.Flags), Addr: uint32(sh.Addr), Off: uint32(sh.Off),
+		Size: uint32(sh.Size), Link: sh.Link, Info: sh.Info, Addralign: uint32(sh.Addralign), Entsize: uint32(sh.Entsize),
+	})
+}
+
+// sectionData returns the file contents of section i.
+func (f *elfSplitFile) sectionData(i int) []byte {
+	sh := &f.shdrs[i]
+	if sh.Type == uint32(elf.SHT_NOBITS) {
+		return nil
+	}
+	return f.data[sh.Off : sh.Off+sh.Size]
+}
+
+// setHeader updates the section header table location of the ELF
+// header at the start of out, and clears its program header table
+// if noProgs is set.
+func (f *elfSplitFile) setHeader(out []byte, shoff uint64, shnum, shstrndx int, noProgs bool) {
+	if f.class == elf.ELFCLASS64 {
+		if noProgs {
+			f.order.PutUint64(out[0x20:], 0) // e_phoff
+			f.order.PutUint16(out[0x38:], 0) // e_phnum
+		}
+		f.order.PutUint64(out[0x28:], shoff)
+		f.order.PutUint16(out[0x3c:], uint16(shnum))
+		f.order.PutUint16(out[0x3e:], uint16(shstrndx))
+		return
+	}
+	if noProgs {
+		f.order.PutUint32(out[0x1c:], 0) // e_phoff
+		f.order.PutUint16(out[0x2c:], 0) // e_phnum
+	}
+	f.order.PutUint32(out[0x20:], uint32(shoff))
+	f.order.PutUint16(out[0x30:], uint16(shnum))
+	f.order.PutUint16(out[0x32:], uint16(shstrndx))
+}
+
+// appendAligned appends b to buf, preceded by enough padding to
+// place it at a multiple of align, and returns its offset.
+func appendAligned(buf *bytes.Buffer, b []byte, align uint64) uint64 {
+	if align > 1 {
+		for uint64(buf.Len())%align != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	off := uint64(buf.Len())
+	buf.Write(b)
+	return off
+}
+
+// isSeparateDebugSection reports whether the section named name of
+// type typ belongs in the separate debug file rather than the
+// executable. These are the sections removed by -s and -w.
+func isSeparateDebugSection(name string, typ elf.SectionType) bool {
+	return strings.HasPrefix(name, ".debug_") || strings.HasPrefix(name, ".zdebug_") ||
+		typ == elf.SHT_SYMTAB || name == ".strtab"
+}
+
+// patchBuildID fills in the placeholder GNU build ID note written
+// for -debugfile with the SHA-1 hash of the whole file, which
+// includes the Go build ID.
+func (f *elfSplitFile) patchBuildID() error {
+	for i, name := range f.names {
+		if name != ".note.gnu.build-id" {
+			continue
+		}
+		note := f.sectionData(i)
+		if len(note) < 12 {
+			return fmt.Errorf("short build ID note")
+		}
+		namesz := f.order.Uint32(note[0:])
+		descsz := f.order.Uint32(note[4:])
+		desc := 12 + (namesz+3)&^3
+		if uint64(desc)+uint64(descsz) > uint64(len(note)) {
+			return fmt.Errorf("malformed build ID note")
+		}
+		sum := sha1.Sum(f.data)
+		copy(note[desc:desc+descsz], sum[:])
+		return nil
+	}
+	return fmt.Errorf("missing build ID note")
+}
+
+// elfSplitDebugFile moves the DWARF sections and symbol table of the
+// linked ELF file out into the file debug, in the format expected by
+// debuggers for separate debug information files. The sections
+// remaining in out keep their contents; the debug file keeps all the
+// original section headers, with SHT_NOBITS for sections it does not
+// contain, so that the symbol table it holds needs no rewriting. A
+// .gnu_debuglink section naming the debug file and its CRC-32 is
+// added to out, and the GNU build ID note, which both files share,
+// identifies the pair.
+func elfSplitDebugFile(out, debug string) error {
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		return err
+	}
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer ef.Close()
+
+	f := &elfSplitFile{data: data, class: ef.Class, order: ef.ByteOrder}
+	var ehsize, shentsize int
+	var shoff uint64
+	if f.class == elf.ELFCLASS64 {
+		ehsize, shentsize = 64, 64
+		shoff = f.order.Uint64(data[0x28:])
+	} else {
+		ehsize, shentsize = 52, 40
+		shoff = uint64(f.order.Uint32(data[0x20:]))
+	}
+	if err := f.readShdrs(shoff, len(ef.Sections), shentsize); err != nil {
+		return err
+	}
+	for _, s := range ef.Sections {
+		f.names = append(f.names, s.Name)
+	}
+	shstrndx := int(f.order.Uint16(data[ehsize-2:]))
+	if shstrndx == 0 || shstrndx >= len(f.shdrs) {
+		return fmt.Errorf("missing section header string table")
+	}
+
+	if buildIDPlaceholder {
+		if err := f.patchBuildID(); err != nil {
+			return err
+		}
+	}
+
+	// Write the debug file.
+	var dbuf bytes.Buffer
+	dbuf.Write(data[:ehsize])
+	dshdrs := make([]elfShdr, len(f.shdrs))
+	for i := range f.shdrs {
+		sh := f.shdrs[i]
+		typ := elf.SectionType(sh.Type)
+		if i == 0 {
+			dshdrs[i] = sh
+			continue
+		}
+		if i == shstrndx || typ == elf.SHT_NOTE || isSeparateDebugSection(f.names[i], typ) {
+			sh.Off = appendAligned(&dbuf, f.sectionData(i), sh.Addralign)
+		} else {
+			sh.Type = uint32(elf.SHT_NOBITS)
+			sh.Off = uint64(dbuf.Len())
+		}
+		dshdrs[i] = sh
+	}
+	dshoff := appendAligned(&dbuf, nil, 8)
+	for i := range dshdrs {
+		f.writeShdr(&dbuf, &dshdrs[i])
+	}
+	debugData := dbuf.Bytes()
+	f.setHeader(debugData, dshoff, len(dshdrs), shstrndx, true)
+	if err := ioutil.WriteFile(debug, debugData, 0644); err != nil {
+		return err
+	}
+
+	// Rewrite the executable without the debug sections. Everything
+	// the program headers refer to stays where it is; the remaining
+	// non-allocated sections are copied after it.
+	end := uint64(ehsize)
+	for _, p := range ef.Progs {
+		if e := p.Off + p.Filesz; e > end {
+			end = e
+		}
+	}
+	if ph := ef.Progs; len(ph) > 0 {
+		var phoff, phentsize uint64
+		if f.class == elf.ELFCLASS64 {
+			phoff, phentsize = f.order.Uint64(data[0x20:]), 56
+		} else {
+			phoff, phentsize = uint64(f.order.Uint32(data[0x1c:])), 32
+		}
+		if e := phoff + uint64(len(ph))*phentsize; e > end {
+			end = e
+		}
+	}
+	remap := make([]int, len(f.shdrs))
+	var keep []int
+	for i := range f.shdrs {
+		if i != 0 && isSeparateDebugSection(f.names[i], elf.SectionType(f.shdrs[i].Type)) {
+			remap[i] = -1
+			continue
+		}
+		remap[i] = len(keep)
+		keep = append(keep, i)
+		sh := &f.shdrs[i]
+		if sh.Flags&uint64(elf.SHF_ALLOC) != 0 && sh.Type != uint32(elf.SHT_NOBITS) {
+			if e := sh.Off + sh.Size; e > end {
+				end = e
+			}
+		}
+	}
+
+	// The dynamic symbol table refers to sections by index and can't
+	// be rewritten, so make sure no index it uses has moved.
+	if syms, err := ef.DynamicSymbols(); err == nil {
+		for _, s := range syms {
+			if s.Section > 0 && s.Section < elf.SHN_LORESERVE && remap[s.Section] != int(s.Section) {
+				return fmt.Errorf("dynamic symbol %s refers to section %s, which would move", s.Name, f.names[s.Section])
+			}
+		}
+	}
+
+	var obuf bytes.Buffer
+	obuf.Write(data[:end])
+	var oshdrs []elfShdr
+	for _, i := range keep {
+		sh := f.shdrs[i]
+		switch {
+		case i == 0:
+		case i == shstrndx:
+			// Add the name of the new .gnu_debuglink section.
+			strs := append([]byte(nil), f.sectionData(i)...)
+			sh.Size = uint64(len(strs) + len(".gnu_debuglink") + 1)
+			strs = append(strs, ".gnu_debuglink\x00"...)
+			sh.Off = appendAligned(&obuf, strs, sh.Addralign)
+		case sh.Flags&uint64(elf.SHF_ALLOC) == 0 && sh.Type != uint32(elf.SHT_NOBITS):
+			sh.Off = appendAligned(&obuf, f.sectionData(i), sh.Addralign)
+		}
+		if sh.Link != 0 {
+			if remap[sh.Link] < 0 {
+				return fmt.Errorf("section %s links to removed section %s", f.names[i], f.names[sh.Link])
+			}
+			sh.Link = uint32(remap[sh.Link])
+		}
+		if sh.Flags&uint64(elf.SHF_INFO_LINK) != 0 && sh.Info != 0 {
+			if remap[sh.Info] < 0 {
+				return fmt.Errorf("section %s refers to removed section %s", f.names[i], f.names[sh.Info])
+			}
+			sh.Info = uint32(remap[sh.Info])
+		}
+		oshdrs = append(oshdrs, sh)
+	}
+
+	// The .gnu_debuglink contents are the base name of the debug
+	// file, padded to a multiple of 4 bytes, followed by its CRC-32.
+	link := []byte(filepath.Base(debug))
+	link = append(link, 0)
+	for len(link)%4 != 0 {
+		link = append(link, 0)
+	}
+	var crc [4]byte
+	f.order.PutUint32(crc[:], crc32.ChecksumIEEE(debugData))
+	link = append(link, crc[:]...)
+	oshdrs = append(oshdrs, elfShdr{
+		Name:      uint32(f.shdrs[shstrndx].Size),
+		Type:      uint32(elf.SHT_PROGBITS),
+		Off:       appendAligned(&obuf, link, 4),
+		Size:      uint64(len(link)),
+		Addralign: 4,
+	})
+
+	oshoff := appendAligned(&obuf, nil, 8)
+	for i := range oshdrs {
+		f.writeShdr(&obuf, &oshdrs[i])
+	}
+	outData := obuf.Bytes()
+	f.setHeader(outData, oshoff, len(oshdrs), remap[shstrndx], false)
+
+	fi, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, outData, fi.Mode())
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ld
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"internal/testenv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDebugFile(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+
+	switch runtime.GOOS {
+	case "aix", "darwin", "ios", "js", "plan9", "windows":
+		t.Skipf("skipping on %s; not an ELF system", runtime.GOOS)
+	}
+
+	t.Parallel()
+
+	const prog = `
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+`
+	dir, err := ioutil.TempDir("", "TestDebugFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	debug := filepath.Join(dir, "out.debug")
+	f := gobuild(t, dir, prog, "-ldflags=-debugfile="+debug)
+	defer f.Close()
+
+	ef, err := elf.Open(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ef.Close()
+	for _, s := range ef.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") || s.Type == elf.SHT_SYMTAB {
+			t.Errorf("unexpected section %s in executable", s.Name)
+		}
+	}
+	if ef.Section(".gnu_debuglink") == nil {
+		t.Errorf("missing .gnu_debuglink section")
+	}
+	note := ef.Section(".note.gnu.build-id")
+	if note == nil {
+		t.Fatalf("missing GNU build ID note")
+	}
+	id, err := note.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(id[len(id)-20:], make([]byte, 20)) {
+		t.Errorf("GNU build ID was not filled in")
+	}
+
+	df, err := ef.DebugFile()
+	if err != nil {
+		t.Fatalf("DebugFile: %v", err)
+	}
+	defer df.Close()
+	// The debug file describes the same memory layout.
+	for _, s := range ef.Sections {
+		if s.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		if ds := df.Section(s.Name); ds == nil || ds.Addr != s.Addr || ds.Size != s.Size {
+			t.Errorf("section %s at %#x does not match debug file", s.Name, s.Addr)
+		}
+	}
+	if s := df.Section(".note.gnu.build-id"); s == nil {
+		t.Errorf("debug file is missing GNU build ID note")
+	} else if did, err := s.Data(); err != nil || !bytes.Equal(did, id) {
+		t.Errorf("debug file GNU build ID note is %x, want %x", did, id)
+	}
+
+	// The executable has no DWARF of its own; objfile finds it in
+	// the debug file.
+	if _, err := ef.DWARF(); err == nil {
+		t.Errorf("executable has DWARF")
+	}
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatalf("error reading DWARF: %v", err)
+	}
+	found := false
+	for r := d.Reader(); ; {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatalf("error reading DWARF: %v", err)
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagSubprogram && e.Val(dwarf.AttrName) == "main.main" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("main.main not found in DWARF")
+	}
+
+	// So is the symbol table.
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatalf("error reading symbols: %v", err)
+	}
+	found = false
+	for _, s := range syms {
+		if s.Name == "main.main" && s.Code == 'T' {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("main.main not found in symbols")
+	}
+}
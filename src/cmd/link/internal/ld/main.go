@@ -36,6 +36,7 @@ import (
 	"cmd/internal/objabi"
 	"cmd/internal/sys"
 	"cmd/link/internal/benchmark"
+	"crypto/sha1"
 	"flag"
 	"log"
 	"os"
@@ -63,6 +64,8 @@ var (
 
 	flagInstallSuffix = flag.String("installsuffix", "", "set package directory `suffix`")
 	flagDumpDep       = flag.Bool("dumpdep", false, "dump symbol dependency graph")
+	flagDumpDepJSON   = flag.String("dumpdepjson", "", "write symbol dependency graph as JSON to `file`")
+	flagDebugFile     = flag.String("debugfile", "", "move DWARF and symbol table to separate ELF `file`")
 	flagRace          = flag.Bool("race", false, "enable race detector")
 	flagMsan          = flag.Bool("msan", false, "enable MSan interface")
 	flagAslr          = flag.Bool("aslr", true, "enable ASLR for buildmode=c-shared on windows")
@@ -207,6 +210,24 @@ func Main(arch *sys.Arch, theArch Arch) {
 		Exitf("-linkshared can only be used on elf systems")
 	}
 
+	if *flagDebugFile != "" {
+		if !ctxt.IsELF {
+			Exitf("-debugfile can only be used on elf systems")
+		}
+		if *FlagS || *FlagW {
+			Exitf("-debugfile cannot be used with -s or -w")
+		}
+		if ctxt.BuildMode == BuildModeCArchive {
+			Exitf("-debugfile cannot be used with -buildmode=c-archive")
+		}
+		if len(buildinfo) == 0 {
+			// The separate debug file is matched with the
+			// executable using its GNU build ID.
+			buildinfo = make([]byte, sha1.Size)
+			buildIDPlaceholder = true
+		}
+	}
+
 	if ctxt.Debugvlog != 0 {
 		ctxt.Logf("HEADER = -H%d -T0x%x -R0x%x\n", ctxt.HeadType, uint64(*FlagTextAddr), uint32(*FlagRound))
 	}
@@ -345,6 +366,12 @@ func Main(arch *sys.Arch, theArch Arch) {
 
 	bench.Start("hostlink")
 	ctxt.hostlink()
+	if *flagDebugFile != "" {
+		bench.Start("debugfile")
+		if err := elfSplitDebugFile(*flagOutfile, *flagDebugFile); err != nil {
+			Exitf("%s: writing debug file: %v", os.Args[0], err)
+		}
+	}
 	if ctxt.Debugvlog != 0 {
 		ctxt.Logf("%s", ctxt.loader.Stat())
 		ctxt.Logf("%d liveness data\n", liveness)
@@ -0,0 +1,29 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test that the methods of a type marked go:noreflectmethods
+// are not kept live by reflect.Value.Method, while those of
+// other types are.
+
+package main
+
+import (
+	"os"
+	"reflect"
+)
+
+//go:noreflectmethods
+type T int
+
+func (T) M() { println("T.M") }
+
+type U int
+
+func (U) M() { println("U.M") }
+
+func main() {
+	i := len(os.Args) - 1
+	println(reflect.ValueOf(T(1)).Method(i).IsValid())
+	reflect.ValueOf(U(1)).Method(i).Call(nil)
+}
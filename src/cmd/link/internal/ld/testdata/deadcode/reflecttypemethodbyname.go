@@ -0,0 +1,20 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test that a call of reflect.Type.MethodByName with a constant
+// name keeps only the methods with that name.
+
+package main
+
+import "reflect"
+
+type T int
+
+func (T) M() { println("M") }
+func (T) N() { println("N") }
+
+func main() {
+	m, _ := reflect.TypeOf(T(1)).MethodByName("M")
+	m.Func.Call([]reflect.Value{reflect.ValueOf(T(1))})
+}
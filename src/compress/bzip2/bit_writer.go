@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import "io"
+
+// bitWriter is the counterpart of bitReader. It packs values into bytes,
+// most-significant bit first, and buffers the complete bytes until Flush
+// writes them to the underlying io.Writer. Like bitReader, it keeps the
+// first error, which Flush returns.
+type bitWriter struct {
+	w    io.Writer
+	out  []byte
+	n    uint64
+	bits uint
+	err  error
+}
+
+func newBitWriter(w io.Writer) bitWriter {
+	return bitWriter{w: w}
+}
+
+// WriteBits64 writes the low bits bits of v. At most 56 bits may be
+// written at once.
+func (bw *bitWriter) WriteBits64(bits uint, v uint64) {
+	bw.n = bw.n<<bits | v&(1<<bits-1)
+	bw.bits += bits
+	for bw.bits >= 8 {
+		bw.bits -= 8
+		bw.out = append(bw.out, byte(bw.n>>bw.bits))
+	}
+}
+
+func (bw *bitWriter) WriteBits(bits uint, v int) {
+	bw.WriteBits64(bits, uint64(v))
+}
+
+func (bw *bitWriter) WriteBit(b bool) {
+	if b {
+		bw.WriteBits(1, 1)
+	} else {
+		bw.WriteBits(1, 0)
+	}
+}
+
+// Align pads the output with zero bits up to a byte boundary.
+func (bw *bitWriter) Align() {
+	if bw.bits > 0 {
+		bw.WriteBits(8-bw.bits, 0)
+	}
+}
+
+// Flush writes the complete bytes written so far to the underlying
+// io.Writer. Bits that do not yet make up a byte stay buffered.
+func (bw *bitWriter) Flush() error {
+	if bw.err == nil && len(bw.out) > 0 {
+		_, bw.err = bw.w.Write(bw.out)
+	}
+	bw.out = bw.out[:0]
+	return bw.err
+}
+
+func (bw *bitWriter) Err() error {
+	return bw.err
+}
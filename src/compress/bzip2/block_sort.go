@@ -0,0 +1,172 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import "sort"
+
+// A blockSorter computes the Burrows-Wheeler transform of blocks. It
+// keeps its buffers between blocks so that a Writer allocates them only
+// once.
+//
+// The transform needs the rotations of the block in sorted order.
+// bzip2 sorts rotations rather than suffixes: the byte that follows the
+// end of the block is its first byte. We cannot use index/suffixarray,
+// which sorts suffixes and lives above this package in the dependency
+// order, so the rotations are sorted here by prefix doubling, as in
+// Larsson and Sadakane's qsufsort ("Faster Suffix Sorting", 1999).
+// After the round with step h, the rotations are ordered by at least
+// their first 2*h bytes, and each round only sorts the groups of
+// rotations that are still tied.
+type blockSorter struct {
+	ptr   []int32 // rotation start positions, in sorted order
+	rank  []int32 // rank[i] is the index in ptr of the last rotation tied with rotation i
+	key   []int32 // scratch: the sort key of each element of ptr
+	count []int   // scratch: radix sort buckets
+
+	groups, next []group // tied ranges of ptr
+	byKey        byKey
+}
+
+// A group is a range ptr[lo:hi] of rotations that are tied so far.
+type group struct {
+	lo, hi int
+}
+
+// sort returns the start positions of the rotations of block in
+// sorted order, valid until the next call, and the index in that order
+// of the block itself, the rotation starting at 0.
+//
+// Equal rotations, which only occur in periodic blocks, are in no
+// particular order; any order gives the same transform. But the
+// index of the block among them matters, and as in the reference
+// implementation it is the last.
+func (s *blockSorter) sort(block []byte) (ptr []int32, origPtr int) {
+	n := len(block)
+	if cap(s.ptr) < n {
+		s.ptr = make([]int32, n)
+		s.rank = make([]int32, n)
+		s.key = make([]int32, n)
+	}
+	ptr = s.ptr[:n]
+	rank := s.rank[:n]
+
+	// Sort by the first four bytes, with a radix sort on
+	// two bytes at a time, starting with the least significant.
+	if s.count == nil {
+		s.count = make([]int, 1<<16)
+	}
+	key4 := func(i int) uint32 {
+		var k uint32
+		for j := 0; j < 4; j++ {
+			if i == n {
+				i = 0
+			}
+			k = k<<8 | uint32(block[i])
+			i++
+		}
+		return k
+	}
+	src, dst := ptr, s.key[:n]
+	for i := range src {
+		src[i] = int32(i)
+	}
+	for shift := uint(0); shift < 32; shift += 16 {
+		count := s.count
+		for i := range count {
+			count[i] = 0
+		}
+		for i := 0; i < n; i++ {
+			count[uint16(key4(i)>>shift)]++
+		}
+		sum := 0
+		for d, c := range count {
+			count[d] = sum
+			sum += c
+		}
+		for _, x := range src {
+			d := uint16(key4(int(x)) >> shift)
+			dst[count[d]] = x
+			count[d]++
+		}
+		src, dst = dst, src
+	}
+	// After an even number of passes, the result is back in ptr.
+
+	groups := s.groups[:0]
+	first := 0
+	for i := 1; i <= n; i++ {
+		if i < n && key4(int(ptr[i])) == key4(int(ptr[first])) {
+			continue
+		}
+		for _, x := range ptr[first:i] {
+			rank[x] = int32(i - 1)
+		}
+		if i-first > 1 {
+			groups = append(groups, group{first, i})
+		}
+		first = i
+	}
+
+	for h := 4; len(groups) > 0 && h < n; h *= 2 {
+		// Sort each group by the rank of the rotations h bytes
+		// later. Ranks updated earlier in the round only refine
+		// the order, so using them is safe.
+		newGroups := s.next[:0]
+		for _, g := range groups {
+			p := ptr[g.lo:g.hi]
+			k := s.key[g.lo:g.hi]
+			for i, x := range p {
+				q := int(x) + h
+				if q >= n {
+					q -= n
+				}
+				k[i] = rank[q]
+			}
+			if len(p) <= 16 {
+				for i := 1; i < len(p); i++ {
+					for j := i; j > 0 && k[j] < k[j-1]; j-- {
+						p[j], p[j-1] = p[j-1], p[j]
+						k[j], k[j-1] = k[j-1], k[j]
+					}
+				}
+			} else {
+				s.byKey = byKey{p, k}
+				sort.Sort(&s.byKey)
+			}
+
+			// Split the group into runs of equal keys.
+			first := 0
+			for i := 1; i <= len(p); i++ {
+				if i < len(p) && k[i] == k[first] {
+					continue
+				}
+				r := int32(g.lo + i - 1)
+				for _, x := range p[first:i] {
+					rank[x] = r
+				}
+				if i-first > 1 {
+					newGroups = append(newGroups, group{g.lo + first, g.lo + i})
+				}
+				first = i
+			}
+		}
+		s.next = groups
+		groups = newGroups
+	}
+	s.groups = groups
+	return ptr, int(rank[0])
+}
+
+// byKey sorts rotation positions by their keys.
+type byKey struct {
+	ptr, key []int32
+}
+
+func (b *byKey) Len() int           { return len(b.ptr) }
+func (b *byKey) Less(i, j int) bool { return b.key[i] < b.key[j] }
+func (b *byKey) Swap(i, j int) {
+	b.ptr[i], b.ptr[j] = b.ptr[j], b.ptr[i]
+	b.key[i], b.key[j] = b.key[j], b.key[i]
+}
@@ -0,0 +1,617 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// These constants are the levels accepted by NewWriterLevel. The level
+// is the block size in units of 100,000 bytes. Larger blocks usually
+// compress better, at the cost of memory when compressing and
+// decompressing. As with the bzip2 command, the default is the largest
+// block size.
+const (
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = 9
+)
+
+// The compressor follows the reference implementation closely enough that
+// for the same input and block size it produces identical output.
+const (
+	groupSize  = 50 // number of symbols coded with each selected table
+	maxGroups  = 6  // maximum number of Huffman tables per block
+	maxCodeLen = 17 // longest Huffman code the compressor produces
+	numIters   = 4  // rounds of refinement of the Huffman tables
+
+	runA = 0 // symbols coding runs of zeros after the move-to-front step
+	runB = 1
+)
+
+// A Writer is an io.WriteCloser.
+// Writes to a Writer are compressed and written to w.
+type Writer struct {
+	bw    bitWriter
+	level int
+
+	// The current block, after the initial run-length encoding:
+	// runs of 4 to 255 equal bytes become the 4 bytes followed by a
+	// count of the remaining repeats. runByte and runLen hold the
+	// run that is not yet in the block, if runLen > 0.
+	block    []byte
+	blockMax int
+	runByte  byte
+	runLen   int
+	inUse    [256]bool
+	blockCRC uint32
+
+	streamCRC     uint32
+	streamStarted bool // the stream header has been written
+	wroteStream   bool // a stream has been ended
+	pending       bool // data has been written since the last stream ended
+	closed        bool
+	err           error
+
+	// Scratch space for encoding a block.
+	sorter   blockSorter
+	mtfv     []uint16
+	mtfFreq  [258]int32
+	selector []uint8
+}
+
+// NewWriter returns a new Writer compressing data at the default
+// level. Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the block size level
+// instead of assuming DefaultCompression.
+//
+// The level can be any integer value between BestSpeed and
+// BestCompression inclusive. The error returned will be nil if the
+// level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("bzip2: invalid compression level: %d", level)
+	}
+	z := &Writer{level: level}
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter or NewWriterLevel, but
+// writing to w instead. This permits reusing a Writer rather than
+// allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.bw = newBitWriter(w)
+	// The limit leaves room for the run that completes the block.
+	z.blockMax = z.level*100000 - 19
+	if cap(z.block) < z.blockMax+5 {
+		z.block = make([]byte, 0, z.blockMax+5)
+	}
+	z.resetBlock()
+	z.runLen = 0
+	z.streamCRC = 0
+	z.streamStarted = false
+	z.wroteStream = false
+	z.pending = false
+	z.closed = false
+	z.err = nil
+}
+
+func (z *Writer) resetBlock() {
+	z.block = z.block[:0]
+	z.inUse = [256]bool{}
+	z.blockCRC = 0
+}
+
+// Write writes a compressed form of p to the underlying io.Writer. The
+// compressed bytes are not necessarily flushed until the Writer is
+// closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errors.New("bzip2: write to closed Writer")
+	}
+	if len(p) > 0 {
+		z.pending = true
+	}
+	for _, b := range p {
+		if z.runLen > 0 && b == z.runByte && z.runLen < 255 {
+			z.runLen++
+			continue
+		}
+		if z.runLen > 0 {
+			z.addRun()
+			if len(z.block) >= z.blockMax {
+				if err := z.writeBlock(false); err != nil {
+					return 0, err
+				}
+			}
+		}
+		z.runByte, z.runLen = b, 1
+	}
+	return len(p), nil
+}
+
+// addRun adds the pending run to the block.
+func (z *Writer) addRun() {
+	b := z.runByte
+	crc := ^z.blockCRC
+	for i := 0; i < z.runLen; i++ {
+		crc = crctab[byte(crc>>24)^b] ^ (crc << 8)
+	}
+	z.blockCRC = ^crc
+	z.inUse[b] = true
+	if z.runLen < 4 {
+		for i := 0; i < z.runLen; i++ {
+			z.block = append(z.block, b)
+		}
+	} else {
+		z.inUse[z.runLen-4] = true
+		z.block = append(z.block, b, b, b, b, byte(z.runLen-4))
+	}
+	z.runLen = 0
+}
+
+// Flush writes any pending data to the underlying writer. bzip2 blocks
+// are not aligned to byte boundaries, so to make all the data written
+// so far decodable, Flush ends the current bzip2 stream; the next
+// Write starts a new one. Readers, including this package's and the
+// bzip2 command, decode a sequence of streams as the concatenation of
+// their contents. Frequent flushes reduce compression.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed || !z.pending {
+		return nil
+	}
+	return z.endStream()
+}
+
+// Close closes the Writer, flushing any unwritten data to the
+// underlying io.Writer and ending the stream, but does not close the
+// underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if z.pending || !z.wroteStream {
+		// Even empty input produces a stream.
+		return z.endStream()
+	}
+	return nil
+}
+
+// endStream writes the last block and the end of the stream.
+func (z *Writer) endStream() error {
+	if z.runLen > 0 {
+		z.addRun()
+	}
+	if err := z.writeBlock(true); err != nil {
+		return err
+	}
+	z.streamCRC = 0
+	z.streamStarted = false
+	z.wroteStream = true
+	z.pending = false
+	return nil
+}
+
+// writeBlock compresses the current block, writing the stream header
+// first if needed and the end of the stream after it if last is set.
+func (z *Writer) writeBlock(last bool) error {
+	bw := &z.bw
+	if !z.streamStarted {
+		z.streamStarted = true
+		bw.WriteBits(16, bzip2FileMagic)
+		bw.WriteBits(8, 'h')
+		bw.WriteBits(8, '0'+z.level)
+	}
+	if len(z.block) > 0 {
+		z.streamCRC = (z.streamCRC<<1 | z.streamCRC>>31) ^ z.blockCRC
+		bw.WriteBits64(48, bzip2BlockMagic)
+		bw.WriteBits64(32, uint64(z.blockCRC))
+		bw.WriteBits(1, 0) // not randomized
+
+		ptr, origPtr := z.sorter.sort(z.block)
+		bw.WriteBits(24, origPtr)
+		nInUse := z.generateMTFValues(ptr)
+		z.writeMTFValues(nInUse)
+	}
+	if last {
+		bw.WriteBits64(48, bzip2FinalMagic)
+		bw.WriteBits64(32, uint64(z.streamCRC))
+		bw.Align()
+	}
+	z.resetBlock()
+	z.err = bw.Flush()
+	return z.err
+}
+
+// generateMTFValues computes the transformed block from the sorted
+// rotations in ptr and applies the move-to-front transform to it,
+// coding runs of the front symbol as runs of runA and runB. It stores
+// the result in z.mtfv and the symbol frequencies in z.mtfFreq and
+// returns the number of distinct bytes in the block.
+func (z *Writer) generateMTFValues(ptr []int32) int {
+	var seqOf [256]uint8
+	nInUse := 0
+	for i, used := range z.inUse {
+		if used {
+			seqOf[i] = uint8(nInUse)
+			nInUse++
+		}
+	}
+	eob := uint16(nInUse + 1)
+	for i := range z.mtfFreq[:eob+1] {
+		z.mtfFreq[i] = 0
+	}
+
+	var list [256]uint8
+	for i := range list {
+		list[i] = uint8(i)
+	}
+	mtfv := z.mtfv[:0]
+	zeros := 0
+	flushZeros := func() {
+		// Runs are written in bijective base 2 with digits
+		// runA (1) and runB (2), least significant first.
+		zeros--
+		for {
+			sym := uint16(runA + zeros&1)
+			mtfv = append(mtfv, sym)
+			z.mtfFreq[sym]++
+			if zeros < 2 {
+				break
+			}
+			zeros = (zeros - 2) / 2
+		}
+		zeros = 0
+	}
+	n := int32(len(z.block))
+	for _, p := range ptr {
+		j := p - 1
+		if j < 0 {
+			j += n
+		}
+		c := seqOf[z.block[j]]
+		if list[0] == c {
+			zeros++
+			continue
+		}
+		if zeros > 0 {
+			flushZeros()
+		}
+		k := 1
+		for list[k] != c {
+			k++
+		}
+		copy(list[1:k+1], list[:k])
+		list[0] = c
+		mtfv = append(mtfv, uint16(k+1))
+		z.mtfFreq[k+1]++
+	}
+	if zeros > 0 {
+		flushZeros()
+	}
+	mtfv = append(mtfv, eob)
+	z.mtfFreq[eob]++
+	z.mtfv = mtfv
+	return nInUse
+}
+
+// writeMTFValues chooses Huffman tables for the symbols in z.mtfv and
+// writes the rest of the block: the symbol map, the table selectors,
+// the tables and the coded symbols.
+func (z *Writer) writeMTFValues(nInUse int) {
+	bw := &z.bw
+	mtfv := z.mtfv
+	nMTF := len(mtfv)
+	alphaSize := nInUse + 2
+
+	var nGroups int
+	switch {
+	case nMTF < 200:
+		nGroups = 2
+	case nMTF < 600:
+		nGroups = 3
+	case nMTF < 1200:
+		nGroups = 4
+	case nMTF < 2400:
+		nGroups = 5
+	default:
+		nGroups = 6
+	}
+
+	// Start with tables that each favor a contiguous range of
+	// symbols with about the same total frequency.
+	const lesserCost, greaterCost = 0, 15
+	var lens [maxGroups][258]uint8
+	remF := nMTF
+	gs := 0
+	for nPart := nGroups; nPart > 0; nPart-- {
+		tFreq := remF / nPart
+		ge := gs - 1
+		aFreq := 0
+		for aFreq < tFreq && ge < alphaSize-1 {
+			ge++
+			aFreq += int(z.mtfFreq[ge])
+		}
+		if ge > gs && nPart != nGroups && nPart != 1 && (nGroups-nPart)%2 == 1 {
+			aFreq -= int(z.mtfFreq[ge])
+			ge--
+		}
+		for v := 0; v < alphaSize; v++ {
+			if v >= gs && v <= ge {
+				lens[nPart-1][v] = lesserCost
+			} else {
+				lens[nPart-1][v] = greaterCost
+			}
+		}
+		gs = ge + 1
+		remF -= aFreq
+	}
+
+	// Refine the tables: code each group of symbols with the cheapest
+	// table, then rebuild each table from the symbols it coded.
+	var freqs [maxGroups][258]int32
+	selector := z.selector[:0]
+	for iter := 0; iter < numIters; iter++ {
+		for t := range freqs[:nGroups] {
+			for v := range freqs[t] {
+				freqs[t][v] = 0
+			}
+		}
+		selector = selector[:0]
+		for gs := 0; gs < nMTF; gs += groupSize {
+			ge := gs + groupSize
+			if ge > nMTF {
+				ge = nMTF
+			}
+			var cost [maxGroups]int
+			for _, v := range mtfv[gs:ge] {
+				for t := 0; t < nGroups; t++ {
+					cost[t] += int(lens[t][v])
+				}
+			}
+			bt := 0
+			for t := 1; t < nGroups; t++ {
+				if cost[t] < cost[bt] {
+					bt = t
+				}
+			}
+			selector = append(selector, uint8(bt))
+			for _, v := range mtfv[gs:ge] {
+				freqs[bt][v]++
+			}
+		}
+		for t := 0; t < nGroups; t++ {
+			makeCodeLengths(lens[t][:alphaSize], freqs[t][:alphaSize], maxCodeLen)
+		}
+	}
+	z.selector = selector
+
+	var codes [maxGroups][258]uint32
+	for t := 0; t < nGroups; t++ {
+		assignCodes(codes[t][:alphaSize], lens[t][:alphaSize])
+	}
+
+	// The symbol map: which of 16 ranges of 16 bytes are used,
+	// then which bytes within each used range.
+	var inUse16 [16]bool
+	for i := range inUse16 {
+		for _, used := range z.inUse[i*16 : i*16+16] {
+			if used {
+				inUse16[i] = true
+			}
+		}
+	}
+	for _, used := range inUse16 {
+		bw.WriteBit(used)
+	}
+	for i, used := range inUse16 {
+		if used {
+			for _, u := range z.inUse[i*16 : i*16+16] {
+				bw.WriteBit(u)
+			}
+		}
+	}
+
+	// The selectors, move-to-front coded and written in unary.
+	bw.WriteBits(3, nGroups)
+	bw.WriteBits(15, len(selector))
+	var pos [maxGroups]uint8
+	for i := range pos {
+		pos[i] = uint8(i)
+	}
+	for _, s := range selector {
+		j := 0
+		for pos[j] != s {
+			j++
+		}
+		copy(pos[1:j+1], pos[:j])
+		pos[0] = s
+		for ; j > 0; j-- {
+			bw.WriteBits(1, 1)
+		}
+		bw.WriteBits(1, 0)
+	}
+
+	// The code lengths, delta coded.
+	for t := 0; t < nGroups; t++ {
+		curr := int(lens[t][0])
+		bw.WriteBits(5, curr)
+		for _, l := range lens[t][:alphaSize] {
+			for curr < int(l) {
+				bw.WriteBits(2, 2)
+				curr++
+			}
+			for curr > int(l) {
+				bw.WriteBits(2, 3)
+				curr--
+			}
+			bw.WriteBits(1, 0)
+		}
+	}
+
+	// The symbols.
+	for i, t := range selector {
+		gs := i * groupSize
+		ge := gs + groupSize
+		if ge > nMTF {
+			ge = nMTF
+		}
+		for _, v := range mtfv[gs:ge] {
+			bw.WriteBits64(uint(lens[t][v]), uint64(codes[t][v]))
+		}
+	}
+}
+
+// makeCodeLengths computes Huffman code lengths no longer than maxLen for
+// symbols with the given frequencies. Every symbol gets a code, even if
+// its frequency is zero. If the code would be too long, the frequencies
+// are scaled down and the code rebuilt, which flattens it.
+//
+// To produce the same output as the reference implementation, this
+// follows its construction exactly, including how ties are broken.
+func makeCodeLengths(lens []uint8, freq []int32, maxLen int) {
+	n := len(freq)
+	// Node weights hold the frequency in the upper 24 bits and the
+	// depth of the subtree in the lower 8, so that among equal
+	// frequencies shallower subtrees are merged first.
+	var heap [258 + 2]int32
+	var weight, parent [258 * 2]int32
+	for i, f := range freq {
+		if f == 0 {
+			f = 1
+		}
+		weight[i+1] = f << 8
+	}
+
+	for {
+		nNodes := n
+		nHeap := 0
+		heap[0] = 0
+		weight[0] = 0
+		parent[0] = -2
+
+		upHeap := func(z int) {
+			tmp := heap[z]
+			for weight[tmp] < weight[heap[z>>1]] {
+				heap[z] = heap[z>>1]
+				z >>= 1
+			}
+			heap[z] = tmp
+		}
+		downHeap := func(z int) {
+			tmp := heap[z]
+			for {
+				y := z << 1
+				if y > nHeap {
+					break
+				}
+				if y < nHeap && weight[heap[y+1]] < weight[heap[y]] {
+					y++
+				}
+				if weight[tmp] < weight[heap[y]] {
+					break
+				}
+				heap[z] = heap[y]
+				z = y
+			}
+			heap[z] = tmp
+		}
+
+		for i := 1; i <= n; i++ {
+			parent[i] = -1
+			nHeap++
+			heap[nHeap] = int32(i)
+			upHeap(nHeap)
+		}
+		for nHeap > 1 {
+			n1 := heap[1]
+			heap[1] = heap[nHeap]
+			nHeap--
+			downHeap(1)
+			n2 := heap[1]
+			heap[1] = heap[nHeap]
+			nHeap--
+			downHeap(1)
+			nNodes++
+			parent[n1] = int32(nNodes)
+			parent[n2] = int32(nNodes)
+			depth := weight[n1] & 0xff
+			if d := weight[n2] & 0xff; d > depth {
+				depth = d
+			}
+			weight[nNodes] = (weight[n1] &^ 0xff) + (weight[n2] &^ 0xff) | (1 + depth)
+			parent[nNodes] = -1
+			nHeap++
+			heap[nHeap] = int32(nNodes)
+			upHeap(nHeap)
+		}
+
+		tooLong := false
+		for i := 1; i <= n; i++ {
+			j := 0
+			for k := i; parent[k] >= 0; k = int(parent[k]) {
+				j++
+			}
+			lens[i-1] = uint8(j)
+			if j > maxLen {
+				tooLong = true
+			}
+		}
+		if !tooLong {
+			return
+		}
+		for i := 1; i <= n; i++ {
+			w := weight[i] >> 8
+			weight[i] = (1 + w/2) << 8
+		}
+	}
+}
+
+// assignCodes assigns canonical Huffman codes for the code lengths in
+// lens: shorter codes come first, and codes of equal length are in
+// symbol order. This is the order newHuffmanTree expects.
+func assignCodes(codes []uint32, lens []uint8) {
+	minLen, maxLen := uint8(32), uint8(0)
+	for _, l := range lens {
+		if l > maxLen {
+			maxLen = l
+		}
+		if l < minLen {
+			minLen = l
+		}
+	}
+	code := uint32(0)
+	for n := minLen; n <= maxLen; n++ {
+		for i, l := range lens {
+			if l == n {
+				codes[i] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+}
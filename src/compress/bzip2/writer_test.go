@@ -0,0 +1,238 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bzip2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func compress(t testing.TB, data []byte, level int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decompress(t testing.TB, compressed []byte) []byte {
+	data, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestWriterReference checks that compressing the contents of files
+// produced by the bzip2 command reproduces them exactly.
+func TestWriterReference(t *testing.T) {
+	files := []string{
+		"testdata/e.txt.bz2",
+		"testdata/Isaac.Newton-Opticks.txt.bz2",
+		"testdata/pass-random1.bz2",
+		"testdata/pass-random2.bz2",
+		"testdata/pass-sawtooth.bz2",
+		"testdata/random.data.bz2",
+	}
+	vectors := map[string][]byte{
+		"hello world": mustDecodeHex("" +
+			"425a68393141592653594eece83600000251800010400006449080200031064c" +
+			"4101a7a9a580bb9431f8bb9229c28482776741b0",
+		),
+		"32B zeros": mustDecodeHex("" +
+			"425a6839314159265359b5aa5098000000600040000004200021008283177245" +
+			"385090b5aa5098",
+		),
+		"1MiB zeros": mustDecodeHex("" +
+			"425a683931415926535938571ce50008084000c0040008200030cc0529a60806" +
+			"c4201e2ee48a70a12070ae39ca",
+		),
+		"random data - uses RLE1 stage": mustDecodeHex("" +
+			"425a6839314159265359d992d0f60000137dfe84020310091c1e280e100e0428" +
+			"01099210094806c0110002e70806402000546034000034000000f28300000320" +
+			"00d3403264049270eb7a9280d308ca06ad28f6981bee1bf8160727c7364510d7" +
+			"3a1e123083421b63f031f63993a0f40051fbf177245385090d992d0f60",
+		),
+	}
+	for _, f := range files {
+		vectors[f] = mustLoadFile(f)
+	}
+	for name, want := range vectors {
+		level := int(want[3] - '0')
+		got := compress(t, decompress(t, want), level)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: output differs from bzip2 (%d bytes, want %d)", name, len(got), len(want))
+		}
+	}
+}
+
+// writerInputs returns data that exercises the run-length encoding
+// and block boundaries at small block sizes.
+func writerInputs() map[string][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 250000)
+	rnd.Read(random)
+
+	// Runs of every length around the limits of the initial
+	// run-length encoding, which must not be split wrongly at
+	// block boundaries.
+	var runs []byte
+	for len(runs) < 350000 {
+		n := rnd.Intn(300)
+		if rnd.Intn(4) == 0 {
+			n = 250 + rnd.Intn(10)
+		}
+		runs = append(runs, bytes.Repeat([]byte{byte(rnd.Intn(4))}, n)...)
+	}
+
+	var text strings.Builder
+	for i := 0; text.Len() < 300000; i++ {
+		fmt.Fprintf(&text, "line %d: the quick brown fox jumps over the lazy dog %d times\n", i, i*i%97)
+	}
+
+	return map[string][]byte{
+		"empty":    {},
+		"byte":     {'x'},
+		"run255":   bytes.Repeat([]byte{'a'}, 255),
+		"run256":   bytes.Repeat([]byte{'a'}, 256),
+		"periodic": bytes.Repeat([]byte("abc"), 1000),
+		"random":   random,
+		"runs":     runs,
+		"text":     []byte(text.String()),
+		"zeros":    make([]byte, 1<<20),
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for name, data := range writerInputs() {
+		for _, level := range []int{1, 2, 9} {
+			got := decompress(t, compress(t, data, level))
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s at level %d: round trip failed", name, level)
+			}
+		}
+	}
+}
+
+// TestWriterCommand checks that the output matches that of the bzip2
+// command, if it is installed.
+func TestWriterCommand(t *testing.T) {
+	bzip2, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("skipping because bzip2 not found")
+	}
+	for name, data := range writerInputs() {
+		for _, level := range []int{1, 9} {
+			cmd := exec.Command(bzip2, "-c", fmt.Sprintf("-%d", level))
+			cmd.Stdin = bytes.NewReader(data)
+			want, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running bzip2: %v", err)
+			}
+			if got := compress(t, data, level); !bytes.Equal(got, want) {
+				t.Errorf("%s at level %d: output differs from bzip2 (%d bytes, want %d)", name, level, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	parts := [][]byte{
+		[]byte("hello "),
+		bytes.Repeat([]byte("x"), 1000),
+		[]byte("world\n"),
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	var want, concat []byte
+	for _, p := range parts {
+		if _, err := w.Write(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// Flushing again adds nothing.
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, p...)
+		concat = append(concat, compress(t, p, DefaultCompression)...)
+		if !bytes.Equal(buf.Bytes(), concat) {
+			t.Fatalf("after writing %q: output is not a sequence of streams", p)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), concat) {
+		t.Errorf("Close after Flush wrote %d more bytes", buf.Len()-len(concat))
+	}
+	if got := decompress(t, buf.Bytes()); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	w, err := NewWriterLevel(io.Discard, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("discarded"))
+	for name, data := range writerInputs() {
+		var buf bytes.Buffer
+		w.Reset(&buf)
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("x")); err == nil {
+			t.Errorf("%s: Write after Close succeeded", name)
+		}
+		if want := compress(t, data, 1); !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: output after Reset differs", name)
+		}
+	}
+}
+
+func TestWriterInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, 0, 10} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, compressed []byte) {
+	data := decompress(b, compressed)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	w := NewWriter(io.Discard)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w.Reset(io.Discard)
+		w.Write(data)
+		w.Close()
+	}
+}
+
+func BenchmarkEncodeDigits(b *testing.B) { benchmarkEncode(b, digits) }
+func BenchmarkEncodeNewton(b *testing.B) { benchmarkEncode(b, newton) }
+func BenchmarkEncodeRand(b *testing.B)   { benchmarkEncode(b, random) }
@@ -746,3 +746,18 @@ func (w *Writer) Reset(dst io.Writer) {
 		w.d.reset(dst)
 	}
 }
+
+// ResetDict is like Reset but replaces w's dictionary with dict,
+// making w equivalent to the result of NewWriterDict called with dst,
+// w's level and dict. Later calls to Reset use dict as well.
+func (w *Writer) ResetDict(dst io.Writer, dict []byte) {
+	dw, ok := w.d.w.writer.(*dictWriter)
+	if ok {
+		dw.w = dst
+	} else {
+		dw = &dictWriter{dst}
+	}
+	w.d.reset(dw)
+	w.dict = append(w.dict[:0], dict...)
+	w.d.fillWindow(w.dict)
+}
@@ -450,6 +450,49 @@ func TestWriterDict(t *testing.T) {
 	}
 }
 
+func TestWriterResetDict(t *testing.T) {
+	const text = "hello again world"
+	for _, level := range []int{0, 1, 5, 9} {
+		for _, first := range []string{"", "goodbye world"} {
+			var w *Writer
+			var err error
+			if first == "" {
+				w, err = NewWriter(io.Discard, level)
+			} else {
+				w, err = NewWriterDict(io.Discard, level, []byte(first))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte("discarded"))
+
+			for _, dict := range []string{"hello world", ""} {
+				var want bytes.Buffer
+				wref, _ := NewWriterDict(&want, level, []byte(dict))
+				wref.Write([]byte(text))
+				wref.Close()
+
+				var got bytes.Buffer
+				w.ResetDict(&got, []byte(dict))
+				w.Write([]byte(text))
+				w.Close()
+				if !bytes.Equal(got.Bytes(), want.Bytes()) {
+					t.Errorf("level %d, dict %q after %q: ResetDict wrote %q, want %q", level, dict, first, got.Bytes(), want.Bytes())
+				}
+
+				// Reset keeps the new dictionary.
+				got.Reset()
+				w.Reset(&got)
+				w.Write([]byte(text))
+				w.Close()
+				if !bytes.Equal(got.Bytes(), want.Bytes()) {
+					t.Errorf("level %d, dict %q after %q: Reset wrote %q, want %q", level, dict, first, got.Bytes(), want.Bytes())
+				}
+			}
+		}
+	}
+}
+
 // See https://golang.org/issue/2508
 func TestRegression2508(t *testing.T) {
 	if testing.Short() {
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sort"
 	"time"
 )
 
@@ -126,3 +127,49 @@ func ExampleReader_Multistream() {
 	//
 	// Hello Gophers - 2
 }
+
+func ExampleReadIndex() {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	// Compress the data in independent blocks of 16 bytes.
+	if err := zw.SetConcurrency(16, 4); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.SetIndexed(true); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := zw.Write([]byte("A long time ago in a galaxy far, far away...")); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Decompress the data from offset 21 on.
+	file := bytes.NewReader(buf.Bytes())
+	blocks, err := gzip.ReadIndex(file, file.Size())
+	if err != nil {
+		log.Fatal(err)
+	}
+	const off = 21
+	i := sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].DataOffset+blocks[i].DataSize > off
+	})
+	b := blocks[i]
+	zr, err := gzip.NewReader(io.NewSectionReader(file, b.Offset, file.Size()-b.Offset))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := io.CopyN(io.Discard, zr, off-b.DataOffset); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d blocks, reading block %d\n", len(blocks), i)
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// 3 blocks, reading block 1
+	// galaxy far, far away...
+}
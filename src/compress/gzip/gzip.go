@@ -36,6 +36,16 @@ type Writer struct {
 	closed      bool
 	buf         [10]byte
 	err         error
+
+	// Block mode, enabled by SetConcurrency or SetIndexed.
+	blockSize  int
+	maxPending int
+	indexed    bool
+	cur        *block   // block being filled
+	pending    []*block // blocks being compressed, oldest first
+	free       []*block
+	tail       []byte // last windowSize bytes written, the next block's dictionary
+	nblocks    int    // blocks started
 }
 
 // NewWriter returns a new Writer.
@@ -134,6 +144,51 @@ func (z *Writer) writeString(s string) (err error) {
 	return err
 }
 
+// writeHeader writes a GZIP header to z.w with the given extra data,
+// name and comment, and the other fields of z.Header.
+func (z *Writer) writeHeader(extra []byte, name, comment string) error {
+	z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
+	if extra != nil {
+		z.buf[3] |= 0x04
+	}
+	if name != "" {
+		z.buf[3] |= 0x08
+	}
+	if comment != "" {
+		z.buf[3] |= 0x10
+	}
+	if z.ModTime.After(time.Unix(0, 0)) {
+		// Section 2.3.1, the zero value for MTIME means that the
+		// modified time is not set.
+		le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
+	}
+	if z.level == BestCompression {
+		z.buf[8] = 2
+	} else if z.level == BestSpeed {
+		z.buf[8] = 4
+	}
+	z.buf[9] = z.OS
+	if _, err := z.w.Write(z.buf[:10]); err != nil {
+		return err
+	}
+	if extra != nil {
+		if err := z.writeBytes(extra); err != nil {
+			return err
+		}
+	}
+	if name != "" {
+		if err := z.writeString(name); err != nil {
+			return err
+		}
+	}
+	if comment != "" {
+		if err := z.writeString(comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Write writes a compressed form of p to the underlying io.Writer. The
 // compressed bytes are not necessarily flushed until the Writer is closed.
 func (z *Writer) Write(p []byte) (int, error) {
@@ -144,53 +199,20 @@ func (z *Writer) Write(p []byte) (int, error) {
 	// Write the GZIP header lazily.
 	if !z.wroteHeader {
 		z.wroteHeader = true
-		z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
-		if z.Extra != nil {
-			z.buf[3] |= 0x04
-		}
-		if z.Name != "" {
-			z.buf[3] |= 0x08
-		}
-		if z.Comment != "" {
-			z.buf[3] |= 0x10
-		}
-		if z.ModTime.After(time.Unix(0, 0)) {
-			// Section 2.3.1, the zero value for MTIME means that the
-			// modified time is not set.
-			le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
-		}
-		if z.level == BestCompression {
-			z.buf[8] = 2
-		} else if z.level == BestSpeed {
-			z.buf[8] = 4
-		}
-		z.buf[9] = z.OS
-		_, z.err = z.w.Write(z.buf[:10])
-		if z.err != nil {
-			return 0, z.err
-		}
-		if z.Extra != nil {
-			z.err = z.writeBytes(z.Extra)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.Name != "" {
-			z.err = z.writeString(z.Name)
+		// In indexed mode, each block has its own header.
+		if !z.indexed {
+			z.err = z.writeHeader(z.Extra, z.Name, z.Comment)
 			if z.err != nil {
 				return 0, z.err
 			}
 		}
-		if z.Comment != "" {
-			z.err = z.writeString(z.Comment)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.compressor == nil {
+		if z.compressor == nil && z.blockSize == 0 {
 			z.compressor, _ = flate.NewWriter(z.w, z.level)
 		}
 	}
+	if z.blockSize > 0 {
+		return z.writeBlocks(p)
+	}
 	z.size += uint32(len(p))
 	z.digest = crc32.Update(z.digest, crc32.IEEETable, p)
 	n, z.err = z.compressor.Write(p)
@@ -218,6 +240,10 @@ func (z *Writer) Flush() error {
 			return z.err
 		}
 	}
+	if z.blockSize > 0 {
+		z.err = z.flushBlocks(false)
+		return z.err
+	}
 	z.err = z.compressor.Flush()
 	return z.err
 }
@@ -239,9 +265,16 @@ func (z *Writer) Close() error {
 			return z.err
 		}
 	}
-	z.err = z.compressor.Close()
-	if z.err != nil {
-		return z.err
+	if z.blockSize > 0 {
+		z.err = z.flushBlocks(true)
+		if z.err != nil || z.indexed {
+			return z.err
+		}
+	} else {
+		z.err = z.compressor.Close()
+		if z.err != nil {
+			return z.err
+		}
 	}
 	le.PutUint32(z.buf[:4], z.digest)
 	le.PutUint32(z.buf[4:8], z.size)
@@ -0,0 +1,58 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"errors"
+	"io"
+)
+
+var errNotIndexed = errors.New("gzip: missing block index")
+
+// A Block describes a block of an indexed gzip file written by a
+// Writer in indexed mode (see Writer.SetIndexed).
+type Block struct {
+	Offset     int64 // offset of the block's gzip member in the file
+	Size       int64 // size of the gzip member
+	DataOffset int64 // offset of the block's data in the uncompressed data
+	DataSize   int64 // size of the block's uncompressed data
+}
+
+// ReadIndex returns the blocks of the indexed gzip file of the given
+// size read from r. It reads only the headers of the blocks.
+//
+// To decompress the data from offset off on, find the block containing
+// off, read from its Offset with a Reader, and discard the first
+// off - DataOffset bytes.
+func ReadIndex(r io.ReaderAt, size int64) ([]Block, error) {
+	var blocks []Block
+	var buf [10 + 2 + indexLen]byte
+	var off, dataOff int64
+	for off < size {
+		if n, err := r.ReadAt(buf[:], off); n < len(buf) {
+			return nil, noEOF(err)
+		}
+		if buf[0] != gzipID1 || buf[1] != gzipID2 || buf[2] != gzipDeflate {
+			return nil, ErrHeader
+		}
+		if buf[3]&flagExtra == 0 || le.Uint16(buf[10:12]) < indexLen ||
+			buf[12] != indexID1 || buf[13] != indexID2 || le.Uint16(buf[14:16]) != 8 {
+			return nil, errNotIndexed
+		}
+		b := Block{
+			Offset:     off,
+			Size:       int64(le.Uint32(buf[16:20])),
+			DataOffset: dataOff,
+			DataSize:   int64(le.Uint32(buf[20:24])),
+		}
+		if b.Size < int64(len(buf))+8 || b.Size > size-off {
+			return nil, ErrHeader
+		}
+		blocks = append(blocks, b)
+		off += b.Size
+		dataOff += b.DataSize
+	}
+	return blocks, nil
+}
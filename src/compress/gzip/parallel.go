@@ -0,0 +1,266 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	windowSize       = 1 << 15 // size of the DEFLATE window
+	defaultBlockSize = 1 << 20
+	maxBlockSize     = 1 << 30
+
+	// The subfield of the extra data that records the sizes of a block
+	// of an indexed file. See SetIndexed.
+	indexID1 = 'I'
+	indexID2 = 'X'
+	indexLen = 4 + 8 // subfield header and data
+)
+
+// SetConcurrency makes z compress its input in independent blocks of
+// blockSize bytes, up to blocks of them at the same time, each in its
+// own goroutine. The output is still a single standard gzip stream,
+// which any reader can decompress: each block is compressed with the
+// last 32 KB of the data before it as a preset dictionary, and the
+// compressed blocks are concatenated in order.
+//
+// Splitting the input costs a little compression, most of all for
+// small blocks and at BestSpeed, which does not use the dictionary.
+// Writes copy their data, and an error compressing or writing a block
+// may be reported by a later call to Write, Flush or Close.
+//
+// SetConcurrency must be called before the first call to Write, Flush
+// or Close. Reset turns it off again.
+func (z *Writer) SetConcurrency(blockSize, blocks int) error {
+	if z.wroteHeader {
+		return errors.New("gzip: SetConcurrency called after Write")
+	}
+	if blockSize <= 0 || blockSize > maxBlockSize || blocks <= 0 {
+		return fmt.Errorf("gzip: invalid concurrency: %d blocks of %d bytes", blocks, blockSize)
+	}
+	z.blockSize = blockSize
+	z.maxPending = blocks
+	return nil
+}
+
+// SetIndexed sets whether z writes an indexed gzip file, in which
+// every block is a separate gzip member, compressed without a
+// dictionary. The file can be decompressed as a whole by any reader
+// that supports multiple members, as Reader does by default, and it
+// can also be decompressed starting at any block: ReadIndex lists the
+// blocks, and a Reader reading from a block's offset returns the data
+// from there on.
+//
+// The header of each member starts its extra data with a subfield
+// with ID "IX" and 8 bytes of data: the size of the member and the
+// size of its uncompressed data, as little-endian 32-bit values. Only
+// the first member has the Name and Comment of z.Header and its Extra,
+// which follows the subfield.
+//
+// If SetConcurrency has not been called, blocks are 1 MB and are
+// compressed one at a time. Like SetConcurrency, SetIndexed must be
+// called before the first call to Write, Flush or Close.
+func (z *Writer) SetIndexed(indexed bool) error {
+	if z.wroteHeader {
+		return errors.New("gzip: SetIndexed called after Write")
+	}
+	if indexed && z.blockSize == 0 {
+		z.blockSize = defaultBlockSize
+		z.maxPending = 1
+	}
+	z.indexed = indexed
+	return nil
+}
+
+// A block is a part of the input compressed by its own goroutine.
+type block struct {
+	level int
+	data  []byte
+	dict  []byte // preset dictionary
+	last  bool   // end the DEFLATE stream
+	done  chan struct{}
+
+	// Results, valid once done is closed.
+	out    bytes.Buffer
+	digest uint32 // CRC-32 of data, in indexed mode
+}
+
+// flateWriters holds unused flate.Writers by compression level.
+var flateWriters [BestCompression - HuffmanOnly + 1]sync.Pool
+
+func (b *block) compress(indexed bool) {
+	pool := &flateWriters[b.level-HuffmanOnly]
+	fw, _ := pool.Get().(*flate.Writer)
+	if fw == nil {
+		fw, _ = flate.NewWriterDict(&b.out, b.level, b.dict)
+	} else {
+		fw.ResetDict(&b.out, b.dict)
+	}
+	// Writes to a bytes.Buffer cannot fail.
+	fw.Write(b.data)
+	if b.last {
+		fw.Close()
+	} else {
+		fw.Flush()
+	}
+	pool.Put(fw)
+	if indexed {
+		b.digest = crc32.ChecksumIEEE(b.data)
+	}
+	close(b.done)
+}
+
+// writeBlocks is Write in block mode.
+func (z *Writer) writeBlocks(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if z.cur == nil {
+			z.cur = z.newBlock()
+		}
+		b := z.cur
+		m := z.blockSize - len(b.data)
+		if m > len(p) {
+			m = len(p)
+		}
+		b.data = append(b.data, p[:m]...)
+		if !z.indexed {
+			z.size += uint32(m)
+			z.digest = crc32.Update(z.digest, crc32.IEEETable, p[:m])
+		}
+		p = p[m:]
+		n += m
+		if len(b.data) == z.blockSize {
+			if err := z.startBlock(false); err != nil {
+				z.err = err
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flushBlocks starts compressing the block being filled and waits for
+// all blocks to be written. If last is set, the block ends the stream.
+func (z *Writer) flushBlocks(last bool) error {
+	// In indexed mode, every block is a complete member, and a block
+	// with no data is needed only if the file would be empty.
+	// Otherwise, flushing needs a sync marker and closing needs a
+	// final block, even without data.
+	if !z.indexed || (z.cur != nil && len(z.cur.data) > 0) || z.nblocks == 0 {
+		if err := z.startBlock(last); err != nil {
+			return err
+		}
+	}
+	for len(z.pending) > 0 {
+		if err := z.finishBlock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *Writer) newBlock() *block {
+	if n := len(z.free); n > 0 {
+		b := z.free[n-1]
+		z.free = z.free[:n-1]
+		return b
+	}
+	return &block{level: z.level, data: make([]byte, 0, z.blockSize)}
+}
+
+// startBlock starts compressing the block being filled, after waiting
+// for enough earlier blocks to be written.
+func (z *Writer) startBlock(last bool) error {
+	b := z.cur
+	z.cur = nil
+	if b == nil {
+		b = z.newBlock()
+	}
+	b.last = last || z.indexed
+	b.dict = b.dict[:0]
+	if !z.indexed {
+		b.dict = append(b.dict, z.tail...)
+		if len(b.data) >= windowSize {
+			z.tail = append(z.tail[:0], b.data[len(b.data)-windowSize:]...)
+		} else {
+			z.tail = append(z.tail, b.data...)
+			if len(z.tail) > windowSize {
+				z.tail = z.tail[:copy(z.tail, z.tail[len(z.tail)-windowSize:])]
+			}
+		}
+	}
+	for len(z.pending) >= z.maxPending {
+		if err := z.finishBlock(); err != nil {
+			return err
+		}
+	}
+	b.done = make(chan struct{})
+	z.pending = append(z.pending, b)
+	z.nblocks++
+	go b.compress(z.indexed)
+	return nil
+}
+
+// finishBlock waits for the oldest block being compressed and writes
+// it to z.w.
+func (z *Writer) finishBlock() error {
+	b := z.pending[0]
+	z.pending = z.pending[:copy(z.pending, z.pending[1:])]
+	<-b.done
+	if z.indexed {
+		if err := z.writeMember(b); err != nil {
+			return err
+		}
+	} else if _, err := z.w.Write(b.out.Bytes()); err != nil {
+		return err
+	}
+	b.data = b.data[:0]
+	b.out.Reset()
+	z.free = append(z.free, b)
+	return nil
+}
+
+// writeMember writes a block of an indexed file as a gzip member.
+func (z *Writer) writeMember(b *block) error {
+	extra := make([]byte, indexLen)
+	var name, comment string
+	if z.nblocks-len(z.pending) == 1 {
+		// This is the first member.
+		extra = append(extra, z.Extra...)
+		name, comment = z.Name, z.Comment
+	}
+	size := 10 + 2 + len(extra) + b.out.Len() + 8
+	if name != "" {
+		size += utf8.RuneCountInString(name) + 1
+	}
+	if comment != "" {
+		size += utf8.RuneCountInString(comment) + 1
+	}
+	if int64(size) > 1<<32-1 {
+		return errors.New("gzip: compressed block is too large")
+	}
+	extra[0] = indexID1
+	extra[1] = indexID2
+	le.PutUint16(extra[2:4], 8)
+	le.PutUint32(extra[4:8], uint32(size))
+	le.PutUint32(extra[8:12], uint32(len(b.data)))
+	if err := z.writeHeader(extra, name, comment); err != nil {
+		return err
+	}
+	if _, err := z.w.Write(b.out.Bytes()); err != nil {
+		return err
+	}
+	le.PutUint32(z.buf[:4], b.digest)
+	le.PutUint32(z.buf[4:8], uint32(len(b.data)))
+	_, err := z.w.Write(z.buf[:8])
+	return err
+}
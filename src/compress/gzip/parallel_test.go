@@ -0,0 +1,295 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func parallelInputs() map[string][]byte {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	var text strings.Builder
+	for i := 0; text.Len() < 300000; i++ {
+		fmt.Fprintf(&text, "line %d: the quick brown fox jumps over the lazy dog %d times\n", i, i*i%97)
+	}
+	return map[string][]byte{
+		"empty":  {},
+		"short":  []byte("hello world\n"),
+		"random": random,
+		"text":   []byte(text.String()),
+	}
+}
+
+func compressBlocks(t *testing.T, data []byte, level, blockSize, blocks int, indexed bool) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetConcurrency(blockSize, blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetIndexed(indexed); err != nil {
+		t.Fatal(err)
+	}
+	// Write in pieces that do not line up with the blocks.
+	for p := data; len(p) > 0; {
+		n := 7777
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterConcurrency(t *testing.T) {
+	for name, data := range parallelInputs() {
+		for _, level := range []int{HuffmanOnly, NoCompression, BestSpeed, DefaultCompression, BestCompression} {
+			for _, blockSize := range []int{1 << 10, 1 << 16} {
+				for _, blocks := range []int{1, 4} {
+					compressed := compressBlocks(t, data, level, blockSize, blocks, false)
+					r, err := NewReader(bytes.NewReader(compressed))
+					if err != nil {
+						t.Fatal(err)
+					}
+					// The output is a single gzip member, so all
+					// the data comes from the first.
+					r.Multistream(false)
+					got, err := io.ReadAll(r)
+					if err != nil {
+						t.Fatalf("%s, level %d, %d blocks of %d bytes: %v", name, level, blocks, blockSize, err)
+					}
+					if !bytes.Equal(got, data) {
+						t.Errorf("%s, level %d, %d blocks of %d bytes: round trip failed", name, level, blocks, blockSize)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWriterConcurrencySingleBlock(t *testing.T) {
+	// With only one block, the output is the same as without blocks.
+	data := parallelInputs()["text"]
+	var want bytes.Buffer
+	w := NewWriter(&want)
+	w.Name = "text"
+	w.Write(data)
+	w.Close()
+
+	var got bytes.Buffer
+	w.Reset(&got)
+	w.Name = "text"
+	if err := w.SetConcurrency(len(data)+1, 2); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("output with one block differs")
+	}
+}
+
+func TestWriterConcurrencyRatio(t *testing.T) {
+	// The dictionary keeps the loss from splitting small.
+	data := parallelInputs()["text"]
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	want := buf.Len()
+	got := len(compressBlocks(t, data, DefaultCompression, 1<<16, 4, false))
+	if got > want+want/100 {
+		t.Errorf("compressed to %d bytes in blocks, %d bytes without", got, want)
+	}
+}
+
+func TestWriterConcurrencyFlush(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		if err := w.SetConcurrency(1000, 3); err != nil {
+			t.Fatal(err)
+		}
+		w.SetIndexed(indexed)
+		var want []byte
+		for i := 0; i < 10; i++ {
+			p := bytes.Repeat([]byte{'a' + byte(i)}, 500*i)
+			w.Write(p)
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, p...)
+			r, err := NewReader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(r, got); err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("indexed=%v: after Flush %d: cannot read the data written: %v", indexed, i, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("indexed=%v: round trip failed: %v", indexed, err)
+		}
+	}
+}
+
+func TestWriterIndexed(t *testing.T) {
+	for name, data := range parallelInputs() {
+		compressed := compressBlocks(t, data, DefaultCompression, 1<<14, 4, true)
+		blocks, err := ReadIndex(bytes.NewReader(compressed), int64(len(compressed)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := (len(data) + 1<<14 - 1) >> 14; len(blocks) != want && !(len(data) == 0 && len(blocks) == 1) {
+			t.Errorf("%s: %d blocks, want %d", name, len(blocks), want)
+		}
+		last := blocks[len(blocks)-1]
+		if last.Offset+last.Size != int64(len(compressed)) || last.DataOffset+last.DataSize != int64(len(data)) {
+			t.Errorf("%s: blocks do not cover the file: last block %+v", name, last)
+		}
+		for _, b := range blocks {
+			r, err := NewReader(io.NewSectionReader(bytes.NewReader(compressed), b.Offset, b.Size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("%s: block %+v: %v", name, b, err)
+			}
+			if !bytes.Equal(got, data[b.DataOffset:b.DataOffset+b.DataSize]) {
+				t.Errorf("%s: block %+v: wrong data", name, b)
+			}
+		}
+		r, err := NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: round trip failed: %v", name, err)
+		}
+	}
+}
+
+func TestWriterIndexedHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Name = "nameé"
+	w.Comment = "comment"
+	w.Extra = []byte("AB\x01\x00z")
+	w.SetIndexed(true)
+	w.SetConcurrency(10, 2)
+	w.Write([]byte("hello, world"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := ReadIndex(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(blocks))
+	}
+	for i, b := range blocks {
+		r, err := NewReader(bytes.NewReader(buf.Bytes()[b.Offset:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(r.Extra[:2]) != "IX" {
+			t.Errorf("block %d: Extra = %q, want index subfield first", i, r.Extra)
+		}
+		want := Header{}
+		if i == 0 {
+			want = Header{Name: w.Name, Comment: w.Comment, Extra: w.Extra}
+		}
+		if r.Name != want.Name || r.Comment != want.Comment || !bytes.Equal(r.Extra[indexLen:], want.Extra) {
+			t.Errorf("block %d: header %+v, want %+v after index", i, r.Header, want)
+		}
+	}
+}
+
+func TestReadIndexErrors(t *testing.T) {
+	var plain bytes.Buffer
+	w := NewWriter(&plain)
+	w.Write([]byte("hello, world"))
+	w.Close()
+	if _, err := ReadIndex(bytes.NewReader(plain.Bytes()), int64(plain.Len())); err != errNotIndexed {
+		t.Errorf("ReadIndex of unindexed file: got %v, want %v", err, errNotIndexed)
+	}
+
+	indexed := compressBlocks(t, parallelInputs()["text"], BestSpeed, 1<<16, 2, true)
+	for _, n := range []int{1, 20, len(indexed) - 1} {
+		if _, err := ReadIndex(bytes.NewReader(indexed[:n]), int64(n)); err == nil {
+			t.Errorf("ReadIndex of %d of %d bytes succeeded", n, len(indexed))
+		}
+	}
+}
+
+func TestWriterConcurrencyErrors(t *testing.T) {
+	w := NewWriter(io.Discard)
+	for _, args := range [][2]int{{0, 1}, {1, 0}, {-1, 1}, {maxBlockSize + 1, 1}} {
+		if err := w.SetConcurrency(args[0], args[1]); err == nil {
+			t.Errorf("SetConcurrency(%d, %d) succeeded", args[0], args[1])
+		}
+	}
+	w.Write([]byte("x"))
+	if err := w.SetConcurrency(1<<20, 1); err == nil {
+		t.Error("SetConcurrency after Write succeeded")
+	}
+	if err := w.SetIndexed(true); err == nil {
+		t.Error("SetIndexed after Write succeeded")
+	}
+
+	// Errors writing blocks are reported.
+	w.Reset(&limitedWriter{1000})
+	w.SetConcurrency(1000, 2)
+	data := parallelInputs()["random"]
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != io.ErrShortWrite {
+		t.Errorf("got error %v, want %v", err, io.ErrShortWrite)
+	}
+}
+
+func benchmarkWriterConcurrency(b *testing.B, blocks int) {
+	data := parallelInputs()["text"]
+	b.SetBytes(int64(len(data)))
+	w := NewWriter(io.Discard)
+	for i := 0; i < b.N; i++ {
+		w.Reset(io.Discard)
+		if blocks > 0 {
+			w.SetConcurrency(1<<16, blocks)
+		}
+		w.Write(data)
+		w.Close()
+	}
+}
+
+func BenchmarkWriter(b *testing.B)             { benchmarkWriterConcurrency(b, 0) }
+func BenchmarkWriterConcurrency1(b *testing.B) { benchmarkWriterConcurrency(b, 1) }
+func BenchmarkWriterConcurrency4(b *testing.B) { benchmarkWriterConcurrency(b, 4) }
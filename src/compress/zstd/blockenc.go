@@ -0,0 +1,441 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// seq is a sequence: some literals followed by a match.
+// RFC 3.1.1.3.2.
+type seq struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32 // distance back to the start of the match
+}
+
+// Block types. RFC 3.1.1.2.2.
+const (
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+// Literals block types. RFC 3.1.1.3.1.1.
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+)
+
+// Sequence compression modes. RFC 3.1.1.3.2.1.
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeCompressed = 2
+)
+
+// minHuffLiterals is the smallest number of literals
+// we try to compress with a Huffman code.
+const minHuffLiterals = 32
+
+// appendBlockHeader appends a block header. RFC 3.1.1.2.
+func appendBlockHeader(dst []byte, last bool, typ, size int) []byte {
+	h := uint32(typ<<1) | uint32(size)<<3
+	if last {
+		h |= 1
+	}
+	return append(dst, byte(h), byte(h>>8), byte(h>>16))
+}
+
+// appendBlock appends the block encoding src to dst, choosing the
+// smallest of a raw, RLE or compressed block. The sequences and
+// literals found for src are in e.seqs and e.lits.
+func (e *encoder) appendBlock(dst, src []byte, last bool) []byte {
+	if len(src) > 1 && isRLE(src) {
+		dst = appendBlockHeader(dst, last, blockRLE, len(src))
+		return append(dst, src[0])
+	}
+
+	if len(src) >= minHuffLiterals {
+		reps := e.reps
+		start := len(dst)
+		dst = appendBlockHeader(dst, last, blockCompressed, 0)
+		dst = e.appendLiterals(dst, e.lits)
+		dst = e.appendSequences(dst, e.seqs)
+		if size := len(dst) - start - 3; size < len(src) {
+			appendBlockHeader(dst[:start], last, blockCompressed, size)
+			return dst
+		}
+		// The decoder won't see the sequences,
+		// so it won't update the repeated offsets.
+		e.reps = reps
+		dst = dst[:start]
+	}
+
+	dst = appendBlockHeader(dst, last, blockRaw, len(src))
+	return append(dst, src...)
+}
+
+// isRLE reports whether all the bytes of b are the same.
+func isRLE(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendLiteralsHeader appends a Raw or RLE literals section header
+// for n literals. RFC 3.1.1.3.1.1.
+func appendLiteralsHeader(dst []byte, typ, n int) []byte {
+	switch {
+	case n < 1<<5:
+		return append(dst, byte(typ|n<<3))
+	case n < 1<<12:
+		h := typ | 1<<2 | n<<4
+		return append(dst, byte(h), byte(h>>8))
+	default:
+		h := typ | 3<<2 | n<<4
+		return append(dst, byte(h), byte(h>>8), byte(h>>16))
+	}
+}
+
+// appendLiterals appends the literals section for lits.
+// RFC 3.1.1.3.1.
+func (e *encoder) appendLiterals(dst, lits []byte) []byte {
+	if len(lits) == 0 {
+		return appendLiteralsHeader(dst, literalsRaw, 0)
+	}
+	if len(lits) > 1 && isRLE(lits) {
+		dst = appendLiteralsHeader(dst, literalsRLE, len(lits))
+		return append(dst, lits[0])
+	}
+	if len(lits) < minHuffLiterals {
+		dst = appendLiteralsHeader(dst, literalsRaw, len(lits))
+		return append(dst, lits...)
+	}
+
+	var counts [256]uint32
+	for _, b := range lits {
+		counts[b]++
+	}
+	h := &e.huff
+	h.build(&counts)
+
+	// Give up early if the Huffman code can't win.
+	if h.cost(&counts)/8+16 >= len(lits) {
+		dst = appendLiteralsHeader(dst, literalsRaw, len(lits))
+		return append(dst, lits...)
+	}
+
+	payload, ok := h.writeTable(e.litBuf[:0])
+	if !ok {
+		dst = appendLiteralsHeader(dst, literalsRaw, len(lits))
+		return append(dst, lits...)
+	}
+
+	// Use a single stream for short literals, four otherwise.
+	// RFC 3.1.1.3.1.6.
+	single := len(lits) < 256
+	if single {
+		payload = h.encodeStream(payload, lits)
+	} else {
+		jump := len(payload)
+		payload = append(payload, 0, 0, 0, 0, 0, 0)
+		seg := (len(lits) + 3) / 4
+		for i := 0; i < 4; i++ {
+			lo := i * seg
+			hi := lo + seg
+			if hi > len(lits) {
+				hi = len(lits)
+			}
+			before := len(payload)
+			payload = h.encodeStream(payload, lits[lo:hi])
+			if i < 3 {
+				binary.LittleEndian.PutUint16(payload[jump+2*i:], uint16(len(payload)-before))
+			}
+		}
+	}
+	e.litBuf = payload
+
+	if len(payload)+5 >= len(lits) {
+		dst = appendLiteralsHeader(dst, literalsRaw, len(lits))
+		return append(dst, lits...)
+	}
+
+	// Compressed_Literals_Block header. RFC 3.1.1.3.1.1.
+	n, c := len(lits), len(payload)
+	switch {
+	case single:
+		h := literalsCompressed | n<<4 | c<<14
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16))
+	case n < 1<<10 && c < 1<<10:
+		h := literalsCompressed | 1<<2 | n<<4 | c<<14
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16))
+	case n < 1<<14 && c < 1<<14:
+		h := uint32(literalsCompressed | 2<<2 | n<<4 | c<<18)
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16), byte(h>>24))
+	default:
+		h := uint64(literalsCompressed|3<<2|n<<4) | uint64(c)<<22
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16), byte(h>>24), byte(h>>32))
+	}
+	return append(dst, payload...)
+}
+
+// appendSequences appends the sequences section for seqs.
+// RFC 3.1.1.3.2.
+func (e *encoder) appendSequences(dst []byte, seqs []seq) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	// Compute the codes, converting offsets to offset values
+	// using the repeated offsets. RFC 3.1.1.5.
+	e.llCodes = e.llCodes[:0]
+	e.mlCodes = e.mlCodes[:0]
+	e.ofCodes = e.ofCodes[:0]
+	e.ofValues = e.ofValues[:0]
+	for _, s := range seqs {
+		ofValue := e.offsetValue(s.offset, s.litLen)
+		e.llCodes = append(e.llCodes, llCode(s.litLen))
+		e.mlCodes = append(e.mlCodes, mlCode(s.matchLen-3))
+		e.ofCodes = append(e.ofCodes, uint8(bits.Len32(ofValue)-1))
+		e.ofValues = append(e.ofValues, ofValue)
+	}
+
+	modesAt := len(dst)
+	dst = append(dst, 0)
+	var modes byte
+	var mode byte
+	dst, mode = e.seqEnc[seqLiteral].choose(dst, e.llCodes, seqLiteral)
+	modes |= mode << 6
+	dst, mode = e.seqEnc[seqOffset].choose(dst, e.ofCodes, seqOffset)
+	modes |= mode << 4
+	dst, mode = e.seqEnc[seqMatch].choose(dst, e.mlCodes, seqMatch)
+	modes |= mode << 2
+	dst[modesAt] = modes
+
+	// Write the bitstream, from the last sequence to the first.
+	// RFC 3.1.1.3.2.2.
+	bw := bitWriter{out: dst}
+	var llState, ofState, mlState fseState
+	last := n - 1
+	mlState.init(&e.seqEnc[seqMatch].fse, e.mlCodes[last])
+	ofState.init(&e.seqEnc[seqOffset].fse, e.ofCodes[last])
+	llState.init(&e.seqEnc[seqLiteral].fse, e.llCodes[last])
+	e.addExtraBits(&bw, seqs, last)
+	for i := last - 1; i >= 0; i-- {
+		ofState.encode(&bw, e.ofCodes[i])
+		mlState.encode(&bw, e.mlCodes[i])
+		llState.encode(&bw, e.llCodes[i])
+		e.addExtraBits(&bw, seqs, i)
+	}
+	mlState.flush(&bw)
+	ofState.flush(&bw)
+	llState.flush(&bw)
+	bw.finish()
+	return bw.out
+}
+
+// addExtraBits writes the extra bits of sequence i.
+func (e *encoder) addExtraBits(bw *bitWriter, seqs []seq, i int) {
+	llc, mlc, ofc := e.llCodes[i], e.mlCodes[i], e.ofCodes[i]
+	llBase, llBits := llBaseline(llc)
+	bw.addBits(seqs[i].litLen-llBase, llBits)
+	mlBase, mlBits := mlBaseline(mlc)
+	bw.addBits(seqs[i].matchLen-mlBase, mlBits)
+	bw.addBits(e.ofValues[i]-1<<ofc, ofc)
+}
+
+// offsetValue returns the Offset_Value that encodes offset after
+// litLen literals, and updates the repeated offsets to match the
+// decoder. RFC 3.1.1.5.
+func (e *encoder) offsetValue(offset, litLen uint32) uint32 {
+	r := &e.reps
+	if litLen > 0 {
+		switch offset {
+		case r[0]:
+			return 1
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 2
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 3
+		}
+	} else {
+		switch offset {
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 1
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 2
+		case r[0] - 1:
+			r[0], r[1], r[2] = offset, r[0], r[1]
+			return 3
+		}
+	}
+	r[0], r[1], r[2] = offset, r[0], r[1]
+	return offset + 3
+}
+
+// seqEncoder chooses and holds the encoding table for one
+// kind of sequence code.
+type seqEncoder struct {
+	fse    fseEncoder
+	counts [53]uint32
+	norm   [53]int16
+}
+
+// choose picks the compression mode for codes and builds the
+// encoding table, appending any table description to dst.
+// It returns the updated dst and the mode.
+func (se *seqEncoder) choose(dst []byte, codes []uint8, kind seqCode) ([]byte, byte) {
+	info := &seqCodeInfo[kind]
+	counts := se.counts[:info.maxSym+1]
+	for i := range counts {
+		counts[i] = 0
+	}
+	maxSym := 0
+	for _, c := range codes {
+		counts[c]++
+		if int(c) > maxSym {
+			maxSym = int(c)
+		}
+	}
+	counts = counts[:maxSym+1]
+	if counts[maxSym] == uint32(len(codes)) {
+		// A single symbol.
+		norm := se.norm[:maxSym+1]
+		for i := range norm {
+			norm[i] = 0
+		}
+		norm[maxSym] = 1
+		se.fse.build(norm, 0)
+		return append(dst, byte(maxSym)), modeRLE
+	}
+
+	predef := predefinedNorms[kind]
+	predefLog := uint8(info.predefTableBits)
+	predefCost, predefOK := fseCost(counts, predef, predefLog)
+
+	tableLog := fseTableLog(info.maxBits, len(codes), maxSym)
+	norm := se.norm[:maxSym+1]
+	normalizeCounts(counts, len(codes), tableLog, norm)
+	cost, _ := fseCost(counts, norm, tableLog)
+	start := len(dst)
+	dst = writeNormalizedCounts(dst, norm, tableLog)
+	cost += float64(8 * (len(dst) - start))
+
+	if predefOK && predefCost <= cost {
+		se.fse.build(predef, predefLog)
+		return dst[:start], modePredefined
+	}
+	se.fse.build(norm, tableLog)
+	return dst, modeCompressed
+}
+
+// Predefined distributions for the sequence codes.
+// RFC 3.1.1.3.2.2.
+
+// literalPredefinedDistribution is the predefined distribution table
+// for literal lengths. RFC 3.1.1.3.2.2.1.
+var literalPredefinedDistribution = []int16{
+	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+	-1, -1, -1, -1,
+}
+
+// offsetPredefinedDistribution is the predefined distribution table
+// for offsets. RFC 3.1.1.3.2.2.3.
+var offsetPredefinedDistribution = []int16{
+	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+}
+
+// matchPredefinedDistribution is the predefined distribution table
+// for match lengths. RFC 3.1.1.3.2.2.2.
+var matchPredefinedDistribution = []int16{
+	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+	-1, -1, -1, -1, -1,
+}
+
+var predefinedNorms = [3][]int16{
+	seqLiteral: literalPredefinedDistribution,
+	seqOffset:  offsetPredefinedDistribution,
+	seqMatch:   matchPredefinedDistribution,
+}
+
+// llCodeTable maps literal lengths below 64 to their codes.
+var llCodeTable = [64]uint8{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 16, 17, 17, 18, 18, 19, 19, 20, 20, 20, 20, 21, 21, 21, 21,
+	22, 22, 22, 22, 22, 22, 22, 22, 23, 23, 23, 23, 23, 23, 23, 23,
+	24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24, 24,
+}
+
+// mlCodeTable maps match lengths minus 3 below 128 to their codes.
+var mlCodeTable = [128]uint8{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	32, 32, 33, 33, 34, 34, 35, 35, 36, 36, 36, 36, 37, 37, 37, 37,
+	38, 38, 38, 38, 38, 38, 38, 38, 39, 39, 39, 39, 39, 39, 39, 39,
+	40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40,
+	41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41, 41,
+	42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42,
+	42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42, 42,
+}
+
+// llCode returns the code for a literal length. RFC 3.1.1.3.2.1.1.
+func llCode(litLen uint32) uint8 {
+	if litLen < 64 {
+		return llCodeTable[litLen]
+	}
+	return uint8(bits.Len32(litLen)-1) + 19
+}
+
+// mlCode returns the code for a match length minus 3.
+// RFC 3.1.1.3.2.1.1.
+func mlCode(mlBase uint32) uint8 {
+	if mlBase < 128 {
+		return mlCodeTable[mlBase]
+	}
+	return uint8(bits.Len32(mlBase)-1) + 36
+}
+
+// llBaseline returns the baseline and number of extra bits
+// for a literal length code.
+func llBaseline(code uint8) (uint32, uint8) {
+	if code < literalLengthOffset {
+		return uint32(code), 0
+	}
+	b := literalLengthBase[code-literalLengthOffset]
+	return b & 0xffffff, uint8(b >> 24)
+}
+
+// mlBaseline returns the baseline and number of extra bits
+// for a match length code.
+func mlBaseline(code uint8) (uint32, uint8) {
+	if code < matchLengthOffset {
+		return uint32(code) + 3, 0
+	}
+	b := matchLengthBase[code-matchLengthOffset]
+	return b & 0xffffff, uint8(b >> 24)
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// dictMagic is the magic number of a formatted dictionary. RFC 5.
+const dictMagic = 0xec30a437
+
+// A Dict is a Zstandard dictionary, for use with NewReaderDict and
+// NewWriterDict. Dictionaries improve the compression of small
+// messages that resemble the data the dictionary was built from.
+// A Dict is immutable and may be shared by any number of Readers
+// and Writers.
+type Dict struct {
+	id      uint32
+	content []byte
+
+	// The initial repeated offsets.
+	offsets [3]uint32
+
+	// The initial literals Huffman table, if any.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// The initial sequence decode FSE tables, if any.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+}
+
+// ParseDict parses a dictionary. The data may be a formatted
+// dictionary, as produced by "zstd --train", or any other data,
+// which is then used as a raw content dictionary with ID zero.
+// The returned Dict refers to data, which must not be modified
+// while the Dict is in use.
+func ParseDict(data []byte) (*Dict, error) {
+	d := &Dict{
+		content: data,
+		offsets: [3]uint32{1, 4, 8},
+	}
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		return d, nil
+	}
+
+	d.id = binary.LittleEndian.Uint32(data[4:])
+
+	// Decode the entropy tables using a Reader,
+	// which owns the table builders. RFC 5.
+	var r Reader
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	bits, off, err := r.readHuff(block(data), 8, d.huffmanTable)
+	if err != nil {
+		return nil, dictError(err)
+	}
+	d.huffmanTable = d.huffmanTable[:1<<bits]
+	d.huffmanTableBits = bits
+
+	fseTable := make([]fseEntry, 1<<9)
+	for _, kind := range []seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		tableBits, roff, err := r.readFSE(block(data), off, info.maxSym, info.maxBits, fseTable)
+		if err != nil {
+			return nil, dictError(err)
+		}
+		table := make([]fseBaselineEntry, 1<<tableBits)
+		if err := info.toBaseline(&r, off, fseTable[:1<<tableBits], table); err != nil {
+			return nil, dictError(err)
+		}
+		d.seqTables[kind] = table
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if off+12 > len(data) {
+		return nil, dictError(errors.New("missing repeat offsets"))
+	}
+	for i := range d.offsets {
+		d.offsets[i] = binary.LittleEndian.Uint32(data[off:])
+		off += 4
+	}
+	d.content = data[off:]
+	for _, o := range d.offsets {
+		if o == 0 || o > uint32(len(d.content)) {
+			return nil, dictError(errors.New("invalid repeat offset"))
+		}
+	}
+	return d, nil
+}
+
+// ID returns the dictionary ID, which is zero for a raw content
+// dictionary.
+func (d *Dict) ID() uint32 {
+	return d.id
+}
+
+func dictError(err error) error {
+	return fmt.Errorf("zstd: invalid dictionary: %v", err)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd_test
+
+import (
+	"bytes"
+	"compress/zstd"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func Example_writerReader() {
+	var buf bytes.Buffer
+	zw := zstd.NewWriter(&buf)
+
+	if _, err := zw.Write([]byte("A long time ago in a galaxy far, far away...")); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	zr := zstd.NewReader(&buf)
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// A long time ago in a galaxy far, far away...
+}
+
+func ExampleWriter_EncodeAll() {
+	// A dictionary helps with small messages that share content.
+	// Real dictionaries are usually built with "zstd --train".
+	dict, err := zstd.ParseDict([]byte(`{"name":"gopher","language":"Go","version":`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw, err := zstd.NewWriterDict(nil, zstd.DefaultCompression, dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zr := zstd.NewReaderDict(nil, dict)
+
+	msg := []byte(`{"name":"gopher","language":"Go","version":16}`)
+	compressed := zw.EncodeAll(msg, nil)
+	decompressed, err := zr.DecodeAll(compressed, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(compressed) < len(msg))
+	fmt.Printf("%s\n", decompressed)
+
+	// Output:
+	// true
+	// {"name":"gopher","language":"Go","version":16}
+}
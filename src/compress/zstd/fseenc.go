@@ -0,0 +1,308 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math"
+	"math/bits"
+)
+
+// bitWriter writes a bit stream to be read backward by a
+// reverseBitReader. Values are written in the reverse of the
+// order in which they will be read.
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// addBits writes the low n bits of v.
+func (bw *bitWriter) addBits(v uint32, n uint8) {
+	bw.bits |= (uint64(v) & (1<<n - 1)) << bw.nbits
+	bw.nbits += uint(n)
+	for bw.nbits >= 8 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// finish writes the end marker, a single 1 bit,
+// and pads the stream to a byte boundary.
+func (bw *bitWriter) finish() {
+	bw.addBits(1, 1)
+	if bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits = 0
+		bw.nbits = 0
+	}
+}
+
+// fseSymbolTransform describes how to encode one symbol
+// with an FSE table.
+type fseSymbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+	first          uint16 // index in stateTable of the symbol's first state
+}
+
+// fseEncoder is an FSE encoding table.
+type fseEncoder struct {
+	tableLog   uint8
+	norm       []int16 // normalized counts the table was built from
+	stateTable []uint16
+	symbols    []fseSymbolTransform
+	spread     []uint8 // scratch: the symbol at each state
+}
+
+// build builds the encoding table for the normalized counts in norm,
+// which sum to 1<<tableLog, counting each -1 as 1.
+// The state spreading mirrors buildFSE. RFC 4.1.1.
+func (e *fseEncoder) build(norm []int16, tableLog uint8) {
+	tableSize := 1 << tableLog
+	e.tableLog = tableLog
+	e.norm = append(e.norm[:0], norm...)
+	if cap(e.stateTable) < tableSize {
+		e.stateTable = make([]uint16, tableSize)
+		e.spread = make([]uint8, tableSize)
+	}
+	e.stateTable = e.stateTable[:tableSize]
+	e.spread = e.spread[:tableSize]
+	if cap(e.symbols) < len(norm) {
+		e.symbols = make([]fseSymbolTransform, len(norm))
+	}
+	e.symbols = e.symbols[:len(norm)]
+
+	// Symbols with a "less than 1" probability get one state each
+	// at the end of the table.
+	var cumul [256 + 1]int
+	highThreshold := tableSize - 1
+	for s, n := range norm {
+		if n == -1 {
+			cumul[s+1] = cumul[s] + 1
+			e.spread[highThreshold] = uint8(s)
+			highThreshold--
+		} else {
+			cumul[s+1] = cumul[s] + int(n)
+		}
+	}
+
+	pos := 0
+	step := (tableSize >> 1) + (tableSize >> 3) + 3
+	mask := tableSize - 1
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			e.spread[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	// The states of each symbol in increasing order.
+	for u := 0; u < tableSize; u++ {
+		s := e.spread[u]
+		e.stateTable[cumul[s]] = uint16(tableSize + u)
+		cumul[s]++
+	}
+
+	total := int32(0)
+	for s, n := range norm {
+		t := &e.symbols[s]
+		switch n {
+		case 0:
+			// Never encoded; make any use obviously wrong.
+			t.deltaNbBits = (uint32(tableLog)+1)<<16 - uint32(tableSize)
+		case -1, 1:
+			t.deltaNbBits = uint32(tableLog)<<16 - uint32(tableSize)
+			t.deltaFindState = total - 1
+			t.first = uint16(total)
+			total++
+		default:
+			maxBitsOut := uint32(tableLog) - uint32(bits.Len16(uint16(n-1))-1)
+			minStatePlus := uint32(n) << maxBitsOut
+			t.deltaNbBits = maxBitsOut<<16 - minStatePlus
+			t.deltaFindState = total - int32(n)
+			t.first = uint16(total)
+			total += int32(n)
+		}
+	}
+}
+
+// fseState is the state of an FSE encoder.
+type fseState struct {
+	e     *fseEncoder
+	state uint32
+}
+
+// init starts encoding with the last symbol to be decoded, sym.
+// That symbol costs no bits; it is recovered from the final state.
+func (st *fseState) init(e *fseEncoder, sym uint8) {
+	st.e = e
+	st.state = uint32(e.stateTable[e.symbols[sym].first])
+}
+
+// encode encodes sym, which precedes the previously encoded symbols
+// in decoding order.
+func (st *fseState) encode(bw *bitWriter, sym uint8) {
+	t := &st.e.symbols[sym]
+	nbBits := (st.state + t.deltaNbBits) >> 16
+	bw.addBits(st.state, uint8(nbBits))
+	st.state = uint32(st.e.stateTable[int32(st.state>>nbBits)+t.deltaFindState])
+}
+
+// flush writes the final state, which the decoder reads first.
+func (st *fseState) flush(bw *bitWriter) {
+	bw.addBits(st.state, st.e.tableLog)
+}
+
+// fseTableLog chooses the accuracy log of an FSE table for
+// total symbols whose largest value is maxSym.
+func fseTableLog(maxLog, total, maxSym int) uint8 {
+	tableLog := maxLog
+	// Don't use more states than are useful for the input.
+	if b := bits.Len(uint(total-1)) - 1 - 2; b < tableLog {
+		tableLog = b
+	}
+	// But leave room for every symbol.
+	minBits := bits.Len(uint(total))
+	if b := bits.Len(uint(maxSym)) + 1; b < minBits {
+		minBits = b
+	}
+	if minBits > tableLog {
+		tableLog = minBits
+	}
+	if tableLog < 5 {
+		tableLog = 5
+	}
+	if tableLog > maxLog {
+		tableLog = maxLog
+	}
+	return uint8(tableLog)
+}
+
+// normalizeCounts scales counts, whose sum is total, to sum to
+// 1<<tableLog, storing the result in norm. Every symbol that
+// occurs gets a count of at least 1.
+func normalizeCounts(counts []uint32, total int, tableLog uint8, norm []int16) {
+	size := 1 << tableLog
+	sum := 0
+	largest := 0
+	for s, c := range counts {
+		if c == 0 {
+			norm[s] = 0
+			continue
+		}
+		n := (int(c)*size + total/2) / total
+		if n < 1 {
+			n = 1
+		}
+		norm[s] = int16(n)
+		sum += n
+		if c > counts[largest] {
+			largest = s
+		}
+	}
+	if sum <= size {
+		norm[largest] += int16(size - sum)
+		return
+	}
+	// Take the excess from the most probable symbols.
+	for sum > size {
+		big := 0
+		for s, n := range norm {
+			if n > norm[big] {
+				big = s
+			}
+		}
+		take := int(norm[big]) / 4
+		if take < 1 {
+			take = 1
+		}
+		if take > sum-size {
+			take = sum - size
+		}
+		norm[big] -= int16(take)
+		sum -= take
+	}
+}
+
+// writeNormalizedCounts appends the FSE table description
+// of norm to dst. RFC 4.1.1.
+func writeNormalizedCounts(dst []byte, norm []int16, tableLog uint8) []byte {
+	var bw bitWriter
+	bw.out = dst
+
+	bw.addBits(uint32(tableLog)-5, 4)
+
+	tableSize := 1 << tableLog
+	remaining := tableSize + 1
+	threshold := tableSize
+	nbBits := uint8(tableLog) + 1
+	prev0 := false
+	sym := 0
+	for remaining > 1 {
+		if prev0 {
+			// Encode the run of zero counts as repeat flags.
+			start := sym
+			for norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				start += 3
+				bw.addBits(3, 2)
+			}
+			bw.addBits(uint32(sym-start), 2)
+		}
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++ // +1 for the extra accuracy
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			bw.addBits(uint32(count), nbBits-1)
+		} else {
+			bw.addBits(uint32(count), nbBits)
+		}
+		prev0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+	}
+	return bw.out
+}
+
+// fseCost estimates the number of bits needed to encode symbols
+// with the given counts using a table built from norm.
+// It reports false if some symbol cannot be encoded.
+func fseCost(counts []uint32, norm []int16, tableLog uint8) (float64, bool) {
+	cost := 0.0
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if s >= len(norm) || norm[s] == 0 {
+			return 0, false
+		}
+		n := float64(norm[s])
+		if n < 0 {
+			n = 1
+		}
+		cost += float64(c) * (float64(tableLog) - math.Log2(n))
+	}
+	return cost, true
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"sort"
+)
+
+// huffEncoder is a Huffman code for literals.
+type huffEncoder struct {
+	maxSym    int // largest symbol with a code
+	tableBits int // length of the longest code
+	codes     [256]uint16
+	nbits     [256]uint8
+
+	// Scratch space for building the code.
+	nodes  []huffNode
+	parent []int32
+	fse    fseEncoder
+}
+
+// huffNode is a leaf of the Huffman tree.
+type huffNode struct {
+	sym   uint8
+	count uint32
+}
+
+// build builds a code for symbols with the given counts.
+// There must be at least two distinct symbols.
+// RFC 4.2.1.
+func (h *huffEncoder) build(counts *[256]uint32) {
+	h.nodes = h.nodes[:0]
+	for s, c := range counts {
+		if c > 0 {
+			h.nodes = append(h.nodes, huffNode{uint8(s), c})
+			h.maxSym = s
+		}
+	}
+	nodes := h.nodes
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].count != nodes[j].count {
+			return nodes[i].count < nodes[j].count
+		}
+		return nodes[i].sym < nodes[j].sym
+	})
+
+	// Build the tree with two queues: the sorted leaves,
+	// and the internal nodes, which are created in order of
+	// increasing weight. Leaves are numbered 0 to n-1 and
+	// internal nodes n to 2n-2.
+	n := len(nodes)
+	if cap(h.parent) < 2*n {
+		h.parent = make([]int32, 2*n)
+	}
+	parent := h.parent[:2*n-1]
+	weight := make([]uint64, 2*n-1)
+	for i, nd := range nodes {
+		weight[i] = uint64(nd.count)
+	}
+	leaf, inner := 0, n
+	next := func(k int) int {
+		if leaf < n && (inner >= k || weight[leaf] <= weight[inner]) {
+			leaf++
+			return leaf - 1
+		}
+		inner++
+		return inner - 1
+	}
+	for k := n; k < 2*n-1; k++ {
+		a, b := next(k), next(k)
+		parent[a] = int32(k)
+		parent[b] = int32(k)
+		weight[k] = weight[a] + weight[b]
+	}
+
+	// Compute depths from the root down, reusing weight.
+	var lenCount [256]int
+	depth := weight
+	depth[2*n-2] = 0
+	maxLen := 0
+	for k := 2*n - 3; k >= 0; k-- {
+		depth[k] = depth[parent[k]] + 1
+		if k < n {
+			d := int(depth[k])
+			lenCount[d]++
+			if d > maxLen {
+				maxLen = d
+			}
+		}
+	}
+
+	// Limit the code lengths to maxHuffmanBits while keeping the
+	// code complete, as in JPEG (ITU T.81) Annex K.3.
+	for i := maxLen; i > maxHuffmanBits; i-- {
+		for lenCount[i] > 0 {
+			j := i - 2
+			for lenCount[j] == 0 {
+				j--
+			}
+			lenCount[i] -= 2
+			lenCount[i-1]++
+			lenCount[j+1] += 2
+			lenCount[j]--
+		}
+	}
+	if maxLen > maxHuffmanBits {
+		maxLen = maxHuffmanBits
+	}
+	for maxLen > 0 && lenCount[maxLen] == 0 {
+		maxLen--
+	}
+	h.tableBits = maxLen
+
+	// Assign the shortest lengths to the most frequent symbols.
+	for i := range h.nbits {
+		h.nbits[i] = 0
+	}
+	l := 1
+	for i := n - 1; i >= 0; i-- {
+		for lenCount[l] == 0 {
+			l++
+		}
+		lenCount[l]--
+		h.nbits[nodes[i].sym] = uint8(l)
+	}
+
+	// Assign codes in the order used by the decoder: by increasing
+	// weight, which is by decreasing length, then by symbol.
+	idx := 0
+	for w := 1; w <= h.tableBits; w++ {
+		length := uint8(h.tableBits + 1 - w)
+		for s := 0; s <= h.maxSym; s++ {
+			if h.nbits[s] == length {
+				h.codes[s] = uint16(idx >> uint(w-1))
+				idx += 1 << uint(w-1)
+			}
+		}
+	}
+}
+
+// weight returns the Huffman weight of sym. RFC 4.2.1.
+func (h *huffEncoder) weight(sym int) uint8 {
+	if h.nbits[sym] == 0 {
+		return 0
+	}
+	return uint8(h.tableBits + 1 - int(h.nbits[sym]))
+}
+
+// writeTable appends the Huffman tree description to dst.
+// It reports false if the description cannot be written,
+// in which case the literals should not be Huffman coded.
+// RFC 4.2.1.
+func (h *huffEncoder) writeTable(dst []byte) ([]byte, bool) {
+	// The weight of the last symbol is implied.
+	num := h.maxSym
+
+	var weights [256]uint8
+	var counts [maxHuffmanBits + 1]uint32
+	distinct := 0
+	maxWeight := 0
+	for s := 0; s < num; s++ {
+		w := h.weight(s)
+		weights[s] = w
+		if counts[w] == 0 {
+			distinct++
+		}
+		counts[w]++
+		if int(w) > maxWeight {
+			maxWeight = int(w)
+		}
+	}
+
+	start := len(dst)
+	if distinct >= 2 {
+		// Try compressing the weights with FSE, using
+		// two interleaved states. RFC 4.2.1.2.
+		tableLog := fseTableLog(6, num, maxWeight)
+		var norm [maxHuffmanBits + 1]int16
+		normalizeCounts(counts[:maxWeight+1], num, tableLog, norm[:])
+		h.fse.build(norm[:maxWeight+1], tableLog)
+
+		dst = append(dst, 0)
+		dst = writeNormalizedCounts(dst, norm[:maxWeight+1], tableLog)
+		bw := bitWriter{out: dst}
+		var st1, st2 fseState
+		i := num
+		if num&1 != 0 {
+			st1.init(&h.fse, weights[i-1])
+			st2.init(&h.fse, weights[i-2])
+			st1.encode(&bw, weights[i-3])
+			i -= 3
+		} else {
+			st2.init(&h.fse, weights[i-1])
+			st1.init(&h.fse, weights[i-2])
+			i -= 2
+		}
+		for i > 0 {
+			st2.encode(&bw, weights[i-1])
+			st1.encode(&bw, weights[i-2])
+			i -= 2
+		}
+		st2.flush(&bw)
+		st1.flush(&bw)
+		bw.finish()
+		dst = bw.out
+
+		size := len(dst) - start - 1
+		if size < 128 && (num > 128 || size < (num+1)/2) {
+			dst[start] = byte(size)
+			return dst, true
+		}
+		dst = dst[:start]
+	}
+
+	if num > 128 {
+		return dst, false
+	}
+
+	// Write the weights directly, 4 bits each. RFC 4.2.1.1.
+	dst = append(dst, byte(127+num))
+	for s := 0; s < num; s += 2 {
+		dst = append(dst, weights[s]<<4|weights[s+1])
+	}
+	return dst, true
+}
+
+// encodeStream appends the Huffman coded stream of src to dst.
+func (h *huffEncoder) encodeStream(dst, src []byte) []byte {
+	bw := bitWriter{out: dst}
+	for i := len(src) - 1; i >= 0; i-- {
+		b := src[i]
+		bw.addBits(uint32(h.codes[b]), h.nbits[b])
+	}
+	bw.finish()
+	return bw.out
+}
+
+// cost returns the number of bits needed to encode
+// symbols with the given counts.
+func (h *huffEncoder) cost(counts *[256]uint32) int {
+	n := 0
+	for s, c := range counts {
+		n += int(c) * int(h.nbits[s])
+	}
+	return n
+}
@@ -0,0 +1,338 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// minMatch is the shortest match the encoder looks for.
+// The format permits matches of 3 bytes, but they rarely pay.
+const minMatch = 4
+
+// compressionLevel holds the parameters of a compression level.
+type compressionLevel struct {
+	windowLog int  // log2 of the largest match offset
+	hashLog   int  // log2 of the number of hash table entries
+	chainLog  int  // log2 of the number of hash chain entries; 0 for none
+	depth     int  // number of hash chain entries to search
+	nice      int  // stop searching at a match this long
+	lazy      bool // look for a longer match at the next byte
+}
+
+var levels = []compressionLevel{
+	{}, // 0 is not a valid level.
+	// BestSpeed uses a single hash table and skips ahead
+	// quickly through data that does not compress.
+	{19, 16, 0, 0, 0, false},
+	// Levels 2-9 search hash chains increasingly deeply,
+	// with lazy matching from level 3 up.
+	{20, 16, 16, 4, 32, false},
+	{21, 17, 16, 8, 64, true}, // DefaultCompression
+	{21, 17, 17, 16, 96, true},
+	{21, 17, 17, 32, 128, true},
+	{22, 18, 18, 64, 192, true},
+	{22, 18, 18, 128, 256, true},
+	{22, 18, 18, 256, 512, true},
+	{23, 18, 19, 512, 1024, true},
+}
+
+// encoder holds the state of a compressor across the blocks of a frame.
+type encoder struct {
+	compressionLevel
+
+	// hist holds the data that matches may refer to: the
+	// dictionary content and earlier blocks, followed by
+	// the current block.
+	hist []byte
+
+	// Hash tables. table maps a hash of 4 bytes to the most
+	// recent position in hist with that hash, plus one;
+	// chain maps a position to the previous position with the
+	// same hash, plus one. A zero entry is empty.
+	table []int32
+	chain []int32
+
+	// The dictionary whose content was last indexed,
+	// and the tables as they were then.
+	primed     *Dict
+	primeTable []int32
+	primeChain []int32
+
+	// The repeated offsets, as the decoder will see them.
+	reps [3]uint32
+
+	// The most recent match offset, for the match finder.
+	lastOffset int
+
+	// The sequences and literals for the current block.
+	seqs []seq
+	lits []byte
+
+	// Entropy coding state and scratch space.
+	huff     huffEncoder
+	seqEnc   [3]seqEncoder
+	litBuf   []byte
+	llCodes  []uint8
+	mlCodes  []uint8
+	ofCodes  []uint8
+	ofValues []uint32
+}
+
+func newEncoder(level int) *encoder {
+	e := &encoder{compressionLevel: levels[level]}
+	e.table = make([]int32, 1<<e.hashLog)
+	if e.chainLog > 0 {
+		e.chain = make([]int32, 1<<e.chainLog)
+	}
+	return e
+}
+
+// reset prepares e to compress a new frame using dict,
+// which may be nil.
+func (e *encoder) reset(dict *Dict) {
+	e.hist = e.hist[:0]
+	e.reps = [3]uint32{1, 4, 8}
+	e.lastOffset = 0
+	if dict == nil || len(dict.content) == 0 {
+		for i := range e.table {
+			e.table[i] = 0
+		}
+		for i := range e.chain {
+			e.chain[i] = 0
+		}
+		if dict != nil {
+			e.reps = dict.offsets
+		}
+		return
+	}
+
+	e.reps = dict.offsets
+	content := dict.content
+	if max := 1 << e.windowLog; len(content) > max {
+		content = content[len(content)-max:]
+	}
+	e.hist = append(e.hist, content...)
+	if e.primed == dict {
+		copy(e.table, e.primeTable)
+		copy(e.chain, e.primeChain)
+		return
+	}
+	for i := range e.table {
+		e.table[i] = 0
+	}
+	for i := range e.chain {
+		e.chain[i] = 0
+	}
+	for i := 0; i+minMatch <= len(e.hist); i++ {
+		e.insert(i)
+	}
+	e.primed = dict
+	e.primeTable = append(e.primeTable[:0], e.table...)
+	e.primeChain = append(e.primeChain[:0], e.chain...)
+}
+
+// encodeBlock compresses src as the next block of the frame,
+// appending it to dst.
+func (e *encoder) encodeBlock(dst, src []byte, last bool) []byte {
+	e.slide(len(src))
+	start := len(e.hist)
+	e.hist = append(e.hist, src...)
+	e.seqs = e.seqs[:0]
+	e.lits = e.lits[:0]
+	if len(src) >= minHuffLiterals {
+		if e.chain == nil {
+			e.matchFast(start)
+		} else {
+			e.matchChain(start)
+		}
+	}
+	return e.appendBlock(dst, src, last)
+}
+
+// slide discards old history, if necessary, to make room
+// for n more bytes.
+func (e *encoder) slide(n int) {
+	window := 1 << e.windowLog
+	if len(e.hist)+n <= 2*window+maxBlockSize {
+		return
+	}
+	// Keep at least a window of history, and move by a
+	// multiple of the window so that positions keep the
+	// same hash chain slots.
+	delta := (len(e.hist) - window) &^ (window - 1)
+	copy(e.hist, e.hist[delta:])
+	e.hist = e.hist[:len(e.hist)-delta]
+	adjust := func(t []int32) {
+		for i, v := range t {
+			if v -= int32(delta); v < 0 {
+				v = 0
+			}
+			t[i] = v
+		}
+	}
+	adjust(e.table)
+	adjust(e.chain)
+	e.primed = nil
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+func (e *encoder) hash(i int) uint32 {
+	return (load32(e.hist, i) * 2654435761) >> (32 - uint(e.hashLog))
+}
+
+// insert adds position i to the hash tables.
+func (e *encoder) insert(i int) {
+	h := e.hash(i)
+	if e.chain != nil {
+		e.chain[i&(len(e.chain)-1)] = e.table[h]
+	}
+	e.table[h] = int32(i + 1)
+}
+
+// matchLen returns the length of the common prefix of
+// hist[a:end] and hist[b:end], where a < b.
+func (e *encoder) matchLen(a, b, end int) int {
+	n := 0
+	for b+n+8 <= end {
+		x := binary.LittleEndian.Uint64(e.hist[a+n:]) ^ binary.LittleEndian.Uint64(e.hist[b+n:])
+		if x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+		n += 8
+	}
+	for b+n < end && e.hist[a+n] == e.hist[b+n] {
+		n++
+	}
+	return n
+}
+
+// emit records a match at position s of length length and
+// offset offset, preceded by the literals from nextEmit.
+func (e *encoder) emit(nextEmit, s, length, offset int) {
+	e.lits = append(e.lits, e.hist[nextEmit:s]...)
+	e.seqs = append(e.seqs, seq{
+		litLen:   uint32(s - nextEmit),
+		matchLen: uint32(length),
+		offset:   uint32(offset),
+	})
+	e.lastOffset = offset
+}
+
+// matchFast finds the sequences of the block in hist[start:]
+// using only the hash table, checking one candidate per position.
+func (e *encoder) matchFast(start int) {
+	end := len(e.hist)
+	window := 1 << e.windowLog
+	s, nextEmit := start, start
+	for s+minMatch <= end {
+		x := load32(e.hist, s)
+		h := e.hash(s)
+		cand := int(e.table[h]) - 1
+		e.table[h] = int32(s + 1)
+
+		// Prefer the previous offset, which is cheap to encode.
+		if o := e.lastOffset; o > 0 && s-o >= 0 && load32(e.hist, s-o) == x {
+			cand = s - o
+		} else if cand < 0 || s-cand > window || load32(e.hist, cand) != x {
+			// Skip ahead faster the longer we go without a match.
+			s += 1 + (s-nextEmit)>>6
+			continue
+		}
+
+		for s > nextEmit && cand > 0 && e.hist[s-1] == e.hist[cand-1] {
+			s--
+			cand--
+		}
+		length := minMatch + e.matchLen(cand+minMatch, s+minMatch, end)
+		e.emit(nextEmit, s, length, s-cand)
+		s += length
+		nextEmit = s
+		if s+minMatch <= end {
+			e.table[e.hash(s-2)] = int32(s - 2 + 1)
+		}
+	}
+	e.lits = append(e.lits, e.hist[nextEmit:]...)
+}
+
+// find returns the longest match for position s found by searching
+// the hash chain, and the position of the matching data.
+func (e *encoder) find(s, end int) (length, cand int) {
+	window := 1 << e.windowLog
+	if o := e.lastOffset; o > 0 && s-o >= 0 && load32(e.hist, s-o) == load32(e.hist, s) {
+		length = minMatch + e.matchLen(s-o+minMatch, s+minMatch, end)
+		cand = s - o
+		if length >= e.nice {
+			return length, cand
+		}
+	}
+	limit := s - window
+	if limit < 0 {
+		limit = 0
+	}
+	p := int(e.table[e.hash(s)]) - 1
+	for depth := e.depth; depth > 0 && p >= limit && p < s; depth-- {
+		if length < minMatch || (s+length < end && e.hist[p+length] == e.hist[s+length]) {
+			if n := e.matchLen(p, s, end); n > length {
+				length, cand = n, p
+				if n >= e.nice {
+					break
+				}
+			}
+		}
+		next := int(e.chain[p&(len(e.chain)-1)]) - 1
+		if next >= p {
+			break
+		}
+		p = next
+	}
+	return length, cand
+}
+
+// matchChain finds the sequences of the block in hist[start:]
+// by searching hash chains.
+func (e *encoder) matchChain(start int) {
+	end := len(e.hist)
+	s, nextEmit := start, start
+	inserted := start // positions before inserted are in the hash tables
+	for s+minMatch <= end {
+		length, cand := e.find(s, end)
+		e.insert(s)
+		inserted = s + 1
+		if length < minMatch {
+			s++
+			continue
+		}
+
+		// See whether waiting one byte finds a longer match.
+		for e.lazy && length < e.nice && s+1+minMatch <= end {
+			n, c := e.find(s+1, end)
+			if n <= length {
+				break
+			}
+			s++
+			e.insert(s)
+			inserted = s + 1
+			length, cand = n, c
+		}
+
+		for s > nextEmit && cand > 0 && e.hist[s-1] == e.hist[cand-1] {
+			s--
+			cand--
+			length++
+		}
+		e.emit(nextEmit, s, length, s-cand)
+		s += length
+		nextEmit = s
+		for ; inserted < s && inserted+minMatch <= end; inserted++ {
+			e.insert(inserted)
+		}
+	}
+	e.lits = append(e.lits, e.hist[nextEmit:]...)
+}
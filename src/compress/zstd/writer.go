@@ -0,0 +1,293 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// These constants are the compression levels accepted by
+// NewWriterLevel. Levels between BestSpeed and BestCompression
+// trade speed for compression; they are roughly comparable to the
+// levels of the reference implementation of the same number.
+const (
+	BestSpeed          = 1
+	BestCompression    = 9
+	DefaultCompression = 3
+)
+
+// A Writer takes data written to it and writes the compressed form
+// of that data to an underlying writer (see NewWriter).
+//
+// The data is written as a single frame with a content checksum.
+type Writer struct {
+	w     io.Writer
+	level int
+	dict  *Dict
+
+	enc         *encoder
+	buf         []byte // data not yet compressed
+	out         []byte // compressed output
+	wroteHeader bool
+	checksum    xxhash64
+	closed      bool
+	err         error
+}
+
+// NewWriter returns a new Writer.
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the Writer when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like NewWriter but specifies the compression level
+// instead of assuming DefaultCompression.
+//
+// The compression level can be DefaultCompression or any integer value
+// between BestSpeed and BestCompression inclusive.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterDict(w, level, nil)
+}
+
+// NewWriterDict is like NewWriterLevel but compresses using a
+// dictionary, which may be nil. A Reader can only decompress the
+// output if it is given the same dictionary.
+func NewWriterDict(w io.Writer, level int, dict *Dict) (*Writer, error) {
+	if level < BestSpeed || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	z := &Writer{level: level, dict: dict}
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the Writer z's state and makes it equivalent to the
+// result of its original state from NewWriter, NewWriterLevel or
+// NewWriterDict, but writing to w instead. This permits reusing a
+// Writer rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.buf = z.buf[:0]
+	z.out = z.out[:0]
+	z.wroteHeader = false
+	z.checksum.reset()
+	z.closed = false
+	z.err = nil
+}
+
+// Write writes a compressed form of p to the underlying io.Writer.
+// The compressed bytes are not necessarily flushed until
+// the Writer is closed.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errors.New("zstd: write to closed Writer")
+	}
+	if cap(z.buf) < maxBlockSize {
+		z.buf = make([]byte, 0, maxBlockSize)
+	}
+	n := len(p)
+	z.checksum.update(p)
+	for len(p) > 0 {
+		// Keep up to a full block buffered, so that
+		// Close can mark the final block.
+		if len(z.buf) == maxBlockSize {
+			z.encode(z.buf, false)
+			z.buf = z.buf[:0]
+			if err := z.writeOut(); err != nil {
+				return 0, err
+			}
+		}
+		m := copy(z.buf[len(z.buf):maxBlockSize], p)
+		z.buf = z.buf[:len(z.buf)+m]
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Flush writes any pending data to the underlying writer.
+// It is useful mainly in network protocols, to make sure that a
+// remote reader has enough data to reconstruct a packet. Flush does
+// not return until the data has been written. If the underlying
+// writer returns an error, Flush returns that error.
+//
+// Flush ends the current block, so frequent flushes
+// reduce compression.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if len(z.buf) > 0 || !z.wroteHeader {
+		z.encode(z.buf, false)
+		z.buf = z.buf[:0]
+	}
+	return z.writeOut()
+}
+
+// Close closes the Writer by flushing any unwritten data to the
+// underlying io.Writer and writing the frame's checksum.
+// It does not close the underlying io.Writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	z.encode(z.buf, true)
+	z.buf = z.buf[:0]
+	z.out = appendUint32(z.out, uint32(z.checksum.digest()))
+	return z.writeOut()
+}
+
+// encode compresses b as the next block of the frame,
+// writing the frame header first if needed.
+func (z *Writer) encode(b []byte, last bool) {
+	if z.enc == nil {
+		z.enc = newEncoder(z.level)
+	}
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		z.enc.reset(z.dict)
+		z.out = appendFrameHeader(z.out, z.dict, -1, z.enc.windowLog)
+	}
+	if len(b) == 0 && !last {
+		return
+	}
+	z.out = z.enc.encodeBlock(z.out, b, last)
+}
+
+// writeOut writes the compressed output to the underlying writer.
+func (z *Writer) writeOut() error {
+	if len(z.out) == 0 {
+		return nil
+	}
+	_, z.err = z.w.Write(z.out)
+	z.out = z.out[:0]
+	return z.err
+}
+
+// appendFrameHeader appends a frame header to dst. RFC 3.1.1.1.
+// The content size is unknown if size is negative. Frames are
+// single segment when the size is known and fits in the window.
+func appendFrameHeader(dst []byte, dict *Dict, size int64, windowLog int) []byte {
+	dst = appendUint32(dst, frameMagic)
+
+	const checksumFlag = 1 << 2
+	descriptor := byte(checksumFlag)
+
+	var dictID uint32
+	if dict != nil {
+		dictID = dict.id
+	}
+	var idSize int
+	switch {
+	case dictID == 0:
+	case dictID < 1<<8:
+		descriptor |= 1
+		idSize = 1
+	case dictID < 1<<16:
+		descriptor |= 2
+		idSize = 2
+	default:
+		descriptor |= 3
+		idSize = 4
+	}
+
+	singleSegment := size >= 0 && size <= 1<<windowLog
+	if singleSegment {
+		descriptor |= 1 << 5
+	}
+	var fcsSize int
+	switch {
+	case size < 0:
+	case size < 256 && singleSegment:
+		fcsSize = 1
+	case size < 256+1<<16:
+		descriptor |= 1 << 6
+		fcsSize = 2
+	case size < 1<<32:
+		descriptor |= 2 << 6
+		fcsSize = 4
+	default:
+		descriptor |= 3 << 6
+		fcsSize = 8
+	}
+	dst = append(dst, descriptor)
+
+	if !singleSegment {
+		// Window_Descriptor with a zero mantissa.
+		dst = append(dst, byte(windowLog-10)<<3)
+	}
+	for i := 0; i < idSize; i++ {
+		dst = append(dst, byte(dictID>>(8*uint(i))))
+	}
+	switch fcsSize {
+	case 1:
+		dst = append(dst, byte(size))
+	case 2:
+		dst = append(dst, byte(size-256), byte((size-256)>>8))
+	case 4:
+		dst = appendUint32(dst, uint32(size))
+	case 8:
+		dst = appendUint32(appendUint32(dst, uint32(size)), uint32(size>>32))
+	}
+	return dst
+}
+
+// encoderPools holds encoders used by EncodeAll, by level.
+var encoderPools [BestCompression + 1]sync.Pool
+
+// EncodeAll compresses src as a single frame and appends it to dst,
+// returning the updated slice. It uses the level and dictionary of z
+// but none of its other state, so unlike the other methods it may be
+// called concurrently, including while another goroutine is writing
+// to z. The frame records the size of src, which lets decoders
+// allocate exactly enough memory for small messages.
+func (z *Writer) EncodeAll(src, dst []byte) []byte {
+	pool := &encoderPools[z.level]
+	e, _ := pool.Get().(*encoder)
+	if e == nil {
+		e = newEncoder(z.level)
+	}
+	defer pool.Put(e)
+
+	e.reset(z.dict)
+	dst = appendFrameHeader(dst, z.dict, int64(len(src)), e.windowLog)
+	var xh xxhash64
+	xh.reset()
+	xh.update(src)
+	for {
+		n := len(src)
+		if n > maxBlockSize {
+			n = maxBlockSize
+		}
+		last := n == len(src)
+		dst = e.encodeBlock(dst, src[:n], last)
+		src = src[n:]
+		if last {
+			break
+		}
+	}
+	return appendUint32(dst, uint32(xh.digest()))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
@@ -0,0 +1,328 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writerInputs returns the inputs used to test the Writer.
+func writerInputs(t testing.TB) []struct {
+	name string
+	data []byte
+} {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 300<<10)
+	rnd.Read(random)
+
+	// Text built from a small vocabulary, so that there are
+	// many short matches at many different offsets.
+	var words bytes.Buffer
+	vocab := strings.Fields("the quick brown fox jumps over lazy dog and cat sat on mat while a bird sang")
+	for words.Len() < 200<<10 {
+		words.WriteString(vocab[rnd.Intn(len(vocab))])
+		words.WriteByte(" \n"[rnd.Intn(10)/9])
+	}
+
+	opticks := bigData(t)
+	opticks = opticks[:len(opticks)/20]
+
+	var sawtooth []byte
+	for i := 0; i < 400<<10; i++ {
+		sawtooth = append(sawtooth, byte(i%251), byte(i%7))
+	}
+
+	return []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"byte", []byte{'x'}},
+		{"hello", []byte("hello, world\n")},
+		{"short", []byte("abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz")},
+		{"zeros", make([]byte, 1<<20)},
+		{"random", random},
+		{"words", words.Bytes()},
+		{"opticks", opticks},
+		{"sawtooth", sawtooth},
+		{"ranges", []byte(tests[1].uncompressed)},
+	}
+}
+
+// compress compresses data with a Writer at level using dict,
+// writing in chunks of varying size.
+func compress(t testing.TB, data []byte, level int, dict *Dict) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterDict(&buf, level, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := 0, 1; len(data) > 0; i, n = i+1, n*3+i {
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	levels := []int{BestSpeed, DefaultCompression, BestCompression}
+	if !testing.Short() {
+		levels = nil
+		for level := BestSpeed; level <= BestCompression; level++ {
+			levels = append(levels, level)
+		}
+	}
+	for _, in := range writerInputs(t) {
+		for _, level := range levels {
+			t.Run(fmt.Sprintf("%s-%d", in.name, level), func(t *testing.T) {
+				compressed := compress(t, in.data, level, nil)
+				t.Logf("compressed %d bytes to %d", len(in.data), len(compressed))
+				got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, in.data) {
+					showDiffs(t, got, in.data)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterRatio(t *testing.T) {
+	data := bigData(t)
+	data = data[:len(data)/20]
+	for _, test := range []struct {
+		level int
+		ratio float64
+	}{
+		{BestSpeed, 0.45},
+		{DefaultCompression, 0.40},
+		{BestCompression, 0.38},
+	} {
+		compressed := compress(t, data, test.level, nil)
+		ratio := float64(len(compressed)) / float64(len(data))
+		if ratio > test.ratio {
+			t.Errorf("level %d: compressed %d bytes to %d (%.3f), want at most %.2f", test.level, len(data), len(compressed), ratio, test.ratio)
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	r := NewReader(&buf)
+	var want []byte
+	for i := 0; i < 20; i++ {
+		msg := []byte(fmt.Sprintf("message %d: %s\n", i, strings.Repeat("data ", i)))
+		want = append(want, msg...)
+		if _, err := w.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// The flushed data must be readable right away.
+		got := make([]byte, len(msg))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("message %d: got %q want %q", i, got, msg)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("unexpected data after Close: %q", rest)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	inputs := writerInputs(t)
+	var buf bytes.Buffer
+	w, err := NewWriterLevel(&buf, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range inputs {
+		buf.Reset()
+		w.Reset(&buf)
+		if _, err := w.Write(in.data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("x")); err == nil {
+			t.Errorf("%s: Write after Close succeeded", in.name)
+		}
+		got, err := io.ReadAll(NewReader(&buf))
+		if err != nil {
+			t.Fatalf("%s: %v", in.name, err)
+		}
+		if !bytes.Equal(got, in.data) {
+			t.Errorf("%s: round trip failed", in.name)
+		}
+	}
+}
+
+func TestWriterInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, 0, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func TestWriterDict(t *testing.T) {
+	dict := testDict(t)
+	data, err := os.ReadFile("testdata/e6adda05.json-records-dict.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = NewReaderDict(nil, dict).DecodeAll(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ParseDict(data[:len(data)/3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []*Dict{dict, raw} {
+		for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+			plain := compress(t, data, level, nil)
+			compressed := compress(t, data, level, d)
+			if len(compressed) >= len(plain) {
+				t.Errorf("dict %d, level %d: compressed to %d bytes with dictionary, %d without", d.ID(), level, len(compressed), len(plain))
+			}
+			got, err := io.ReadAll(NewReaderDict(bytes.NewReader(compressed), d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				showDiffs(t, got, data)
+			}
+		}
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	inputs := writerInputs(t)
+	dict := testDict(t)
+	w, err := NewWriterDict(nil, DefaultCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReaderDict(nil, dict)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, in := range inputs {
+				compressed := w.EncodeAll(in.data, []byte("prefix"))
+				if !bytes.HasPrefix(compressed, []byte("prefix")) {
+					t.Errorf("%s: EncodeAll dropped dst", in.name)
+					return
+				}
+				got, err := r.DecodeAll(compressed[len("prefix"):], nil)
+				if err != nil {
+					t.Errorf("%s: %v", in.name, err)
+					return
+				}
+				if !bytes.Equal(got, in.data) {
+					t.Errorf("%s: round trip failed", in.name)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Test that the reference implementation can decompress our output.
+// This only runs on systems with zstd installed.
+func TestWriterReference(t *testing.T) {
+	zstd := findZstd(t)
+	dir := t.TempDir()
+
+	dict := testDict(t)
+	w, err := NewWriterDict(nil, BestCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range writerInputs(t) {
+		for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+			name := fmt.Sprintf("%s-%d", in.name, level)
+			testReferenceDecode(t, zstd, dir, name, compress(t, in.data, level, nil), in.data)
+		}
+		testReferenceDecode(t, zstd, dir, in.name+"-dict", w.EncodeAll(in.data, nil), in.data)
+	}
+}
+
+func testReferenceDecode(t *testing.T, zstd, dir, name string, compressed, want []byte) {
+	file := filepath.Join(dir, name+".zst")
+	if err := os.WriteFile(file, compressed, 0666); err != nil {
+		t.Fatal(err)
+	}
+	abs, err := filepath.Abs("testdata/json.dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(zstd, "-d", "-c", "-D", abs, file)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	got, err := cmd.Output()
+	if err != nil {
+		t.Errorf("%s: zstd failed: %v\n%s", name, err, stderr.Bytes())
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: zstd decompressed data mismatch", name)
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := bigData(b)
+	data = data[:len(data)/20]
+	for _, level := range []int{BestSpeed, DefaultCompression, BestCompression} {
+		b.Run(fmt.Sprint(level), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			w, err := NewWriterLevel(io.Discard, level)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
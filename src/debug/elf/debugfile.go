@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package elf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ntGNUBuildID is the type of the GNU build ID note.
+const ntGNUBuildID = 3
+
+// debugFileDir is the global debug directory searched by DebugFile.
+var debugFileDir = "/usr/lib/debug"
+
+// maxDebugFileSize is the size of the largest candidate debug file
+// whose checksum DebugFile computes.
+const maxDebugFileSize = 1 << 32
+
+// ErrNoDebugFile is returned by File.DebugFile if f does not refer to
+// a separate debug file or the debug file cannot be found.
+var ErrNoDebugFile = errors.New("no separate debug file")
+
+// DebugFile opens the separate debug information file for f, as
+// created by "objcopy --only-keep-debug" or by the Go linker's
+// -debugfile option. The file is located in the same way as GDB
+// does: by the GNU build ID note of f, as
+// /usr/lib/debug/.build-id/xx/yyyy.debug, and by the name recorded
+// in the .gnu_debuglink section of f, in the directory containing f,
+// its .debug subdirectory, or the corresponding directory under
+// /usr/lib/debug. Candidate files must match the build ID or the
+// checksum recorded in f.
+//
+// Files found through .gnu_debuglink are relative to the location of
+// f, so they are only found if f was opened with Open or by passing
+// an *os.File to NewFile. The recorded name must be a plain file name;
+// names containing a path separator are ignored.
+//
+// Methods such as DWARF and Symbols do not consult the debug file;
+// callers that want its contents call DebugFile explicitly.
+func (f *File) DebugFile() (*File, error) {
+	if id := f.buildID(); id != nil {
+		h := fmt.Sprintf("%x", id)
+		if len(h) > 2 {
+			name := filepath.Join(debugFileDir, ".build-id", h[:2], h[2:]+".debug")
+			if df, err := Open(name); err == nil {
+				if bytes.Equal(df.buildID(), id) {
+					return df, nil
+				}
+				df.Close()
+			}
+		}
+	}
+
+	link, crc, ok := f.debugLink()
+	if !ok || f.name == "" {
+		return nil, ErrNoDebugFile
+	}
+	dir := filepath.Dir(f.name)
+	candidates := []string{
+		filepath.Join(dir, link),
+		filepath.Join(dir, ".debug", link),
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		candidates = append(candidates, filepath.Join(debugFileDir, abs, link))
+	}
+	for _, name := range candidates {
+		if name == f.name {
+			continue
+		}
+		if df, err := openDebugLink(name, crc); err == nil {
+			return df, nil
+		}
+	}
+	return nil, ErrNoDebugFile
+}
+
+// openDebugLink opens the file name if its CRC-32 checksum is crc.
+func openDebugLink(name string, crc uint32) (*File, error) {
+	of, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := of.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxDebugFileSize {
+		of.Close()
+		return nil, ErrNoDebugFile
+	}
+	h := crc32.NewIEEE()
+	if n, err := io.Copy(h, io.LimitReader(of, fi.Size())); err != nil || n != fi.Size() || h.Sum32() != crc {
+		of.Close()
+		return nil, ErrNoDebugFile
+	}
+	df, err := NewFile(of)
+	if err != nil {
+		of.Close()
+		return nil, err
+	}
+	df.closer = of
+	return df, nil
+}
+
+// buildID returns the contents of the GNU build ID note of f, or nil.
+func (f *File) buildID() []byte {
+	for _, s := range f.Sections {
+		if s.Type != SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			continue
+		}
+		for len(data) >= 12 {
+			namesz := f.ByteOrder.Uint32(data)
+			descsz := f.ByteOrder.Uint32(data[4:])
+			typ := f.ByteOrder.Uint32(data[8:])
+			desc := 12 + (uint64(namesz)+3)&^3
+			next := desc + (uint64(descsz)+3)&^3
+			if desc+uint64(descsz) > uint64(len(data)) {
+				break
+			}
+			if typ == ntGNUBuildID && string(data[12:12+namesz]) == "GNU\x00" {
+				return data[desc : desc+uint64(descsz)]
+			}
+			if next > uint64(len(data)) {
+				break
+			}
+			data = data[next:]
+		}
+	}
+	return nil
+}
+
+// debugLink returns the file name and CRC-32 checksum recorded in
+// the .gnu_debuglink section of f.
+func (f *File) debugLink() (name string, crc uint32, ok bool) {
+	s := f.Section(".gnu_debuglink")
+	if s == nil {
+		return "", 0, false
+	}
+	data, err := s.Data()
+	if err != nil {
+		return "", 0, false
+	}
+	i := bytes.IndexByte(data, 0)
+	if i <= 0 {
+		return "", 0, false
+	}
+	off := (i + 4) &^ 3
+	if off+4 > len(data) {
+		return "", 0, false
+	}
+	name = string(data[:i])
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", 0, false
+	}
+	return name, f.ByteOrder.Uint32(data[off:]), true
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package elf
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withDebugLink returns a copy of sections with a .gnu_debuglink
+// section recording name and crc.
+func withDebugLink(f *File, sections []*Section, name string, crc uint32) []*Section {
+	data := append([]byte(name), 0)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	data = append(data, 0, 0, 0, 0)
+	f.ByteOrder.PutUint32(data[len(data)-4:], crc)
+	r := bytes.NewReader(data)
+	s := &Section{
+		SectionHeader: SectionHeader{Name: ".gnu_debuglink", Type: SHT_PROGBITS, Size: uint64(len(data))},
+		ReaderAt:      r,
+		sr:            io.NewSectionReader(r, 0, int64(len(data))),
+	}
+	return append(append([]*Section(nil), sections...), s)
+}
+
+func TestDebugFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestDebugFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(old string) { debugFileDir = old }(debugFileDir)
+	debugFileDir = filepath.Join(dir, "global")
+
+	exec, err := ioutil.ReadFile("testdata/gcc-amd64-linux-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug, err := ioutil.ReadFile("testdata/go-relocation-test-gcc441-x86-64.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc := crc32.ChecksumIEEE(debug)
+	for _, d := range []string{"bin", "bin/.debug", "lib"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string][]byte{
+		"bin/exec":                 exec,
+		"bin/.debug/exec.debug":    debug,
+		"lib/escape.debug":         debug,
+		"bin/.debug/corrupt.debug": append(debug[:len(debug):len(debug)], 0),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := Open(filepath.Join(dir, "bin/exec"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Drop the DWARF sections of f, which are in the debug file.
+	var sections []*Section
+	for _, s := range f.Sections {
+		if !strings.HasPrefix(s.Name, ".debug_") {
+			sections = append(sections, s)
+		}
+	}
+	f.Sections = sections
+
+	if _, err := f.DebugFile(); err != ErrNoDebugFile {
+		t.Errorf("DebugFile without .gnu_debuglink: got %v, want ErrNoDebugFile", err)
+	}
+
+	f.Sections = withDebugLink(f, sections, "exec.debug", crc)
+	df, err := f.DebugFile()
+	if err != nil {
+		t.Fatalf("DebugFile: %v", err)
+	}
+	if want := filepath.Join(dir, "bin/.debug/exec.debug"); df.name != want {
+		t.Errorf("DebugFile opened %s, want %s", df.name, want)
+	}
+	if df.Section(".debug_info") == nil {
+		t.Errorf("debug file has no .debug_info section")
+	}
+	df.Close()
+
+	// DWARF does not look for the debug file.
+	if _, err := f.DWARF(); err == nil {
+		t.Errorf("DWARF succeeded on a file without DWARF sections")
+	}
+
+	testCases := []struct {
+		name string
+		crc  uint32
+	}{
+		{"exec.debug", crc + 1},
+		{"corrupt.debug", crc},
+		{"../lib/escape.debug", crc},
+		{"..", crc},
+		{"missing.debug", crc},
+	}
+	for _, tc := range testCases {
+		f.Sections = withDebugLink(f, sections, tc.name, tc.crc)
+		if df, err := f.DebugFile(); err != ErrNoDebugFile {
+			if err == nil {
+				df.Close()
+			}
+			t.Errorf("DebugFile with link %q and crc %#x: got %v, want ErrNoDebugFile", tc.name, tc.crc, err)
+		}
+	}
+
+	// A file not opened by name has no directory to search.
+	nf, err := NewFile(bytes.NewReader(exec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nf.Sections = withDebugLink(nf, nf.Sections, "exec.debug", crc)
+	if _, err := nf.DebugFile(); err != ErrNoDebugFile {
+		t.Errorf("DebugFile of unnamed file: got %v, want ErrNoDebugFile", err)
+	}
+}
@@ -53,6 +53,7 @@ type File struct {
 	Sections  []*Section
 	Progs     []*Prog
 	closer    io.Closer
+	name      string // file name, if known; used by DebugFile
 	gnuNeed   []verneed
 	gnuVersym []byte
 }
@@ -249,6 +250,9 @@ func NewFile(r io.ReaderAt) (*File, error) {
 	}
 
 	f := new(File)
+	if of, ok := r.(*os.File); ok {
+		f.name = of.Name()
+	}
 	f.Class = Class(ident[EI_CLASS])
 	switch f.Class {
 	case ELFCLASS32:
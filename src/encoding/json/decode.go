@@ -44,7 +44,11 @@ import (
 // keys to the keys used by Marshal (either the struct field name or its tag),
 // preferring an exact match but also accepting a case-insensitive match. By
 // default, object keys which don't have a corresponding struct field are
-// ignored (see Decoder.DisallowUnknownFields for an alternative).
+// ignored (see Decoder.DisallowUnknownFields for an alternative), or stored
+// in the field with the "unknown" option, if the struct has one.
+//
+// UnmarshalOptions.Unmarshal is like Unmarshal, with options that
+// change some of these rules.
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -213,6 +217,8 @@ type decodeState struct {
 	savedError            error
 	useNumber             bool
 	disallowUnknownFields bool
+	matchCaseSensitive    bool
+	unmarshalers          *Unmarshalers
 }
 
 // readIndex returns the position of the last byte read.
@@ -351,6 +357,12 @@ Switch:
 // reads the following byte ahead. If v is invalid, the value is discarded.
 // The first byte of the value has been read already.
 func (d *decodeState) value(v reflect.Value) error {
+	if d.unmarshalers != nil {
+		if fn, p := d.unmarshalers.lookup(v, d.data[d.readIndex()] == 'n'); fn.IsValid() {
+			return d.unmarshalWith(fn, p)
+		}
+	}
+
 	switch d.opcode {
 	default:
 		panic(phasePanicMsg)
@@ -657,6 +669,7 @@ func (d *decodeState) object(v reflect.Value) error {
 	}
 
 	var mapElem reflect.Value
+	var unknown, unknownElem reflect.Value // field with the unknown option
 	origErrorContext := d.errorContext
 
 	for {
@@ -682,6 +695,8 @@ func (d *decodeState) object(v reflect.Value) error {
 		// Figure out field corresponding to key.
 		var subv reflect.Value
 		destring := false // whether the value is wrapped in a string to be decoded first
+		format := ""      // format option of the field
+		isUnknown := false
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -696,7 +711,7 @@ func (d *decodeState) object(v reflect.Value) error {
 			if i, ok := fields.nameIndex[string(key)]; ok {
 				// Found an exact name match.
 				f = &fields.list[i]
-			} else {
+			} else if !d.matchCaseSensitive {
 				// Fall back to the expensive case-insensitive
 				// linear search.
 				for i := range fields.list {
@@ -708,32 +723,28 @@ func (d *decodeState) object(v reflect.Value) error {
 				}
 			}
 			if f != nil {
-				subv = v
-				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.value(subv) skips over
-								// the JSON value without assigning it to subv.
-								subv = reflect.Value{}
-								destring = false
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
+				subv = d.fieldByIndex(v, f.index)
+				if subv.IsValid() {
+					destring = f.quoted
+					format = f.format
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
+			} else if fields.unknown != nil {
+				if !unknown.IsValid() {
+					unknown = d.fieldByIndex(v, fields.unknown.index)
+					if unknown.IsValid() {
+						if unknown.IsNil() {
+							unknown.Set(reflect.MakeMap(unknown.Type()))
+						}
+						unknownElem = reflect.New(unknown.Type().Elem()).Elem()
+					}
+				}
+				if unknown.IsValid() {
+					unknownElem.Set(reflect.Zero(unknownElem.Type()))
+					subv = unknownElem
+					isUnknown = true
+				}
 			} else if d.disallowUnknownFields {
 				d.saveError(fmt.Errorf("json: unknown field %q", key))
 			}
@@ -761,12 +772,20 @@ func (d *decodeState) object(v reflect.Value) error {
 			default:
 				d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", subv.Type()))
 			}
+		} else if format != "" {
+			if err := d.formatValue(subv, format); err != nil {
+				return err
+			}
 		} else {
 			if err := d.value(subv); err != nil {
 				return err
 			}
 		}
 
+		if isUnknown {
+			unknown.SetMapIndex(reflect.ValueOf(string(key)).Convert(unknown.Type().Key()), subv)
+		}
+
 		// Write value back to map;
 		// if using struct, subv points into struct already.
 		if v.Kind() == reflect.Map {
@@ -827,6 +846,33 @@ func (d *decodeState) object(v reflect.Value) error {
 	return nil
 }
 
+// fieldByIndex returns the field of the struct v with the index
+// sequence index, allocating the embedded structs that lead to it as
+// needed. If one of them cannot be allocated, fieldByIndex saves an
+// error and returns the zero Value, so that value skips the JSON value
+// without assigning it.
+func (d *decodeState) fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
 // depending on the setting of d.useNumber.
 func (d *decodeState) convertNumber(s string) (interface{}, error) {
@@ -103,6 +103,36 @@ import (
 //
 //    Int64String int64 `json:",string"`
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if it is zero: if its type has an IsZero() bool
+// method, such as time.Time, when that method reports true, and
+// otherwise when the field has the zero value of its type.
+//
+// The "inline" option, on a field of struct or struct pointer type,
+// causes its fields to be treated as fields of the outer struct, as if
+// it were an anonymous struct field.
+//
+// The "unknown" option, on a field of map type with string keys, causes
+// the entries of the map to be encoded as members of the outer object,
+// after the other fields, and the object members that match no field to
+// be stored in the map by Unmarshal. A key of the map must not be the
+// name of another field.
+//
+// The "format:name" option selects another encoding for fields of some
+// types, or pointers to them:
+//   - a time.Duration with "units" encodes as a string such as "1h30m0s",
+//     and with "sec", "milli", "micro", or "nano" as a number of seconds,
+//     milliseconds, microseconds, or nanoseconds
+//   - a time.Time with "unix", "unixmilli", "unixmicro", or "unixnano"
+//     encodes as the number of seconds, milliseconds, microseconds, or
+//     nanoseconds since January 1, 1970 UTC, and with the name of a
+//     layout of package time, such as "RFC1123", or a layout without
+//     commas, such as "2006-01-02", as a string in that layout
+//   - a []byte with "base64", "base64url", "base32", "base32hex", or
+//     "base16" encodes as a string with that encoding, and with "array"
+//     as an array of numbers
+// The format takes precedence over the Marshaler and "string" options.
+//
 // The key name will be used if it's a non-empty string consisting of
 // only Unicode letters, digits, and ASCII punctuation except quotation
 // marks, backslash, and comma.
@@ -356,8 +386,43 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+// isZeroer is the interface of values that report whether they are
+// zero, for the omitzero option.
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// zeroFunc returns the function that reports whether a value of type t
+// is zero, for the omitzero option: its IsZero method, if it has one,
+// or else reflect.Value.IsZero.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	if !t.Implements(isZeroerType) {
+		return reflect.Value.IsZero
+	}
+	return func(v reflect.Value) bool {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return true
+		}
+		if !v.CanInterface() {
+			return v.IsZero()
+		}
+		return v.Interface().(isZeroer).IsZero()
+	}
+}
+
 func (e *encodeState) reflectValue(v reflect.Value, opts encOpts) {
-	valueEncoder(v)(e, v, opts)
+	e.encodeValue(valueEncoder(v), v, opts)
+}
+
+// encodeValue encodes v with enc, or with the function from
+// opts.marshalers for its type, if there is one.
+func (e *encodeState) encodeValue(enc encoderFunc, v reflect.Value, opts encOpts) {
+	if opts.marshalers != nil && opts.marshalers.encode(e, v, opts) {
+		return
+	}
+	enc(e, v, opts)
 }
 
 type encOpts struct {
@@ -365,6 +430,8 @@ type encOpts struct {
 	quoted bool
 	// escapeHTML causes '<', '>', and '&' to be escaped in JSON strings.
 	escapeHTML bool
+	// marshalers holds the functions from MarshalOptions.
+	marshalers *Marshalers
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
@@ -727,6 +794,7 @@ type structEncoder struct {
 type structFields struct {
 	list      []field
 	nameIndex map[string]int
+	unknown   *field // field with the unknown option, or nil
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
@@ -750,6 +818,9 @@ FieldLoop:
 		if f.omitEmpty && isEmptyValue(fv) {
 			continue
 		}
+		if f.isZero != nil && f.isZero(fv) {
+			continue
+		}
 		e.WriteByte(next)
 		next = ','
 		if opts.escapeHTML {
@@ -758,7 +829,11 @@ FieldLoop:
 			e.WriteString(f.nameNonEsc)
 		}
 		opts.quoted = f.quoted
-		f.encoder(e, fv, opts)
+		e.encodeValue(f.encoder, fv, opts)
+	}
+	if se.fields.unknown != nil {
+		opts.quoted = false
+		next = se.encodeUnknown(e, v, next, opts)
 	}
 	if next == '{' {
 		e.WriteString("{}")
@@ -767,6 +842,39 @@ FieldLoop:
 	}
 }
 
+// encodeUnknown encodes the members of the map in the field of v with
+// the unknown option, after the other fields, and returns the next
+// separator.
+func (se structEncoder) encodeUnknown(e *encodeState, v reflect.Value, next byte, opts encOpts) byte {
+	f := se.fields.unknown
+	for _, i := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return next
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.IsNil() {
+		return next
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, k := range keys {
+		name := k.String()
+		if _, ok := se.fields.nameIndex[name]; ok {
+			e.error(&UnsupportedValueError{v, fmt.Sprintf("key %q of field %s duplicates a struct field", name, f.name)})
+		}
+		e.WriteByte(next)
+		next = ','
+		e.string(name, opts.escapeHTML)
+		e.WriteByte(':')
+		e.encodeValue(f.encoder, v.MapIndex(k), opts)
+	}
+	return next
+}
+
 func newStructEncoder(t reflect.Type) encoderFunc {
 	se := structEncoder{fields: cachedTypeFields(t)}
 	return se.encode
@@ -810,7 +918,7 @@ func (me mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		}
 		e.string(kv.s, opts.escapeHTML)
 		e.WriteByte(':')
-		me.elemEnc(e, v.MapIndex(kv.v), opts)
+		e.encodeValue(me.elemEnc, v.MapIndex(kv.v), opts)
 	}
 	e.WriteByte('}')
 	e.ptrLevel--
@@ -912,7 +1020,7 @@ func (ae arrayEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		if i > 0 {
 			e.WriteByte(',')
 		}
-		ae.elemEnc(e, v.Index(i), opts)
+		e.encodeValue(ae.elemEnc, v.Index(i), opts)
 	}
 	e.WriteByte(']')
 }
@@ -941,7 +1049,7 @@ func (pe ptrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.ptrSeen[ptr] = struct{}{}
 		defer delete(e.ptrSeen, ptr)
 	}
-	pe.elemEnc(e, v.Elem(), opts)
+	e.encodeValue(pe.elemEnc, v.Elem(), opts)
 	e.ptrLevel--
 }
 
@@ -1182,9 +1290,12 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
 	quoted    bool
+	format    string // from the format option
 
 	encoder encoderFunc
+	isZero  func(reflect.Value) bool // for omitZero
 }
 
 // byIndex sorts field by index sequence.
@@ -1223,6 +1334,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Fields with the unknown option found.
+	var unknowns []field
+
 	// Buffer to run HTMLEscape on field names.
 	var nameEscBuf bytes.Buffer
 
@@ -1286,8 +1400,24 @@ func typeFields(t reflect.Type) structFields {
 					}
 				}
 
+				format, _ := opts.Value("format")
+
+				// A field of a map type with string keys can hold the
+				// object members that match no other field.
+				if opts.Contains("unknown") && sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+					unknowns = append(unknowns, field{name: sf.Name, index: index, typ: sf.Type})
+					if count[f.typ] > 1 {
+						unknowns = append(unknowns, unknowns[len(unknowns)-1])
+					}
+					continue
+				}
+
+				// A struct field with the inline option is explored
+				// like an embedded struct.
+				inline := opts.Contains("inline") && ft.Kind() == reflect.Struct
+
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if !inline && (name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct) {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
@@ -1298,7 +1428,9 @@ func typeFields(t reflect.Type) structFields {
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
-						quoted:    quoted,
+						omitZero:  opts.Contains("omitzero"),
+						quoted:    quoted && format == "",
+						format:    format,
 					}
 					field.nameBytes = []byte(field.name)
 					field.equalFold = foldFunc(field.nameBytes)
@@ -1381,13 +1513,29 @@ func typeFields(t reflect.Type) structFields {
 
 	for i := range fields {
 		f := &fields[i]
-		f.encoder = typeEncoder(typeByIndex(t, f.index))
+		ft := typeByIndex(t, f.index)
+		if f.format != "" {
+			f.encoder = newFormatEncoder(ft, f.format)
+		} else {
+			f.encoder = typeEncoder(ft)
+		}
+		if f.omitZero {
+			f.isZero = zeroFunc(ft)
+		}
 	}
 	nameIndex := make(map[string]int, len(fields))
 	for i, field := range fields {
 		nameIndex[field.name] = i
 	}
-	return structFields{fields, nameIndex}
+
+	// The least nested field with the unknown option is used, unless
+	// there are several of them.
+	var unknown *field
+	if len(unknowns) == 1 || len(unknowns) > 1 && len(unknowns[0].index) < len(unknowns[1].index) {
+		unknown = &unknowns[0]
+		unknown.encoder = typeEncoder(unknown.typ.Elem())
+	}
+	return structFields{fields, nameIndex, unknown}
 }
 
 // dominantField looks through the fields, all of which are known to
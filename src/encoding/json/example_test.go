@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"strings"
+	"time"
 )
 
 func ExampleMarshal() {
@@ -308,3 +310,50 @@ func ExampleHTMLEscape() {
 	// Output:
 	//{"Name":"\u003cb\u003eHTML content\u003c/b\u003e"}
 }
+
+func ExampleMarshalOptions() {
+	type Event struct {
+		Name     string
+		Start    time.Time     `json:",format:2006-01-02"`
+		Duration time.Duration `json:",format:units"`
+		Tags     []string      `json:",omitzero"`
+		Place    net.IP
+	}
+	// Encode IP addresses with their Go syntax, in place of the
+	// encoding of their MarshalText method.
+	opts := json.MarshalOptions{
+		Marshalers: json.MarshalFuncs(func(ip net.IP) ([]byte, error) {
+			return json.Marshal(fmt.Sprintf("%#v", []byte(ip.To4())))
+		}),
+	}
+	b, err := opts.Marshal(Event{
+		Name:     "Meetup",
+		Start:    time.Date(2020, 10, 19, 18, 0, 0, 0, time.UTC),
+		Duration: 90 * time.Minute,
+		Place:    net.IPv4(10, 0, 0, 1),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+	// Output:
+	// {"Name":"Meetup","Start":"2020-10-19","Duration":"1h30m0s","Place":"[]byte{0xa, 0x0, 0x0, 0x1}"}
+}
+
+func ExampleUnmarshalOptions() {
+	type Config struct {
+		Name  string            `json:"name"`
+		Extra map[string]string `json:",unknown"`
+	}
+	opts := json.UnmarshalOptions{MatchCaseSensitive: true, RejectDuplicateNames: true}
+
+	var c Config
+	err := opts.Unmarshal([]byte(`{"name": "a", "Name": "b", "mode": "fast"}`), &c)
+	fmt.Printf("%q %q %v\n", c.Name, c.Extra, err)
+
+	err = opts.Unmarshal([]byte(`{"name": "a", "name": "b"}`), &c)
+	fmt.Println(err)
+	// Output:
+	// "a" map["Name":"b" "mode":"fast"] <nil>
+	// jsontext: duplicate object name "name" at offset 14 (in /name)
+}
@@ -0,0 +1,419 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements the format option of struct fields, which
+// selects another encoding for values of some types.
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// durationFormats maps the number formats of a time.Duration to the
+// number of digits of nanoseconds in their fraction.
+var durationFormats = map[string]int{
+	"sec":   9,
+	"milli": 6,
+	"micro": 3,
+	"nano":  0,
+}
+
+// unixFormats maps the Unix time formats of a time.Time to the number
+// of decimal digits of a second in their integer part.
+var unixFormats = map[string]int{
+	"unix":      0,
+	"unixmilli": 3,
+	"unixmicro": 6,
+	"unixnano":  9,
+}
+
+// timeLayouts maps the names of the layouts in package time to them.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+}
+
+// timeLayout returns the layout of a time.Time with format. A format
+// that is not the name of a layout is a layout itself.
+func timeLayout(format string) string {
+	if layout, ok := timeLayouts[format]; ok {
+		return layout
+	}
+	return format
+}
+
+// A byteEncoding is an encoding of []byte as a string.
+type byteEncoding interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+var byteEncodings = map[string]byteEncoding{
+	"base64":    base64.StdEncoding,
+	"base64url": base64.URLEncoding,
+	"base32":    base32.StdEncoding,
+	"base32hex": base32.HexEncoding,
+	"base16":    base16Encoding{},
+}
+
+// base16Encoding is the hexadecimal encoding of format:base16.
+type base16Encoding struct{}
+
+func (base16Encoding) EncodeToString(src []byte) string {
+	dst := make([]byte, 2*len(src))
+	for i, c := range src {
+		dst[2*i] = hex[c>>4]
+		dst[2*i+1] = hex[c&0xF]
+	}
+	return string(dst)
+}
+
+func (base16Encoding) DecodeString(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, errors.New("json: odd length hexadecimal string")
+	}
+	dst := make([]byte, len(s)/2)
+	for i := range dst {
+		hi, ok1 := unhex(s[2*i])
+		lo, ok2 := unhex(s[2*i+1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("json: invalid hexadecimal string %q", s)
+		}
+		dst[i] = hi<<4 | lo
+	}
+	return dst, nil
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// isByteSlice reports whether t is a slice of bytes, which the format
+// option applies to.
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// newFormatEncoder returns the encoder for values of type t in the
+// struct field with the option format:format.
+func newFormatEncoder(t reflect.Type, format string) encoderFunc {
+	switch {
+	case t.Kind() == reflect.Ptr:
+		enc := ptrEncoder{newFormatEncoder(t.Elem(), format)}
+		return enc.encode
+
+	case t == durationType:
+		if format == "units" {
+			return func(e *encodeState, v reflect.Value, _ encOpts) {
+				e.WriteByte('"')
+				e.WriteString(time.Duration(v.Int()).String())
+				e.WriteByte('"')
+			}
+		}
+		digits, ok := durationFormats[format]
+		if !ok {
+			break
+		}
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			n := v.Int()
+			u := uint64(n)
+			if n < 0 {
+				u = -u
+			}
+			pow := pow10(digits)
+			b := appendDecimal(e.scratch[:0], n < 0, u/pow, u%pow, digits)
+			e.Write(b)
+		}
+
+	case t == timeType:
+		if scale, ok := unixFormats[format]; ok {
+			return func(e *encodeState, v reflect.Value, _ encOpts) {
+				tm := v.Interface().(time.Time)
+				sec, nsec := tm.Unix(), int64(tm.Nanosecond())
+				neg := sec < 0
+				if neg && nsec > 0 {
+					sec, nsec = sec+1, 1e9-nsec
+				}
+				u := uint64(sec)
+				if neg {
+					u = -u
+				}
+				pow := pow10(9 - scale)
+				whole := u*pow10(scale) + uint64(nsec)/pow
+				b := appendDecimal(e.scratch[:0], neg, whole, uint64(nsec)%pow, 9-scale)
+				e.Write(b)
+			}
+		}
+		layout := timeLayout(format)
+		return func(e *encodeState, v reflect.Value, opts encOpts) {
+			e.string(v.Interface().(time.Time).Format(layout), opts.escapeHTML)
+		}
+
+	case isByteSlice(t) && format == "array":
+		enc := sliceEncoder{newArrayEncoder(t)}
+		return enc.encode
+
+	case isByteSlice(t):
+		be, ok := byteEncodings[format]
+		if !ok {
+			break
+		}
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			if v.IsNil() {
+				e.WriteString("null")
+				return
+			}
+			e.WriteByte('"')
+			e.WriteString(be.EncodeToString(v.Bytes()))
+			e.WriteByte('"')
+		}
+	}
+	return func(e *encodeState, v reflect.Value, _ encOpts) {
+		e.error(fmt.Errorf("json: invalid format %q for type %v", format, t))
+	}
+}
+
+// formatValue is like value, for a struct field with the option
+// format:format.
+func (d *decodeState) formatValue(v reflect.Value, format string) error {
+	start := d.readIndex()
+	isNull := d.data[start] == 'n'
+	if d.unmarshalers != nil {
+		if fn, p := d.unmarshalers.lookup(v, isNull); fn.IsValid() {
+			return d.unmarshalWith(fn, p)
+		}
+	}
+	if isNull {
+		return d.value(v)
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+
+	if isByteSlice(t) && format == "array" {
+		if d.opcode == scanBeginArray {
+			return d.value(v)
+		}
+	} else if d.opcode == scanBeginLiteral {
+		d.rescanLiteral()
+		item := d.data[start:d.readIndex()]
+		err := d.formatStore(item, v, format)
+		if err != nil {
+			d.saveError(err)
+		}
+		return nil
+	}
+
+	val := "object"
+	switch d.opcode {
+	case scanBeginArray:
+		val = "array"
+	case scanBeginLiteral:
+		val = "string"
+		if d.data[start] != '"' {
+			val = "number"
+		}
+	}
+	d.saveError(&UnmarshalTypeError{Value: val, Type: t, Offset: int64(d.off)})
+	if d.opcode == scanBeginLiteral {
+		d.rescanLiteral()
+	} else {
+		d.skip()
+		d.scanNext()
+	}
+	return nil
+}
+
+// formatStore decodes the literal item into v, for a struct field with
+// the option format:format.
+func (d *decodeState) formatStore(item []byte, v reflect.Value, format string) error {
+	t := v.Type()
+	typeError := func() error {
+		val := "number " + string(item)
+		switch item[0] {
+		case '"':
+			val = "string " + string(item)
+		case 't', 'f':
+			val = "bool"
+		}
+		return &UnmarshalTypeError{Value: val, Type: t, Offset: int64(d.readIndex())}
+	}
+	var s string
+	if item[0] == '"' {
+		b, ok := unquoteBytes(item)
+		if !ok {
+			panic(phasePanicMsg)
+		}
+		s = string(b)
+	}
+
+	switch {
+	case t == durationType:
+		if format == "units" {
+			if item[0] != '"' {
+				return typeError()
+			}
+			dur, err := time.ParseDuration(s)
+			if err != nil {
+				return typeError()
+			}
+			v.SetInt(int64(dur))
+			return nil
+		}
+		digits, ok := durationFormats[format]
+		if !ok {
+			break
+		}
+		neg, whole, frac, ok := parseDecimal(string(item), digits)
+		pow := pow10(digits)
+		if !ok || whole > (1<<63-1-frac)/pow {
+			return typeError()
+		}
+		n := int64(whole*pow + frac)
+		if neg {
+			n = -n
+		}
+		v.SetInt(n)
+		return nil
+
+	case t == timeType:
+		var tm time.Time
+		if scale, ok := unixFormats[format]; ok {
+			neg, whole, frac, ok := parseDecimal(string(item), 9-scale)
+			pow := pow10(scale)
+			if !ok || whole/pow > 1<<63-1 {
+				return typeError()
+			}
+			sec := int64(whole / pow)
+			nsec := int64(whole%pow*pow10(9-scale) + frac)
+			if neg {
+				sec, nsec = -sec, -nsec
+			}
+			tm = time.Unix(sec, nsec)
+		} else {
+			if item[0] != '"' {
+				return typeError()
+			}
+			var err error
+			if tm, err = time.Parse(timeLayout(format), s); err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+
+	case isByteSlice(t):
+		be, ok := byteEncodings[format]
+		if !ok {
+			break
+		}
+		if item[0] != '"' {
+			return typeError()
+		}
+		b, err := be.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(b)
+		return nil
+	}
+	return fmt.Errorf("json: invalid format %q for type %v", format, t)
+}
+
+func pow10(n int) uint64 {
+	p := uint64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// appendDecimal appends to b the decimal number with the integer part
+// whole and the fraction frac / 10^digits, without trailing zeros.
+func appendDecimal(b []byte, neg bool, whole, frac uint64, digits int) []byte {
+	if neg {
+		b = append(b, '-')
+	}
+	b = strconv.AppendUint(b, whole, 10)
+	if frac == 0 {
+		return b
+	}
+	b = append(b, '.')
+	n := len(b)
+	// Adding 10^digits pads the fraction with leading zeros, after a 1.
+	b = strconv.AppendUint(b, frac+pow10(digits), 10)
+	b = append(b[:n], b[n+1:]...)
+	for b[len(b)-1] == '0' {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// parseDecimal parses the JSON number s, which must not have an
+// exponent, into its integer part and the first digits digits of its
+// fraction, as an integer.
+func parseDecimal(s string, digits int) (neg bool, whole, frac uint64, ok bool) {
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	fs := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, fs = s[:i], s[i+1:]
+	}
+	whole, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return false, 0, 0, false
+	}
+	for i := 0; i < len(fs) || i < digits; i++ {
+		c := byte('0')
+		if i < len(fs) {
+			c = fs[i]
+		}
+		if c < '0' || '9' < c {
+			return false, 0, 0, false
+		}
+		if i < digits {
+			frac = frac*10 + uint64(c-'0')
+		}
+	}
+	return neg, whole, frac, true
+}
@@ -0,0 +1,395 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+)
+
+// A Decoder reads a stream of JSON values, one token or value at a
+// time. The stream is a sequence of top-level values, separated by
+// optional whitespace. Reading a token or a value checks that the
+// stream so far is valid JSON, as described by RFC 8259.
+//
+// By default, a Decoder accepts objects with duplicate names and
+// strings with invalid UTF-8, as package json does. The
+// DisallowDuplicateNames and DisallowInvalidUTF8 methods make it
+// reject them, as RFC 8259 recommends.
+type Decoder struct {
+	state
+	r    io.Reader
+	rerr error  // error from r
+	err  error  // syntax or read error that stopped the decoder
+	buf  []byte // data read from r
+	pos  int    // start of unread data in buf
+	keep int    // start of the value being read by ReadValue, or -1
+	base int64  // offset of buf[0] in the input
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+//
+// The Decoder introduces its own buffering and may read data from r
+// beyond the JSON values requested.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.Reset(r)
+	return d
+}
+
+// resetBytes resets d to read b, with the options o.
+func (d *Decoder) resetBytes(b []byte, o options) {
+	d.Reset(nil)
+	d.buf = b
+	d.options = o
+}
+
+// Reset resets d to read from r, keeping its options.
+func (d *Decoder) Reset(r io.Reader) {
+	d.state.reset(d.options)
+	d.r = r
+	d.rerr = nil
+	d.err = nil
+	if d.r == nil {
+		d.buf = nil
+	} else {
+		d.buf = d.buf[:0]
+	}
+	d.pos = 0
+	d.keep = -1
+	d.base = 0
+}
+
+// DisallowDuplicateNames causes the Decoder to return an error when an
+// object has the same name twice.
+func (d *Decoder) DisallowDuplicateNames() { d.rejectDuplicateNames = true }
+
+// DisallowInvalidUTF8 causes the Decoder to return an error when a
+// string contains invalid UTF-8, or an escaped surrogate half that is
+// not part of a pair.
+func (d *Decoder) DisallowInvalidUTF8() { d.rejectInvalidUTF8 = true }
+
+// InputOffset returns the offset in the input of the end of the last
+// token or value read, where the next one begins, after whitespace.
+func (d *Decoder) InputOffset() int64 {
+	return d.base + int64(d.pos)
+}
+
+// StackDepth returns the number of objects and arrays that the Decoder
+// is in.
+func (d *Decoder) StackDepth() int {
+	return d.depth()
+}
+
+// StackPointer returns a JSON Pointer (RFC 6901) to the last value,
+// or object name, read: the names of the objects members and the
+// indexes of the array elements that lead to it from the top-level
+// value. It is the empty string at the top level.
+func (d *Decoder) StackPointer() string {
+	return d.pointer(false)
+}
+
+// PeekKind returns the kind of the next token, without reading it.
+// It returns 0 if there is no next token, because of an error or of
+// the end of the input; the error is returned by the next read.
+func (d *Decoder) PeekKind() Kind {
+	k, _, err := d.peek()
+	if err != nil {
+		return 0
+	}
+	return k
+}
+
+// ReadToken reads the next token. At the end of the input, between
+// top-level values, it returns io.EOF.
+//
+// The Token refers to the Decoder's buffer, and is only valid until
+// the next call to the Decoder. It does not allocate.
+func (d *Decoder) ReadToken() (Token, error) {
+	k, off, err := d.peek()
+	if err != nil {
+		return Token{}, err
+	}
+	return d.read(k, off)
+}
+
+// ReadValue reads the next value, which may be an object or array
+// holding other values, and returns its encoding, which may contain
+// whitespace. At the end of the input, between top-level values, it
+// returns io.EOF. An object name is read as a string value.
+//
+// The Value refers to the Decoder's buffer, and is only valid until
+// the next call to the Decoder.
+func (d *Decoder) ReadValue() (Value, error) {
+	d.keep = d.pos
+	defer func() { d.keep = -1 }()
+	k, off, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	if k == KindEndObject || k == KindEndArray {
+		return nil, d.syntaxError(off, errors.New("unexpected "+quoteChar(byte(k))+" looking for beginning of value"))
+	}
+	// The start of the value, relative to d.keep, which the buffer
+	// keeps in place.
+	start := d.pos + off - d.keep
+	if err := d.skip(k, off); err != nil {
+		return nil, err
+	}
+	v := d.buf[d.keep+start : d.pos]
+	return v[:len(v):len(v)], nil
+}
+
+// SkipValue reads the next value, like ReadValue, without returning it.
+func (d *Decoder) SkipValue() error {
+	k, off, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if k == KindEndObject || k == KindEndArray {
+		return d.syntaxError(off, errors.New("unexpected "+quoteChar(byte(k))+" looking for beginning of value"))
+	}
+	return d.skip(k, off)
+}
+
+// skip reads the value starting with the token of kind k at off.
+func (d *Decoder) skip(k Kind, off int) error {
+	depth := d.depth()
+	for {
+		_, err := d.read(k, off)
+		if err != nil {
+			return err
+		}
+		if d.depth() == depth {
+			return nil
+		}
+		if k, off, err = d.peek(); err != nil {
+			if err == io.EOF {
+				err = d.syntaxError(0, errUnexpectedEOF)
+			}
+			return err
+		}
+	}
+}
+
+var errUnexpectedEOF = errors.New("unexpected end of JSON input")
+
+// peek finds the next token, after whitespace and the separator
+// before it, and returns its kind and its offset from d.pos.
+func (d *Decoder) peek() (Kind, int, error) {
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	off, err := d.skipSpace(0)
+	if err != nil {
+		if err == io.EOF && d.depth() > 0 {
+			err = d.syntaxError(off, errUnexpectedEOF)
+		}
+		return 0, 0, err
+	}
+	var sep byte
+	want := d.separator()
+	if c := d.buf[d.pos+off]; c == ',' || c == ':' {
+		if c != want {
+			return 0, 0, d.syntaxError(off, errors.New("invalid character "+quoteChar(c)+" "+d.context()))
+		}
+		sep = c
+		if off, err = d.skipSpace(off + 1); err != nil {
+			if err == io.EOF {
+				err = d.tokenError(off, errUnexpectedEOF)
+			}
+			return 0, 0, err
+		}
+	}
+	// From here on, when sep is what the next token needs, the error is
+	// in the next token, rather than between it and the last one.
+	c := d.buf[d.pos+off]
+	k := kindOf(c)
+	if k == 0 {
+		if sep == want {
+			return 0, 0, d.tokenError(off, errors.New("invalid character "+quoteChar(c)+" "+d.context()))
+		}
+		return 0, 0, d.syntaxError(off, errors.New("invalid character "+quoteChar(c)+" "+d.context()))
+	}
+	if k == KindEndObject || k == KindEndArray {
+		if sep != 0 {
+			return 0, 0, d.tokenError(off, errors.New("invalid character "+quoteChar(c)+" after "+quoteChar(sep)))
+		}
+	} else if sep != want {
+		return 0, 0, d.syntaxError(off, errors.New("invalid character "+quoteChar(c)+" "+d.context()))
+	}
+	if _, err := d.check(k); err != nil {
+		if k == KindEndObject || k == KindEndArray {
+			return 0, 0, d.syntaxError(off, err)
+		}
+		return 0, 0, d.tokenError(off, err)
+	}
+	return k, off, nil
+}
+
+// separator returns the separator that the next token needs, unless it
+// ends an object or an array.
+func (d *Decoder) separator() byte {
+	f := d.top()
+	switch {
+	case f.kind == KindBeginObject && f.n%2 == 1:
+		return ':'
+	case f.kind != 0 && f.n > 0:
+		return ','
+	}
+	return 0
+}
+
+// context describes where the decoder is, for errors.
+func (d *Decoder) context() string {
+	f := d.top()
+	switch {
+	case f.kind == KindBeginObject && f.n%2 == 1:
+		return "after object name (expecting ':')"
+	case f.kind == KindBeginObject && f.n > 0:
+		return "after object member (expecting ',' or '}')"
+	case f.kind == KindBeginObject:
+		return "looking for beginning of object name or '}'"
+	case f.kind == KindBeginArray && f.n > 0:
+		return "after array element (expecting ',' or ']')"
+	}
+	return "looking for beginning of value"
+}
+
+// skipSpace returns the offset from d.pos of the first byte at or after
+// off that is not whitespace, reading more data as needed. It returns
+// io.EOF at the end of the input.
+func (d *Decoder) skipSpace(off int) (int, error) {
+	for {
+		for b := d.buf[d.pos:]; off < len(b); off++ {
+			switch b[off] {
+			case ' ', '\t', '\r', '\n':
+				continue
+			}
+			return off, nil
+		}
+		if err := d.fill(); err != nil {
+			if err != io.EOF {
+				d.err = err
+			}
+			return off, err
+		}
+	}
+}
+
+// read reads the token of kind k at offset off from d.pos.
+func (d *Decoder) read(k Kind, off int) (Token, error) {
+	var n int
+	var err error
+	for {
+		b := d.buf[d.pos+off:]
+		switch k {
+		case KindNull:
+			n, err = consumeLiteral(b, "null")
+		case KindFalse:
+			n, err = consumeLiteral(b, "false")
+		case KindTrue:
+			n, err = consumeLiteral(b, "true")
+		case KindString:
+			n, err = consumeString(b, d.rejectInvalidUTF8)
+		case KindNumber:
+			n, err = consumeNumber(b, d.r == nil || d.rerr != nil)
+		default:
+			n = 1
+		}
+		if err != errIncomplete {
+			break
+		}
+		if ferr := d.fill(); ferr != nil && ferr != io.EOF {
+			d.err = ferr
+			return Token{}, ferr
+		} else if ferr == io.EOF && k != KindNumber {
+			return Token{}, d.tokenError(off+len(b), errUnexpectedEOF)
+		}
+	}
+	if err != nil {
+		return Token{}, d.tokenError(off+n, err)
+	}
+	raw := d.buf[d.pos+off : d.pos+off+n : d.pos+off+n]
+	if _, err := d.push(k, raw); err != nil {
+		return Token{}, d.syntaxError(off, err)
+	}
+	d.pos += off + n
+	t := Token{kind: k}
+	if k == KindString || k == KindNumber {
+		t.raw = raw
+	}
+	return t, nil
+}
+
+// fill reads more data into d.buf. It returns io.EOF at the end of the
+// input.
+func (d *Decoder) fill() error {
+	if d.r == nil {
+		return io.EOF
+	}
+	if d.rerr != nil {
+		return d.rerr
+	}
+
+	// Drop the data that is no longer needed.
+	start := d.pos
+	if d.keep >= 0 && d.keep < start {
+		start = d.keep
+	}
+	if start > 0 {
+		n := copy(d.buf, d.buf[start:])
+		d.buf = d.buf[:n]
+		d.base += int64(start)
+		d.pos -= start
+		if d.keep >= 0 {
+			d.keep -= start
+		}
+	}
+
+	const minRead = 512
+	if cap(d.buf)-len(d.buf) < minRead {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+minRead)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	for i := 0; i < 100; i++ {
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err != nil {
+			d.rerr = err
+		}
+		if n > 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	d.rerr = io.ErrNoProgress
+	return d.rerr
+}
+
+// syntaxError returns a SyntaxError for err at offset off from d.pos,
+// and stops the decoder.
+func (d *Decoder) syntaxError(off int, err error) error {
+	d.err = &SyntaxError{
+		msg:     err.Error(),
+		Offset:  d.base + int64(d.pos+off),
+		Pointer: d.pointer(false),
+	}
+	return d.err
+}
+
+// tokenError is like syntaxError, for an error in the token at or
+// before off rather than between it and the last token.
+func (d *Decoder) tokenError(off int, err error) error {
+	d.err = &SyntaxError{
+		msg:     err.Error(),
+		Offset:  d.base + int64(d.pos+off),
+		Pointer: d.pointer(true),
+	}
+	return d.err
+}
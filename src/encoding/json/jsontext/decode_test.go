@@ -0,0 +1,346 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokenStrings reads the tokens of in and formats them, with a '/'
+// after each.
+func tokenStrings(d *Decoder) (string, error) {
+	var b strings.Builder
+	for {
+		t, err := d.ReadToken()
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return b.String(), err
+		}
+		if t.Kind() == KindString {
+			b.WriteString(strconvQuote(t.String()))
+		} else {
+			b.WriteString(t.String())
+		}
+		b.WriteByte('/')
+	}
+}
+
+func strconvQuote(s string) string {
+	b, _ := appendQuote(nil, s, false)
+	return string(b)
+}
+
+var readTokenTests = []struct {
+	in   string
+	want string
+}{
+	{``, ``},
+	{` null true false `, `null/true/false/`},
+	{`1 -2.5e+3 0 0.25 1E2`, `1/-2.5e+3/0/0.25/1E2/`},
+	{`"" "a\"b" "é😀\n" "é"`, `""/"a\"b"/"é😀\n"/"é"/`},
+	{`{"a": [1, {}, []], "b": {"c": null}}`, `{/"a"/[/1/{/}/[/]/]/"b"/{/"c"/null/}/}/`},
+	{"[\n\t1 ,\r\n2 ]", `[/1/2/]/`},
+	{`{}{}[]`, `{/}/{/}/[/]/`},
+	{"\"\\ud800\" \"\xff\"", `"�"/"�"/`},
+	{`{"a":1,"a":2}`, `{/"a"/1/"a"/2/}/`},
+}
+
+func TestReadToken(t *testing.T) {
+	for _, tt := range readTokenTests {
+		// Read the input a byte at a time too, so that every token
+		// is split across reads.
+		for _, r := range []io.Reader{strings.NewReader(tt.in), iotest.OneByteReader(strings.NewReader(tt.in))} {
+			got, err := tokenStrings(NewDecoder(r))
+			if err != nil || got != tt.want {
+				t.Errorf("tokens of %#q = %#q, %v; want %#q", tt.in, got, err, tt.want)
+			}
+		}
+	}
+}
+
+var syntaxErrorTests = []struct {
+	in      string
+	msg     string
+	offset  int64
+	pointer string
+}{
+	{`[1 2]`, "invalid character '2' after array element (expecting ',' or ']')", 3, "/0"},
+	{`{"a" 1}`, "invalid character '1' after object name (expecting ':')", 5, "/a"},
+	{`{"a":1 "b":2}`, "invalid character '\"' after object member (expecting ',' or '}')", 7, "/a"},
+	{`{1:2}`, "object name must be a string, not number", 1, ""},
+	{`[1,]`, "invalid character ']' after ','", 3, "/1"},
+	{`{"a":}`, "invalid character '}' after ':'", 5, "/a"},
+	{`{"a"}`, "missing value after object name", 4, "/a"},
+	{`[}`, "unexpected '}'", 1, ""},
+	{`]`, "unexpected ']'", 0, ""},
+	{`nul`, "unexpected end of JSON input", 3, ""},
+	{`nulx`, "invalid character 'x' in literal null (expecting 'l')", 3, ""},
+	{`[1, 01]`, "invalid character '1' after array element (expecting ',' or ']')", 5, "/1"},
+	{`-`, "unexpected end of JSON input (expecting digit)", 1, ""},
+	{`1.e3`, "invalid character 'e' in numeric literal", 2, ""},
+	{`{"a": [true, "x`, "unexpected end of JSON input", 15, "/a/1"},
+	{"\"a\x01\"", "invalid character '\\x01' in string literal", 2, ""},
+	{`"\x"`, "invalid character 'x' in string escape code", 2, ""},
+	{`"\u12G4"`, "invalid character 'G' in \\u hexadecimal character escape", 5, ""},
+	{`[1] x`, "invalid character 'x' looking for beginning of value", 4, ""},
+	{`[`, "unexpected end of JSON input", 1, ""},
+
+	// Errors inside an array element or object member point to it,
+	// not to the one before.
+	{`[tru]`, "invalid character ']' in literal true (expecting 'e')", 4, "/0"},
+	{`[1,2,tru]`, "invalid character ']' in literal true (expecting 'e')", 8, "/2"},
+	{`[1,2,x]`, "invalid character 'x' after array element (expecting ',' or ']')", 5, "/2"},
+	{`[x]`, "invalid character 'x' looking for beginning of value", 1, "/0"},
+	{`[1, -]`, "invalid character ']' in numeric literal", 5, "/1"},
+	{`[1,"a`, "unexpected end of JSON input", 5, "/1"},
+	{`[1,`, "unexpected end of JSON input", 3, "/1"},
+	{`[[1],[2,nul]]`, "invalid character ']' in literal null (expecting 'l')", 11, "/1/1"},
+	{`{"a":[1],"b":tru}`, "invalid character '}' in literal true (expecting 'e')", 16, "/b"},
+	{`{"a":1,"b":{"c":[0,"\x"]}}`, "invalid character 'x' in string escape code", 21, "/b/c/1"},
+	{`{"a":1,"b`, "unexpected end of JSON input", 9, ""},
+	{`{"a":1,}`, "invalid character '}' after ','", 7, ""},
+}
+
+func TestReadTokenError(t *testing.T) {
+	for _, tt := range syntaxErrorTests {
+		d := NewDecoder(iotest.OneByteReader(strings.NewReader(tt.in)))
+		_, err := tokenStrings(d)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%#q: error %v, want SyntaxError", tt.in, err)
+			continue
+		}
+		if serr.msg != tt.msg || serr.Offset != tt.offset || serr.Pointer != tt.pointer {
+			t.Errorf("%#q: error %q at %d in %q, want %q at %d in %q", tt.in, serr.msg, serr.Offset, serr.Pointer, tt.msg, tt.offset, tt.pointer)
+		}
+		// The error sticks.
+		if _, err2 := d.ReadToken(); err2 != err {
+			t.Errorf("%#q: error %v after %v", tt.in, err2, err)
+		}
+	}
+}
+
+func TestDecoderStrict(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		msg     string
+		pointer string
+	}{
+		{`{"a":1,"b":{"a":2},"a":3}`, `duplicate object name "a"`, "/a"},
+		{`{"a":1,"a":3}`, `duplicate object name "a"`, "/a"},
+		{"[\"\xff\"]", "invalid UTF-8 in string", "/0"},
+		{"[1,\"\xff\"]", "invalid UTF-8 in string", "/1"},
+		{"{\"a\":[1,2],\"b\":[\"\xff\"]}", "invalid UTF-8 in string", "/b/0"},
+		{"{\"a\":1,\"\xff\":2}", "invalid UTF-8 in string", ""},
+		{`"\ud800"`, "invalid UTF-8 in string", ""},
+		{`"\udc00\ud800"`, "invalid UTF-8 in string", ""},
+		{`"\ud800A"`, "invalid UTF-8 in string", ""},
+	} {
+		d := NewDecoder(strings.NewReader(tt.in))
+		d.DisallowDuplicateNames()
+		d.DisallowInvalidUTF8()
+		_, err := tokenStrings(d)
+		if serr, ok := err.(*SyntaxError); !ok || serr.msg != tt.msg || serr.Pointer != tt.pointer {
+			t.Errorf("%#q: error %v, want %q in %q", tt.in, err, tt.msg, tt.pointer)
+		}
+	}
+	d := NewDecoder(strings.NewReader(`{"a":{"b":1},"b":[{"b":2,"a":3}],"😀":"😀"}`))
+	d.DisallowDuplicateNames()
+	d.DisallowInvalidUTF8()
+	if _, err := tokenStrings(d); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadValue(t *testing.T) {
+	in := ` {"a": [1, 2], "b" : {"c": "d"}} "e" 3 `
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	var got []string
+	for {
+		v, err := d.ReadValue()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(v))
+	}
+	want := []string{`{"a": [1, 2], "b" : {"c": "d"}}`, `"e"`, `3`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("values %q, want %q", got, want)
+	}
+
+	// Values and tokens mix.
+	d = NewDecoder(strings.NewReader(in))
+	for _, want := range []string{"{", `"a"`, `[1, 2]`, `"b"`} {
+		var got string
+		if want == "{" || want == `"a"` {
+			tok, err := d.ReadToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = tok.String()
+			if want == `"a"` {
+				got = strconvQuote(got)
+			}
+		} else {
+			v, err := d.ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = string(v)
+		}
+		if got != want {
+			t.Errorf("read %#q, want %#q", got, want)
+		}
+	}
+	if d.StackPointer() != "/b" || d.StackDepth() != 1 {
+		t.Errorf("at %q, depth %d; want /b, 1", d.StackPointer(), d.StackDepth())
+	}
+	if k := d.PeekKind(); k != KindBeginObject {
+		t.Errorf("PeekKind = %v, want {", k)
+	}
+	if err := d.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+	if k := d.PeekKind(); k != KindEndObject {
+		t.Errorf("PeekKind = %v, want }", k)
+	}
+	if _, err := d.ReadValue(); err == nil {
+		t.Error("ReadValue at end of object succeeded")
+	}
+}
+
+func TestInputOffset(t *testing.T) {
+	in := `{"a": [1, "b"]} 2`
+	d := NewDecoder(strings.NewReader(in))
+	var offsets []int64
+	for {
+		if _, err := d.ReadToken(); err != nil {
+			break
+		}
+		offsets = append(offsets, d.InputOffset())
+	}
+	want := []int64{1, 4, 7, 8, 13, 14, 15, 17}
+	if !int64sEqual(offsets, want) {
+		t.Errorf("offsets %v, want %v", offsets, want)
+	}
+}
+
+func int64sEqual(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStackPointer(t *testing.T) {
+	in := `{"a/b": [0, {"~c": 1}], "": 2}`
+	d := NewDecoder(strings.NewReader(in))
+	var got []string
+	for {
+		if _, err := d.ReadToken(); err != nil {
+			break
+		}
+		got = append(got, d.StackPointer())
+	}
+	want := "|/a~1b|/a~1b|/a~1b/0|/a~1b/1|/a~1b/1/~0c|/a~1b/1/~0c|/a~1b/1|/a~1b|/|/|"
+	if s := strings.Join(got, "|"); s != want {
+		t.Errorf("pointers %q, want %q", s, want)
+	}
+}
+
+func TestTokenValues(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`[12, -3.5, 1e400, 18446744073709551615, -1, true, "x"]`))
+	var toks []Token
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks = append(toks, tok.Clone())
+	}
+	if toks[1].Int() != 12 || toks[1].Float() != 12 || toks[1].Uint() != 12 {
+		t.Errorf("12 = %d, %g, %d", toks[1].Int(), toks[1].Float(), toks[1].Uint())
+	}
+	if toks[2].Int() != -3 || toks[2].Float() != -3.5 || toks[2].Uint() != 0 {
+		t.Errorf("-3.5 = %d, %g, %d", toks[2].Int(), toks[2].Float(), toks[2].Uint())
+	}
+	if toks[3].Int() != 1<<63-1 || toks[3].Uint() != 1<<64-1 {
+		t.Errorf("1e400 = %d, %d", toks[3].Int(), toks[3].Uint())
+	}
+	if toks[4].Uint() != 1<<64-1 || toks[4].Int() != 1<<63-1 {
+		t.Errorf("2^64-1 = %d, %d", toks[4].Uint(), toks[4].Int())
+	}
+	if toks[5].Int() != -1 {
+		t.Errorf("-1 = %d", toks[5].Int())
+	}
+	if !toks[6].Bool() || toks[7].String() != "x" {
+		t.Errorf("true, x = %v, %q", toks[6].Bool(), toks[7].String())
+	}
+}
+
+func TestReadTokenAllocs(t *testing.T) {
+	in := []byte(strings.Repeat(`{"name": "value\n", "n": [1.5, true, null]} `, 100))
+	r := bytes.NewReader(in)
+	d := NewDecoder(r)
+	allocs := testing.AllocsPerRun(10, func() {
+		r.Reset(in)
+		d.Reset(r)
+		for {
+			if _, err := d.ReadToken(); err != nil {
+				break
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations reading tokens", allocs)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read error") }
+
+func TestDecoderReadError(t *testing.T) {
+	d := NewDecoder(io.MultiReader(strings.NewReader(`[1, 2`), errReader{}))
+	_, err := tokenStrings(d)
+	if err == nil || err.Error() != "read error" {
+		t.Errorf("error %v, want read error", err)
+	}
+}
+
+func BenchmarkReadToken(b *testing.B) {
+	in := []byte(strings.Repeat(`{"time": "2020-10-19T12:00:00Z", "level": "info", "msg": "request served", "ms": 12.5, "ok": true}`+"\n", 1000))
+	r := bytes.NewReader(in)
+	d := NewDecoder(r)
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(in)
+		d.Reset(r)
+		for {
+			if _, err := d.ReadToken(); err != nil {
+				break
+			}
+		}
+	}
+}
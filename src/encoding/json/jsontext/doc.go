@@ -0,0 +1,16 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsontext reads and writes JSON text, as defined by RFC 8259,
+// one token or value at a time, without converting it to Go values as
+// package encoding/json does.
+//
+// A Decoder reads a stream of JSON values as Tokens, such as strings,
+// numbers and the delimiters of objects and arrays, or as the encoded
+// Values themselves. Tokens and Values read by a Decoder refer to its
+// buffer, so that reading them does not allocate. An Encoder writes
+// Tokens and Values, adding the separators between them. Both check
+// that the stream is valid JSON, and track where they are in it, as an
+// offset and as a JSON Pointer (RFC 6901), which their errors report.
+package jsontext
@@ -0,0 +1,253 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+)
+
+// An Encoder writes a stream of JSON values, one token or value at a
+// time. It checks that what it writes is valid JSON, as described by
+// RFC 8259, and supplies the separators between tokens. It ends each
+// top-level value with a newline.
+//
+// The output is buffered, and written to the underlying io.Writer at
+// the end of each top-level value, or when the buffer is large.
+//
+// By default, an Encoder accepts objects with duplicate names, and
+// writes invalid UTF-8 in strings as U+FFFD, as package json does.
+// The DisallowDuplicateNames and DisallowInvalidUTF8 methods make it
+// reject them instead, as RFC 8259 recommends.
+type Encoder struct {
+	state
+	w    io.Writer
+	err  error  // error from w
+	buf  []byte // output not yet written to w
+	base int64  // offset of buf[0] in the output
+
+	prefix, indent string
+	indented       bool
+
+	dec Decoder // reads the tokens of values for WriteValue
+}
+
+// flushSize is the size of the output above which an Encoder writes
+// it out before the end of a top-level value.
+const flushSize = 1 << 16
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	e := new(Encoder)
+	e.Reset(w)
+	return e
+}
+
+// Reset resets e to write to w, keeping its options. Output not yet
+// written to the previous io.Writer is discarded.
+func (e *Encoder) Reset(w io.Writer) {
+	e.state.reset(e.options)
+	e.w = w
+	e.err = nil
+	e.buf = e.buf[:0]
+	e.base = 0
+}
+
+// SetIndent makes the Encoder write each object member and array
+// element on a new line, beginning with prefix followed by one copy of
+// indent for each level of nesting. Calling SetIndent("", "") turns
+// indentation off.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix, e.indent = prefix, indent
+	e.indented = prefix != "" || indent != ""
+}
+
+// DisallowDuplicateNames causes the Encoder to return an error when an
+// object would have the same name twice.
+func (e *Encoder) DisallowDuplicateNames() { e.rejectDuplicateNames = true }
+
+// DisallowInvalidUTF8 causes the Encoder to return an error when a
+// string contains invalid UTF-8.
+func (e *Encoder) DisallowInvalidUTF8() { e.rejectInvalidUTF8 = true }
+
+// OutputOffset returns the offset in the output of the end of the last
+// token or value written.
+func (e *Encoder) OutputOffset() int64 {
+	return e.base + int64(len(e.buf))
+}
+
+// StackDepth returns the number of objects and arrays that the Encoder
+// is in.
+func (e *Encoder) StackDepth() int {
+	return e.depth()
+}
+
+// StackPointer returns a JSON Pointer (RFC 6901) to the last value, or
+// object name, written. See Decoder.StackPointer.
+func (e *Encoder) StackPointer() string {
+	return e.pointer(false)
+}
+
+// WriteToken writes the next token. If the token is not valid where it
+// is written, it returns a *SyntaxError and writes nothing.
+func (e *Encoder) WriteToken(t Token) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.write(t); err != nil {
+		return err
+	}
+	return e.maybeFlush()
+}
+
+// WriteValue writes the next value, which must be a single valid JSON
+// value. It is written without its whitespace, or indented as set by
+// SetIndent. If the value is not valid, or not valid where it is
+// written, WriteValue returns a *SyntaxError and writes nothing.
+func (e *Encoder) WriteValue(v Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	// Check the value before writing any of it.
+	d := &e.dec
+	if err := v.validate(d, e.options); err != nil {
+		if serr, ok := err.(*SyntaxError); ok {
+			serr.Offset += e.OutputOffset()
+		}
+		return err
+	}
+	d.resetBytes(v, e.options)
+	for {
+		t, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = e.write(t)
+		}
+		if err != nil {
+			// Only the first token can be out of place.
+			return err
+		}
+	}
+	return e.maybeFlush()
+}
+
+// write appends the token t to the output.
+func (e *Encoder) write(t Token) error {
+	k := t.kind
+	if k == 0 {
+		return e.tokenError(errors.New("invalid token"))
+	}
+	sep, err := e.check(k)
+	if err != nil {
+		return e.syntaxError(err)
+	}
+	n0 := len(e.buf)
+	b := e.buf
+	if sep != 0 {
+		b = append(b, sep)
+	}
+	if e.indented {
+		switch {
+		case k == KindEndObject || k == KindEndArray:
+			if e.top().n > 0 {
+				b = e.appendIndent(b, e.depth()-1)
+			}
+		case sep == ':':
+			b = append(b, ' ')
+		case e.depth() > 0:
+			b = e.appendIndent(b, e.depth())
+		}
+	}
+	start := len(b)
+	switch k {
+	case KindNull:
+		b = append(b, "null"...)
+	case KindFalse:
+		b = append(b, "false"...)
+	case KindTrue:
+		b = append(b, "true"...)
+	case KindString:
+		if t.raw != nil {
+			if e.rejectInvalidUTF8 {
+				if _, err = consumeString(t.raw, true); err != nil {
+					break
+				}
+			}
+			b = append(b, t.raw...)
+		} else {
+			b, err = appendQuote(b, t.str, e.rejectInvalidUTF8)
+		}
+	case KindNumber:
+		if t.raw != nil {
+			b = append(b, t.raw...)
+		} else if b, _ = t.appendNumber(b); len(b) == start {
+			err = errors.New("invalid number " + t.String())
+		}
+	default:
+		b = append(b, byte(k))
+	}
+	if err != nil {
+		e.buf = b[:n0]
+		return e.tokenError(err)
+	}
+	done, err := e.push(k, b[start:])
+	if err != nil {
+		e.buf = b[:n0]
+		return e.syntaxError(err)
+	}
+	if done {
+		b = append(b, '\n')
+	}
+	e.buf = b
+	return nil
+}
+
+func (e *Encoder) appendIndent(b []byte, depth int) []byte {
+	b = append(b, '\n')
+	b = append(b, e.prefix...)
+	for i := 0; i < depth; i++ {
+		b = append(b, e.indent...)
+	}
+	return b
+}
+
+// maybeFlush writes out the output at the end of a top-level value, or
+// when it is large.
+func (e *Encoder) maybeFlush() error {
+	if e.w == nil || e.depth() > 0 && len(e.buf) < flushSize {
+		return nil
+	}
+	n, err := e.w.Write(e.buf)
+	if err == nil && n < len(e.buf) {
+		err = io.ErrShortWrite
+	}
+	e.base += int64(n)
+	e.buf = e.buf[:copy(e.buf, e.buf[n:])]
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
+
+// syntaxError returns a SyntaxError for err at the end of the output.
+func (e *Encoder) syntaxError(err error) error {
+	return &SyntaxError{
+		msg:     err.Error(),
+		Offset:  e.OutputOffset(),
+		Pointer: e.pointer(false),
+	}
+}
+
+// tokenError is like syntaxError, for an error in the token being
+// written rather than in where it is written.
+func (e *Encoder) tokenError(err error) error {
+	return &SyntaxError{
+		msg:     err.Error(),
+		Offset:  e.OutputOffset(),
+		Pointer: e.pointer(true),
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriteToken(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for _, tok := range []Token{
+		BeginObject,
+		String("a"), BeginArray, Int(-1), Uint(math.MaxUint64), Float(0.5), Float(1e21), Float(1e-7), EndArray,
+		String("b\n\"\x01\xff"), BeginObject, EndObject,
+		String("c"), Bool(true),
+		EndObject,
+		Null,
+		String("é"),
+	} {
+		if err := e.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `{"a":[-1,18446744073709551615,0.5,1e+21,1e-7],"b\n\"\u0001�":{},"c":true}` + "\nnull\n\"é\"\n"
+	if buf.String() != want {
+		t.Errorf("got %#q, want %#q", buf.String(), want)
+	}
+	if e.OutputOffset() != int64(buf.Len()) {
+		t.Errorf("OutputOffset = %d, want %d", e.OutputOffset(), buf.Len())
+	}
+}
+
+func TestWriteTokenError(t *testing.T) {
+	for _, tt := range []struct {
+		toks    []Token
+		msg     string
+		pointer string
+	}{
+		{[]Token{EndArray}, "unexpected ']'", ""},
+		{[]Token{BeginArray, EndObject}, "unexpected '}'", ""},
+		{[]Token{BeginObject, Int(1)}, "object name must be a string, not number", ""},
+		{[]Token{BeginObject, String("a"), EndObject}, "missing value after object name", "/a"},
+		{[]Token{BeginArray, Float(math.NaN())}, "invalid number NaN", "/0"},
+		{[]Token{BeginArray, Int(1), {}}, "invalid token", "/1"},
+		{[]Token{BeginArray, Int(1), Int(2), Float(math.Inf(1))}, "invalid number +Inf", "/2"},
+		{[]Token{BeginObject, String("a"), BeginArray, Int(1), Float(math.NaN())}, "invalid number NaN", "/a/1"},
+		{[]Token{BeginObject, String("a"), Float(math.NaN())}, "invalid number NaN", "/a"},
+	} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		var err error
+		for _, tok := range tt.toks {
+			if err = e.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok || serr.msg != tt.msg || serr.Pointer != tt.pointer {
+			t.Errorf("%v: error %v, want %q in %q", tt.toks, err, tt.msg, tt.pointer)
+		}
+	}
+
+	// The Encoder goes on after a syntax error.
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.WriteToken(BeginArray)
+	if e.WriteToken(EndObject) == nil {
+		t.Error("writing '}' in array succeeded")
+	}
+	e.WriteToken(EndArray)
+	if buf.String() != "[]\n" {
+		t.Errorf("got %#q, want %#q", buf.String(), "[]\n")
+	}
+}
+
+func TestEncoderStrict(t *testing.T) {
+	e := NewEncoder(io.Discard)
+	e.DisallowDuplicateNames()
+	e.DisallowInvalidUTF8()
+	e.WriteToken(BeginObject)
+	e.WriteToken(String("a"))
+	e.WriteToken(Null)
+	if err := e.WriteToken(String("a")); err == nil || !strings.Contains(err.Error(), `duplicate object name "a"`) {
+		t.Errorf("duplicate name: error %v", err)
+	}
+	if err := e.WriteToken(String("\xff")); err == nil || !strings.Contains(err.Error(), "invalid UTF-8") {
+		t.Errorf("invalid UTF-8: error %v", err)
+	}
+	if err := e.WriteValue(Value(`"a"`)); err == nil {
+		t.Error("duplicate name written as a value")
+	}
+	if err := e.WriteToken(String("b")); err != nil {
+		t.Error(err)
+	}
+	if err := e.WriteValue(Value(`{"c": 1, "c": 2}`)); err == nil {
+		t.Error("value with duplicate names written")
+	}
+}
+
+func TestWriteValue(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.WriteToken(BeginObject)
+	for _, v := range []string{`"a"`, ` [1, {"b" : null}, "é"] `, `"c"`, `{}`} {
+		if err := e.WriteValue(Value(v)); err != nil {
+			t.Fatalf("WriteValue(%#q): %v", v, err)
+		}
+	}
+	for _, v := range []string{``, `1 2`, `[1,]`, `}`, `{"a"}`} {
+		if err := e.WriteValue(Value(v)); err == nil {
+			t.Errorf("WriteValue(%#q) succeeded", v)
+		}
+	}
+	e.WriteToken(EndObject)
+	want := `{"a":[1,{"b":null},"é"],"c":{}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %#q, want %#q", buf.String(), want)
+	}
+}
+
+func TestEncoderIndent(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetIndent(">", "\t")
+	e.WriteValue(Value(`{"a": [1, 2, []], "b": {}, "c": {"d": true}}`))
+	e.WriteToken(Int(3))
+	want := "{\n>\t\"a\": [\n>\t\t1,\n>\t\t2,\n>\t\t[]\n>\t],\n>\t\"b\": {},\n>\t\"c\": {\n>\t\t\"d\": true\n>\t}\n>}\n3\n"
+	if buf.String() != want {
+		t.Errorf("got %#q, want %#q", buf.String(), want)
+	}
+}
+
+func TestValue(t *testing.T) {
+	v := Value(" {\"a\" : [ 1 , \"\xff\" ] } ")
+	if v.Kind() != KindBeginObject {
+		t.Errorf("Kind = %v", v.Kind())
+	}
+	if v.IsValid() {
+		t.Error("value with invalid UTF-8 is valid")
+	}
+	w := v.Clone()
+	if err := w.Compact(); err != nil || string(w) != "{\"a\":[1,\"\xff\"]}" {
+		t.Errorf("Compact = %#q, %v", w, err)
+	}
+	if err := w.Indent("", "  "); err != nil || string(w) != "{\n  \"a\": [\n    1,\n    \"\xff\"\n  ]\n}" {
+		t.Errorf("Indent = %#q, %v", w, err)
+	}
+	for _, s := range []string{``, ` `, `{`, `1 2`, `{"a":1,"a":2}`, "\"\xff\""} {
+		if Value(s).IsValid() {
+			t.Errorf("%#q is valid", s)
+		}
+	}
+	bad := Value(`[1,,2]`)
+	if err := bad.Compact(); err == nil || string(bad) != `[1,,2]` {
+		t.Errorf("Compact of invalid value = %#q, %v", bad, err)
+	}
+}
+
+// Reading and writing back the tokens of a stream reproduces it, in
+// compact form.
+func TestRoundTrip(t *testing.T) {
+	in := `{"a": [1.50, -0, "xAy"], "b": {"c": [], "d": {}}, "e": "😀"} [] "z" 1e3`
+	d := NewDecoder(strings.NewReader(in))
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `{"a":[1.50,-0,"xAy"],"b":{"c":[],"d":{}},"e":"😀"}` + "\n[]\n\"z\"\n1e3\n"
+	if buf.String() != want {
+		t.Errorf("got %#q, want %#q", buf.String(), want)
+	}
+}
+
+func TestWriteTokenAllocs(t *testing.T) {
+	e := NewEncoder(io.Discard)
+	allocs := testing.AllocsPerRun(10, func() {
+		e.WriteToken(BeginObject)
+		e.WriteToken(String("name"))
+		e.WriteToken(Float(1.5))
+		e.WriteToken(String("list"))
+		e.WriteToken(BeginArray)
+		e.WriteToken(Int(-1))
+		e.WriteToken(True)
+		e.WriteToken(EndArray)
+		e.WriteToken(EndObject)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations writing tokens", allocs)
+	}
+}
+
+func BenchmarkWriteToken(b *testing.B) {
+	e := NewEncoder(io.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.WriteToken(BeginObject)
+		e.WriteToken(String("time"))
+		e.WriteToken(String("2020-10-19T12:00:00Z"))
+		e.WriteToken(String("ms"))
+		e.WriteToken(Float(12.5))
+		e.WriteToken(String("ok"))
+		e.WriteToken(True)
+		e.WriteToken(EndObject)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext_test
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// This example filters a stream of log records, copying the ones at
+// level "error" to the output without decoding them into Go values.
+func Example_filter() {
+	const stream = `
+		{"level": "info", "msg": "started"}
+		{"level": "error", "msg": "disk full", "free": 0}
+		{"level": "info", "msg": "retrying"}
+		{"level": "error", "msg": "giving up"}
+	`
+	dec := jsontext.NewDecoder(strings.NewReader(stream))
+	enc := jsontext.NewEncoder(os.Stdout)
+	for {
+		v, err := dec.ReadValue()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if isError(v) {
+			if err := enc.WriteValue(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// Output:
+	// {"level":"error","msg":"disk full","free":0}
+	// {"level":"error","msg":"giving up"}
+}
+
+// isError reports whether the record v has level "error".
+func isError(v jsontext.Value) bool {
+	dec := jsontext.NewDecoder(strings.NewReader(string(v)))
+	if _, err := dec.ReadToken(); err != nil { // '{'
+		return false
+	}
+	for dec.PeekKind() == jsontext.KindString {
+		name, _ := dec.ReadToken()
+		if name.String() == "level" {
+			level, err := dec.ReadToken()
+			return err == nil && level.Kind() == jsontext.KindString && level.String() == "error"
+		}
+		if err := dec.SkipValue(); err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+func ExampleDecoder_StackPointer() {
+	dec := jsontext.NewDecoder(strings.NewReader(`{"a": [1, {"b/c": true}]}`))
+	for {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			break
+		}
+		fmt.Printf("%-5v %q\n", tok, dec.StackPointer())
+	}
+	// Output:
+	// {     ""
+	// a     "/a"
+	// [     "/a"
+	// 1     "/a/0"
+	// {     "/a/1"
+	// b/c   "/a/1/b~1c"
+	// true  "/a/1/b~1c"
+	// }     "/a/1"
+	// ]     "/a"
+	// }     ""
+}
+
+func ExampleValue_Indent() {
+	v := jsontext.Value(`{"name": "gopher", "tags": ["a", "b"]}`)
+	if err := v.Indent("", "  "); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(v)
+	// Output:
+	// {
+	//   "name": "gopher",
+	//   "tags": [
+	//     "a",
+	//     "b"
+	//   ]
+	// }
+}
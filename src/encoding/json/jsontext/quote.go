@@ -0,0 +1,323 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errIncomplete reports that a token continues past the end of the
+// data scanned so far.
+var errIncomplete = errors.New("jsontext: incomplete token")
+
+// consumeLiteral returns the length of the literal lit at the start of
+// b, where b[0] == lit[0].
+func consumeLiteral(b []byte, lit string) (int, error) {
+	for i := 1; i < len(lit); i++ {
+		if i == len(b) {
+			return 0, errIncomplete
+		}
+		if b[i] != lit[i] {
+			return i, errors.New("invalid character " + quoteChar(b[i]) + " in literal " + lit + " (expecting " + quoteChar(lit[i]) + ")")
+		}
+	}
+	return len(lit), nil
+}
+
+// consumeNumber returns the length of the number at the start of b.
+// If eof is not set, b may continue after its end.
+func consumeNumber(b []byte, eof bool) (int, error) {
+	i := 0
+	// next returns the byte at i, or 0 at the end of b.
+	next := func() byte {
+		if i < len(b) {
+			return b[i]
+		}
+		return 0
+	}
+	digits := func() {
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+	}
+	// bad returns the error for an unexpected byte at i, where the
+	// number needs one of expected.
+	bad := func(expected string) (int, error) {
+		if i == len(b) {
+			if !eof {
+				return 0, errIncomplete
+			}
+			return i, errors.New("unexpected end of JSON input (expecting " + expected + ")")
+		}
+		return i, errors.New("invalid character " + quoteChar(b[i]) + " in numeric literal")
+	}
+
+	if next() == '-' {
+		i++
+	}
+	switch c := next(); {
+	case c == '0':
+		i++
+	case '1' <= c && c <= '9':
+		digits()
+	default:
+		return bad("digit")
+	}
+	if next() == '.' {
+		i++
+		if c := next(); c < '0' || '9' < c {
+			return bad("digit")
+		}
+		digits()
+	}
+	if c := next(); c == 'e' || c == 'E' {
+		i++
+		if c := next(); c == '+' || c == '-' {
+			i++
+		}
+		if c := next(); c < '0' || '9' < c {
+			return bad("digit")
+		}
+		digits()
+	}
+	if i == len(b) && !eof {
+		return 0, errIncomplete
+	}
+	return i, nil
+}
+
+// errInvalidUTF8 reports invalid UTF-8 in a string, or a lone
+// surrogate escape, which cannot be converted to UTF-8.
+var errInvalidUTF8 = errors.New("invalid UTF-8 in string")
+
+// consumeString returns the length of the quoted string at the start
+// of b, where b[0] == '"'. It rejects invalid UTF-8 if strict is set.
+func consumeString(b []byte, strict bool) (int, error) {
+	i := 1
+	for {
+		// Skip the bytes that need no checking.
+		for i < len(b) && b[i] >= ' ' && b[i] != '"' && b[i] != '\\' && b[i] < utf8.RuneSelf {
+			i++
+		}
+		if i == len(b) {
+			return 0, errIncomplete
+		}
+		switch c := b[i]; {
+		case c == '"':
+			return i + 1, nil
+		case c == '\\':
+			n, err := consumeEscape(b[i:], strict)
+			if err != nil {
+				return i + n, err
+			}
+			i += n
+		case c < ' ':
+			return i, errors.New("invalid character " + quoteChar(c) + " in string literal")
+		default:
+			if !utf8.FullRune(b[i:]) {
+				return 0, errIncomplete
+			}
+			r, size := utf8.DecodeRune(b[i:])
+			if r == utf8.RuneError && size == 1 && strict {
+				return i, errInvalidUTF8
+			}
+			i += size
+		}
+	}
+}
+
+// consumeEscape returns the length of the escape sequence at the start
+// of b, where b[0] == '\\'. It rejects lone surrogates if strict is set.
+func consumeEscape(b []byte, strict bool) (int, error) {
+	if len(b) < 2 {
+		return 0, errIncomplete
+	}
+	switch b[1] {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		return 2, nil
+	case 'u':
+	default:
+		return 1, errors.New("invalid character " + quoteChar(b[1]) + " in string escape code")
+	}
+	r, n, err := consumeHex(b)
+	if err != nil || !strict || !utf16.IsSurrogate(r) {
+		return n, err
+	}
+	// A surrogate must be the first of a pair.
+	if len(b) > n && b[n] != '\\' || len(b) > n+1 && b[n+1] != 'u' {
+		return 0, errInvalidUTF8
+	}
+	if len(b) < n+2 {
+		return 0, errIncomplete
+	}
+	r2, n2, err := consumeHex(b[n:])
+	if err != nil {
+		return n + n2, err
+	}
+	if utf16.DecodeRune(r, r2) == utf8.RuneError {
+		return 0, errInvalidUTF8
+	}
+	return n + n2, nil
+}
+
+// consumeHex returns the rune of the \uXXXX escape at the start of b,
+// and its length.
+func consumeHex(b []byte) (rune, int, error) {
+	var r rune
+	for i := 2; i < 6; i++ {
+		if i == len(b) {
+			return 0, 0, errIncomplete
+		}
+		c := b[i]
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, i, errors.New("invalid character " + quoteChar(c) + " in \\u hexadecimal character escape")
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, 6, nil
+}
+
+// appendUnquote appends the value of the valid quoted string q to dst.
+// Invalid UTF-8 and lone surrogates are replaced by U+FFFD, unless
+// strict is set, in which case they are an error.
+func appendUnquote(dst, q []byte, strict bool) ([]byte, error) {
+	q = q[1 : len(q)-1]
+	for len(q) > 0 {
+		// Copy the bytes that need no decoding.
+		i := 0
+		for i < len(q) && q[i] != '\\' && q[i] < utf8.RuneSelf {
+			i++
+		}
+		dst = append(dst, q[:i]...)
+		q = q[i:]
+		if len(q) == 0 {
+			break
+		}
+		if q[0] != '\\' {
+			r, size := utf8.DecodeRune(q)
+			if r == utf8.RuneError && size == 1 {
+				if strict {
+					return dst, errInvalidUTF8
+				}
+				dst = append(dst, "�"...)
+			} else {
+				dst = append(dst, q[:size]...)
+			}
+			q = q[size:]
+			continue
+		}
+		switch c := q[1]; c {
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'u':
+			r, _, _ := consumeHex(q)
+			q = q[6:]
+			if utf16.IsSurrogate(r) {
+				r2 := rune(-1)
+				if len(q) >= 6 && q[0] == '\\' && q[1] == 'u' {
+					r2, _, _ = consumeHex(q)
+				}
+				if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+					q = q[6:]
+				} else if strict {
+					return dst, errInvalidUTF8
+				}
+			}
+			var buf [utf8.UTFMax]byte
+			n := utf8.EncodeRune(buf[:], r)
+			dst = append(dst, buf[:n]...)
+			continue
+		default:
+			dst = append(dst, c)
+		}
+		q = q[2:]
+	}
+	return dst, nil
+}
+
+const hex = "0123456789abcdef"
+
+// appendQuote appends the JSON encoding of the string s to dst.
+// Invalid UTF-8 is replaced by U+FFFD, unless strict is set,
+// in which case it is an error.
+func appendQuote(dst []byte, s string, strict bool) ([]byte, error) {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if strict {
+				return dst, errInvalidUTF8
+			}
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "�"...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"'), nil
+}
+
+// quoteChar formats c as a quoted character literal.
+func quoteChar(c byte) string {
+	// special cases - different from quoted strings
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+
+	// use quoted string with different quotation marks
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
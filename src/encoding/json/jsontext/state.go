@@ -0,0 +1,211 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A SyntaxError describes invalid JSON text, read by a Decoder or
+// written to an Encoder.
+type SyntaxError struct {
+	msg string // description of error
+
+	// Offset is the offset in the input or output of the error:
+	// it occurred after reading or writing Offset bytes.
+	Offset int64
+
+	// Pointer is a JSON Pointer (RFC 6901) to the value in which the
+	// error occurred. For an error inside a value or object name, it
+	// names that value, such as the array element being read; otherwise
+	// it is the StackPointer of the last token handled.
+	Pointer string
+}
+
+func (e *SyntaxError) Error() string {
+	s := "jsontext: " + e.msg + " at offset " + strconv.FormatInt(e.Offset, 10)
+	if e.Pointer != "" {
+		s += " (in " + e.Pointer + ")"
+	}
+	return s
+}
+
+// options holds the options of an Encoder or a Decoder.
+type options struct {
+	rejectDuplicateNames bool
+	rejectInvalidUTF8    bool
+}
+
+// A frame is an object or an array being read or written, or the top
+// level of the stream.
+type frame struct {
+	kind Kind // KindBeginObject, KindBeginArray, or 0 at the top level
+	n    int  // number of names and values, or values, so far
+
+	name  []byte              // encoding of the last name, in an object
+	names map[string]struct{} // names so far, when rejecting duplicates
+}
+
+// A state tracks where the tokens of a stream are in the grammar of
+// JSON. It is shared by Encoder and Decoder.
+type state struct {
+	options
+	stack   []frame // stack[0] is the top level
+	scratch []byte
+}
+
+func (s *state) reset(opts options) {
+	s.options = opts
+	if len(s.stack) == 0 {
+		s.stack = make([]frame, 1, 8)
+	}
+	s.stack = s.stack[:1]
+	s.stack[0].n = 0
+}
+
+func (s *state) top() *frame {
+	return &s.stack[len(s.stack)-1]
+}
+
+// needName reports whether the next token must be an object name or
+// the end of an object.
+func (s *state) needName() bool {
+	f := s.top()
+	return f.kind == KindBeginObject && f.n%2 == 0
+}
+
+// check reports whether a token of kind k can come next, and whether
+// it needs a separator before it.
+func (s *state) check(k Kind) (sep byte, err error) {
+	f := s.top()
+	switch k {
+	case KindEndObject, KindEndArray:
+		if f.kind != k-2 { // '{'+2 == '}' and '['+2 == ']'
+			return 0, errors.New("unexpected " + quoteChar(byte(k)))
+		}
+		if f.kind == KindBeginObject && f.n%2 == 1 {
+			return 0, errors.New("missing value after object name")
+		}
+		return 0, nil
+	}
+	switch {
+	case f.kind == KindBeginObject && f.n%2 == 0:
+		if k != KindString {
+			return 0, errors.New("object name must be a string, not " + k.String())
+		}
+		if f.n > 0 {
+			return ',', nil
+		}
+	case f.kind == KindBeginObject:
+		return ':', nil
+	case f.kind == KindBeginArray && f.n > 0:
+		return ',', nil
+	}
+	return 0, nil
+}
+
+// push records a token of kind k, which check accepted. For an object
+// name, q is its encoding. In a top-level value, push reports whether
+// the value is complete.
+func (s *state) push(k Kind, q []byte) (done bool, err error) {
+	f := s.top()
+	switch k {
+	case KindEndObject, KindEndArray:
+		s.stack = s.stack[:len(s.stack)-1]
+		return len(s.stack) == 1, nil
+	}
+	if f.kind == KindBeginObject && f.n%2 == 0 {
+		if err := s.pushName(f, q); err != nil {
+			return false, err
+		}
+	}
+	f.n++
+	if k == KindBeginObject || k == KindBeginArray {
+		if len(s.stack) < cap(s.stack) {
+			s.stack = s.stack[:len(s.stack)+1]
+		} else {
+			s.stack = append(s.stack, frame{})
+		}
+		f = s.top()
+		f.kind = k
+		f.n = 0
+		if f.names != nil {
+			for name := range f.names {
+				delete(f.names, name)
+			}
+		}
+		return false, nil
+	}
+	return len(s.stack) == 1, nil
+}
+
+func (s *state) pushName(f *frame, q []byte) error {
+	f.name = append(f.name[:0], q...)
+	if !s.rejectDuplicateNames {
+		return nil
+	}
+	var err error
+	s.scratch, err = appendUnquote(s.scratch[:0], q, s.rejectInvalidUTF8)
+	if err != nil {
+		return err
+	}
+	if f.names == nil {
+		f.names = make(map[string]struct{})
+	}
+	if _, ok := f.names[string(s.scratch)]; ok {
+		return errors.New("duplicate object name " + string(q))
+	}
+	f.names[string(s.scratch)] = struct{}{}
+	return nil
+}
+
+// depth returns the number of objects and arrays the stream is in.
+func (s *state) depth() int {
+	return len(s.stack) - 1
+}
+
+// pointer returns a JSON Pointer to the last value or name handled:
+// the names of the members and the indexes of the elements that lead
+// to it. If next is set, it instead returns a pointer to the next value
+// or name, for an error in it: the next element of an array, or the
+// value of the last name of an object. The next name of an object is
+// not known yet, so the pointer then ends at the object.
+func (s *state) pointer(next bool) string {
+	var b strings.Builder
+	for i := 1; i < len(s.stack); i++ {
+		f := &s.stack[i]
+		n := f.n
+		if next && i == len(s.stack)-1 {
+			if f.kind == KindBeginArray {
+				n++
+			} else if n%2 == 0 {
+				break
+			}
+		}
+		if n == 0 {
+			break
+		}
+		b.WriteByte('/')
+		if f.kind == KindBeginArray {
+			b.WriteString(strconv.Itoa(n - 1))
+			continue
+		}
+		name, _ := appendUnquote(s.scratch[:0], f.name, false)
+		s.scratch = name
+		for _, c := range name {
+			switch c {
+			case '~':
+				b.WriteString("~0")
+			case '/':
+				b.WriteString("~1")
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
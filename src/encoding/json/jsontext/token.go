@@ -0,0 +1,281 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"math"
+	"strconv"
+)
+
+// A Kind is the kind of a JSON token or value, written as the first
+// byte of its encoding, except that all numbers have Kind '0'.
+type Kind byte
+
+const (
+	KindNull        Kind = 'n'
+	KindFalse       Kind = 'f'
+	KindTrue        Kind = 't'
+	KindString      Kind = '"'
+	KindNumber      Kind = '0'
+	KindBeginObject Kind = '{'
+	KindEndObject   Kind = '}'
+	KindBeginArray  Kind = '['
+	KindEndArray    Kind = ']'
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindFalse:
+		return "false"
+	case KindTrue:
+		return "true"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindBeginObject:
+		return "{"
+	case KindEndObject:
+		return "}"
+	case KindBeginArray:
+		return "["
+	case KindEndArray:
+		return "]"
+	}
+	return "invalid kind"
+}
+
+// kindOf returns the kind of the token starting with c,
+// or 0 if no token starts with c.
+func kindOf(c byte) Kind {
+	switch c {
+	case 'n', 'f', 't', '"', '{', '}', '[', ']':
+		return Kind(c)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return KindNumber
+	}
+	return 0
+}
+
+// A Token is a JSON token: a null, a boolean, a string, a number, or
+// the beginning or end of an object or an array. Object names are
+// string tokens. The zero Token is not valid.
+//
+// A Token read by a Decoder refers to the Decoder's buffer and is only
+// valid until the next call to the Decoder, unless it is cloned. Reading
+// a Token does not allocate: strings and numbers are decoded when their
+// value is asked for.
+type Token struct {
+	kind Kind
+	raw  []byte // encoding of a string or number read by a Decoder
+
+	// The value of a Token made by String, Float, Int or Uint.
+	str     string
+	num     uint64 // bits of the number
+	numKind byte   // 'f', 'i' or 'u'
+}
+
+// Tokens for the literals and the delimiters.
+var (
+	Null        = Token{kind: KindNull}
+	False       = Token{kind: KindFalse}
+	True        = Token{kind: KindTrue}
+	BeginObject = Token{kind: KindBeginObject}
+	EndObject   = Token{kind: KindEndObject}
+	BeginArray  = Token{kind: KindBeginArray}
+	EndArray    = Token{kind: KindEndArray}
+)
+
+// Bool returns the Token for the boolean b.
+func Bool(b bool) Token {
+	if b {
+		return True
+	}
+	return False
+}
+
+// String returns the Token for the string s.
+func String(s string) Token {
+	return Token{kind: KindString, str: s}
+}
+
+// Float returns the Token for the number f, which must be finite for
+// the Token to be written.
+func Float(f float64) Token {
+	return Token{kind: KindNumber, num: math.Float64bits(f), numKind: 'f'}
+}
+
+// Int returns the Token for the number n.
+func Int(n int64) Token {
+	return Token{kind: KindNumber, num: uint64(n), numKind: 'i'}
+}
+
+// Uint returns the Token for the number n.
+func Uint(n uint64) Token {
+	return Token{kind: KindNumber, num: n, numKind: 'u'}
+}
+
+// Kind returns the kind of t, or 0 if t is the zero Token.
+func (t Token) Kind() Kind {
+	return t.kind
+}
+
+// Clone returns a copy of t that does not refer to the buffer of the
+// Decoder that read it.
+func (t Token) Clone() Token {
+	if t.raw != nil {
+		t.raw = append([]byte(nil), t.raw...)
+	}
+	return t
+}
+
+// Bool returns the value of a boolean token.
+// It panics if t is not a boolean.
+func (t Token) Bool() bool {
+	switch t.kind {
+	case KindTrue:
+		return true
+	case KindFalse:
+		return false
+	}
+	panic("jsontext: Bool of " + t.kind.String() + " token")
+}
+
+// String returns the value of a string token. Invalid UTF-8 in it,
+// which a Decoder accepts unless told not to, is replaced by U+FFFD.
+// For the other kinds of token, String returns their JSON encoding.
+func (t Token) String() string {
+	switch {
+	case t.kind == KindString && t.raw != nil:
+		b, _ := appendUnquote(nil, t.raw, false)
+		return string(b)
+	case t.kind == KindString:
+		return t.str
+	case t.kind == KindNumber && t.raw != nil:
+		return string(t.raw)
+	case t.kind == KindNumber:
+		b, ok := t.appendNumber(nil)
+		if !ok {
+			// NaN or an infinity, which JSON cannot encode.
+			return strconv.FormatFloat(math.Float64frombits(t.num), 'g', -1, 64)
+		}
+		return string(b)
+	case t.kind == 0:
+		return "<invalid jsontext.Token>"
+	}
+	return t.kind.String()
+}
+
+// Float returns the value of a number token as a float64, rounded to
+// the nearest representable value. Numbers too large for a float64
+// are ±Inf. Float panics if t is not a number.
+func (t Token) Float() float64 {
+	switch {
+	case t.kind != KindNumber:
+		panic("jsontext: Float of " + t.kind.String() + " token")
+	case t.raw != nil:
+		f, _ := strconv.ParseFloat(string(t.raw), 64)
+		return f
+	case t.numKind == 'i':
+		return float64(int64(t.num))
+	case t.numKind == 'u':
+		return float64(t.num)
+	}
+	return math.Float64frombits(t.num)
+}
+
+// Int returns the value of a number token as an int64, truncated
+// toward zero and clamped to the range of an int64.
+// It panics if t is not a number.
+func (t Token) Int() int64 {
+	switch {
+	case t.kind != KindNumber:
+		panic("jsontext: Int of " + t.kind.String() + " token")
+	case t.raw != nil:
+		if n, err := strconv.ParseInt(string(t.raw), 10, 64); err == nil {
+			return n
+		}
+	case t.numKind == 'i':
+		return int64(t.num)
+	case t.numKind == 'u':
+		if t.num > math.MaxInt64 {
+			return math.MaxInt64
+		}
+		return int64(t.num)
+	}
+	switch f := t.Float(); {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f != f:
+		return 0
+	default:
+		return int64(f)
+	}
+}
+
+// Uint returns the value of a number token as a uint64, truncated
+// toward zero and clamped to the range of a uint64.
+// It panics if t is not a number.
+func (t Token) Uint() uint64 {
+	switch {
+	case t.kind != KindNumber:
+		panic("jsontext: Uint of " + t.kind.String() + " token")
+	case t.raw != nil:
+		if n, err := strconv.ParseUint(string(t.raw), 10, 64); err == nil {
+			return n
+		}
+	case t.numKind == 'i':
+		if int64(t.num) < 0 {
+			return 0
+		}
+		return t.num
+	case t.numKind == 'u':
+		return t.num
+	}
+	switch f := t.Float(); {
+	case f >= math.MaxUint64:
+		return math.MaxUint64
+	case f <= 0 || f != f:
+		return 0
+	default:
+		return uint64(f)
+	}
+}
+
+// appendNumber appends the encoding of a number token made by Float,
+// Int or Uint to b. It fails if the number is not finite.
+func (t Token) appendNumber(b []byte) ([]byte, bool) {
+	switch t.numKind {
+	case 'i':
+		return strconv.AppendInt(b, int64(t.num), 10), true
+	case 'u':
+		return strconv.AppendUint(b, t.num, 10), true
+	}
+	f := math.Float64frombits(t.num)
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return b, false
+	}
+	// Format as encoding/json does, like ES6 number to string
+	// conversion.
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		fmt = 'e'
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, 64)
+	if fmt == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, true
+}
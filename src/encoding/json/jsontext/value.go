@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+)
+
+// A Value is the encoding of a single JSON value, which may contain
+// whitespace.
+type Value []byte
+
+// Clone returns a copy of v.
+func (v Value) Clone() Value {
+	if v == nil {
+		return nil
+	}
+	return append(Value{}, v...)
+}
+
+// String returns v as a string.
+func (v Value) String() string {
+	return string(v)
+}
+
+// Kind returns the kind of v, from its first byte after whitespace.
+// It returns 0 if v does not start like a value.
+func (v Value) Kind() Kind {
+	for _, c := range v {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		if k := kindOf(c); k != KindEndObject && k != KindEndArray {
+			return k
+		}
+		break
+	}
+	return 0
+}
+
+// IsValid reports whether v is a single valid JSON value, surrounded
+// by optional whitespace, that follows RFC 8259 strictly: its strings
+// are valid UTF-8 and its objects have no duplicate names.
+func (v Value) IsValid() bool {
+	var d Decoder
+	return v.validate(&d, options{rejectDuplicateNames: true, rejectInvalidUTF8: true}) == nil
+}
+
+// validate checks that v is a single valid value, with the options o,
+// using the Decoder d.
+func (v Value) validate(d *Decoder, o options) error {
+	d.resetBytes(v, o)
+	if err := d.SkipValue(); err != nil {
+		if err == io.EOF {
+			err = d.syntaxError(0, errUnexpectedEOF)
+		}
+		return err
+	}
+	if _, _, err := d.peek(); err != io.EOF {
+		if err == nil {
+			err = d.syntaxError(0, errors.New("invalid data after top-level value"))
+		}
+		return err
+	}
+	return nil
+}
+
+// Compact removes the whitespace from v.
+// It fails, leaving v unchanged, if v is not a single valid value.
+func (v *Value) Compact() error {
+	return v.reformat("", "")
+}
+
+// Indent reformats v, writing each object member and array element on
+// a new line, beginning with prefix followed by one copy of indent for
+// each level of nesting, as an Encoder does after SetIndent.
+// It fails, leaving v unchanged, if v is not a single valid value.
+func (v *Value) Indent(prefix, indent string) error {
+	return v.reformat(prefix, indent)
+}
+
+func (v *Value) reformat(prefix, indent string) error {
+	var e Encoder
+	e.Reset(nil)
+	e.SetIndent(prefix, indent)
+	if err := e.WriteValue(*v); err != nil {
+		return err
+	}
+	// Drop the newline after the top-level value.
+	*v = e.buf[:len(e.buf)-1]
+	return nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// MarshalOptions configures the encoding of Go values as JSON.
+// The zero MarshalOptions encodes values as Marshal does.
+type MarshalOptions struct {
+	// Marshalers, if not nil, holds functions that encode values of
+	// particular types in place of their usual encoding.
+	Marshalers *Marshalers
+}
+
+// Marshal returns the JSON encoding of v, as Marshal does, using the
+// options in o.
+func (o MarshalOptions) Marshal(v interface{}) ([]byte, error) {
+	e := newEncodeState()
+
+	err := e.marshal(v, encOpts{escapeHTML: true, marshalers: o.Marshalers})
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), e.Bytes()...)
+
+	encodeStatePool.Put(e)
+
+	return buf, nil
+}
+
+// UnmarshalOptions configures the decoding of JSON into Go values.
+// The zero UnmarshalOptions decodes values as Unmarshal does.
+type UnmarshalOptions struct {
+	// MatchCaseSensitive makes object keys match struct field names
+	// only exactly. By default, an exact match is preferred but a
+	// case-insensitive match is also accepted.
+	MatchCaseSensitive bool
+
+	// RejectDuplicateNames makes Unmarshal fail, before storing
+	// anything, if an object in the input has the same key twice.
+	// The error is a *jsontext.SyntaxError.
+	RejectDuplicateNames bool
+
+	// DisallowUnknownFields makes Unmarshal return an error when the
+	// destination is a struct and the input contains object keys which
+	// do not match any of its fields, as Decoder.DisallowUnknownFields
+	// does.
+	DisallowUnknownFields bool
+
+	// UseNumber makes Unmarshal store a number into an interface{} as
+	// a Number instead of as a float64, as Decoder.UseNumber does.
+	UseNumber bool
+
+	// Unmarshalers, if not nil, holds functions that decode values of
+	// particular types in place of their usual decoding.
+	Unmarshalers *Unmarshalers
+}
+
+// Unmarshal parses the JSON-encoded data and stores the result in the
+// value pointed to by v, as Unmarshal does, using the options in o.
+func (o UnmarshalOptions) Unmarshal(data []byte, v interface{}) error {
+	var d decodeState
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+	if o.RejectDuplicateNames {
+		dec := jsontext.NewDecoder(bytes.NewReader(data))
+		dec.DisallowDuplicateNames()
+		if err := dec.SkipValue(); err != nil {
+			return err
+		}
+	}
+
+	d.init(data)
+	d.matchCaseSensitive = o.MatchCaseSensitive
+	d.disallowUnknownFields = o.DisallowUnknownFields
+	d.useNumber = o.UseNumber
+	d.unmarshalers = o.Unmarshalers
+	return d.unmarshal(v)
+}
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Marshalers is a set of functions that encode values of particular
+// types, made by MarshalFuncs. It is safe for concurrent use.
+type Marshalers struct {
+	funcs  map[reflect.Type]reflect.Value // by argument type
+	ifaces []reflect.Type                 // argument types that are interfaces, in order
+	cache  sync.Map                       // map[reflect.Type]reflect.Value, invalid if none
+}
+
+// MarshalFuncs returns the Marshalers for fns, each of which must be a
+// function of the form
+//
+//	func(T) ([]byte, error)
+//
+// that returns the JSON encoding of a value of type T. The function is
+// used to encode values of type T, and, if T is an interface type,
+// values whose type implements T, in place of the encoding Marshal
+// would use, including that of a MarshalJSON method. A function for a
+// type itself takes precedence over those for the interfaces it
+// implements; otherwise, the first function of fns that applies is
+// used.
+//
+// MarshalFuncs panics if one of fns is not of that form.
+func MarshalFuncs(fns ...interface{}) *Marshalers {
+	m := &Marshalers{funcs: make(map[reflect.Type]reflect.Value)}
+	for _, fn := range fns {
+		fv := reflect.ValueOf(fn)
+		if fv.Kind() != reflect.Func {
+			panic(fmt.Sprintf("json: MarshalFuncs of %T, not func(T) ([]byte, error)", fn))
+		}
+		ft := fv.Type()
+		if ft.NumIn() != 1 || ft.NumOut() != 2 || ft.Out(0) != bytesType || ft.Out(1) != errorType {
+			panic(fmt.Sprintf("json: MarshalFuncs of %v, not func(T) ([]byte, error)", ft))
+		}
+		t := ft.In(0)
+		if _, ok := m.funcs[t]; ok {
+			continue
+		}
+		m.funcs[t] = fv
+		if t.Kind() == reflect.Interface {
+			m.ifaces = append(m.ifaces, t)
+		}
+	}
+	return m
+}
+
+// lookup returns the function for values of type t, if any.
+func (m *Marshalers) lookup(t reflect.Type) (reflect.Value, bool) {
+	if fv, ok := m.cache.Load(t); ok {
+		fn := fv.(reflect.Value)
+		return fn, fn.IsValid()
+	}
+	fn, ok := m.funcs[t]
+	if !ok {
+		for _, it := range m.ifaces {
+			if t.Implements(it) {
+				fn = m.funcs[it]
+				break
+			}
+		}
+	}
+	m.cache.Store(t, fn)
+	return fn, fn.IsValid()
+}
+
+// encode encodes v with the function for its type, and reports whether
+// there is one.
+func (m *Marshalers) encode(e *encodeState, v reflect.Value, opts encOpts) bool {
+	if !v.IsValid() || !v.CanInterface() {
+		return false
+	}
+	fn, ok := m.lookup(v.Type())
+	if !ok {
+		return false
+	}
+	out := fn.Call([]reflect.Value{v})
+	err, _ := out[1].Interface().(error)
+	if err == nil {
+		// copy JSON into buffer, checking validity.
+		err = compact(&e.Buffer, out[0].Bytes(), opts.escapeHTML)
+	}
+	if err != nil {
+		e.error(&MarshalerError{v.Type(), err, "marshal function"})
+	}
+	return true
+}
+
+// Unmarshalers is a set of functions that decode values of particular
+// types, made by UnmarshalFuncs. It is safe for concurrent use.
+type Unmarshalers struct {
+	funcs map[reflect.Type]reflect.Value // by type pointed to by the argument
+}
+
+// UnmarshalFuncs returns the Unmarshalers for fns, each of which must
+// be a function of the form
+//
+//	func([]byte, *T) error
+//
+// that decodes a JSON value into the value of type T pointed to. The
+// function is used to decode into values of type T in place of the
+// decoding Unmarshal would use, including that of an UnmarshalJSON
+// method. It is passed a valid JSON value, which may be the literal
+// null, and must copy it if it wishes to retain it after returning.
+// If several of fns are for the same type, the first is used.
+//
+// UnmarshalFuncs panics if one of fns is not of that form.
+func UnmarshalFuncs(fns ...interface{}) *Unmarshalers {
+	u := &Unmarshalers{funcs: make(map[reflect.Type]reflect.Value)}
+	for _, fn := range fns {
+		fv := reflect.ValueOf(fn)
+		if fv.Kind() != reflect.Func {
+			panic(fmt.Sprintf("json: UnmarshalFuncs of %T, not func([]byte, *T) error", fn))
+		}
+		ft := fv.Type()
+		if ft.NumIn() != 2 || ft.NumOut() != 1 || ft.In(0) != bytesType || ft.In(1).Kind() != reflect.Ptr || ft.Out(0) != errorType {
+			panic(fmt.Sprintf("json: UnmarshalFuncs of %v, not func([]byte, *T) error", ft))
+		}
+		t := ft.In(1).Elem()
+		if _, ok := u.funcs[t]; !ok {
+			u.funcs[t] = fv
+		}
+	}
+	return u
+}
+
+// lookup returns the function for decoding into v, and the pointer to
+// pass it, if any. Like indirect, it leaves a null decoded into a
+// settable pointer to set the pointer to nil.
+func (u *Unmarshalers) lookup(v reflect.Value, decodingNull bool) (fn, p reflect.Value) {
+	if !v.IsValid() {
+		return reflect.Value{}, reflect.Value{}
+	}
+	if fn, ok := u.funcs[v.Type()]; ok && v.CanAddr() {
+		return fn, v.Addr()
+	}
+	if v.Kind() != reflect.Ptr {
+		return reflect.Value{}, reflect.Value{}
+	}
+	fn, ok := u.funcs[v.Type().Elem()]
+	if !ok || decodingNull && v.CanSet() {
+		return reflect.Value{}, reflect.Value{}
+	}
+	if v.IsNil() {
+		if !v.CanSet() {
+			return reflect.Value{}, reflect.Value{}
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return fn, v
+}
+
+// unmarshalWith consumes a JSON value from d.data[d.off-1:], decoding it
+// by calling fn with the value and p, and reads the following byte
+// ahead, as value does.
+func (d *decodeState) unmarshalWith(fn, p reflect.Value) error {
+	start := d.readIndex()
+	if d.opcode == scanBeginLiteral {
+		d.rescanLiteral()
+	} else {
+		d.skip()
+		d.scanNext()
+	}
+	out := fn.Call([]reflect.Value{reflect.ValueOf(d.data[start:d.readIndex()]), p})
+	err, _ := out[0].Interface().(error)
+	return err
+}
@@ -0,0 +1,410 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type optCelsius float64
+
+type optTemps struct {
+	Now  optCelsius
+	Past []optCelsius
+	Max  *optCelsius
+	Any  interface{}
+}
+
+func TestMarshalFuncs(t *testing.T) {
+	o := MarshalOptions{Marshalers: MarshalFuncs(
+		func(c optCelsius) ([]byte, error) {
+			return []byte(strconv.Quote(fmt.Sprintf("%.1f°C", float64(c)))), nil
+		},
+		func(err error) ([]byte, error) {
+			return []byte(strconv.Quote(err.Error())), nil
+		},
+		// Not used: the first function for a type wins.
+		func(c optCelsius) ([]byte, error) {
+			return []byte("0"), nil
+		},
+	)}
+	max := optCelsius(30)
+	v := []interface{}{
+		optTemps{Now: 21.5, Past: []optCelsius{20, 19.25}, Max: &max, Any: optCelsius(-1)},
+		errors.New("broken"),
+		map[string]optCelsius{"a": 1},
+		optCelsius(2),
+	}
+	b, err := o.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"Now":"21.5°C","Past":["20.0°C","19.2°C"],"Max":"30.0°C","Any":"-1.0°C"},"broken",{"a":"1.0°C"},"2.0°C"]`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+
+	// Without them, values are marshaled as usual.
+	b, err = MarshalOptions{}.Marshal(optTemps{Now: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Now":1,"Past":null,"Max":null,"Any":null}`; string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
+
+func TestMarshalFuncsOverrideMarshaler(t *testing.T) {
+	o := MarshalOptions{Marshalers: MarshalFuncs(func(tm time.Time) ([]byte, error) {
+		return []byte(strconv.FormatInt(tm.Unix(), 10)), nil
+	})}
+	b, err := o.Marshal(struct{ T time.Time }{time.Unix(1e9, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"T":1000000000}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestMarshalFuncsError(t *testing.T) {
+	errBad := errors.New("bad")
+	for _, tt := range []struct {
+		fn   interface{}
+		want string
+	}{
+		{func(optCelsius) ([]byte, error) { return nil, errBad }, "json: error calling marshal function for type json.optCelsius: bad"},
+		{func(optCelsius) ([]byte, error) { return []byte("{"), nil }, "json: error calling marshal function for type json.optCelsius: unexpected end of JSON input"},
+	} {
+		_, err := MarshalOptions{Marshalers: MarshalFuncs(tt.fn)}.Marshal(optTemps{})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("error %v, want %s", err, tt.want)
+		}
+	}
+	if _, err := (MarshalOptions{Marshalers: MarshalFuncs(func(optCelsius) ([]byte, error) { return nil, errBad })}.Marshal(optCelsius(0))); !errors.Is(err, errBad) {
+		t.Errorf("error %v does not wrap %v", err, errBad)
+	}
+
+	for _, fn := range []interface{}{nil, 1, func(int) []byte { return nil }, func(int) (string, error) { return "", nil }} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MarshalFuncs(%T) did not panic", fn)
+				}
+			}()
+			MarshalFuncs(fn)
+		}()
+	}
+}
+
+func TestUnmarshalFuncs(t *testing.T) {
+	o := UnmarshalOptions{Unmarshalers: UnmarshalFuncs(func(b []byte, c *optCelsius) error {
+		if string(b) == "null" {
+			*c = -273.15
+			return nil
+		}
+		s, err := strconv.Unquote(string(b))
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "°C"), 64)
+		*c = optCelsius(f)
+		return err
+	})}
+	var v optTemps
+	in := `{"Now": "21.5°C", "Past": ["20°C", null], "Max": "30°C", "Any": "1°C"}`
+	if err := o.Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := optTemps{Now: 21.5, Past: []optCelsius{20, -273.15}, Any: "1°C"}
+	if v.Max == nil || *v.Max != 30 {
+		t.Errorf("Max = %v, want 30", v.Max)
+	}
+	v.Max = nil
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+
+	// A null leaves a pointer to set to nil.
+	v.Max = new(optCelsius)
+	if err := o.Unmarshal([]byte(`{"Max": null}`), &v); err != nil || v.Max != nil {
+		t.Errorf("Max = %v, %v, want nil", v.Max, err)
+	}
+
+	// At the top level.
+	var c optCelsius
+	if err := o.Unmarshal([]byte(`"-5°C"`), &c); err != nil || c != -5 {
+		t.Errorf("got %v, %v, want -5", c, err)
+	}
+
+	// Errors are returned as is.
+	if err := o.Unmarshal([]byte(`[1]`), &v.Past); err != strconv.ErrSyntax {
+		t.Errorf("error %v, want %v", err, strconv.ErrSyntax)
+	}
+}
+
+func TestUnmarshalOptions(t *testing.T) {
+	type T struct {
+		Name string
+		N    int
+	}
+
+	var v T
+	o := UnmarshalOptions{MatchCaseSensitive: true}
+	if err := o.Unmarshal([]byte(`{"name": "a", "N": 1}`), &v); err != nil || v != (T{N: 1}) {
+		t.Errorf("MatchCaseSensitive: got %+v, %v", v, err)
+	}
+
+	o = UnmarshalOptions{DisallowUnknownFields: true}
+	if err := o.Unmarshal([]byte(`{"X": 1}`), &v); err == nil || err.Error() != `json: unknown field "X"` {
+		t.Errorf("DisallowUnknownFields: error %v", err)
+	}
+
+	var i interface{}
+	o = UnmarshalOptions{UseNumber: true}
+	if err := o.Unmarshal([]byte(`[1.0]`), &i); err != nil || !reflect.DeepEqual(i, []interface{}{Number("1.0")}) {
+		t.Errorf("UseNumber: got %#v, %v", i, err)
+	}
+
+	o = UnmarshalOptions{RejectDuplicateNames: true}
+	v = T{}
+	err := o.Unmarshal([]byte(`{"Name": "a", "N": [{"x": 1, "x": 2}]}`), &v)
+	serr, ok := err.(*jsontext.SyntaxError)
+	if !ok || serr.Pointer != "/N/0/x" {
+		t.Errorf("RejectDuplicateNames: error %v, want duplicate at /N/0/x", err)
+	}
+	if v != (T{}) {
+		t.Errorf("RejectDuplicateNames: stored %+v before failing", v)
+	}
+	if err := o.Unmarshal([]byte(`{"a": {"x": 1}, "b": {"x": 2}}`), &i); err != nil {
+		t.Errorf("RejectDuplicateNames: %v", err)
+	}
+
+	// Syntax errors come first.
+	if err := o.Unmarshal([]byte(`{"a": 1, "a": }`), &i); err == nil {
+		t.Error("invalid JSON accepted")
+	} else if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("error %T, want *SyntaxError", err)
+	}
+}
+
+type optZero struct {
+	t int
+}
+
+func (z optZero) IsZero() bool { return z.t < 10 }
+
+type optOmitZero struct {
+	Int    int             `json:",omitzero"`
+	Struct struct{ A int } `json:",omitzero"`
+	Array  [2]int          `json:",omitzero"`
+	Slice  []int           `json:",omitzero"`
+	Ptr    *int            `json:",omitzero"`
+	Time   time.Time       `json:",omitzero"`
+	Zeroer optZero         `json:",omitzero"`
+	PZero  *optZero        `json:",omitzero"`
+	Empty  []int           `json:",omitempty,omitzero"`
+}
+
+func TestOmitZero(t *testing.T) {
+	b, err := Marshal(optOmitZero{Zeroer: optZero{5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{}` {
+		t.Errorf("got %s, want {}", b)
+	}
+
+	v := optOmitZero{
+		Slice:  []int{},
+		Ptr:    new(int),
+		Time:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Zeroer: optZero{10},
+		PZero:  &optZero{1},
+		Empty:  []int{},
+	}
+	v.Struct.A = 1
+	v.Array[1] = 1
+	b, err = Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Struct":{"A":1},"Array":[0,1],"Slice":[],"Ptr":0,"Time":"2020-01-01T00:00:00Z","Zeroer":{}}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
+
+type optAddress struct {
+	Street string
+	City   string `json:"city"`
+}
+
+type optPerson struct {
+	Name    string
+	Home    optAddress             `json:",inline"`
+	Work    *optAddress            `json:"work"`
+	Extra   *optExtra              `json:",inline"`
+	Unknown map[string]interface{} `json:",unknown"`
+}
+
+type optExtra struct {
+	Age int `json:"age"`
+}
+
+func TestInlineUnknown(t *testing.T) {
+	v := optPerson{
+		Name:    "Gopher",
+		Home:    optAddress{"Main St", "Springfield"},
+		Work:    &optAddress{City: "Shelbyville"},
+		Unknown: map[string]interface{}{"z": true, "b": []int{1}},
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Name":"Gopher","Street":"Main St","city":"Springfield","work":{"Street":"","city":"Shelbyville"},"b":[1],"z":true}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+
+	var w optPerson
+	in := `{"Name": "Gopher", "Street": "Main St", "city": "Springfield", "age": 3, "b": [1], "work": null, "z": true}`
+	if err := Unmarshal([]byte(in), &w); err != nil {
+		t.Fatal(err)
+	}
+	v.Work = nil
+	v.Extra = &optExtra{3}
+	v.Unknown["b"] = []interface{}{1.0}
+	if !reflect.DeepEqual(w, v) {
+		t.Errorf("got  %+v\nwant %+v", w, v)
+	}
+
+	// The unknown field captures the members that would otherwise
+	// be unknown.
+	w = optPerson{}
+	o := UnmarshalOptions{DisallowUnknownFields: true}
+	if err := o.Unmarshal([]byte(`{"x": 1}`), &w); err != nil || !reflect.DeepEqual(w.Unknown, map[string]interface{}{"x": 1.0}) {
+		t.Errorf("got %v, %v", w.Unknown, err)
+	}
+
+	// A key of the unknown field cannot repeat a struct field.
+	v.Unknown = map[string]interface{}{"Name": 1}
+	if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), `key "Name" of field Unknown duplicates a struct field`) {
+		t.Errorf("duplicate name: error %v", err)
+	}
+
+	// The unknown option only applies to maps with string keys.
+	type T struct {
+		M map[int]int `json:",unknown"`
+	}
+	b, err = Marshal(T{map[int]int{1: 2}})
+	if err != nil || string(b) != `{"M":{"1":2}}` {
+		t.Errorf("unknown map[int]int: got %s, %v", b, err)
+	}
+}
+
+type optFormats struct {
+	Units   time.Duration  `json:",format:units"`
+	Sec     time.Duration  `json:",format:sec"`
+	Milli   *time.Duration `json:",format:milli"`
+	Nano    time.Duration  `json:",format:nano"`
+	Unix    time.Time      `json:",format:unix"`
+	Milli2  time.Time      `json:",format:unixmilli"`
+	Date    time.Time      `json:",format:2006-01-02"`
+	RFC1123 time.Time      `json:",format:RFC1123"`
+	Base64  []byte         `json:",format:base64url"`
+	Base32  []byte         `json:",format:base32"`
+	Base16  []byte         `json:",format:base16"`
+	Array   []byte         `json:",format:array"`
+}
+
+func TestFormat(t *testing.T) {
+	milli := 1500 * time.Microsecond
+	v := optFormats{
+		Units:   90 * time.Minute,
+		Sec:     -1500 * time.Millisecond,
+		Milli:   &milli,
+		Nano:    7,
+		Unix:    time.Unix(-2, 250e6).UTC(),
+		Milli2:  time.Unix(1600000000, 123456789).UTC(),
+		Date:    time.Date(2020, 10, 19, 0, 0, 0, 0, time.UTC),
+		RFC1123: time.Date(2020, 10, 19, 12, 0, 0, 0, time.UTC),
+		Base64:  []byte{0xff, 0xfe},
+		Base32:  []byte("hi"),
+		Base16:  []byte{0xab, 0x01},
+		Array:   []byte{1, 2},
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Units":"1h30m0s","Sec":-1.5,"Milli":1.5,"Nano":7,"Unix":-1.75,"Milli2":1600000000123.456789,` +
+		`"Date":"2020-10-19","RFC1123":"Mon, 19 Oct 2020 12:00:00 UTC","Base64":"__4=","Base32":"NBUQ====","Base16":"ab01","Array":[1,2]}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+
+	var w optFormats
+	if err := Unmarshal(b, &w); err != nil {
+		t.Fatal(err)
+	}
+	w.Unix = w.Unix.UTC()
+	w.Milli2 = w.Milli2.UTC()
+	if !reflect.DeepEqual(w, v) {
+		t.Errorf("got  %+v\nwant %+v", w, v)
+	}
+
+	// Decoding accepts more digits and upper case hexadecimal.
+	w = optFormats{}
+	if err := Unmarshal([]byte(`{"Sec": 1.0000000019, "Base16": "AB", "Milli": null}`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Sec != time.Second+1 || !bytes.Equal(w.Base16, []byte{0xab}) || w.Milli != nil {
+		t.Errorf("got %+v", w)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{`{"Units": 1}`, "json: cannot unmarshal number 1 into Go struct field optFormats.Units of type time.Duration"},
+		{`{"Units": "1x"}`, `json: cannot unmarshal string "1x" into Go struct field optFormats.Units of type time.Duration`},
+		{`{"Sec": "1"}`, `json: cannot unmarshal string "1" into Go struct field optFormats.Sec of type time.Duration`},
+		{`{"Sec": 1e3}`, "json: cannot unmarshal number 1e3 into Go struct field optFormats.Sec of type time.Duration"},
+		{`{"Nano": 9223372036854775808}`, "json: cannot unmarshal number 9223372036854775808 into Go struct field optFormats.Nano of type time.Duration"},
+		{`{"Date": "2020"}`, `parsing time "2020" as "2006-01-02": cannot parse "" as "-"`},
+		{`{"Base16": "abc"}`, "json: odd length hexadecimal string"},
+		{`{"Array": "AQI="}`, "json: cannot unmarshal string into Go struct field optFormats.Array of type []uint8"},
+		{`{"Base32": [1]}`, "json: cannot unmarshal array into Go struct field optFormats.Base32 of type []uint8"},
+	} {
+		var v optFormats
+		err := Unmarshal([]byte(tt.in), &v)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%s): error %v\nwant %s", tt.in, err, tt.want)
+		}
+	}
+
+	type T struct {
+		N int `json:",format:units"`
+	}
+	want := `json: invalid format "units" for type int`
+	if _, err := Marshal(T{}); err == nil || err.Error() != want {
+		t.Errorf("Marshal: error %v, want %s", err, want)
+	}
+	if err := Unmarshal([]byte(`{"N": 1}`), new(T)); err == nil || err.Error() != want {
+		t.Errorf("Unmarshal: error %v, want %s", err, want)
+	}
+}
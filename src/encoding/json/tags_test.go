@@ -26,3 +26,21 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagOptionValue(t *testing.T) {
+	_, opts := parseTag("field,format,omitzero,format:units,formatx:y")
+	for _, tt := range []struct {
+		opt   string
+		value string
+		ok    bool
+	}{
+		{"format", "units", true},
+		{"formatx", "y", true},
+		{"omitzero", "", false},
+		{"form", "", false},
+	} {
+		if value, ok := opts.Value(tt.opt); value != tt.value || ok != tt.ok {
+			t.Errorf("Value(%q) = %q, %v, want %q, %v", tt.opt, value, ok, tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A CanonicalWriter writes XML tokens in the canonical form defined by
+// Exclusive XML Canonicalization Version 1.0,
+// https://www.w3.org/TR/xml-exc-c14n/, as XML Signature requires of the
+// data it signs. Two documents, or parts of documents, that are
+// equivalent but for their syntax, such as the order of attributes or
+// the quoting of characters, have the same canonical form.
+//
+// The tokens are those of a document, or of an element and its
+// descendants, as returned by Decoder.Token, which keeps the name space
+// declarations of the input as xmlns attributes and the prefixes of
+// names in the Prefix and AttrPrefix fields of a StartElement; the
+// writer writes names with those prefixes. Each element is written
+// with the declarations of the prefixes it uses, unless they are
+// already written on an ancestor in the output, and without the others.
+// An empty element is written as a start and end tag, the xml
+// declaration and directives are dropped, and so is character data
+// outside the elements.
+type CanonicalWriter struct {
+	// InclusivePrefixes lists prefixes whose declarations are written
+	// as in inclusive canonicalization, on each element where they are
+	// in scope and not already written, whether used or not. It is the
+	// InclusiveNamespaces PrefixList of the specification, so "#default"
+	// stands for the default name space.
+	InclusivePrefixes []string
+
+	// Comments makes the writer include comments, as the "with
+	// comments" variant of the canonicalization does. By default,
+	// they are dropped.
+	Comments bool
+
+	w        *bufio.Writer
+	ns       nsScope // declarations in scope
+	rendered nsScope // declarations written, in the same scopes as ns
+	tags     []Name
+	qnames   []string
+	started  bool // whether an element has been written
+}
+
+// NewCanonicalWriter returns a new CanonicalWriter that writes to w.
+//
+// The map inScope holds the name space declarations, from prefix to URL,
+// in scope where the tokens appear in the document, such as those
+// returned by Decoder.Namespaces after Token returns the StartElement
+// of an element to write with its descendants. It may be nil for a
+// whole document. The key "" holds the default name space.
+func NewCanonicalWriter(w io.Writer, inScope map[string]string) *CanonicalWriter {
+	c := &CanonicalWriter{w: bufio.NewWriter(w)}
+	prefixes := make([]string, 0, len(inScope))
+	for prefix := range inScope {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		c.ns.declare(prefix, inScope[prefix])
+	}
+	return c
+}
+
+// WriteToken writes the canonical form of the given token.
+// It returns an error if StartElement and EndElement tokens are not
+// properly matched, or if a name has a name space that no prefix in
+// scope is declared for.
+//
+// WriteToken does not call Flush.
+func (c *CanonicalWriter) WriteToken(t Token) error {
+	w := c.w
+	switch t := t.(type) {
+	case StartElement:
+		if err := c.writeStart(&t); err != nil {
+			return err
+		}
+	case EndElement:
+		if err := c.writeEnd(t.Name); err != nil {
+			return err
+		}
+	case CharData:
+		if len(c.tags) > 0 {
+			escapeCanonical(w, t, false)
+		}
+	case Comment:
+		if !c.Comments {
+			break
+		}
+		if bytes.Contains(t, endComment) {
+			return fmt.Errorf("xml: WriteToken of Comment containing --> marker")
+		}
+		c.beginTopLevel()
+		w.WriteString("<!--")
+		w.Write(t)
+		w.WriteString("-->")
+		c.endTopLevel()
+	case ProcInst:
+		if t.Target == "xml" {
+			break
+		}
+		if !isNameString(t.Target) {
+			return fmt.Errorf("xml: WriteToken of ProcInst with invalid Target")
+		}
+		if bytes.Contains(t.Inst, endProcInst) {
+			return fmt.Errorf("xml: WriteToken of ProcInst containing ?> marker")
+		}
+		c.beginTopLevel()
+		w.WriteString("<?")
+		w.WriteString(t.Target)
+		if len(t.Inst) > 0 {
+			w.WriteByte(' ')
+			w.Write(t.Inst)
+		}
+		w.WriteString("?>")
+		c.endTopLevel()
+	case Directive:
+	default:
+		return fmt.Errorf("xml: WriteToken of invalid token type")
+	}
+	return nil
+}
+
+// beginTopLevel and endTopLevel separate a comment or processing
+// instruction outside the elements from the element before or after it
+// with a newline.
+func (c *CanonicalWriter) beginTopLevel() {
+	if len(c.tags) == 0 && c.started {
+		c.w.WriteByte('\n')
+	}
+}
+
+func (c *CanonicalWriter) endTopLevel() {
+	if len(c.tags) == 0 && !c.started {
+		c.w.WriteByte('\n')
+	}
+}
+
+// Flush flushes any buffered XML to the underlying writer.
+func (c *CanonicalWriter) Flush() error {
+	return c.w.Flush()
+}
+
+func (c *CanonicalWriter) writeStart(start *StartElement) error {
+	if start.Name.Local == "" {
+		return fmt.Errorf("xml: start tag with no name")
+	}
+	c.ns.push()
+	var attrs []Attr
+	for _, attr := range start.Attr {
+		if prefix, ok := declaredPrefix(attr.Name); ok {
+			c.ns.declare(prefix, attr.Value)
+		} else if attr.Name.Local != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	// The prefixes that the element visibly utilizes, with those of the
+	// InclusivePrefixes in scope.
+	var used []string
+	qname, err := c.qname(start.Name, start.Prefix, true)
+	if err != nil {
+		c.ns.pop()
+		return err
+	}
+	if url, _ := c.ns.url(""); qname.prefix == "" && url != start.Name.Space {
+		// An unprefixed name in no name space undeclares the default.
+		c.ns.declare("", start.Name.Space)
+	}
+	used = append(used, qname.prefix)
+	qnames := make([]string, len(attrs))
+	for i, attr := range attrs {
+		qname, err := c.qname(attr.Name, start.AttrPrefix[attr.Name], false)
+		if err != nil {
+			c.ns.pop()
+			return err
+		}
+		if qname.prefix != "" && qname.prefix != xmlPrefix {
+			used = append(used, qname.prefix)
+		}
+		qnames[i] = qname.String()
+	}
+	for _, prefix := range c.InclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if _, ok := c.ns.url(prefix); ok {
+			used = append(used, prefix)
+		}
+	}
+
+	c.rendered.push()
+	c.tags = append(c.tags, start.Name)
+	c.qnames = append(c.qnames, qname.String())
+	c.started = true
+
+	w := c.w
+	w.WriteByte('<')
+	w.WriteString(c.qnames[len(c.qnames)-1])
+
+	sort.Strings(used)
+	for i, prefix := range used {
+		if i > 0 && prefix == used[i-1] {
+			continue
+		}
+		url, _ := c.ns.url(prefix)
+		written, ok := c.rendered.url(prefix)
+		if ok && written == url || !ok && url == "" {
+			continue
+		}
+		c.rendered.declare(prefix, url)
+		w.WriteString(" xmlns")
+		if prefix != "" {
+			w.WriteByte(':')
+			w.WriteString(prefix)
+		}
+		w.WriteString(`="`)
+		escapeCanonical(w, []byte(url), true)
+		w.WriteByte('"')
+	}
+
+	order := make([]int, len(attrs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := attrs[order[i]].Name, attrs[order[j]].Name
+		if a.Space != b.Space {
+			return a.Space < b.Space
+		}
+		return a.Local < b.Local
+	})
+	for _, i := range order {
+		w.WriteByte(' ')
+		w.WriteString(qnames[i])
+		w.WriteString(`="`)
+		escapeCanonical(w, []byte(attrs[i].Value), true)
+		w.WriteByte('"')
+	}
+	w.WriteByte('>')
+	return nil
+}
+
+// A qualifiedName is a name as written, with a prefix.
+type qualifiedName struct {
+	prefix, local string
+}
+
+func (q qualifiedName) String() string {
+	if q.prefix == "" {
+		return q.local
+	}
+	return q.prefix + ":" + q.local
+}
+
+// qname returns the name as written of the element or attribute name,
+// with prefix if it is in scope for the name space of name, or else
+// with another prefix in scope.
+func (c *CanonicalWriter) qname(name Name, prefix string, isElementName bool) (qualifiedName, error) {
+	switch name.Space {
+	case "":
+		return qualifiedName{"", name.Local}, nil
+	case xmlURL:
+		return qualifiedName{xmlPrefix, name.Local}, nil
+	}
+	prefix, ok := c.ns.prefix(name.Space, prefix, isElementName)
+	if !ok {
+		return qualifiedName{}, fmt.Errorf("xml: no prefix in scope for name space %s of <%s>", name.Space, name.Local)
+	}
+	return qualifiedName{prefix, name.Local}, nil
+}
+
+func (c *CanonicalWriter) writeEnd(name Name) error {
+	if name.Local == "" {
+		return fmt.Errorf("xml: end tag with no name")
+	}
+	if len(c.tags) == 0 {
+		return fmt.Errorf("xml: end tag </%s> without start tag", name.Local)
+	}
+	if top := c.tags[len(c.tags)-1]; top != name {
+		if top.Local != name.Local {
+			return fmt.Errorf("xml: end tag </%s> does not match start tag <%s>", name.Local, top.Local)
+		}
+		return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s", name.Local, name.Space, top.Local, top.Space)
+	}
+	n := len(c.tags) - 1
+	c.tags = c.tags[:n]
+	c.w.WriteString("</")
+	c.w.WriteString(c.qnames[n])
+	c.w.WriteByte('>')
+	c.qnames = c.qnames[:n]
+	c.ns.pop()
+	c.rendered.pop()
+	return nil
+}
+
+// escapeCanonical writes s to w with the character references of the
+// canonical form of text, or of an attribute value if isAttr.
+func escapeCanonical(w *bufio.Writer, s []byte, isAttr bool) {
+	last := 0
+	for i, c := range s {
+		var esc string
+		switch {
+		case c == '&':
+			esc = "&amp;"
+		case c == '<':
+			esc = "&lt;"
+		case c == '>' && !isAttr:
+			esc = "&gt;"
+		case c == '"' && isAttr:
+			esc = "&quot;"
+		case c == '\t' && isAttr:
+			esc = "&#x9;"
+		case c == '\n' && isAttr:
+			esc = "&#xA;"
+		case c == '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		w.Write(s[last:i])
+		w.WriteString(esc)
+		last = i + 1
+	}
+	w.Write(s[last:])
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var canonicalTests = []struct {
+	in        string
+	out       string
+	comments  bool
+	inclusive []string
+}{
+	// Processing instructions and comments, from section 3.1 of
+	// https://www.w3.org/TR/xml-c14n.
+	{
+		in: `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`,
+		out: `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!</doc>
+<?pi-without-data?>`,
+	},
+	{
+		in: `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`,
+		out: `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!<!-- Comment 1 --></doc>
+<?pi-without-data?>
+<!-- Comment 2 -->
+<!-- Comment 3 -->`,
+		comments: true,
+	},
+	// Only the declarations of the prefixes used are written,
+	// on the outermost element that uses them.
+	{
+		in:  `<a:root xmlns:a="urn:a" xmlns:b="urn:b" xmlns="urn:d"><a:x b:attr="1" attr="2" a:z="0"><y/><b:w xmlns:a="urn:a"/></a:x></a:root>`,
+		out: `<a:root xmlns:a="urn:a"><a:x xmlns:b="urn:b" attr="2" a:z="0" b:attr="1"><y xmlns="urn:d"></y><b:w></b:w></a:x></a:root>`,
+	},
+	{
+		in:        `<a:r xmlns:a="urn:a" xmlns:b="urn:b" xmlns="urn:d"><a:c/></a:r>`,
+		out:       `<a:r xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b"><a:c></a:c></a:r>`,
+		inclusive: []string{"b", "#default", "c"},
+	},
+	{
+		in:  `<a:r xmlns:a="urn:a"><a:c xmlns:a="urn:b"><a:d xmlns:a="urn:a"/></a:c></a:r>`,
+		out: `<a:r xmlns:a="urn:a"><a:c xmlns:a="urn:b"><a:d xmlns:a="urn:a"></a:d></a:c></a:r>`,
+	},
+	// The default name space.
+	{
+		in:  `<r xmlns="urn:d"><e xmlns=""><f/></e></r>`,
+		out: `<r xmlns="urn:d"><e xmlns=""><f></f></e></r>`,
+	},
+	{
+		in:  `<r><e xmlns=""/></r>`,
+		out: `<r><e></e></r>`,
+	},
+	{
+		in:  `<r xmlns="urn:d"><p:e xmlns:p="urn:p"><f/></p:e></r>`,
+		out: `<r xmlns="urn:d"><p:e xmlns:p="urn:p"><f></f></p:e></r>`,
+	},
+	// Names keep their prefixes where a name space has several, as
+	// xmllint --exc-c14n writes them.
+	{
+		in:  `<r xmlns:a="urn:same" xmlns:b="urn:same"><a:x b:y="1"/></r>`,
+		out: `<r><a:x xmlns:a="urn:same" xmlns:b="urn:same" b:y="1"></a:x></r>`,
+	},
+	{
+		in:  `<r xmlns="urn:d" xmlns:q="urn:d"><x/><q:y/></r>`,
+		out: `<r xmlns="urn:d"><x></x><q:y xmlns:q="urn:d"></q:y></r>`,
+	},
+	{
+		in:  `<r xmlns:a="urn:a" xmlns:b="urn:a"><b:x a:y="1" b:z="2"><a:w/></b:x></r>`,
+		out: `<r><b:x xmlns:a="urn:a" xmlns:b="urn:a" a:y="1" b:z="2"><a:w></a:w></b:x></r>`,
+	},
+	{
+		in:  `<q:r xmlns:q="urn:d" xmlns="urn:d"><x q:a="1"/></q:r>`,
+		out: `<q:r xmlns:q="urn:d"><x xmlns="urn:d" q:a="1"></x></q:r>`,
+	},
+	// Character references.
+	{
+		in:  "<e xml:lang=\"en\" a=\"&lt;&quot;&#9;&#10;&#13;&gt;'\">&amp;&lt;&gt;&#13;\"'<![CDATA[<x>&]]></e>",
+		out: "<e a=\"&lt;&quot;&#x9;&#xA;&#xD;>'\" xml:lang=\"en\">&amp;&lt;&gt;&#xD;\"'&lt;x&gt;&amp;</e>",
+	},
+}
+
+func TestCanonicalWriter(t *testing.T) {
+	for _, tt := range canonicalTests {
+		var buf bytes.Buffer
+		c := NewCanonicalWriter(&buf, nil)
+		c.Comments = tt.comments
+		c.InclusivePrefixes = tt.inclusive
+		d := NewDecoder(strings.NewReader(tt.in))
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: Token: %v", tt.in, err)
+			}
+			if err := c.WriteToken(tok); err != nil {
+				t.Fatalf("%s: WriteToken: %v", tt.in, err)
+			}
+		}
+		if err := c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.out {
+			t.Errorf("%s:\nhave %s\nwant %s", tt.in, got, tt.out)
+		}
+	}
+}
+
+// The example of section 2.2 of https://www.w3.org/TR/xml-exc-c14n/,
+// which canonicalizes an element without its ancestors.
+func TestCanonicalWriterElement(t *testing.T) {
+	const in = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`
+	const want = `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`
+
+	d := NewDecoder(strings.NewReader(in))
+	var buf bytes.Buffer
+	var c *CanonicalWriter
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := tok.(StartElement); ok && start.Name.Local == "elem2" {
+			c = NewCanonicalWriter(&buf, d.Namespaces())
+		}
+		if c == nil {
+			continue
+		}
+		if err := c.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+		switch tok.(type) {
+		case StartElement:
+			depth++
+		case EndElement:
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("have %s\nwant %s", got, want)
+	}
+}
+
+func TestCanonicalWriterErrors(t *testing.T) {
+	tests := []struct {
+		toks []Token
+		err  string
+	}{
+		{
+			toks: []Token{StartElement{Name: Name{Space: "urn:a", Local: "a"}}},
+			err:  "xml: no prefix in scope for name space urn:a of <a>",
+		},
+		{
+			toks: []Token{StartElement{Name: Name{Local: "a"}, Attr: []Attr{{Name: Name{Space: "urn:a", Local: "b"}}}}},
+			err:  "xml: no prefix in scope for name space urn:a of <b>",
+		},
+		{
+			toks: []Token{StartElement{Name: Name{Local: "a"}}, EndElement{Name: Name{Local: "b"}}},
+			err:  "xml: end tag </b> does not match start tag <a>",
+		},
+		{
+			toks: []Token{EndElement{Name: Name{Local: "a"}}},
+			err:  "xml: end tag </a> without start tag",
+		},
+	}
+	for _, tt := range tests {
+		c := NewCanonicalWriter(io.Discard, nil)
+		var err error
+		for _, tok := range tt.toks {
+			if err = c.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%v: error %v, want %s", tt.toks, err, tt.err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func ExampleMarshalIndent() {
@@ -149,3 +150,68 @@ func ExampleUnmarshal() {
 	// Groups: [Friends Squash]
 	// Address: {Hanga Roa Easter Island}
 }
+
+// This example marshals a SOAP envelope with the conventional soap prefix,
+// declared by an attribute of the envelope.
+func ExampleEncoder_UsePrefixes() {
+	const soapNS = "http://schemas.xmlsoap.org/soap/envelope/"
+	type Body struct {
+		Text string `xml:",chardata"`
+	}
+	type Envelope struct {
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		Soap    string   `xml:"xmlns soap,attr"`
+		Body    Body     `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+	}
+
+	enc := xml.NewEncoder(os.Stdout)
+	enc.UsePrefixes(nil)
+	if err := enc.Encode(Envelope{Soap: soapNS, Body: Body{"Hello"}}); err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+
+	// Output:
+	// <soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>Hello</soap:Body></soap:Envelope>
+}
+
+// This example canonicalizes an element of a document, as XML Signature
+// does with the element it signs.
+func ExampleCanonicalWriter() {
+	const doc = `<Response xmlns="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+<saml:Assertion ID="a1"   Version='2.0'><saml:Issuer>https://idp.example.com</saml:Issuer><saml:Subject/></saml:Assertion>
+</Response>`
+
+	d := xml.NewDecoder(strings.NewReader(doc))
+	var c *xml.CanonicalWriter
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "Assertion" {
+			c = xml.NewCanonicalWriter(os.Stdout, d.Namespaces())
+		}
+		if c == nil {
+			continue
+		}
+		if err := c.WriteToken(tok); err != nil {
+			fmt.Printf("error: %v\n", err)
+			return
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	c.Flush()
+
+	// Output:
+	// <saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="a1" Version="2.0"><saml:Issuer>https://idp.example.com</saml:Issuer><saml:Subject></saml:Subject></saml:Assertion>
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -152,6 +153,41 @@ func (enc *Encoder) Indent(prefix, indent string) {
 	enc.p.indent = indent
 }
 
+// UsePrefixes sets the encoder to write name spaces with prefixes, as
+// XML documents that use them do, instead of writing an xmlns attribute
+// for the name space of each element and making up prefixes for those of
+// attributes.
+//
+// The xmlns and xmlns:prefix attributes of a StartElement are then written
+// as name space declarations, and the names of the element and its
+// descendants, and of their attributes, are written with the prefix
+// declared for their Space, declaring one only if none is in scope.
+// An element name is written unprefixed, in the default name space in
+// scope, if its Space is empty. If several prefixes are in scope for the
+// same Space, the one given by the Prefix or AttrPrefix field of the
+// StartElement is used, or else the one declared most recently. Thus the
+// tokens returned by Decoder.Token are written with the prefixes of the
+// input.
+//
+// The map inScope holds the declarations, from prefix to URL, already in
+// scope where the output appears, such as those returned by
+// Decoder.Namespaces for a part of a larger document; their prefixes are
+// used without being declared again. The key "" holds the default name
+// space.
+func (enc *Encoder) UsePrefixes(inScope map[string]string) {
+	p := &enc.p
+	p.usePrefixes = true
+	p.ns = nsScope{}
+	prefixes := make([]string, 0, len(inScope))
+	for prefix := range inScope {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		p.ns.declare(prefix, inScope[prefix])
+	}
+}
+
 // Encode writes the XML encoding of v to the stream.
 //
 // See the documentation for Marshal for details about the conversion
@@ -313,6 +349,97 @@ type printer struct {
 	attrPrefix map[string]string // map name space -> prefix
 	prefixes   []string
 	tags       []Name
+
+	// Set by UsePrefixes.
+	usePrefixes bool
+	ns          nsScope
+	qnames      []string // prefixed names of the open elements
+}
+
+// An nsBinding binds a name space prefix to a URL.
+// The prefix "" is the default name space.
+type nsBinding struct {
+	prefix, url string
+}
+
+// An nsScope holds the name space declarations in scope in a document.
+type nsScope struct {
+	bindings []nsBinding // in the order declared
+	marks    []int       // len(bindings) at the start of each open element
+}
+
+// push opens the scope of an element.
+func (s *nsScope) push() {
+	s.marks = append(s.marks, len(s.bindings))
+}
+
+// pop closes the scope of the innermost element, dropping its declarations.
+func (s *nsScope) pop() {
+	n := len(s.marks) - 1
+	s.bindings = s.bindings[:s.marks[n]]
+	s.marks = s.marks[:n]
+}
+
+func (s *nsScope) declare(prefix, url string) {
+	s.bindings = append(s.bindings, nsBinding{prefix, url})
+}
+
+// url returns the URL bound to prefix.
+func (s *nsScope) url(prefix string) (string, bool) {
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		if b := s.bindings[i]; b.prefix == prefix {
+			return b.url, true
+		}
+	}
+	return "", false
+}
+
+// prefix returns a prefix bound to url in scope: preferred, such as the
+// prefix of the name in the input, if it is bound to url, or else the
+// one bound most recently. The default name space applies only to
+// element names.
+func (s *nsScope) prefix(url, preferred string, isElementName bool) (string, bool) {
+	if preferred != "" || isElementName {
+		if u, ok := s.url(preferred); ok && u == url {
+			return preferred, true
+		}
+	}
+	for i := len(s.bindings) - 1; i >= 0; i-- {
+		b := s.bindings[i]
+		if b.url != url || b.prefix == "" && !isElementName {
+			continue
+		}
+		if u, _ := s.url(b.prefix); u == url {
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// declaredHere reports whether the innermost element declares prefix.
+func (s *nsScope) declaredHere(prefix string) bool {
+	start := 0
+	if len(s.marks) > 0 {
+		start = s.marks[len(s.marks)-1]
+	}
+	for _, b := range s.bindings[start:] {
+		if b.prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredPrefix returns the prefix declared by an attribute named name,
+// if it is a name space declaration.
+func declaredPrefix(name Name) (string, bool) {
+	switch {
+	case name.Space == xmlnsPrefix:
+		return name.Local, true
+	case name.Space == "" && name.Local == xmlnsPrefix:
+		return "", true
+	}
+	return "", false
 }
 
 // createAttrPrefix finds the name space prefix attribute to use for the given name space,
@@ -336,6 +463,24 @@ func (p *printer) createAttrPrefix(url string) string {
 		p.attrNS = make(map[string]string)
 	}
 
+	prefix := p.newPrefix(url, func(prefix string) bool { return p.attrNS[prefix] != "" })
+
+	p.attrPrefix[url] = prefix
+	p.attrNS[prefix] = url
+
+	p.WriteString(`xmlns:`)
+	p.WriteString(prefix)
+	p.WriteString(`="`)
+	EscapeText(p, []byte(url))
+	p.WriteString(`" `)
+
+	p.prefixes = append(p.prefixes, prefix)
+
+	return prefix
+}
+
+// newPrefix picks a new name space prefix for url, one that is not taken.
+func (p *printer) newPrefix(url string, taken func(prefix string) bool) string {
 	// Pick a name. We try to use the final element of the path
 	// but fall back to _.
 	prefix := strings.TrimRight(url, "/")
@@ -352,27 +497,15 @@ func (p *printer) createAttrPrefix(url string) string {
 	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
 		prefix = "_" + prefix
 	}
-	if p.attrNS[prefix] != "" {
+	if taken(prefix) {
 		// Name is taken. Find a better one.
 		for p.seq++; ; p.seq++ {
-			if id := prefix + "_" + strconv.Itoa(p.seq); p.attrNS[id] == "" {
+			if id := prefix + "_" + strconv.Itoa(p.seq); !taken(id) {
 				prefix = id
 				break
 			}
 		}
 	}
-
-	p.attrPrefix[url] = prefix
-	p.attrNS[prefix] = url
-
-	p.WriteString(`xmlns:`)
-	p.WriteString(prefix)
-	p.WriteString(`="`)
-	EscapeText(p, []byte(url))
-	p.WriteString(`" `)
-
-	p.prefixes = append(p.prefixes, prefix)
-
 	return prefix
 }
 
@@ -699,6 +832,10 @@ func (p *printer) writeStart(start *StartElement) error {
 
 	p.writeIndent(1)
 	p.WriteByte('<')
+	if p.usePrefixes {
+		p.writeStartPrefixed(start)
+		return nil
+	}
 	p.WriteString(start.Name.Local)
 
 	if start.Name.Space != "" {
@@ -727,6 +864,87 @@ func (p *printer) writeStart(start *StartElement) error {
 	return nil
 }
 
+// writeStartPrefixed writes the given start element after its '<',
+// for UsePrefixes.
+func (p *printer) writeStartPrefixed(start *StartElement) {
+	p.ns.push()
+	for _, attr := range start.Attr {
+		if prefix, ok := declaredPrefix(attr.Name); ok && attr.Name.Local != "" {
+			p.ns.declare(prefix, attr.Value)
+		}
+	}
+
+	var decl *nsBinding
+	qname := start.Name.Local
+	if url := start.Name.Space; url != "" {
+		prefix, ok := p.ns.prefix(url, start.Prefix, true)
+		if !ok {
+			if p.ns.declaredHere("") {
+				prefix = p.newPrefix(url, p.prefixTaken)
+			}
+			p.ns.declare(prefix, url)
+			decl = &nsBinding{prefix, url}
+		}
+		if prefix != "" {
+			qname = prefix + ":" + qname
+		}
+	}
+	p.qnames = append(p.qnames, qname)
+	p.WriteString(qname)
+	if decl != nil {
+		p.writeDecl(decl.prefix, decl.url)
+	}
+
+	for _, attr := range start.Attr {
+		name := attr.Name
+		if name.Local == "" {
+			continue
+		}
+		if prefix, ok := declaredPrefix(name); ok {
+			p.writeDecl(prefix, attr.Value)
+			continue
+		}
+		qname := name.Local
+		switch name.Space {
+		case "":
+		case xmlURL:
+			qname = xmlPrefix + ":" + qname
+		default:
+			prefix, ok := p.ns.prefix(name.Space, start.AttrPrefix[name], false)
+			if !ok {
+				prefix = p.newPrefix(name.Space, p.prefixTaken)
+				p.ns.declare(prefix, name.Space)
+				p.writeDecl(prefix, name.Space)
+			}
+			qname = prefix + ":" + qname
+		}
+		p.WriteByte(' ')
+		p.WriteString(qname)
+		p.WriteString(`="`)
+		p.EscapeString(attr.Value)
+		p.WriteByte('"')
+	}
+	p.WriteByte('>')
+}
+
+// prefixTaken reports whether prefix is bound in scope, for UsePrefixes.
+func (p *printer) prefixTaken(prefix string) bool {
+	_, ok := p.ns.url(prefix)
+	return ok
+}
+
+// writeDecl writes the declaration of prefix as url, with a leading space.
+func (p *printer) writeDecl(prefix, url string) {
+	p.WriteString(" xmlns")
+	if prefix != "" {
+		p.WriteByte(':')
+		p.WriteString(prefix)
+	}
+	p.WriteString(`="`)
+	p.EscapeString(url)
+	p.WriteByte('"')
+}
+
 func (p *printer) writeEnd(name Name) error {
 	if name.Local == "" {
 		return fmt.Errorf("xml: end tag with no name")
@@ -745,7 +963,14 @@ func (p *printer) writeEnd(name Name) error {
 	p.writeIndent(-1)
 	p.WriteByte('<')
 	p.WriteByte('/')
-	p.WriteString(name.Local)
+	if p.usePrefixes {
+		n := len(p.qnames) - 1
+		p.WriteString(p.qnames[n])
+		p.qnames = p.qnames[:n]
+		p.ns.pop()
+	} else {
+		p.WriteString(name.Local)
+	}
 	p.WriteByte('>')
 	p.popPrefix()
 	return nil
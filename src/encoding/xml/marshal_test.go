@@ -1963,13 +1963,13 @@ var encodeTokenTests = []struct {
 }{{
 	desc: "start element with name space",
 	toks: []Token{
-		StartElement{Name{"space", "local"}, nil},
+		StartElement{Name: Name{"space", "local"}, Attr: nil},
 	},
 	want: `<local xmlns="space">`,
 }, {
 	desc: "start element with no name",
 	toks: []Token{
-		StartElement{Name{"space", ""}, nil},
+		StartElement{Name: Name{"space", ""}, Attr: nil},
 	},
 	err: "xml: start tag with no name",
 }, {
@@ -2047,7 +2047,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "mismatching end tag local name",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, nil},
+		StartElement{Name: Name{"", "foo"}, Attr: nil},
 		EndElement{Name{"", "bar"}},
 	},
 	err:  "xml: end tag </bar> does not match start tag <foo>",
@@ -2055,7 +2055,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "mismatching end tag namespace",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, nil},
+		StartElement{Name: Name{"space", "foo"}, Attr: nil},
 		EndElement{Name{"another", "foo"}},
 	},
 	err:  "xml: end tag </foo> in namespace another does not match start tag <foo> in namespace space",
@@ -2063,7 +2063,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "start element with explicit namespace",
 	toks: []Token{
-		StartElement{Name{"space", "local"}, []Attr{
+		StartElement{Name: Name{"space", "local"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space"},
 			{Name{"space", "foo"}, "value"},
 		}},
@@ -2072,7 +2072,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "start element with explicit namespace and colliding prefix",
 	toks: []Token{
-		StartElement{Name{"space", "local"}, []Attr{
+		StartElement{Name: Name{"space", "local"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space"},
 			{Name{"space", "foo"}, "value"},
 			{Name{"x", "bar"}, "other"},
@@ -2082,10 +2082,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "start element using previously defined namespace",
 	toks: []Token{
-		StartElement{Name{"", "local"}, []Attr{
+		StartElement{Name: Name{"", "local"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space"},
 		}},
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"space", "x"}, "y"},
 		}},
 	},
@@ -2093,19 +2093,19 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested name space with same prefix",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space1"},
 		}},
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space2"},
 		}},
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"space1", "a"}, "space1 value"},
 			{Name{"space2", "b"}, "space2 value"},
 		}},
 		EndElement{Name{"", "foo"}},
 		EndElement{Name{"", "foo"}},
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"space1", "a"}, "space1 value"},
 			{Name{"space2", "b"}, "space2 value"},
 		}},
@@ -2114,7 +2114,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "start element defining several prefixes for the same name space",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "a"}, "space"},
 			{Name{"xmlns", "b"}, "space"},
 			{Name{"space", "x"}, "value"},
@@ -2124,10 +2124,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element redefines name space",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space"},
 		}},
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "y"}, "space"},
 			{Name{"space", "a"}, "value"},
 		}},
@@ -2136,10 +2136,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element creates alias for default name space",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 		}},
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "y"}, "space"},
 			{Name{"space", "a"}, "value"},
 		}},
@@ -2148,10 +2148,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element defines default name space with existing prefix",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "x"}, "space"},
 		}},
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 			{Name{"space", "a"}, "value"},
 		}},
@@ -2160,10 +2160,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element uses empty attribute name space when default ns defined",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 		}},
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "attr"}, "value"},
 		}},
 	},
@@ -2171,7 +2171,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "redefine xmlns",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"foo", "xmlns"}, "space"},
 		}},
 	},
@@ -2179,7 +2179,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "xmlns with explicit name space #1",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"xml", "xmlns"}, "space"},
 		}},
 	},
@@ -2187,7 +2187,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "xmlns with explicit name space #2",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{xmlURL, "xmlns"}, "space"},
 		}},
 	},
@@ -2195,7 +2195,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "empty name space declaration is ignored",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"xmlns", "foo"}, ""},
 		}},
 	},
@@ -2203,7 +2203,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "attribute with no name is ignored",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"", ""}, "value"},
 		}},
 	},
@@ -2211,7 +2211,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "namespace URL with non-valid name",
 	toks: []Token{
-		StartElement{Name{"/34", "foo"}, []Attr{
+		StartElement{Name: Name{"/34", "foo"}, Attr: []Attr{
 			{Name{"/34", "x"}, "value"},
 		}},
 	},
@@ -2219,10 +2219,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element resets default namespace to empty",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 		}},
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, ""},
 			{Name{"", "x"}, "value"},
 			{Name{"space", "x"}, "value"},
@@ -2232,16 +2232,16 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "nested element requires empty default name space",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 		}},
-		StartElement{Name{"", "foo"}, nil},
+		StartElement{Name: Name{"", "foo"}, Attr: nil},
 	},
 	want: `<foo xmlns="space" xmlns="space"><foo>`,
 }, {
 	desc: "attribute uses name space from xmlns",
 	toks: []Token{
-		StartElement{Name{"some/space", "foo"}, []Attr{
+		StartElement{Name: Name{"some/space", "foo"}, Attr: []Attr{
 			{Name{"", "attr"}, "value"},
 			{Name{"some/space", "other"}, "other value"},
 		}},
@@ -2250,12 +2250,12 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "default name space should not be used by attributes",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 			{Name{"xmlns", "bar"}, "space"},
 			{Name{"space", "baz"}, "foo"},
 		}},
-		StartElement{Name{"space", "baz"}, nil},
+		StartElement{Name: Name{"space", "baz"}, Attr: nil},
 		EndElement{Name{"space", "baz"}},
 		EndElement{Name{"space", "foo"}},
 	},
@@ -2263,11 +2263,11 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "default name space not used by attributes, not explicitly defined",
 	toks: []Token{
-		StartElement{Name{"space", "foo"}, []Attr{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 			{Name{"space", "baz"}, "foo"},
 		}},
-		StartElement{Name{"space", "baz"}, nil},
+		StartElement{Name: Name{"space", "baz"}, Attr: nil},
 		EndElement{Name{"space", "baz"}},
 		EndElement{Name{"space", "foo"}},
 	},
@@ -2275,10 +2275,10 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "impossible xmlns declaration",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"", "xmlns"}, "space"},
 		}},
-		StartElement{Name{"space", "bar"}, []Attr{
+		StartElement{Name: Name{"space", "bar"}, Attr: []Attr{
 			{Name{"space", "attr"}, "value"},
 		}},
 	},
@@ -2286,7 +2286,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "reserved namespace prefix -- all lower case",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"http://www.w3.org/2001/xmlSchema-instance", "nil"}, "true"},
 		}},
 	},
@@ -2294,7 +2294,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "reserved namespace prefix -- all upper case",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"http://www.w3.org/2001/XMLSchema-instance", "nil"}, "true"},
 		}},
 	},
@@ -2302,7 +2302,7 @@ var encodeTokenTests = []struct {
 }, {
 	desc: "reserved namespace prefix -- all mixed case",
 	toks: []Token{
-		StartElement{Name{"", "foo"}, []Attr{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
 			{Name{"http://www.w3.org/2001/XmLSchema-instance", "nil"}, "true"},
 		}},
 	},
@@ -2381,6 +2381,147 @@ func TestDecodeEncode(t *testing.T) {
 	}
 }
 
+var usePrefixesTests = []struct {
+	desc    string
+	inScope map[string]string
+	toks    []Token
+	want    string
+}{{
+	desc: "start element in name space",
+	toks: []Token{
+		StartElement{Name: Name{"space", "local"}, Attr: nil},
+		EndElement{Name{"space", "local"}},
+	},
+	want: `<local xmlns="space"></local>`,
+}, {
+	desc: "start element with explicit namespace",
+	toks: []Token{
+		StartElement{Name: Name{"space", "local"}, Attr: []Attr{
+			{Name{"xmlns", "x"}, "space"},
+			{Name{"space", "foo"}, "value"},
+		}},
+		StartElement{Name: Name{"space", "bar"}, Attr: nil},
+		EndElement{Name{"space", "bar"}},
+		EndElement{Name{"space", "local"}},
+	},
+	want: `<x:local xmlns:x="space" x:foo="value"><x:bar></x:bar></x:local>`,
+}, {
+	desc: "attribute in undeclared namespace",
+	toks: []Token{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
+			{Name{"space", "a"}, "value"},
+			{Name{"space", "b"}, "value"},
+			{Name{xmlURL, "lang"}, "en"},
+		}},
+	},
+	want: `<foo xmlns:space="space" space:a="value" space:b="value" xml:lang="en">`,
+}, {
+	desc: "default namespace declared for another name space",
+	toks: []Token{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
+			{Name{"", "xmlns"}, "other"},
+		}},
+	},
+	want: `<space:foo xmlns:space="space" xmlns="other">`,
+}, {
+	desc: "shadowed prefix",
+	toks: []Token{
+		StartElement{Name: Name{"space1", "foo"}, Attr: []Attr{
+			{Name{"xmlns", "x"}, "space1"},
+		}},
+		StartElement{Name: Name{"space1", "foo"}, Attr: []Attr{
+			{Name{"xmlns", "x"}, "space2"},
+		}},
+		EndElement{Name{"space1", "foo"}},
+		StartElement{Name: Name{"space1", "bar"}, Attr: nil},
+	},
+	want: `<x:foo xmlns:x="space1"><foo xmlns="space1" xmlns:x="space2"></foo><x:bar>`,
+}, {
+	desc:    "prefix in scope",
+	inScope: map[string]string{"soap": "urn:soap", "": "urn:default"},
+	toks: []Token{
+		StartElement{Name: Name{"urn:soap", "Envelope"}, Attr: nil},
+		StartElement{Name: Name{"urn:default", "Body"}, Attr: nil},
+		EndElement{Name{"urn:default", "Body"}},
+		EndElement{Name{"urn:soap", "Envelope"}},
+	},
+	want: `<soap:Envelope><Body></Body></soap:Envelope>`,
+}, {
+	desc:    "new prefix taken in scope",
+	inScope: map[string]string{"space": "other"},
+	toks: []Token{
+		StartElement{Name: Name{"", "foo"}, Attr: []Attr{
+			{Name{"space", "a"}, "value"},
+		}},
+	},
+	want: `<foo xmlns:space_1="space" space_1:a="value">`,
+}, {
+	desc: "prefixes of names",
+	toks: []Token{
+		StartElement{Name: Name{"space", "foo"}, Attr: []Attr{
+			{Name{"", "xmlns"}, "space"},
+			{Name{"xmlns", "x"}, "space"},
+			{Name{"xmlns", "y"}, "space"},
+			{Name{"space", "a"}, "value"},
+			{Name{"space", "b"}, "value"},
+		}, AttrPrefix: map[Name]string{{"space", "a"}: "x"}},
+		StartElement{Name: Name{"space", "bar"}, Prefix: "x"},
+		StartElement{Name: Name{"space", "baz"}, Prefix: "z"},
+		EndElement{Name{"space", "baz"}},
+		EndElement{Name{"space", "bar"}},
+	},
+	want: `<foo xmlns="space" xmlns:x="space" xmlns:y="space" x:a="value" y:b="value"><x:bar><y:baz></y:baz></x:bar>`,
+}}
+
+func TestUsePrefixes(t *testing.T) {
+	for _, tt := range usePrefixesTests {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.UsePrefixes(tt.inScope)
+		for _, tok := range tt.toks {
+			if err := enc.EncodeToken(tok); err != nil {
+				t.Fatalf("%s: %v", tt.desc, err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeEncodePrefixes(t *testing.T) {
+	for _, in := range []string{
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` +
+			`<soap:Body wsu:Id="body" xmlns:wsu="urn:wsu"><ds:Signature><ds:SignedInfo xmlns="urn:d">` +
+			`<x a="1" xml:lang="en"><y xmlns=""></y></x></ds:SignedInfo></ds:Signature></soap:Body></soap:Envelope>`,
+		// Name spaces with several prefixes.
+		`<r xmlns:a="urn:same" xmlns:b="urn:same"><a:x b:y="1" a:z="2"></a:x><b:x></b:x></r>`,
+		`<r xmlns="urn:d" xmlns:q="urn:d"><x q:a="1"></x><q:y></q:y></r>`,
+	} {
+		var out bytes.Buffer
+		dec := NewDecoder(strings.NewReader(in))
+		enc := NewEncoder(&out)
+		enc.UsePrefixes(nil)
+		for tok, err := dec.Token(); err != io.EOF; tok, err = dec.Token() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.EncodeToken(tok); err != nil {
+				t.Fatalf("enc.EncodeToken: Unable to encode token (%#v), %v", tok, err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != in {
+			t.Errorf("got  %s\nwant %s", got, in)
+		}
+	}
+}
+
 // Issue 9796. Used to fail with GORACE="halt_on_error=1" -race.
 func TestRace9796(t *testing.T) {
 	type A struct{}
@@ -58,6 +58,14 @@ type Token interface{}
 type StartElement struct {
 	Name Name
 	Attr []Attr
+
+	// Prefix is the name space prefix that Name has in the input, and
+	// AttrPrefix maps the names in Attr that have a prefix to it, as
+	// set by Decoder.Token. An Encoder set by UsePrefixes and a
+	// CanonicalWriter write names with these prefixes where they are
+	// bound to the Space of the names.
+	Prefix     string
+	AttrPrefix map[Name]string
 }
 
 // Copy creates a new copy of StartElement.
@@ -65,6 +73,13 @@ func (e StartElement) Copy() StartElement {
 	attrs := make([]Attr, len(e.Attr))
 	copy(attrs, e.Attr)
 	e.Attr = attrs
+	if e.AttrPrefix != nil {
+		prefixes := make(map[Name]string, len(e.AttrPrefix))
+		for name, prefix := range e.AttrPrefix {
+			prefixes[name] = prefix
+		}
+		e.AttrPrefix = prefixes
+	}
 	return e
 }
 
@@ -276,6 +291,10 @@ func NewTokenDecoder(t TokenReader) *Decoder {
 // set to the URL identifying its name space when known.
 // If Token encounters an unrecognized name space prefix,
 // it uses the prefix as the Space rather than report an error.
+// The prefixes themselves are kept in the Prefix and AttrPrefix
+// fields of the StartElement, with the xmlns attributes that
+// declare them, so an Encoder set by UsePrefixes writes the
+// tokens with the prefixes of the input.
 func (d *Decoder) Token() (Token, error) {
 	var t Token
 	var err error
@@ -321,9 +340,18 @@ func (d *Decoder) Token() (Token, error) {
 			}
 		}
 
+		t1.Prefix = t1.Name.Space
 		d.translate(&t1.Name, true)
 		for i := range t1.Attr {
-			d.translate(&t1.Attr[i].Name, false)
+			name := &t1.Attr[i].Name
+			prefix := name.Space
+			d.translate(name, false)
+			if prefix != "" && prefix != xmlnsPrefix {
+				if t1.AttrPrefix == nil {
+					t1.AttrPrefix = make(map[Name]string)
+				}
+				t1.AttrPrefix[*name] = prefix
+			}
 		}
 		d.pushElement(t1.Name)
 		t = t1
@@ -841,7 +869,7 @@ func (d *Decoder) rawToken() (Token, error) {
 		d.needClose = true
 		d.toClose = name
 	}
-	return StartElement{name, attr}, nil
+	return StartElement{Name: name, Attr: attr}, nil
 }
 
 func (d *Decoder) attrval() []byte {
@@ -928,6 +956,31 @@ func (d *Decoder) InputOffset() int64 {
 	return d.offset
 }
 
+// Namespaces returns the name space prefixes in scope at the current
+// decoder position, as declared by the xmlns attributes of the elements
+// that Token has opened and not yet closed, mapped to their URLs.
+// The key "" holds the default name space, if any.
+//
+// The declarations of the prefixes of names are known only from the
+// xmlns attributes of the tokens. Namespaces reports those made outside
+// the tokens that a caller passes on, such as on the ancestors of an element
+// being re-encoded with Encoder.UsePrefixes or canonicalized with a
+// CanonicalWriter.
+func (d *Decoder) Namespaces() map[string]string {
+	ns := make(map[string]string)
+	if d.DefaultSpace != "" {
+		ns[""] = d.DefaultSpace
+	}
+	for prefix, url := range d.ns {
+		if url == "" {
+			delete(ns, prefix)
+			continue
+		}
+		ns[prefix] = url
+	}
+	return ns
+}
+
 // Return saved offset.
 // If we did ungetc (nextByte >= 0), have to back up one.
 func (d *Decoder) savedOffset() int {
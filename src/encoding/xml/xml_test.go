@@ -85,27 +85,27 @@ var rawTokens = []Token{
 	Directive(`DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN"
   "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd"`),
 	CharData("\n"),
-	StartElement{Name{"", "body"}, []Attr{{Name{"xmlns", "foo"}, "ns1"}, {Name{"", "xmlns"}, "ns2"}, {Name{"xmlns", "tag"}, "ns3"}}},
+	StartElement{Name: Name{"", "body"}, Attr: []Attr{{Name{"xmlns", "foo"}, "ns1"}, {Name{"", "xmlns"}, "ns2"}, {Name{"xmlns", "tag"}, "ns3"}}},
 	CharData("\n  "),
-	StartElement{Name{"", "hello"}, []Attr{{Name{"", "lang"}, "en"}}},
+	StartElement{Name: Name{"", "hello"}, Attr: []Attr{{Name{"", "lang"}, "en"}}},
 	CharData("World <>'\" 白鵬翔"),
 	EndElement{Name{"", "hello"}},
 	CharData("\n  "),
-	StartElement{Name{"", "query"}, []Attr{}},
+	StartElement{Name: Name{"", "query"}, Attr: []Attr{}},
 	CharData("What is it?"),
 	EndElement{Name{"", "query"}},
 	CharData("\n  "),
-	StartElement{Name{"", "goodbye"}, []Attr{}},
+	StartElement{Name: Name{"", "goodbye"}, Attr: []Attr{}},
 	EndElement{Name{"", "goodbye"}},
 	CharData("\n  "),
-	StartElement{Name{"", "outer"}, []Attr{{Name{"foo", "attr"}, "value"}, {Name{"xmlns", "tag"}, "ns4"}}},
+	StartElement{Name: Name{"", "outer"}, Attr: []Attr{{Name{"foo", "attr"}, "value"}, {Name{"xmlns", "tag"}, "ns4"}}},
 	CharData("\n    "),
-	StartElement{Name{"", "inner"}, []Attr{}},
+	StartElement{Name: Name{"", "inner"}, Attr: []Attr{}},
 	EndElement{Name{"", "inner"}},
 	CharData("\n  "),
 	EndElement{Name{"", "outer"}},
 	CharData("\n  "),
-	StartElement{Name{"tag", "name"}, []Attr{}},
+	StartElement{Name: Name{"tag", "name"}, Attr: []Attr{}},
 	CharData("\n    "),
 	CharData("Some text here."),
 	CharData("\n  "),
@@ -122,27 +122,27 @@ var cookedTokens = []Token{
 	Directive(`DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN"
   "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd"`),
 	CharData("\n"),
-	StartElement{Name{"ns2", "body"}, []Attr{{Name{"xmlns", "foo"}, "ns1"}, {Name{"", "xmlns"}, "ns2"}, {Name{"xmlns", "tag"}, "ns3"}}},
+	StartElement{Name: Name{"ns2", "body"}, Attr: []Attr{{Name{"xmlns", "foo"}, "ns1"}, {Name{"", "xmlns"}, "ns2"}, {Name{"xmlns", "tag"}, "ns3"}}},
 	CharData("\n  "),
-	StartElement{Name{"ns2", "hello"}, []Attr{{Name{"", "lang"}, "en"}}},
+	StartElement{Name: Name{"ns2", "hello"}, Attr: []Attr{{Name{"", "lang"}, "en"}}},
 	CharData("World <>'\" 白鵬翔"),
 	EndElement{Name{"ns2", "hello"}},
 	CharData("\n  "),
-	StartElement{Name{"ns2", "query"}, []Attr{}},
+	StartElement{Name: Name{"ns2", "query"}, Attr: []Attr{}},
 	CharData("What is it?"),
 	EndElement{Name{"ns2", "query"}},
 	CharData("\n  "),
-	StartElement{Name{"ns2", "goodbye"}, []Attr{}},
+	StartElement{Name: Name{"ns2", "goodbye"}, Attr: []Attr{}},
 	EndElement{Name{"ns2", "goodbye"}},
 	CharData("\n  "),
-	StartElement{Name{"ns2", "outer"}, []Attr{{Name{"ns1", "attr"}, "value"}, {Name{"xmlns", "tag"}, "ns4"}}},
+	StartElement{Name: Name{"ns2", "outer"}, Attr: []Attr{{Name{"ns1", "attr"}, "value"}, {Name{"xmlns", "tag"}, "ns4"}}, AttrPrefix: map[Name]string{{"ns1", "attr"}: "foo"}},
 	CharData("\n    "),
-	StartElement{Name{"ns2", "inner"}, []Attr{}},
+	StartElement{Name: Name{"ns2", "inner"}, Attr: []Attr{}},
 	EndElement{Name{"ns2", "inner"}},
 	CharData("\n  "),
 	EndElement{Name{"ns2", "outer"}},
 	CharData("\n  "),
-	StartElement{Name{"ns3", "name"}, []Attr{}},
+	StartElement{Name: Name{"ns3", "name"}, Attr: []Attr{}, Prefix: "tag"},
 	CharData("\n    "),
 	CharData("Some text here."),
 	CharData("\n  "),
@@ -160,7 +160,7 @@ var rawTokensAltEncoding = []Token{
 	CharData("\n"),
 	ProcInst{"xml", []byte(`version="1.0" encoding="x-testing-uppercase"`)},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("value"),
 	EndElement{Name{"", "tag"}},
 }
@@ -231,35 +231,35 @@ const nonStrictInput = `
 
 var nonStrictTokens = []Token{
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("non&entity"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&unknown;entity"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&#123"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&#zzz;"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&なまえ3;"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&lt-gt;"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&;"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
-	StartElement{Name{"", "tag"}, []Attr{}},
+	StartElement{Name: Name{"", "tag"}, Attr: []Attr{}},
 	CharData("&0a;"),
 	EndElement{Name{"", "tag"}},
 	CharData("\n"),
@@ -598,7 +598,7 @@ func TestCopyTokenCharData(t *testing.T) {
 }
 
 func TestCopyTokenStartElement(t *testing.T) {
-	elt := StartElement{Name{"", "hello"}, []Attr{{Name{"", "lang"}, "en"}}}
+	elt := StartElement{Name: Name{"", "hello"}, Attr: []Attr{{Name{"", "lang"}, "en"}}}
 	var tok1 Token = elt
 	tok2 := CopyToken(tok1)
 	if tok1.(StartElement).Attr[0].Value != "en" {
@@ -194,6 +194,13 @@ var depsRules = `
 
 	log !< FMT;
 
+	# Coverage counters for programs built with go build -cover.
+	# Instrumented packages import it, so it must stay small.
+	OS
+	< runtime/coverage;
+
+	fmt !< runtime/coverage;
+
 	# Misc packages needing only FMT.
 	FMT
 	< flag,
@@ -218,6 +225,7 @@ var depsRules = `
 	fmt !< encoding/base32, encoding/base64;
 
 	FMT, encoding/base32, encoding/base64
+	< encoding/json/jsontext
 	< encoding/ascii85, encoding/csv, encoding/gob, encoding/hex,
 	  encoding/json, encoding/pem, encoding/xml, mime;
 
@@ -232,7 +240,7 @@ var depsRules = `
 
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32
-	< compress/bzip2, compress/flate, compress/lzw
+	< compress/bzip2, compress/flate, compress/lzw, compress/zstd
 	< archive/zip, compress/gzip, compress/zlib;
 
 	# templates
@@ -303,6 +311,10 @@ var depsRules = `
 	< image/draw
 	< image/gif, image/jpeg, image/png;
 
+	image, image/color
+	< image/webp/internal/riff, image/webp/internal/vp8, image/webp/internal/vp8l
+	< image/webp;
+
 	# cgo, delayed as long as possible.
 	# If you add a dependency on CGO, you must add the package
 	# to cgoPackages in cmd/dist/test.go as well.
@@ -485,7 +497,7 @@ var depsRules = `
 	FMT, flag, math/rand
 	< testing/quick;
 
-	FMT, flag, runtime/debug, runtime/trace, internal/sysinfo
+	FMT, flag, math/rand, runtime/debug, runtime/trace, internal/sysinfo
 	< testing;
 
 	internal/testlog, runtime/pprof, regexp
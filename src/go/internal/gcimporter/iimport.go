@@ -61,7 +61,7 @@ const (
 // If the export data version is not recognized or the format is otherwise
 // compromised, an error is returned.
 func iImportData(fset *token.FileSet, imports map[string]*types.Package, data []byte, path string) (_ int, pkg *types.Package, err error) {
-	const currentVersion = 1
+	const currentVersion = 2
 	version := int64(-1)
 	defer func() {
 		if e := recover(); e != nil {
@@ -77,7 +77,7 @@ func iImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 
 	version = int64(r.uint64())
 	switch version {
-	case currentVersion, 0:
+	case currentVersion, 1, 0:
 	default:
 		errorf("unknown iexport format version %d", version)
 	}
@@ -0,0 +1,586 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package color
+
+import (
+	"errors"
+	"math"
+	"unicode/utf16"
+)
+
+// A Profile is an ICC color profile, which describes the color space of
+// image data. ICC profiles are specified by the International Color
+// Consortium in ICC.1:2001-04 (version 2) and ICC.1:2010 (version 4), at
+// https://www.color.org/specification/ICC1v43_2010-12.pdf.
+//
+// A Transform converts colors between the color spaces of RGB and gray
+// profiles that describe them with colorants and tone reproduction curves,
+// the matrix/TRC profiles of most cameras and displays.
+type Profile struct {
+	// Version is the profile version, with the major version in the most
+	// significant byte and the minor and bug fix versions in the nibbles
+	// of the next byte, such as 0x04300000 for version 4.3.
+	Version uint32
+	// Class is the profile/device class signature, such as "mntr" for a
+	// display or "scnr" for an input device.
+	Class string
+	// ColorSpace is the data color space signature, such as "RGB " or
+	// "GRAY".
+	ColorSpace string
+	// Description is the profile description, in English if the profile
+	// has more than one.
+	Description string
+
+	data []byte
+	// matrix maps linearized values to the XYZ values of the profile
+	// connection space, in row major order.
+	matrix [9]float64
+	// trc are the tone reproduction curves that linearize values, one per
+	// channel, or nil if the profile is not a matrix/TRC profile.
+	trc []curve
+}
+
+// d50 is the illuminant of the profile connection space.
+var d50 = [3]float64{0.9642, 1, 0.8249}
+
+var errInvalidProfile = errors.New("color: invalid ICC profile")
+
+// ParseProfile parses an ICC profile of version 2 or 4.
+//
+// The profile need not be a matrix/TRC profile, but if it has the tags of
+// one, they must be valid.
+func ParseProfile(data []byte) (*Profile, error) {
+	const headerSize = 128
+	if len(data) < headerSize+4 || string(data[36:40]) != "acsp" {
+		return nil, errInvalidProfile
+	}
+	size := be32(data)
+	if size < headerSize+4 || uint64(size) > uint64(len(data)) {
+		return nil, errInvalidProfile
+	}
+	data = append([]byte(nil), data[:size]...)
+	p := &Profile{
+		Version:    be32(data[8:]),
+		Class:      string(data[12:16]),
+		ColorSpace: string(data[16:20]),
+		data:       data,
+	}
+
+	n := be32(data[headerSize:])
+	if uint64(n) > uint64(size-headerSize-4)/12 {
+		return nil, errInvalidProfile
+	}
+	tags := make(map[string][]byte, n)
+	for i := uint32(0); i < n; i++ {
+		entry := data[headerSize+4+12*i:]
+		offset, length := be32(entry[4:]), be32(entry[8:])
+		if uint64(offset)+uint64(length) > uint64(size) {
+			return nil, errInvalidProfile
+		}
+		tags[string(entry[:4])] = data[offset : offset+length]
+	}
+	p.Description = parseText(tags["desc"])
+
+	if string(data[20:24]) != "XYZ " {
+		// Only the colorants of matrix/TRC profiles are in XYZ.
+		return p, nil
+	}
+	switch p.ColorSpace {
+	case "RGB ":
+		if tags["rXYZ"] == nil || tags["gXYZ"] == nil || tags["bXYZ"] == nil ||
+			tags["rTRC"] == nil || tags["gTRC"] == nil || tags["bTRC"] == nil {
+			return p, nil
+		}
+		trc := make([]curve, 3)
+		for i, c := range [3]string{"r", "g", "b"} {
+			xyz, ok := parseXYZ(tags[c+"XYZ"])
+			if !ok {
+				return nil, errInvalidProfile
+			}
+			for j := range xyz {
+				p.matrix[3*j+i] = xyz[j]
+			}
+			if trc[i], ok = parseCurve(tags[c+"TRC"]); !ok {
+				return nil, errInvalidProfile
+			}
+		}
+		p.trc = trc
+	case "GRAY":
+		if tags["kTRC"] == nil {
+			return p, nil
+		}
+		c, ok := parseCurve(tags["kTRC"])
+		if !ok {
+			return nil, errInvalidProfile
+		}
+		// A gray value is the luminance of the illuminant.
+		p.matrix = [9]float64{d50[0], 0, 0, 0, d50[1], 0, 0, 0, d50[2]}
+		p.trc = []curve{c}
+	}
+	return p, nil
+}
+
+// Bytes returns the encoded profile, which is suitable for embedding in an
+// image. The caller must not modify it.
+func (p *Profile) Bytes() []byte {
+	return p.data
+}
+
+func be16(b []byte) uint16 {
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// s15Fixed16 decodes a signed fixed point number with 16 fractional bits.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(be32(b))) / 65536
+}
+
+// parseText parses a textDescriptionType tag of a version 2 profile, a
+// multiLocalizedUnicodeType tag of a version 4 profile, or a textType tag.
+func parseText(b []byte) string {
+	if len(b) < 12 {
+		return ""
+	}
+	switch string(b[:4]) {
+	case "desc":
+		n := be32(b[8:])
+		if uint64(n) > uint64(len(b)-12) {
+			return ""
+		}
+		return trimNUL(b[12 : 12+n])
+	case "text":
+		return trimNUL(b[8:])
+	case "mluc":
+		if len(b) < 16 {
+			return ""
+		}
+		n, recordSize := be32(b[8:]), be32(b[12:])
+		if recordSize < 12 || uint64(n)*uint64(recordSize) > uint64(len(b)-16) {
+			return ""
+		}
+		var s []byte
+		for i := uint32(0); i < n; i++ {
+			r := b[16+i*recordSize:]
+			length, offset := be32(r[4:]), be32(r[8:])
+			if uint64(offset)+uint64(length) > uint64(len(b)) {
+				return ""
+			}
+			if i == 0 || string(r[:2]) == "en" {
+				s = b[offset : offset+length]
+			}
+			if string(r[:2]) == "en" {
+				break
+			}
+		}
+		u := make([]uint16, len(s)/2)
+		for i := range u {
+			u[i] = be16(s[2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+func trimNUL(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// parseXYZ parses an XYZType tag.
+func parseXYZ(b []byte) (xyz [3]float64, ok bool) {
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return xyz, false
+	}
+	for i := range xyz {
+		xyz[i] = s15Fixed16(b[8+4*i:])
+	}
+	return xyz, true
+}
+
+// A curve is a tone reproduction curve, which maps a value in [0, 1] to its
+// linear value in [0, 1].
+//
+// A curve that has no table is the parametric function
+//
+//	y = (a*x + b)**g + e  if x >= d
+//	y = c*x + f           if x < d
+//
+// which all the parametric curves of the specification reduce to.
+type curve struct {
+	table               []float64
+	g, a, b, c, d, e, f float64
+}
+
+// parseCurve parses a curveType or parametricCurveType tag.
+func parseCurve(b []byte) (curve, bool) {
+	if len(b) < 12 {
+		return curve{}, false
+	}
+	switch string(b[:4]) {
+	case "curv":
+		n := be32(b[8:])
+		if uint64(n) > uint64(len(b)-12)/2 {
+			return curve{}, false
+		}
+		switch n {
+		case 0:
+			return curve{g: 1, a: 1}, true
+		case 1:
+			return curve{g: float64(be16(b[12:])) / 256, a: 1}, true
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(be16(b[12+2*i:])) / 0xffff
+		}
+		return curve{table: table}, true
+	case "para":
+		typ := be16(b[8:])
+		if typ > 4 {
+			return curve{}, false
+		}
+		n := [5]int{1, 3, 4, 5, 7}[typ]
+		if len(b) < 12+4*n {
+			return curve{}, false
+		}
+		var p [7]float64
+		for i := 0; i < n; i++ {
+			p[i] = s15Fixed16(b[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		switch typ {
+		case 0:
+			return curve{g: g, a: 1}, true
+		case 1, 2:
+			if a == 0 {
+				return curve{}, false
+			}
+			// Below -b/a, the curve is 0 or c.
+			return curve{g: g, a: a, b: b, d: -b / a, e: c, f: c}, true
+		case 3:
+			return curve{g: g, a: a, b: b, c: c, d: d}, true
+		}
+		return curve{g: g, a: a, b: b, c: c, d: d, e: e, f: f}, true
+	}
+	return curve{}, false
+}
+
+// eval returns the linear value of x.
+func (c *curve) eval(x float64) float64 {
+	x = clamp01(x)
+	if c.table != nil {
+		n := len(c.table) - 1
+		i := int(x * float64(n))
+		if i >= n {
+			return c.table[n]
+		}
+		t := x*float64(n) - float64(i)
+		return c.table[i] + t*(c.table[i+1]-c.table[i])
+	}
+	if x < c.d {
+		return clamp01(c.c*x + c.f)
+	}
+	return clamp01(math.Pow(math.Max(c.a*x+c.b, 0), c.g) + c.e)
+}
+
+// invert returns the value whose linear value is y.
+func (c *curve) invert(y float64) float64 {
+	y = clamp01(y)
+	if c.table != nil {
+		// Find the first entry that is not less than y, in a table that
+		// is non-decreasing.
+		t := c.table
+		i, j := 0, len(t)
+		for i < j {
+			h := int(uint(i+j) >> 1)
+			if t[h] < y {
+				i = h + 1
+			} else {
+				j = h
+			}
+		}
+		switch {
+		case i == 0:
+			return 0
+		case i == len(t):
+			return 1
+		}
+		return (float64(i-1) + (y-t[i-1])/(t[i]-t[i-1])) / float64(len(t)-1)
+	}
+	if c.d > 0 && y < c.c*c.d+c.f {
+		if c.c == 0 {
+			return 0
+		}
+		return clamp01((y - c.f) / c.c)
+	}
+	if c.a == 0 || c.g == 0 {
+		return 0
+	}
+	return clamp01((math.Pow(math.Max(y-c.e, 0), 1/c.g) - c.b) / c.a)
+}
+
+func clamp01(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	if x > 1 {
+		return 1
+	}
+	return x
+}
+
+// A Transform converts colors from the color space of a source profile to
+// that of a destination profile, through the XYZ values of the profile
+// connection space, with the media-relative colorimetric intent: the white
+// of the source maps to the white of the destination, and colors out of the
+// gamut of the destination are clipped.
+//
+// A Transform is a Model. Its Convert method returns an RGBA64 with the
+// alpha of the given color.
+type Transform struct {
+	src, dst *Profile
+	// m maps linear source values to linear destination values.
+	m [9]float64
+}
+
+// NewTransform returns a Transform from the color space of src to that of
+// dst, which must be matrix/TRC profiles.
+func NewTransform(src, dst *Profile) (*Transform, error) {
+	if src.trc == nil || dst.trc == nil {
+		return nil, errors.New("color: transform between profiles that are not matrix/TRC profiles")
+	}
+	inv, ok := invert3(&dst.matrix)
+	if !ok {
+		return nil, errInvalidProfile
+	}
+	return &Transform{src: src, dst: dst, m: mul3(&inv, &src.matrix)}, nil
+}
+
+// Convert converts c to the color space of the destination profile.
+func (t *Transform) Convert(c Color) Color {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return RGBA64{}
+	}
+	fa := float64(a)
+	v := [3]float64{float64(r) / fa, float64(g) / fa, float64(b) / fa}
+	for i := range v {
+		v[i] = t.src.trc[i%len(t.src.trc)].eval(v[i])
+	}
+	var out [3]float64
+	for i := range out {
+		out[i] = t.m[3*i]*v[0] + t.m[3*i+1]*v[1] + t.m[3*i+2]*v[2]
+	}
+	if len(t.dst.trc) == 1 {
+		// A gray value depends only on the luminance.
+		out[0], out[2] = out[1], out[1]
+	}
+	var x [3]uint16
+	for i := range x {
+		x[i] = uint16(t.dst.trc[i%len(t.dst.trc)].invert(out[i])*fa + 0.5)
+	}
+	return RGBA64{x[0], x[1], x[2], uint16(a)}
+}
+
+// invert3 returns the inverse of the 3x3 matrix m.
+func invert3(m *[9]float64) (inv [9]float64, ok bool) {
+	inv = [9]float64{
+		m[4]*m[8] - m[5]*m[7], m[2]*m[7] - m[1]*m[8], m[1]*m[5] - m[2]*m[4],
+		m[5]*m[6] - m[3]*m[8], m[0]*m[8] - m[2]*m[6], m[2]*m[3] - m[0]*m[5],
+		m[3]*m[7] - m[4]*m[6], m[1]*m[6] - m[0]*m[7], m[0]*m[4] - m[1]*m[3],
+	}
+	det := m[0]*inv[0] + m[1]*inv[3] + m[2]*inv[6]
+	if det == 0 {
+		return inv, false
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+// mul3 returns the product of the 3x3 matrices p and q.
+func mul3(p, q *[9]float64) (m [9]float64) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[3*i+j] = p[3*i]*q[j] + p[3*i+1]*q[3+j] + p[3*i+2]*q[6+j]
+		}
+	}
+	return m
+}
+
+// Standard RGB color profiles.
+var (
+	// SRGBProfile is the profile of the sRGB color space of IEC
+	// 61966-2-1, which image data without a profile is assumed to be in.
+	SRGBProfile = newRGBProfile("sRGB IEC61966-2.1", srgbPrimaries)
+	// DisplayP3Profile is the profile of the Display P3 color space, with
+	// the primaries of DCI-P3, and the white point and tone reproduction
+	// curve of sRGB, of many phone cameras and displays.
+	DisplayP3Profile = newRGBProfile("Display P3", displayP3Primaries)
+)
+
+// The chromaticities of the red, green and blue primaries, and of the D65
+// white point.
+var (
+	srgbPrimaries      = [3][2]float64{{0.64, 0.33}, {0.30, 0.60}, {0.15, 0.06}}
+	displayP3Primaries = [3][2]float64{{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}}
+	d65                = [2]float64{0.3127, 0.3290}
+)
+
+// newRGBProfile returns a version 4 matrix/TRC profile of a display with the
+// given primaries, the D65 white point and the sRGB tone reproduction curve.
+func newRGBProfile(desc string, primaries [3][2]float64) *Profile {
+	// The matrix that maps linear RGB to XYZ has the XYZ of the primaries,
+	// scaled so that white has the XYZ of D65, as its columns.
+	xyz := func(xy [2]float64) [3]float64 {
+		return [3]float64{xy[0] / xy[1], 1, (1 - xy[0] - xy[1]) / xy[1]}
+	}
+	var m [9]float64
+	for i, p := range primaries {
+		c := xyz(p)
+		m[i], m[3+i], m[6+i] = c[0], c[1], c[2]
+	}
+	inv, _ := invert3(&m)
+	w := xyz(d65)
+	for i := 0; i < 3; i++ {
+		s := inv[3*i]*w[0] + inv[3*i+1]*w[1] + inv[3*i+2]*w[2]
+		m[i], m[3+i], m[6+i] = m[i]*s, m[3+i]*s, m[6+i]*s
+	}
+	// Adapt the colorants to the illuminant of the profile connection
+	// space, with the linear Bradford transform of Annex E.
+	chad := bradford(w, d50)
+	m = mul3(&chad, &m)
+
+	var tags []profileTag
+	tags = append(tags,
+		profileTag{"desc", mlucTag(desc)},
+		profileTag{"cprt", mlucTag("No copyright, use freely")},
+		profileTag{"wtpt", xyzTag(d50)},
+		profileTag{"chad", sf32Tag(chad[:])},
+	)
+	for i, c := range [3]string{"r", "g", "b"} {
+		tags = append(tags, profileTag{c + "XYZ", xyzTag([3]float64{m[i], m[3+i], m[6+i]})})
+	}
+	// The parametric curve of sRGB.
+	trc := []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+	for _, x := range [5]float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		trc = appendBE32(trc, toS15Fixed16(x))
+	}
+	for _, c := range [3]string{"r", "g", "b"} {
+		tags = append(tags, profileTag{c + "TRC", trc})
+	}
+	p, err := ParseProfile(encodeProfile(tags))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// bradford returns the linear Bradford chromatic adaptation matrix from the
+// white point src to dst, both in XYZ.
+func bradford(src, dst [3]float64) [9]float64 {
+	b := [9]float64{
+		0.8951, 0.2664, -0.1614,
+		-0.7502, 1.7135, 0.0367,
+		0.0389, -0.0685, 1.0296,
+	}
+	inv, _ := invert3(&b)
+	var s [9]float64
+	for i := 0; i < 3; i++ {
+		ds := b[3*i]*dst[0] + b[3*i+1]*dst[1] + b[3*i+2]*dst[2]
+		ss := b[3*i]*src[0] + b[3*i+1]*src[1] + b[3*i+2]*src[2]
+		s[4*i] = ds / ss
+	}
+	m := mul3(&s, &b)
+	return mul3(&inv, &m)
+}
+
+type profileTag struct {
+	sig  string
+	data []byte
+}
+
+// encodeProfile encodes a version 4 display profile of an RGB color space
+// with the given tags. Tags with the same data share it.
+func encodeProfile(tags []profileTag) []byte {
+	b := make([]byte, 128, 1024)
+	b = appendBE32(b, uint32(len(tags)))
+	b = append(b, make([]byte, 12*len(tags))...)
+	for i, t := range tags {
+		offset := len(b)
+		for j := 0; j < i; j++ {
+			if &tags[j].data[0] == &t.data[0] {
+				offset = int(be32(b[128+4+12*j+4:]))
+				break
+			}
+		}
+		if offset == len(b) {
+			b = append(b, t.data...)
+			for len(b)%4 != 0 {
+				b = append(b, 0)
+			}
+		}
+		entry := b[128+4+12*i:]
+		copy(entry, t.sig)
+		putBE32(entry[4:], uint32(offset))
+		putBE32(entry[8:], uint32(len(t.data)))
+	}
+	putBE32(b, uint32(len(b)))
+	putBE32(b[8:], 0x04300000)
+	copy(b[12:], "mntrRGB XYZ ")
+	copy(b[36:], "acsp")
+	for i, x := range d50 {
+		putBE32(b[68+4*i:], toS15Fixed16(x))
+	}
+	return b
+}
+
+func appendBE32(b []byte, x uint32) []byte {
+	return append(b, byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+func putBE32(b []byte, x uint32) {
+	b[0], b[1], b[2], b[3] = byte(x>>24), byte(x>>16), byte(x>>8), byte(x)
+}
+
+func toS15Fixed16(x float64) uint32 {
+	return uint32(int32(math.Round(x * 65536)))
+}
+
+// mlucTag returns a multiLocalizedUnicodeType tag with the English text s.
+func mlucTag(s string) []byte {
+	b := []byte("mluc\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x0cenUS")
+	u := utf16.Encode([]rune(s))
+	b = appendBE32(b, uint32(2*len(u)))
+	b = appendBE32(b, 28)
+	for _, c := range u {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
+
+// xyzTag returns an XYZType tag with the given XYZ values.
+func xyzTag(xyz [3]float64) []byte {
+	b := sf32Tag(xyz[:])
+	copy(b, "XYZ ")
+	return b
+}
+
+// sf32Tag returns an s15Fixed16ArrayType tag with the given numbers.
+func sf32Tag(x []float64) []byte {
+	b := []byte("sf32\x00\x00\x00\x00")
+	for _, v := range x {
+		b = appendBE32(b, toS15Fixed16(v))
+	}
+	return b
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package color
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStandardProfiles(t *testing.T) {
+	// The colorants of the sRGB profile that the ICC publishes.
+	want := [9]float64{
+		0.4361, 0.3851, 0.1431,
+		0.2225, 0.7169, 0.0606,
+		0.0139, 0.0971, 0.7141,
+	}
+	for i, x := range SRGBProfile.matrix {
+		if math.Abs(x-want[i]) > 0.0005 {
+			t.Errorf("sRGB matrix[%d] = %.4f, want %.4f", i, x, want[i])
+		}
+	}
+
+	for _, p := range []*Profile{SRGBProfile, DisplayP3Profile} {
+		q, err := ParseProfile(p.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", p.Description, err)
+		}
+		if q.Version != 0x04300000 || q.Class != "mntr" || q.ColorSpace != "RGB " || q.Description != p.Description {
+			t.Errorf("%s: parsed %d, %q, %q, %q", p.Description, q.Version, q.Class, q.ColorSpace, q.Description)
+		}
+	}
+}
+
+// rgb returns the RGBA64 color of the given RGB values in [0, 1].
+func rgb(r, g, b float64) RGBA64 {
+	return RGBA64{uint16(r*0xffff + 0.5), uint16(g*0xffff + 0.5), uint16(b*0xffff + 0.5), 0xffff}
+}
+
+func delta32(x, y uint32) uint32 {
+	if x >= y {
+		return x - y
+	}
+	return y - x
+}
+
+func TestTransform(t *testing.T) {
+	gray := SRGBProfile.Bytes()
+	gray = append([]byte(nil), gray...)
+	copy(gray[16:], "GRAY")
+	// Replace the rTRC tag by a kTRC tag with a gamma of 2.2.
+	for i := 0; i < int(be32(gray[128:])); i++ {
+		entry := gray[128+4+12*i:]
+		if string(entry[:4]) == "rTRC" {
+			copy(entry, "kTRC")
+			curv := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x02\x33")
+			putBE32(entry[4:], uint32(len(gray)))
+			putBE32(entry[8:], uint32(len(curv)))
+			gray = append(gray, curv...)
+		}
+	}
+	putBE32(gray, uint32(len(gray)))
+	grayProfile, err := ParseProfile(gray)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		src, dst *Profile
+		in, want Color
+	}{
+		{SRGBProfile, SRGBProfile, rgb(0.2, 0.5, 0.9), rgb(0.2, 0.5, 0.9)},
+		{SRGBProfile, DisplayP3Profile, rgb(1, 0, 0), rgb(0.9175, 0.2003, 0.1386)},
+		{SRGBProfile, DisplayP3Profile, rgb(1, 1, 1), rgb(1, 1, 1)},
+		{DisplayP3Profile, SRGBProfile, rgb(0.9175, 0.2003, 0.1386), rgb(1, 0, 0)},
+		// Out of the sRGB gamut.
+		{DisplayP3Profile, SRGBProfile, rgb(1, 0, 0), rgb(1, 0, 0)},
+		{DisplayP3Profile, SRGBProfile, rgb(0, 1, 0), rgb(0, 1, 0)},
+		// Premultiplied alpha.
+		{SRGBProfile, SRGBProfile, RGBA64{0x4000, 0x2000, 0, 0x8000}, RGBA64{0x4000, 0x2000, 0, 0x8000}},
+		{SRGBProfile, SRGBProfile, Alpha16{0}, RGBA64{}},
+		// Gray, with a gamma close to that of sRGB.
+		{grayProfile, SRGBProfile, Gray{0x80}, rgb(0.5, 0.5, 0.5)},
+		{SRGBProfile, grayProfile, White, rgb(1, 1, 1)},
+		{SRGBProfile, grayProfile, rgb(0.5, 0.5, 0.5), rgb(0.5, 0.5, 0.5)},
+	}
+	for _, tc := range testCases {
+		tr, err := NewTransform(tc.src, tc.dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tr.Convert(tc.in).(RGBA64)
+		r0, g0, b0, a0 := got.RGBA()
+		r1, g1, b1, a1 := tc.want.RGBA()
+		const tolerance = 0xffff / 100
+		if delta32(r0, r1) > tolerance || delta32(g0, g1) > tolerance || delta32(b0, b1) > tolerance || a0 != a1 {
+			t.Errorf("%s to %s: Convert(%v) = %v, want %v", tc.src.ColorSpace, tc.dst.ColorSpace, tc.in, got, tc.want)
+		}
+	}
+}
+
+// TestCurves tests that curves invert, and that a table curve agrees with
+// the parametric curve it samples.
+func TestCurves(t *testing.T) {
+	table := make([]float64, 1024)
+	srgb := SRGBProfile.trc[0]
+	for i := range table {
+		table[i] = math.Round(srgb.eval(float64(i)/1023)*0xffff) / 0xffff
+	}
+	curves := []curve{
+		srgb,
+		{table: table},
+		{g: 2.2, a: 1},
+		{g: 1.8, a: 0.9, b: 0.1},
+		{g: 2.4, a: 0.9, b: 0.1, c: 0.136, d: 0.1, f: 0.005},
+	}
+	for i, c := range curves {
+		for x := 0.0; x <= 1; x += 1.0 / 64 {
+			y := c.eval(x)
+			if i == 1 && math.Abs(y-srgb.eval(x)) > 0.001 {
+				t.Errorf("table curve: eval(%v) = %v, want %v", x, y, srgb.eval(x))
+			}
+			if got := c.invert(y); math.Abs(got-x) > 0.001 {
+				t.Errorf("curve #%d: invert(eval(%v)) = %v", i, x, got)
+			}
+		}
+	}
+}
+
+func TestParseProfileErrors(t *testing.T) {
+	valid := SRGBProfile.Bytes()
+	testCases := [][]byte{
+		nil,
+		valid[:100],
+		valid[:len(valid)-1],
+	}
+	badTag := append([]byte(nil), valid...)
+	putBE32(badTag[128+4+4:], uint32(len(valid)))
+	testCases = append(testCases, badTag)
+	badCurve := append([]byte(nil), valid...)
+	for i := 0; i < int(be32(valid[128:])); i++ {
+		entry := badCurve[128+4+12*i:]
+		if string(entry[:4]) == "gTRC" {
+			copy(badCurve[be32(entry[4:]):], "curv\x00\x00\x00\x00\xff\xff\xff\xff")
+		}
+	}
+	testCases = append(testCases, badCurve)
+	for i, b := range testCases {
+		if _, err := ParseProfile(b); err == nil {
+			t.Errorf("test case #%d: ParseProfile succeeded", i)
+		}
+	}
+
+	// A profile that is not a matrix/TRC one can be parsed, but not used by
+	// a Transform.
+	lab := append([]byte(nil), valid...)
+	copy(lab[20:], "Lab ")
+	p, err := ParseProfile(lab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTransform(p, SRGBProfile); err == nil {
+		t.Errorf("NewTransform with a Lab profile succeeded")
+	}
+}
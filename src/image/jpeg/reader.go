@@ -64,6 +64,8 @@ const (
 	// but in practice, their use is described at
 	// https://www.sno.phy.queensu.ca/~phil/exiftool/TagNames/JPEG.html
 	app0Marker  = 0xe0
+	app1Marker  = 0xe1
+	app2Marker  = 0xe2
 	app14Marker = 0xee
 	app15Marker = 0xef
 )
@@ -143,6 +145,11 @@ type decoder struct {
 	adobeTransform      uint8
 	eobRun              uint16 // End-of-Band run, specified in section G.1.2.2.
 
+	// wantProfile is whether to collect the chunks of an ICC profile from
+	// the APP2 segments into iccChunks, indexed by sequence number minus 1.
+	wantProfile bool
+	iccChunks   [][]byte
+
 	comp       [maxComponents]component
 	progCoeffs [maxComponents][]block // Saved state between progressive-mode scans.
 	huff       [maxTc + 1][maxTh + 1]huffman
@@ -522,6 +529,49 @@ func (d *decoder) processApp14Marker(n int) error {
 	return nil
 }
 
+func (d *decoder) processApp2Marker(n int) error {
+	if !d.wantProfile || n < len(iccHeader)+2 {
+		return d.ignore(n)
+	}
+	if err := d.readFull(d.tmp[:len(iccHeader)+2]); err != nil {
+		return err
+	}
+	n -= len(iccHeader) + 2
+
+	// The header is followed by the sequence number of the chunk, starting
+	// at 1, and the number of chunks. Chunks that are inconsistent with the
+	// first one are ignored.
+	seq, count := int(d.tmp[len(iccHeader)]), int(d.tmp[len(iccHeader)+1])
+	if string(d.tmp[:len(iccHeader)]) != iccHeader || seq == 0 || seq > count {
+		return d.ignore(n)
+	}
+	if d.iccChunks == nil {
+		d.iccChunks = make([][]byte, count)
+	}
+	if count != len(d.iccChunks) || d.iccChunks[seq-1] != nil {
+		return d.ignore(n)
+	}
+	chunk := make([]byte, n)
+	if err := d.readFull(chunk); err != nil {
+		return err
+	}
+	d.iccChunks[seq-1] = chunk
+	return nil
+}
+
+// iccProfile returns the ICC profile of the APP2 segments, or nil if there
+// is none or some of its chunks are missing.
+func (d *decoder) iccProfile() []byte {
+	var profile []byte
+	for _, chunk := range d.iccChunks {
+		if chunk == nil {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile
+}
+
 // decode reads a JPEG image from r and returns it as an image.Image.
 func (d *decoder) decode(r io.Reader, configOnly bool) (image.Image, error) {
 	d.r = r
@@ -636,6 +686,8 @@ func (d *decoder) decode(r io.Reader, configOnly bool) (image.Image, error) {
 			}
 		case app0Marker:
 			err = d.processApp0Marker(n)
+		case app2Marker:
+			err = d.processApp2Marker(n)
 		case app14Marker:
 			err = d.processApp14Marker(n)
 		default:
@@ -779,6 +831,18 @@ func Decode(r io.Reader) (image.Image, error) {
 	return d.decode(r, false)
 }
 
+// DecodeWithProfile is like Decode, but it also returns the ICC profile
+// embedded in the APP2 segments of the JPEG image, or nil if there is none.
+// The profile can be parsed with color.ParseProfile.
+func DecodeWithProfile(r io.Reader) (image.Image, []byte, error) {
+	d := decoder{wantProfile: true}
+	m, err := d.decode(r, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, d.iccProfile(), nil
+}
+
 // DecodeConfig returns the color model and dimensions of a JPEG image without
 // decoding the entire image.
 func DecodeConfig(r io.Reader) (image.Config, error) {
@@ -33,6 +33,10 @@ func TestDecodeProgressive(t *testing.T) {
 		"../testdata/video-005.gray.q50",
 		"../testdata/video-005.gray.q50.2x2",
 		"../testdata/video-001.separate.dc.progression",
+		// These are libjpeg's 4:2:0 output with a restart interval of 7
+		// MCUs, which is 7 blocks in the non-interleaved scans of the
+		// progressive image.
+		"../testdata/video-001.restart",
 	}
 	for _, tc := range testCases {
 		m0, err := decodeFile(tc + ".jpeg")
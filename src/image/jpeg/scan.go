@@ -168,6 +168,9 @@ func (d *decoder) processSOS(n int) error {
 		// blocks: the third block in the first row has (bx, by) = (2, 0).
 		bx, by     int
 		blockCount int
+		// coded is the number of blocks of a non-interleaved scan
+		// decoded so far. Each is an MCU, as per section A.2.2.
+		coded int
 	)
 	for my := 0; my < myy; my++ {
 		for mx := 0; mx < mxx; mx++ {
@@ -212,6 +215,13 @@ func (d *decoder) processSOS(n int) error {
 						if bx*8 >= d.width || by*8 >= d.height {
 							continue
 						}
+						if d.ri > 0 && coded > 0 && coded%d.ri == 0 {
+							if err := d.processRST(&expectedRST); err != nil {
+								return err
+							}
+							dc = [maxComponents]int32{}
+						}
+						coded++
 					}
 
 					// Load the previous partially decoded coefficients, if applicable.
@@ -304,44 +314,12 @@ func (d *decoder) processSOS(n int) error {
 				} // for j
 			} // for i
 			mcu++
-			if d.ri > 0 && mcu%d.ri == 0 && mcu < mxx*myy {
-				// A more sophisticated decoder could use RST[0-7] markers to resynchronize from corrupt input,
-				// but this one assumes well-formed input, and hence the restart marker follows immediately.
-				if err := d.readFull(d.tmp[:2]); err != nil {
+			if d.ri > 0 && mcu%d.ri == 0 && mcu < mxx*myy && nComp != 1 {
+				if err := d.processRST(&expectedRST); err != nil {
 					return err
 				}
-
-				// Section F.1.2.3 says that "Byte alignment of markers is
-				// achieved by padding incomplete bytes with 1-bits. If padding
-				// with 1-bits creates a X’FF’ value, a zero byte is stuffed
-				// before adding the marker."
-				//
-				// Seeing "\xff\x00" here is not spec compliant, as we are not
-				// expecting an *incomplete* byte (that needed padding). Still,
-				// some real world encoders (see golang.org/issue/28717) insert
-				// it, so we accept it and re-try the 2 byte read.
-				//
-				// libjpeg issues a warning (but not an error) for this:
-				// https://github.com/LuaDist/libjpeg/blob/6c0fcb8ddee365e7abc4d332662b06900612e923/jdmarker.c#L1041-L1046
-				if d.tmp[0] == 0xff && d.tmp[1] == 0x00 {
-					if err := d.readFull(d.tmp[:2]); err != nil {
-						return err
-					}
-				}
-
-				if d.tmp[0] != 0xff || d.tmp[1] != expectedRST {
-					return FormatError("bad RST marker")
-				}
-				expectedRST++
-				if expectedRST == rst7Marker+1 {
-					expectedRST = rst0Marker
-				}
-				// Reset the Huffman decoder.
-				d.bits = bits{}
 				// Reset the DC components, as per section F.2.1.3.1.
 				dc = [maxComponents]int32{}
-				// Reset the progressive decoder state, as per section G.1.2.2.
-				d.eobRun = 0
 			}
 		} // for mx
 	} // for my
@@ -349,6 +327,48 @@ func (d *decoder) processSOS(n int) error {
 	return nil
 }
 
+// processRST reads the restart marker that ends a restart interval, which
+// must be expectedRST, and resets the decoder state other than the DC
+// components, which the caller resets.
+func (d *decoder) processRST(expectedRST *uint8) error {
+	// A more sophisticated decoder could use RST[0-7] markers to resynchronize from corrupt input,
+	// but this one assumes well-formed input, and hence the restart marker follows immediately.
+	if err := d.readFull(d.tmp[:2]); err != nil {
+		return err
+	}
+
+	// Section F.1.2.3 says that "Byte alignment of markers is
+	// achieved by padding incomplete bytes with 1-bits. If padding
+	// with 1-bits creates a X’FF’ value, a zero byte is stuffed
+	// before adding the marker."
+	//
+	// Seeing "\xff\x00" here is not spec compliant, as we are not
+	// expecting an *incomplete* byte (that needed padding). Still,
+	// some real world encoders (see golang.org/issue/28717) insert
+	// it, so we accept it and re-try the 2 byte read.
+	//
+	// libjpeg issues a warning (but not an error) for this:
+	// https://github.com/LuaDist/libjpeg/blob/6c0fcb8ddee365e7abc4d332662b06900612e923/jdmarker.c#L1041-L1046
+	if d.tmp[0] == 0xff && d.tmp[1] == 0x00 {
+		if err := d.readFull(d.tmp[:2]); err != nil {
+			return err
+		}
+	}
+
+	if d.tmp[0] != 0xff || d.tmp[1] != *expectedRST {
+		return FormatError("bad RST marker")
+	}
+	*expectedRST++
+	if *expectedRST == rst7Marker+1 {
+		*expectedRST = rst0Marker
+	}
+	// Reset the Huffman decoder.
+	d.bits = bits{}
+	// Reset the progressive decoder state, as per section G.1.2.2.
+	d.eobRun = 0
+	return nil
+}
+
 // refine decodes a successive approximation refinement block, as specified in
 // section G.1.2.
 func (d *decoder) refine(b *block, h *huffman, zigStart, zigEnd, delta int32) error {
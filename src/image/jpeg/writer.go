@@ -103,8 +103,8 @@ type huffmanSpec struct {
 	value []byte
 }
 
-// theHuffmanSpec is the Huffman encoding specifications.
-// This encoder uses the same Huffman encoding for all images.
+// theHuffmanSpec is the standard Huffman encoding specifications, which the
+// encoder uses unless it optimizes them.
 var theHuffmanSpec = [nHuffIndex]huffmanSpec{
 	// Luminance DC.
 	{
@@ -207,6 +207,105 @@ func init() {
 	}
 }
 
+// newHuffmanSpec returns a Huffman encoding, with codewords of at most 16
+// bits, for values with the given frequencies, as generated by the procedure
+// of section K.2. Values with a zero frequency have no codeword.
+func newHuffmanSpec(freq *[256]int) huffmanSpec {
+	// f[256] is reserved, so that no codeword consists of all 1 bits.
+	var f [257]int
+	copy(f[:], freq[:])
+	f[256] = 1
+	empty := true
+	for _, n := range freq {
+		if n > 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		// A table needs a value besides the reserved one.
+		f[0] = 1
+	}
+
+	// codeSize[v] is the codeword size of value v. next links the values
+	// of a branch of the tree.
+	var codeSize, next [257]int
+	for i := range next {
+		next[i] = -1
+	}
+	for {
+		// Merge the two least frequent branches, v1 and v2.
+		v1 := -1
+		for v := range f {
+			if f[v] > 0 && (v1 < 0 || f[v] <= f[v1]) {
+				v1 = v
+			}
+		}
+		v2 := -1
+		for v := range f {
+			if f[v] > 0 && v != v1 && (v2 < 0 || f[v] <= f[v2]) {
+				v2 = v
+			}
+		}
+		if v2 < 0 {
+			break
+		}
+		f[v1] += f[v2]
+		f[v2] = 0
+		codeSize[v1]++
+		for next[v1] >= 0 {
+			v1 = next[v1]
+			codeSize[v1]++
+		}
+		next[v1] = v2
+		codeSize[v2]++
+		for next[v2] >= 0 {
+			v2 = next[v2]
+			codeSize[v2]++
+		}
+	}
+
+	// count[i] is the number of codewords of i bits.
+	var count [len(f) + 1]int
+	for _, n := range codeSize {
+		if n > 0 {
+			count[n]++
+		}
+	}
+	// Limit the codeword sizes to 16 bits, as in Figure K.3.
+	for i := len(count) - 1; i > 16; i-- {
+		for count[i] > 0 {
+			j := i - 2
+			for count[j] == 0 {
+				j--
+			}
+			count[i] -= 2
+			count[i-1]++
+			count[j+1] += 2
+			count[j]--
+		}
+	}
+	// Drop the reserved value, which has one of the longest codewords.
+	i := 16
+	for count[i] == 0 {
+		i--
+	}
+	count[i]--
+
+	var s huffmanSpec
+	for i := range s.count {
+		s.count[i] = byte(count[i+1])
+	}
+	for n := 1; n < len(count); n++ {
+		for v := 0; v < 256; v++ {
+			if codeSize[v] == n {
+				s.value = append(s.value, byte(v))
+			}
+		}
+	}
+	return s
+}
+
 // writer is a buffered writer.
 type writer interface {
 	Flush() error
@@ -214,6 +313,34 @@ type writer interface {
 	io.ByteWriter
 }
 
+// discard is a writer that drops everything written to it. The encoder
+// writes to it when it only counts the Huffman values of a scan.
+type discard struct{}
+
+func (discard) Flush() error                { return nil }
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+func (discard) WriteByte(byte) error        { return nil }
+
+// coefBlock holds the quantized DCT coefficients of a block, in zig-zag
+// order.
+type coefBlock [blockSize]int16
+
+// encComponent is an image component as the encoder writes it.
+type encComponent struct {
+	// h and v are the horizontal and vertical sampling factors.
+	h, v int
+	// q is the quantization table, and also the Huffman encoding table.
+	q quantIndex
+	// bw and bh are the number of blocks in a row and a column of the
+	// component when it is in a non-interleaved scan.
+	bw, bh int
+	// coef holds the blocks of one MCU row, or of the whole image if the
+	// encoder needs them for more than one pass, with a stride of mxx*h.
+	coef []coefBlock
+	// rows is the number of block rows that coef holds.
+	rows int
+}
+
 // encoder encodes an image to the JPEG format.
 type encoder struct {
 	// w is the writer to write to. err is the first error encountered during
@@ -226,6 +353,32 @@ type encoder struct {
 	bits, nBits uint32
 	// quant is the scaled quantization tables, in zig-zag order.
 	quant [nQuantIndex][blockSize]byte
+	// huffSpec and huffLUT are the Huffman encodings, the standard ones
+	// unless they are optimized.
+	huffSpec [nHuffIndex]huffmanSpec
+	huffLUT  [nHuffIndex]huffmanLUT
+	// freq, if non-nil, counts the values that emitHuff is given instead of
+	// emitting them.
+	freq *[nHuffIndex][256]int
+	// comp are the image components. mxx and myy are the number of MCUs in
+	// a row and a column of the image.
+	comp     []encComponent
+	mxx, myy int
+	// progressive is whether the image is progressive, and ri is its restart
+	// interval, or 0.
+	progressive bool
+	ri          int
+	// whole is whether the components hold the coefficients of the whole
+	// image, instead of those of the MCU row being written.
+	whole bool
+	// eobRun is the number of blocks in the current end-of-band run, and
+	// eobBits are the correction bits that follow it in an AC refinement
+	// scan. eobHuff is the Huffman encoding of the run.
+	eobRun  int
+	eobBits []byte
+	eobHuff huffIndex
+	// corrBits is a scratch buffer for the correction bits of a block.
+	corrBits []byte
 }
 
 func (e *encoder) flush() {
@@ -267,9 +420,26 @@ func (e *encoder) emit(bits, nBits uint32) {
 	e.bits, e.nBits = bits, nBits
 }
 
+// emitBits emits bits, each of which is 0 or 1, to the bit-stream.
+func (e *encoder) emitBits(bits []byte) {
+	for _, b := range bits {
+		e.emit(uint32(b), 1)
+	}
+}
+
+// pad pads the last byte of the bit-stream with 1's.
+func (e *encoder) pad() {
+	e.emit(0x7f, 7)
+	e.bits, e.nBits = 0, 0
+}
+
 // emitHuff emits the given value with the given Huffman encoder.
 func (e *encoder) emitHuff(h huffIndex, value int32) {
-	x := theHuffmanLUT[h][value]
+	if e.freq != nil {
+		e.freq[h][value]++
+		return
+	}
+	x := e.huffLUT[h][value]
 	e.emit(x&(1<<24-1), x>>24)
 }
 
@@ -292,6 +462,25 @@ func (e *encoder) emitHuffRLE(h huffIndex, runLength, value int32) {
 	}
 }
 
+// emitEOBRun emits the current end-of-band run, if any, followed by its
+// correction bits.
+func (e *encoder) emitEOBRun() {
+	if e.eobRun == 0 {
+		return
+	}
+	nBits := uint32(0)
+	for e.eobRun>>(nBits+1) > 0 {
+		nBits++
+	}
+	e.emitHuff(e.eobHuff, int32(nBits<<4))
+	if nBits > 0 {
+		e.emit(uint32(e.eobRun)&(1<<nBits-1), nBits)
+	}
+	e.eobRun = 0
+	e.emitBits(e.eobBits)
+	e.eobBits = e.eobBits[:0]
+}
+
 // writeMarkerHeader writes the header for a marker with the given length.
 func (e *encoder) writeMarkerHeader(marker uint8, markerlen int) {
 	e.buf[0] = 0xff
@@ -301,6 +490,46 @@ func (e *encoder) writeMarkerHeader(marker uint8, markerlen int) {
 	e.write(e.buf[:4])
 }
 
+// exifHeader and iccHeader start the APP1 segment of EXIF metadata and the
+// APP2 segments of an ICC profile.
+const (
+	exifHeader = "Exif\x00\x00"
+	iccHeader  = "ICC_PROFILE\x00"
+)
+
+// maxICCChunk is the maximum size of the part of an ICC profile that an
+// APP2 segment holds, after its header and sequence number and count.
+const maxICCChunk = 0xffff - 2 - len(iccHeader) - 2
+
+// writeEXIF writes the APP1 segment of the given EXIF metadata, which may or
+// may not start with exifHeader.
+func (e *encoder) writeEXIF(exif []byte) {
+	header := exifHeader
+	if len(exif) >= len(exifHeader) && string(exif[:len(exifHeader)]) == exifHeader {
+		header = ""
+	}
+	e.writeMarkerHeader(app1Marker, 2+len(header)+len(exif))
+	e.write([]byte(header))
+	e.write(exif)
+}
+
+// writeICC writes the APP2 segments of the given ICC profile, as specified
+// in section B.4 of the ICC specification.
+func (e *encoder) writeICC(icc []byte) {
+	n := (len(icc) + maxICCChunk - 1) / maxICCChunk
+	for i := 0; i < n; i++ {
+		chunk := icc[i*maxICCChunk:]
+		if len(chunk) > maxICCChunk {
+			chunk = chunk[:maxICCChunk]
+		}
+		e.writeMarkerHeader(app2Marker, 2+len(iccHeader)+2+len(chunk))
+		e.write([]byte(iccHeader))
+		e.writeByte(uint8(i + 1))
+		e.writeByte(uint8(n))
+		e.write(chunk)
+	}
+}
+
 // writeDQT writes the Define Quantization Table marker.
 func (e *encoder) writeDQT() {
 	const markerlen = 2 + int(nQuantIndex)*(1+blockSize)
@@ -311,78 +540,48 @@ func (e *encoder) writeDQT() {
 	}
 }
 
-// writeSOF0 writes the Start Of Frame (Baseline Sequential) marker.
-func (e *encoder) writeSOF0(size image.Point, nComponent int) {
+// writeSOF writes the Start Of Frame marker, either sof0Marker (Baseline
+// Sequential) or sof2Marker (Progressive).
+func (e *encoder) writeSOF(marker uint8, size image.Point) {
+	nComponent := len(e.comp)
 	markerlen := 8 + 3*nComponent
-	e.writeMarkerHeader(sof0Marker, markerlen)
+	e.writeMarkerHeader(marker, markerlen)
 	e.buf[0] = 8 // 8-bit color.
 	e.buf[1] = uint8(size.Y >> 8)
 	e.buf[2] = uint8(size.Y & 0xff)
 	e.buf[3] = uint8(size.X >> 8)
 	e.buf[4] = uint8(size.X & 0xff)
 	e.buf[5] = uint8(nComponent)
-	if nComponent == 1 {
-		e.buf[6] = 1
-		// No subsampling for grayscale image.
-		e.buf[7] = 0x11
-		e.buf[8] = 0x00
-	} else {
-		for i := 0; i < nComponent; i++ {
-			e.buf[3*i+6] = uint8(i + 1)
-			// We use 4:2:0 chroma subsampling.
-			e.buf[3*i+7] = "\x22\x11\x11"[i]
-			e.buf[3*i+8] = "\x00\x01\x01"[i]
-		}
+	for i, c := range e.comp {
+		e.buf[3*i+6] = uint8(i + 1)
+		e.buf[3*i+7] = uint8(c.h<<4 | c.v)
+		e.buf[3*i+8] = uint8(c.q)
 	}
 	e.write(e.buf[:3*(nComponent-1)+9])
 }
 
-// writeDHT writes the Define Huffman Table marker.
-func (e *encoder) writeDHT(nComponent int) {
+// writeDHT writes the Define Huffman Table marker for the given Huffman
+// encodings.
+func (e *encoder) writeDHT(hs []huffIndex) {
 	markerlen := 2
-	specs := theHuffmanSpec[:]
-	if nComponent == 1 {
-		// Drop the Chrominance tables.
-		specs = specs[:2]
-	}
-	for _, s := range specs {
-		markerlen += 1 + 16 + len(s.value)
+	for _, h := range hs {
+		markerlen += 1 + 16 + len(e.huffSpec[h].value)
 	}
 	e.writeMarkerHeader(dhtMarker, markerlen)
-	for i, s := range specs {
-		e.writeByte("\x00\x10\x01\x11"[i])
+	for _, h := range hs {
+		s := e.huffSpec[h]
+		e.writeByte("\x00\x10\x01\x11"[h])
 		e.write(s.count[:])
 		e.write(s.value)
 	}
 }
 
-// writeBlock writes a block of pixel data using the given quantization table,
-// returning the post-quantized DC value of the DCT-transformed block. b is in
-// natural (not zig-zag) order.
-func (e *encoder) writeBlock(b *block, q quantIndex, prevDC int32) int32 {
-	fdct(b)
-	// Emit the DC delta.
-	dc := div(b[0], 8*int32(e.quant[q][0]))
-	e.emitHuffRLE(huffIndex(2*q+0), 0, dc-prevDC)
-	// Emit the AC components.
-	h, runLength := huffIndex(2*q+1), int32(0)
-	for zig := 1; zig < blockSize; zig++ {
-		ac := div(b[unzig[zig]], 8*int32(e.quant[q][zig]))
-		if ac == 0 {
-			runLength++
-		} else {
-			for runLength > 15 {
-				e.emitHuff(h, 0xf0)
-				runLength -= 16
-			}
-			e.emitHuffRLE(h, runLength, ac)
-			runLength = 0
-		}
-	}
-	if runLength > 0 {
-		e.emitHuff(h, 0x00)
-	}
-	return dc
+// writeDRI writes the Define Restart Interval marker.
+func (e *encoder) writeDRI() {
+	e.writeMarkerHeader(driMarker, 4)
+	e.buf[0] = uint8(e.ri >> 8)
+	e.buf[1] = uint8(e.ri & 0xff)
+	e.write(e.buf[:2])
 }
 
 // toYCbCr converts the 8x8 region of m whose top-left corner is p to its
@@ -465,118 +664,460 @@ func yCbCrToYCbCr(m *image.YCbCr, p image.Point, yBlock, cbBlock, crBlock *block
 	}
 }
 
-// scale scales the 16x16 region represented by the 4 src blocks to the 8x8
-// dst block.
-func scale(dst *block, src *[4]block) {
-	for i := 0; i < 4; i++ {
-		dstOff := (i&2)<<4 | (i&1)<<2
-		for y := 0; y < 4; y++ {
-			for x := 0; x < 4; x++ {
-				j := 16*y + 2*x
-				sum := src[i][j] + src[i][j+1] + src[i][j+8] + src[i][j+9]
-				dst[8*y+x+dstOff] = (sum + 2) >> 2
+// maxMCUBlocks is the maximum number of luma blocks in an MCU, for 4:1:0
+// chroma subsampling.
+const maxMCUBlocks = 8
+
+// scale scales the (8*h)x(8*v) region represented by the h*v src blocks, in
+// row-major order, to the 8x8 dst block.
+func scale(dst *block, src *[maxMCUBlocks]block, h, v int) {
+	switch {
+	case h == 1 && v == 1:
+		*dst = src[0]
+		return
+	case h == 2 && v == 2:
+		for i := 0; i < 4; i++ {
+			dstOff := (i&2)<<4 | (i&1)<<2
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 4; x++ {
+					j := 16*y + 2*x
+					sum := src[i][j] + src[i][j+1] + src[i][j+8] + src[i][j+9]
+					dst[8*y+x+dstOff] = (sum + 2) >> 2
+				}
+			}
+		}
+		return
+	}
+	n := int32(h * v)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			sum := int32(0)
+			for j := 0; j < v; j++ {
+				sy := y*v + j
+				for i := 0; i < h; i++ {
+					sx := x*h + i
+					sum += src[(sy/8)*h+sx/8][8*(sy%8)+sx%8]
+				}
 			}
+			dst[8*y+x] = (sum + n/2) / n
 		}
 	}
 }
 
-// sosHeaderY is the SOS marker "\xff\xda" followed by 8 bytes:
-//	- the marker length "\x00\x08",
-//	- the number of components "\x01",
-//	- component 1 uses DC table 0 and AC table 0 "\x01\x00",
-//	- the bytes "\x00\x3f\x00". Section B.2.3 of the spec says that for
-//	  sequential DCTs, those bytes (8-bit Ss, 8-bit Se, 4-bit Ah, 4-bit Al)
-//	  should be 0x00, 0x3f, 0x00<<4 | 0x00.
-var sosHeaderY = []byte{
-	0xff, 0xda, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3f, 0x00,
+// quantize applies the forward DCT to b, which is in natural order, and
+// stores its coefficients quantized with the given table in dst.
+func (e *encoder) quantize(b *block, q quantIndex, dst *coefBlock) {
+	fdct(b)
+	for zig := 0; zig < blockSize; zig++ {
+		dst[zig] = int16(div(b[unzig[zig]], 8*int32(e.quant[q][zig])))
+	}
 }
 
-// sosHeaderYCbCr is the SOS marker "\xff\xda" followed by 12 bytes:
-//	- the marker length "\x00\x0c",
-//	- the number of components "\x03",
-//	- component 1 uses DC table 0 and AC table 0 "\x01\x00",
-//	- component 2 uses DC table 1 and AC table 1 "\x02\x11",
-//	- component 3 uses DC table 1 and AC table 1 "\x03\x11",
-//	- the bytes "\x00\x3f\x00". Section B.2.3 of the spec says that for
-//	  sequential DCTs, those bytes (8-bit Ss, 8-bit Se, 4-bit Ah, 4-bit Al)
-//	  should be 0x00, 0x3f, 0x00<<4 | 0x00.
-var sosHeaderYCbCr = []byte{
-	0xff, 0xda, 0x00, 0x0c, 0x03, 0x01, 0x00, 0x02,
-	0x11, 0x03, 0x11, 0x00, 0x3f, 0x00,
+// coef returns the block of c at the given block coordinates, which must be
+// in an MCU row that c.coef holds.
+func (e *encoder) coef(c *encComponent, bx, by int) *coefBlock {
+	return &c.coef[(by%c.rows)*e.mxx*c.h+bx]
 }
 
-// writeSOS writes the StartOfScan marker.
-func (e *encoder) writeSOS(m image.Image) {
-	switch m.(type) {
-	case *image.Gray:
-		e.write(sosHeaderY)
-	default:
-		e.write(sosHeaderYCbCr)
-	}
+// loadMCURow stores the quantized coefficients of the MCU row my of m.
+func (e *encoder) loadMCURow(m image.Image, my int) {
 	var (
 		// Scratch buffers to hold the YCbCr values.
 		// The blocks are in natural (not zig-zag) order.
 		b      block
-		cb, cr [4]block
-		// DC components are delta-encoded.
-		prevDCY, prevDCCb, prevDCCr int32
+		cb, cr [maxMCUBlocks]block
 	)
 	bounds := m.Bounds()
-	switch m := m.(type) {
-	// TODO(wathiede): switch on m.ColorModel() instead of type.
-	case *image.Gray:
-		for y := bounds.Min.Y; y < bounds.Max.Y; y += 8 {
-			for x := bounds.Min.X; x < bounds.Max.X; x += 8 {
-				p := image.Pt(x, y)
-				grayToY(m, p, &b)
-				prevDCY = e.writeBlock(&b, 0, prevDCY)
+	gray, _ := m.(*image.Gray)
+	rgba, _ := m.(*image.RGBA)
+	ycbcr, _ := m.(*image.YCbCr)
+	y := &e.comp[0]
+	for mx := 0; mx < e.mxx; mx++ {
+		for i := 0; i < y.h*y.v; i++ {
+			bx, by := mx*y.h+i%y.h, my*y.v+i/y.h
+			p := image.Pt(bounds.Min.X+8*bx, bounds.Min.Y+8*by)
+			// TODO(wathiede): switch on m.ColorModel() instead of type.
+			if gray != nil {
+				grayToY(gray, p, &b)
+			} else if rgba != nil {
+				rgbaToYCbCr(rgba, p, &b, &cb[i], &cr[i])
+			} else if ycbcr != nil {
+				yCbCrToYCbCr(ycbcr, p, &b, &cb[i], &cr[i])
+			} else {
+				toYCbCr(m, p, &b, &cb[i], &cr[i])
 			}
+			e.quantize(&b, y.q, e.coef(y, bx, by))
 		}
-	default:
-		rgba, _ := m.(*image.RGBA)
-		ycbcr, _ := m.(*image.YCbCr)
-		for y := bounds.Min.Y; y < bounds.Max.Y; y += 16 {
-			for x := bounds.Min.X; x < bounds.Max.X; x += 16 {
-				for i := 0; i < 4; i++ {
-					xOff := (i & 1) * 8
-					yOff := (i & 2) * 4
-					p := image.Pt(x+xOff, y+yOff)
-					if rgba != nil {
-						rgbaToYCbCr(rgba, p, &b, &cb[i], &cr[i])
-					} else if ycbcr != nil {
-						yCbCrToYCbCr(ycbcr, p, &b, &cb[i], &cr[i])
-					} else {
-						toYCbCr(m, p, &b, &cb[i], &cr[i])
+		if gray != nil {
+			continue
+		}
+		scale(&b, &cb, y.h, y.v)
+		e.quantize(&b, e.comp[1].q, e.coef(&e.comp[1], mx, my))
+		scale(&b, &cr, y.h, y.v)
+		e.quantize(&b, e.comp[2].q, e.coef(&e.comp[2], mx, my))
+	}
+}
+
+// scan is a scan of the image: the indexes of its components, its spectral
+// selection ss to se, and its successive approximation bit positions ah and
+// al, as in section G.1.1. The scan of a sequential image has all the
+// components, and ss, se, ah and al are 0, 63, 0 and 0.
+type scan struct {
+	comp           []int
+	ss, se, ah, al int
+}
+
+// progressiveScans and grayProgressiveScans are the scans of progressive
+// color and grayscale images. They are those that libjpeg writes by default:
+// the DC coefficients first, then a coarse approximation of the AC
+// coefficients, and finally the remaining bits.
+var (
+	progressiveScans = []scan{
+		{[]int{0, 1, 2}, 0, 0, 0, 1},
+		{[]int{0}, 1, 5, 0, 2},
+		{[]int{2}, 1, 63, 0, 1},
+		{[]int{1}, 1, 63, 0, 1},
+		{[]int{0}, 6, 63, 0, 2},
+		{[]int{0}, 1, 63, 2, 1},
+		{[]int{0, 1, 2}, 0, 0, 1, 0},
+		{[]int{2}, 1, 63, 1, 0},
+		{[]int{1}, 1, 63, 1, 0},
+		{[]int{0}, 1, 63, 1, 0},
+	}
+	grayProgressiveScans = []scan{
+		{[]int{0}, 0, 0, 0, 1},
+		{[]int{0}, 1, 5, 0, 2},
+		{[]int{0}, 6, 63, 0, 2},
+		{[]int{0}, 1, 63, 2, 1},
+		{[]int{0}, 0, 0, 1, 0},
+		{[]int{0}, 1, 63, 1, 0},
+	}
+)
+
+// huffTables returns the Huffman encodings that the scan s uses.
+func (e *encoder) huffTables(s scan) []huffIndex {
+	var hs []huffIndex
+	for _, i := range s.comp {
+		q := e.comp[i].q
+		if s.ss == 0 && s.ah == 0 {
+			hs = append(hs, huffIndex(2*q))
+		}
+		if s.se > 0 {
+			hs = append(hs, huffIndex(2*q+1))
+		}
+		if q != quantIndexLuminance {
+			// The chrominance components share their tables.
+			break
+		}
+	}
+	return hs
+}
+
+// optimizeHuffman computes the Huffman encodings that the scan s of m uses
+// from the frequencies of their values, and writes them.
+func (e *encoder) optimizeHuffman(m image.Image, s scan) {
+	hs := e.huffTables(s)
+	if len(hs) == 0 {
+		return
+	}
+	var freq [nHuffIndex][256]int
+	w := e.w
+	e.w, e.freq = discard{}, &freq
+	e.writeSOS(m, s)
+	e.w, e.freq = w, nil
+	for _, h := range hs {
+		e.huffSpec[h] = newHuffmanSpec(&freq[h])
+		e.huffLUT[h].init(e.huffSpec[h])
+	}
+	e.writeDHT(hs)
+}
+
+// writeSOS writes the StartOfScan marker and the image data of the scan s.
+func (e *encoder) writeSOS(m image.Image, s scan) {
+	n := len(s.comp)
+	e.writeMarkerHeader(sosMarker, 6+2*n)
+	e.buf[0] = uint8(n)
+	for i, c := range s.comp {
+		e.buf[2*i+1] = uint8(c + 1)
+		e.buf[2*i+2] = "\x00\x11"[e.comp[c].q]
+	}
+	e.buf[2*n+1] = uint8(s.ss)
+	e.buf[2*n+2] = uint8(s.se)
+	e.buf[2*n+3] = uint8(s.ah<<4 | s.al)
+	e.write(e.buf[:2*n+4])
+
+	// DC components are delta-encoded.
+	var prevDC [3]int32
+	// mcu counts the MCUs, and rst is the index of the next restart marker.
+	mcu, rst := 0, 0
+	restart := func() {
+		if e.ri > 0 && mcu > 0 && mcu%e.ri == 0 {
+			e.emitEOBRun()
+			e.pad()
+			e.buf[0] = 0xff
+			e.buf[1] = rst0Marker + uint8(rst)
+			e.write(e.buf[:2])
+			rst = (rst + 1) % 8
+			prevDC = [3]int32{}
+		}
+		mcu++
+	}
+	if n == 1 {
+		// The MCU of a non-interleaved scan is a single block.
+		c := &e.comp[s.comp[0]]
+		for by := 0; by < c.bh; by++ {
+			if !e.whole {
+				// Only grayscale images, whose MCUs are single
+				// blocks, are in non-interleaved scans that are
+				// encoded an MCU row at a time.
+				e.loadMCURow(m, by)
+			}
+			for bx := 0; bx < c.bw; bx++ {
+				restart()
+				e.writeBlock(s, c, e.coef(c, bx, by), &prevDC[s.comp[0]])
+			}
+		}
+	} else {
+		for my := 0; my < e.myy; my++ {
+			if !e.whole {
+				e.loadMCURow(m, my)
+			}
+			for mx := 0; mx < e.mxx; mx++ {
+				restart()
+				for _, i := range s.comp {
+					c := &e.comp[i]
+					for j := 0; j < c.h*c.v; j++ {
+						e.writeBlock(s, c, e.coef(c, mx*c.h+j%c.h, my*c.v+j/c.h), &prevDC[i])
 					}
-					prevDCY = e.writeBlock(&b, 0, prevDCY)
 				}
-				scale(&b, &cb)
-				prevDCCb = e.writeBlock(&b, 1, prevDCCb)
-				scale(&b, &cr)
-				prevDCCr = e.writeBlock(&b, 1, prevDCCr)
 			}
 		}
 	}
-	// Pad the last byte with 1's.
-	e.emit(0x7f, 7)
+	e.emitEOBRun()
+	e.pad()
+}
+
+// writeBlock writes the part of the block b of the component c that is in
+// the scan s. prevDC is the DC value of the previous block of c in the scan.
+func (e *encoder) writeBlock(s scan, c *encComponent, b *coefBlock, prevDC *int32) {
+	if s.ss == 0 {
+		dc := int32(b[0]) >> uint(s.al)
+		if s.ah == 0 {
+			// Emit the DC delta.
+			e.emitHuffRLE(huffIndex(2*c.q), 0, dc-*prevDC)
+			*prevDC = dc
+		} else {
+			// Emit the next bit of the DC value.
+			e.emit(uint32(dc)&1, 1)
+		}
+	}
+	if s.se == 0 {
+		return
+	}
+	ss := s.ss
+	if ss == 0 {
+		ss = 1
+	}
+	e.eobHuff = huffIndex(2*c.q + 1)
+	if s.ah == 0 {
+		e.writeAC(b[ss:s.se+1], uint(s.al))
+	} else {
+		e.refineAC(b[ss:s.se+1], uint(s.al))
+	}
+}
+
+// writeAC emits the AC coefficients of a block, divided by 1<<al, in a
+// sequential scan or the first scan of a progressive image to have them.
+// The blocks of a sequential scan each end their own end-of-band run.
+func (e *encoder) writeAC(coef []int16, al uint) {
+	h, runLength := e.eobHuff, int32(0)
+	for _, x := range coef {
+		ac := int32(x)
+		if al > 0 {
+			if ac < 0 {
+				ac = -(-ac >> al)
+			} else {
+				ac >>= al
+			}
+		}
+		if ac == 0 {
+			runLength++
+			continue
+		}
+		if e.eobRun > 0 {
+			e.emitEOBRun()
+		}
+		for runLength > 15 {
+			e.emitHuff(h, 0xf0)
+			runLength -= 16
+		}
+		e.emitHuffRLE(h, runLength, ac)
+		runLength = 0
+	}
+	if runLength > 0 {
+		e.eobRun++
+		if !e.progressive || e.eobRun == 0x7fff {
+			e.emitEOBRun()
+		}
+	}
+}
+
+// refineAC emits the bit al of the AC coefficients of a block, in an AC
+// refinement scan, as in section G.1.2.3.
+func (e *encoder) refineAC(coef []int16, al uint) {
+	// eob is the index of the last coefficient that becomes non-zero.
+	eob := -1
+	for k, x := range coef {
+		if abs16(x)>>al == 1 {
+			eob = k
+		}
+	}
+	h, runLength := e.eobHuff, int32(0)
+	// bits are the correction bits of the coefficients that were already
+	// non-zero, since the last emitted value.
+	bits := e.corrBits[:0]
+	for k, x := range coef {
+		a := abs16(x) >> al
+		if a == 0 {
+			runLength++
+			continue
+		}
+		for runLength > 15 && k <= eob {
+			e.emitEOBRun()
+			e.emitHuff(h, 0xf0)
+			runLength -= 16
+			e.emitBits(bits)
+			bits = bits[:0]
+		}
+		if a > 1 {
+			bits = append(bits, byte(a&1))
+			continue
+		}
+		e.emitEOBRun()
+		e.emitHuff(h, runLength<<4|1)
+		if x < 0 {
+			e.emit(0, 1)
+		} else {
+			e.emit(1, 1)
+		}
+		e.emitBits(bits)
+		bits = bits[:0]
+		runLength = 0
+	}
+	if runLength > 0 || len(bits) > 0 {
+		e.eobRun++
+		e.eobBits = append(e.eobBits, bits...)
+		if e.eobRun == 0x7fff {
+			e.emitEOBRun()
+		}
+	}
+	e.corrBits = bits[:0]
+}
+
+func abs16(x int16) int32 {
+	if x < 0 {
+		return -int32(x)
+	}
+	return int32(x)
 }
 
 // DefaultQuality is the default quality encoding parameter.
 const DefaultQuality = 75
 
+// Subsampling is the chroma subsampling ratio of an encoded color image.
+// The names follow those of image.YCbCrSubsampleRatio.
+type Subsampling int
+
+const (
+	Subsampling420 Subsampling = iota
+	Subsampling444
+	Subsampling422
+	Subsampling440
+	Subsampling411
+	Subsampling410
+)
+
 // Options are the encoding parameters.
 // Quality ranges from 1 to 100 inclusive, higher is better.
 type Options struct {
 	Quality int
+
+	// Subsampling is the chroma subsampling ratio of color images. The
+	// zero value is Subsampling420. Grayscale images have no chroma.
+	Subsampling Subsampling
+
+	// OptimizeHuffman is whether to compute the Huffman tables from the
+	// image, as in section K.2 of the JPEG specification, instead of using
+	// the standard tables of section K.3. It makes the encoded image
+	// smaller but the encoder slower, and it needs memory for the
+	// coefficients of the whole image.
+	OptimizeHuffman bool
+
+	// Progressive is whether to encode a progressive image, which decoders
+	// can show in successively better approximations as it loads.
+	// Progressive images always have optimized Huffman tables.
+	Progressive bool
+
+	// RestartInterval, if positive, is the number of MCUs between restart
+	// markers, which let decoders resynchronize after corrupted data. It
+	// must be less than 1<<16.
+	RestartInterval int
+
+	// EXIF, if not empty, is the EXIF metadata to write in an APP1 segment.
+	// The "Exif\x00\x00" header that starts the segment is added if the
+	// metadata does not start with it.
+	EXIF []byte
+
+	// ICCProfile, if not empty, is the ICC color profile to write in APP2
+	// segments.
+	ICCProfile []byte
 }
 
-// Encode writes the Image m to w in JPEG 4:2:0 baseline format with the given
-// options. Default parameters are used if a nil *Options is passed.
+// Encode writes the Image m to w in JPEG format with the given options:
+// baseline, or progressive, with 4:2:0 chroma subsampling unless the options
+// say otherwise. Default parameters are used if a nil *Options is passed.
 func Encode(w io.Writer, m image.Image, o *Options) error {
 	b := m.Bounds()
 	if b.Dx() >= 1<<16 || b.Dy() >= 1<<16 {
 		return errors.New("jpeg: image is too large to encode")
 	}
+	var opts Options
+	if o != nil {
+		opts = *o
+	} else {
+		opts.Quality = DefaultQuality
+	}
+	var hy, vy int
+	switch opts.Subsampling {
+	case Subsampling420:
+		hy, vy = 2, 2
+	case Subsampling444:
+		hy, vy = 1, 1
+	case Subsampling422:
+		hy, vy = 2, 1
+	case Subsampling440:
+		hy, vy = 1, 2
+	case Subsampling411:
+		hy, vy = 4, 1
+	case Subsampling410:
+		hy, vy = 4, 2
+	default:
+		return errors.New("jpeg: invalid subsampling ratio")
+	}
+	if opts.RestartInterval < 0 || opts.RestartInterval >= 1<<16 {
+		return errors.New("jpeg: invalid restart interval")
+	}
+	exifLen := len(opts.EXIF)
+	if exifLen > 0 && (exifLen < len(exifHeader) || string(opts.EXIF[:len(exifHeader)]) != exifHeader) {
+		exifLen += len(exifHeader)
+	}
+	if exifLen > 0xffff-2 {
+		return errors.New("jpeg: EXIF metadata is too large")
+	}
+	if len(opts.ICCProfile) > 255*maxICCChunk {
+		return errors.New("jpeg: ICC profile is too large")
+	}
+
 	var e encoder
 	if ww, ok := w.(writer); ok {
 		e.w = ww
@@ -584,14 +1125,11 @@ func Encode(w io.Writer, m image.Image, o *Options) error {
 		e.w = bufio.NewWriter(w)
 	}
 	// Clip quality to [1, 100].
-	quality := DefaultQuality
-	if o != nil {
-		quality = o.Quality
-		if quality < 1 {
-			quality = 1
-		} else if quality > 100 {
-			quality = 100
-		}
+	quality := opts.Quality
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
 	}
 	// Convert from a quality rating to a scaling factor.
 	var scale int
@@ -613,25 +1151,87 @@ func Encode(w io.Writer, m image.Image, o *Options) error {
 			e.quant[i][j] = uint8(x)
 		}
 	}
-	// Compute number of components based on input image type.
-	nComponent := 3
+	e.huffSpec = theHuffmanSpec
+	e.huffLUT = theHuffmanLUT
+	e.progressive = opts.Progressive
+	e.ri = opts.RestartInterval
+
+	// Set up the components based on input image type.
 	switch m.(type) {
 	// TODO(wathiede): switch on m.ColorModel() instead of type.
 	case *image.Gray:
-		nComponent = 1
+		// No subsampling for grayscale image.
+		e.comp = []encComponent{{h: 1, v: 1, q: quantIndexLuminance}}
+	default:
+		e.comp = []encComponent{
+			{h: hy, v: vy, q: quantIndexLuminance},
+			{h: 1, v: 1, q: quantIndexChrominance},
+			{h: 1, v: 1, q: quantIndexChrominance},
+		}
+	}
+	hmax, vmax := e.comp[0].h, e.comp[0].v
+	e.mxx = (b.Dx() + 8*hmax - 1) / (8 * hmax)
+	e.myy = (b.Dy() + 8*vmax - 1) / (8 * vmax)
+	// Unless the image data is needed for more than one pass, it is
+	// converted an MCU row at a time.
+	e.whole = opts.Progressive || opts.OptimizeHuffman
+	for i := range e.comp {
+		c := &e.comp[i]
+		c.bw = ((b.Dx()*c.h+hmax-1)/hmax + 7) / 8
+		c.bh = ((b.Dy()*c.v+vmax-1)/vmax + 7) / 8
+		c.rows = c.v
+		if e.whole {
+			c.rows *= e.myy
+		}
+		c.coef = make([]coefBlock, c.rows*e.mxx*c.h)
 	}
+
 	// Write the Start Of Image marker.
 	e.buf[0] = 0xff
 	e.buf[1] = 0xd8
 	e.write(e.buf[:2])
+	// Write the metadata.
+	if len(opts.EXIF) > 0 {
+		e.writeEXIF(opts.EXIF)
+	}
+	if len(opts.ICCProfile) > 0 {
+		e.writeICC(opts.ICCProfile)
+	}
 	// Write the quantization tables.
 	e.writeDQT()
 	// Write the image dimensions.
-	e.writeSOF0(b.Size(), nComponent)
-	// Write the Huffman tables.
-	e.writeDHT(nComponent)
-	// Write the image data.
-	e.writeSOS(m)
+	if e.progressive {
+		e.writeSOF(sof2Marker, b.Size())
+	} else {
+		e.writeSOF(sof0Marker, b.Size())
+	}
+	if e.ri > 0 {
+		e.writeDRI()
+	}
+	if e.whole {
+		for my := 0; my < e.myy; my++ {
+			e.loadMCURow(m, my)
+		}
+	}
+	// Write the Huffman tables and the image data.
+	if e.progressive {
+		scans := progressiveScans
+		if len(e.comp) == 1 {
+			scans = grayProgressiveScans
+		}
+		for _, s := range scans {
+			e.optimizeHuffman(m, s)
+			e.writeSOS(m, s)
+		}
+	} else {
+		s := scan{comp: []int{0, 1, 2}[:len(e.comp)], se: blockSize - 1}
+		if opts.OptimizeHuffman {
+			e.optimizeHuffman(m, s)
+		} else {
+			e.writeDHT(e.huffTables(s))
+		}
+		e.writeSOS(m, s)
+	}
 	// Write the End Of Image marker.
 	e.buf[0] = 0xff
 	e.buf[1] = 0xd9
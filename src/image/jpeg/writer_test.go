@@ -242,6 +242,174 @@ func TestEncodeYCbCr(t *testing.T) {
 	}
 }
 
+// TestEncodeOptions tests that the options that only change how the
+// coefficients are encoded do not change the decoded image.
+func TestEncodeOptions(t *testing.T) {
+	m, err := readPng("../testdata/video-001.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An odd size exercises the partial MCUs.
+	sub := m.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(image.Rect(0, 0, 137, 93))
+	gray := image.NewGray(sub.Bounds())
+	draw(gray, sub)
+
+	for _, m0 := range []image.Image{sub, gray} {
+		for _, sub := range []Subsampling{Subsampling420, Subsampling444, Subsampling422, Subsampling440, Subsampling411, Subsampling410} {
+			var want image.Image
+			var wantSize int
+			for _, o := range []Options{
+				{},
+				{OptimizeHuffman: true},
+				{RestartInterval: 7},
+				{Progressive: true},
+				{Progressive: true, RestartInterval: 5},
+				{Progressive: true, OptimizeHuffman: true, RestartInterval: 1},
+			} {
+				o.Quality = 80
+				o.Subsampling = sub
+				var buf bytes.Buffer
+				if err := Encode(&buf, m0, &o); err != nil {
+					t.Fatalf("%T, %+v: Encode: %v", m0, o, err)
+				}
+				size := buf.Len()
+				m1, err := Decode(&buf)
+				if err != nil {
+					t.Fatalf("%T, %+v: Decode: %v", m0, o, err)
+				}
+				if want == nil {
+					want, wantSize = m1, size
+					if d := averageDelta(m0, m1); d > 6<<8 {
+						t.Errorf("%T, %+v: average delta is too high: %d", m0, o, d)
+					}
+					continue
+				}
+				if o.OptimizeHuffman && o.RestartInterval == 0 && size >= wantSize {
+					t.Errorf("%T, %+v: optimized size %d, want less than %d", m0, o, size, wantSize)
+				}
+				if !sameImage(m1, want) {
+					t.Errorf("%T, %+v: decoded image differs from the baseline one", m0, o)
+				}
+			}
+		}
+	}
+}
+
+// draw copies src to dst, which have the same bounds.
+func draw(dst *image.Gray, src image.Image) {
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x, y, src.At(x, y))
+		}
+	}
+}
+
+func sameImage(m0, m1 image.Image) bool {
+	b := m0.Bounds()
+	if b != m1.Bounds() {
+		return false
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if m0.At(x, y) != m1.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	exif := []byte("II*\x00\x08\x00\x00\x00\x00\x00")
+	icc := make([]byte, 2*maxICCChunk+10)
+	for i := range icc {
+		icc[i] = byte(i)
+	}
+	for _, e := range [][]byte{exif, append([]byte(exifHeader), exif...)} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, &Options{EXIF: e, ICCProfile: icc}); err != nil {
+			t.Fatal(err)
+		}
+		// Collect the APP1 and APP2 segments, which follow the SOI marker.
+		var gotEXIF, gotICC []byte
+		data := buf.Bytes()[2:]
+		for len(data) >= 4 && data[0] == 0xff && (data[1] == app1Marker || data[1] == app2Marker) {
+			n := int(data[2])<<8 | int(data[3])
+			seg := data[4 : 2+n]
+			if data[1] == app1Marker {
+				gotEXIF = seg
+			} else {
+				if !bytes.HasPrefix(seg, []byte(iccHeader)) || seg[len(iccHeader)+1] != 3 {
+					t.Fatalf("bad APP2 segment header % x", seg[:len(iccHeader)+2])
+				}
+				gotICC = append(gotICC, seg[len(iccHeader)+2:]...)
+			}
+			data = data[2+n:]
+		}
+		if want := exifHeader + string(exif); string(gotEXIF) != want {
+			t.Errorf("EXIF: got %q, want %q", gotEXIF, want)
+		}
+		if !bytes.Equal(gotICC, icc) {
+			t.Errorf("ICC profile: got %d bytes, want %d", len(gotICC), len(icc))
+		}
+		if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		if _, p, err := DecodeWithProfile(&buf); err != nil {
+			t.Errorf("DecodeWithProfile: %v", err)
+		} else if !bytes.Equal(p, icc) {
+			t.Errorf("DecodeWithProfile: got %d bytes of profile, want %d", len(p), len(icc))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, &Options{EXIF: exif}); err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err := DecodeWithProfile(&buf); err != nil || p != nil {
+		t.Errorf("DecodeWithProfile: profile %v, error %v, want nil", p, err)
+	}
+
+	for _, o := range []Options{
+		{EXIF: make([]byte, 0xffff)},
+		{ICCProfile: make([]byte, 255*maxICCChunk+1)},
+		{RestartInterval: 1 << 16},
+		{Subsampling: -1},
+	} {
+		if err := Encode(io.Discard, m, &o); err == nil {
+			t.Errorf("Encode with invalid options succeeded")
+		}
+	}
+}
+
+func TestNewHuffmanSpec(t *testing.T) {
+	// Frequencies that make a tree deeper than 16 levels.
+	var freq [256]int
+	for i := range freq[:40] {
+		freq[i] = 1 << uint(i/2)
+	}
+	s := newHuffmanSpec(&freq)
+	n := 0
+	for _, c := range s.count {
+		n += int(c)
+	}
+	if n != 40 || len(s.value) != 40 {
+		t.Fatalf("got %d codes and %d values, want 40", n, len(s.value))
+	}
+	// Kraft's inequality, with the all 1 bits code reserved.
+	sum := 0
+	for i, c := range s.count {
+		sum += int(c) << uint(15-i)
+	}
+	if sum >= 1<<16 {
+		t.Errorf("codes are not a prefix code: sum %d", sum)
+	}
+}
+
 func BenchmarkEncodeRGBA(b *testing.B) {
 	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
 	bo := img.Bounds()
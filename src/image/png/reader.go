@@ -4,10 +4,12 @@
 
 // Package png implements a PNG image decoder and encoder.
 //
-// The PNG specification is at https://www.w3.org/TR/PNG/.
+// The PNG specification is at https://www.w3.org/TR/PNG/. The APNG extension
+// for animations is specified at https://wiki.mozilla.org/APNG_Specification.
 package png
 
 import (
+	"bytes"
 	"compress/zlib"
 	"encoding/binary"
 	"fmt"
@@ -16,6 +18,7 @@ import (
 	"image"
 	"image/color"
 	"io"
+	"time"
 )
 
 // Color type, as per the PNG spec.
@@ -101,6 +104,12 @@ const (
 
 const pngHeader = "\x89PNG\r\n\x1a\n"
 
+// maxProfileSize is the largest decompressed ICC profile that the decoder
+// accepts, so that a small iCCP chunk cannot expand into an arbitrarily
+// large allocation. Real profiles, even those with large lookup tables,
+// are well under this size.
+const maxProfileSize = 4 << 20
+
 type decoder struct {
 	r             io.Reader
 	img           image.Image
@@ -118,6 +127,28 @@ type decoder struct {
 	// transparency, as opposed to palette transparency.
 	useTransparent bool
 	transparent    [6]byte
+
+	// wantProfile is whether to decompress the ICC profile of an iCCP
+	// chunk into profile, rather than ignore the chunk.
+	wantProfile bool
+	profile     []byte
+
+	// anim is the animation that DecodeAll collects the frames of an APNG
+	// image into, or nil to ignore the acTL, fcTL and fdAT chunks.
+	anim      *APNG
+	numFrames int    // the number of frames given by the acTL chunk
+	seq       uint32 // the next sequence number of an fcTL or fdAT chunk
+	frame     *frameControl
+	fdat      bool // whether Read presents fdAT chunks rather than IDAT chunks
+}
+
+// A frameControl holds the fields of an fcTL chunk, for the frame whose
+// data follows.
+type frameControl struct {
+	bounds  image.Rectangle
+	delay   time.Duration
+	dispose byte
+	blend   byte
 }
 
 // A FormatError reports that the input is not a valid PNG.
@@ -323,6 +354,49 @@ func (d *decoder) parsetRNS(length uint32) error {
 	return d.verifyChecksum()
 }
 
+func (d *decoder) parseiCCP(length uint32) error {
+	if length > 0x7fffffff {
+		return FormatError(fmt.Sprintf("Bad chunk length: %d", length))
+	}
+	// Read the chunk through a LimitReader, so that a bad length in a
+	// truncated stream does not allocate the whole length up front.
+	data, err := io.ReadAll(io.LimitReader(d.r, int64(length)))
+	if err != nil {
+		return err
+	}
+	if len(data) < int(length) {
+		return io.ErrUnexpectedEOF
+	}
+	d.crc.Write(data)
+	if err := d.verifyChecksum(); err != nil {
+		return err
+	}
+
+	// The chunk holds a profile name of 1 to 79 bytes, a null separator,
+	// the compression method and the compressed profile.
+	i := bytes.IndexByte(data, 0)
+	if i < 1 || i > 79 || i+2 > len(data) {
+		return FormatError("bad iCCP profile name")
+	}
+	if data[i+1] != 0 {
+		return FormatError("bad iCCP compression method")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data[i+2:]))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	profile, err := io.ReadAll(io.LimitReader(r, maxProfileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(profile) > maxProfileSize {
+		return FormatError("iCCP profile too large")
+	}
+	d.profile = profile
+	return nil
+}
+
 // Read presents one or more IDAT chunks as one continuous stream (minus the
 // intermediate chunk headers and footers). If the PNG data looked like:
 //   ... len0 IDAT xxx crc0 len1 IDAT yy crc1 len2 IEND crc2
@@ -340,16 +414,25 @@ func (d *decoder) Read(p []byte) (int, error) {
 			return 0, err
 		}
 		// Read the length and chunk type of the next chunk, and check that
-		// it is an IDAT chunk.
+		// it is an IDAT chunk, or an fdAT chunk for a frame of an animation.
 		if _, err := io.ReadFull(d.r, d.tmp[:8]); err != nil {
 			return 0, err
 		}
 		d.idatLength = binary.BigEndian.Uint32(d.tmp[:4])
-		if string(d.tmp[4:8]) != "IDAT" {
+		name := "IDAT"
+		if d.fdat {
+			name = "fdAT"
+		}
+		if string(d.tmp[4:8]) != name {
 			return 0, FormatError("not enough pixel data")
 		}
 		d.crc.Reset()
 		d.crc.Write(d.tmp[4:8])
+		if d.fdat {
+			if err := d.readSequenceNumber(&d.idatLength); err != nil {
+				return 0, err
+			}
+		}
 	}
 	if int(d.idatLength) < 0 {
 		return 0, UnsupportedError("IDAT chunk length overflow")
@@ -850,9 +933,144 @@ func (d *decoder) parseIDAT(length uint32) (err error) {
 	if err != nil {
 		return err
 	}
+	if d.frame != nil {
+		// The default image is the first frame of the animation.
+		if d.frame.bounds != image.Rect(0, 0, d.width, d.height) {
+			return FormatError("bad fcTL bounds of the default image")
+		}
+		d.addFrame(d.img)
+	}
 	return d.verifyChecksum()
 }
 
+// readSequenceNumber reads the sequence number that starts an fcTL or fdAT
+// chunk of the given remaining length, and checks that it is the next one.
+func (d *decoder) readSequenceNumber(length *uint32) error {
+	if *length < 4 {
+		return FormatError("bad APNG chunk length")
+	}
+	if _, err := io.ReadFull(d.r, d.tmp[:4]); err != nil {
+		return err
+	}
+	d.crc.Write(d.tmp[:4])
+	*length -= 4
+	if binary.BigEndian.Uint32(d.tmp[:4]) != d.seq {
+		return FormatError("bad APNG sequence number")
+	}
+	d.seq++
+	return nil
+}
+
+func (d *decoder) parseacTL(length uint32) error {
+	if length != 8 {
+		return FormatError("bad acTL length")
+	}
+	if _, err := io.ReadFull(d.r, d.tmp[:8]); err != nil {
+		return err
+	}
+	d.crc.Write(d.tmp[:8])
+	n := binary.BigEndian.Uint32(d.tmp[:4])
+	plays := binary.BigEndian.Uint32(d.tmp[4:8])
+	if n == 0 || n > 1<<31-1 || plays > 1<<31-1 {
+		return FormatError("bad acTL values")
+	}
+	d.numFrames = int(n)
+	d.anim.LoopCount = int(plays)
+	return d.verifyChecksum()
+}
+
+func (d *decoder) parsefcTL(length uint32) error {
+	if length != 26 {
+		return FormatError("bad fcTL length")
+	}
+	if d.frame != nil {
+		return FormatError("fcTL without frame data")
+	}
+	if err := d.readSequenceNumber(&length); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(d.r, d.tmp[:length]); err != nil {
+		return err
+	}
+	d.crc.Write(d.tmp[:length])
+	w := int64(binary.BigEndian.Uint32(d.tmp[0:4]))
+	h := int64(binary.BigEndian.Uint32(d.tmp[4:8]))
+	x := int64(binary.BigEndian.Uint32(d.tmp[8:12]))
+	y := int64(binary.BigEndian.Uint32(d.tmp[12:16]))
+	if w == 0 || h == 0 || x+w > int64(d.width) || y+h > int64(d.height) {
+		return FormatError("bad fcTL bounds")
+	}
+	num := time.Duration(binary.BigEndian.Uint16(d.tmp[16:18]))
+	den := time.Duration(binary.BigEndian.Uint16(d.tmp[18:20]))
+	if den == 0 {
+		// A denominator of 0 stands for 100.
+		den = 100
+	}
+	dispose, blend := d.tmp[20], d.tmp[21]
+	if dispose > DisposeOpPrevious || blend > BlendOpOver {
+		return FormatError("bad fcTL operation")
+	}
+	d.frame = &frameControl{
+		bounds:  image.Rect(int(x), int(y), int(x+w), int(y+h)),
+		delay:   num * time.Second / den,
+		dispose: dispose,
+		blend:   blend,
+	}
+	return d.verifyChecksum()
+}
+
+func (d *decoder) parsefdAT(length uint32) error {
+	if err := d.readSequenceNumber(&length); err != nil {
+		return err
+	}
+	// Decode the frame data as an image of the size of the frame, with the
+	// parameters of the IHDR chunk, then move it to the frame position.
+	width, height := d.width, d.height
+	d.width, d.height = d.frame.bounds.Dx(), d.frame.bounds.Dy()
+	d.idatLength = length
+	d.fdat = true
+	m, err := d.decode()
+	d.width, d.height = width, height
+	d.fdat = false
+	if err != nil {
+		return err
+	}
+	d.addFrame(translate(m, d.frame.bounds.Min))
+	return d.verifyChecksum()
+}
+
+// addFrame adds the image m to the animation, with the pending frame
+// control.
+func (d *decoder) addFrame(m image.Image) {
+	a := d.anim
+	a.Image = append(a.Image, m)
+	a.Delay = append(a.Delay, d.frame.delay)
+	a.Dispose = append(a.Dispose, d.frame.dispose)
+	a.Blend = append(a.Blend, d.frame.blend)
+	d.frame = nil
+}
+
+// translate moves the image m, with its origin at (0, 0), to p.
+func translate(m image.Image, p image.Point) image.Image {
+	switch m := m.(type) {
+	case *image.Gray:
+		m.Rect = m.Rect.Add(p)
+	case *image.Gray16:
+		m.Rect = m.Rect.Add(p)
+	case *image.NRGBA:
+		m.Rect = m.Rect.Add(p)
+	case *image.NRGBA64:
+		m.Rect = m.Rect.Add(p)
+	case *image.RGBA:
+		m.Rect = m.Rect.Add(p)
+	case *image.RGBA64:
+		m.Rect = m.Rect.Add(p)
+	case *image.Paletted:
+		m.Rect = m.Rect.Add(p)
+	}
+	return m
+}
+
 func (d *decoder) parseIEND(length uint32) error {
 	if length != 0 {
 		return FormatError("bad IEND length")
@@ -893,6 +1111,39 @@ func (d *decoder) parseChunk() error {
 		}
 		d.stage = dsSeentRNS
 		return d.parsetRNS(length)
+	case "iCCP":
+		// The iCCP chunk must appear before the PLTE and IDAT chunks, and
+		// at most once. One that does not is ignored, as other chunks that
+		// the decoder does not need are.
+		if d.wantProfile && d.stage == dsSeenIHDR && d.profile == nil {
+			return d.parseiCCP(length)
+		}
+	case "acTL":
+		// The acTL chunk must appear before the IDAT chunks, and at most
+		// once, for the fcTL and fdAT chunks to make an animation.
+		if d.anim == nil {
+			break
+		}
+		if d.stage < dsSeenIHDR || d.stage >= dsSeenIDAT || d.numFrames != 0 {
+			return chunkOrderError
+		}
+		return d.parseacTL(length)
+	case "fcTL":
+		if d.anim == nil || d.numFrames == 0 {
+			break
+		}
+		if d.stage < dsSeenIHDR {
+			return chunkOrderError
+		}
+		return d.parsefcTL(length)
+	case "fdAT":
+		if d.anim == nil || d.numFrames == 0 {
+			break
+		}
+		if d.stage != dsSeenIDAT || d.frame == nil {
+			return chunkOrderError
+		}
+		return d.parsefdAT(length)
 	case "IDAT":
 		if d.stage < dsSeenIHDR || d.stage > dsSeenIDAT || (d.stage == dsSeenIHDR && cbPaletted(d.cb)) {
 			return chunkOrderError
@@ -957,21 +1208,121 @@ func Decode(r io.Reader) (image.Image, error) {
 		r:   r,
 		crc: crc32.NewIEEE(),
 	}
+	if err := d.decodeAll(); err != nil {
+		return nil, err
+	}
+	return d.img, nil
+}
+
+// DecodeWithProfile is like Decode, but it also returns the ICC profile
+// embedded in the iCCP chunk of the PNG image, or nil if there is none.
+// The profile can be parsed with color.ParseProfile.
+func DecodeWithProfile(r io.Reader) (image.Image, []byte, error) {
+	d := &decoder{
+		r:           r,
+		crc:         crc32.NewIEEE(),
+		wantProfile: true,
+	}
+	if err := d.decodeAll(); err != nil {
+		return nil, nil, err
+	}
+	return d.img, d.profile, nil
+}
+
+// Frame disposal operations, from the dispose_op field of the fcTL chunk. They
+// specify how the area of a frame is disposed of before rendering the next one.
+const (
+	DisposeOpNone       = 0 // Leave the area as it is.
+	DisposeOpBackground = 1 // Clear the area to fully transparent black.
+	DisposeOpPrevious   = 2 // Revert the area to what it was before the frame.
+)
+
+// Frame blend operations, from the blend_op field of the fcTL chunk. They
+// specify how a frame is rendered over the area it covers.
+const (
+	BlendOpSource = 0 // Replace the area with the frame, including its alpha.
+	BlendOpOver   = 1 // Composite the frame over the area.
+)
+
+// APNG represents the possibly multiple images of an animated PNG image,
+// which is a PNG image with additional chunks that specify the frames of
+// an animation. Decoders without support for animations only see its
+// default image.
+type APNG struct {
+	Image []image.Image   // The successive frames.
+	Delay []time.Duration // The successive delay times, one per frame.
+	// Dispose and Blend are the successive disposal and blend operations,
+	// one per frame. A nil Dispose or Blend is valid to pass to EncodeAll,
+	// and implies that the operation of each frame is DisposeOpNone or
+	// BlendOpSource respectively.
+	Dispose []byte
+	Blend   []byte
+	// LoopCount is the number of times to play the animation. A LoopCount
+	// of 0 means to loop forever.
+	LoopCount int
+	// Default is the default image, when it is not the first frame of the
+	// animation. It has the size of the canvas. If nil, the first frame
+	// is the default image, and its bounds must be those of the canvas.
+	Default image.Image
+	// Config is the color model and the width and height of the canvas.
+	// The bounds of each frame must be within the rectangle defined by the
+	// two points (0, 0) and (Config.Width, Config.Height).
+	//
+	// EncodeAll ignores the color model. A zero width and height imply that
+	// the canvas is the rectangle from (0, 0) to the first frame's bounds'
+	// Rectangle.Max point.
+	Config image.Config
+}
+
+// DecodeAll reads a PNG image from r and returns the sequential frames of
+// its animation, and their timing and operations. An image without the
+// chunks of an animation is returned as an animation of one frame, the
+// image itself, to play once.
+func DecodeAll(r io.Reader) (*APNG, error) {
+	d := &decoder{
+		r:    r,
+		crc:  crc32.NewIEEE(),
+		anim: &APNG{},
+	}
+	if err := d.decodeAll(); err != nil {
+		return nil, err
+	}
+	a := d.anim
+	a.Config = d.config()
+	if d.numFrames == 0 {
+		a.Image = []image.Image{d.img}
+		a.Delay = []time.Duration{0}
+		a.Dispose = []byte{DisposeOpNone}
+		a.Blend = []byte{BlendOpSource}
+		a.LoopCount = 1
+		return a, nil
+	}
+	if d.frame != nil || len(a.Image) != d.numFrames {
+		return nil, FormatError("bad number of APNG frames")
+	}
+	if a.Image[0] != d.img {
+		a.Default = d.img
+	}
+	return a, nil
+}
+
+// decodeAll reads the chunks of a PNG image up to the IEND chunk.
+func (d *decoder) decodeAll() error {
 	if err := d.checkHeader(); err != nil {
 		if err == io.EOF {
 			err = io.ErrUnexpectedEOF
 		}
-		return nil, err
+		return err
 	}
 	for d.stage != dsSeenIEND {
 		if err := d.parseChunk(); err != nil {
 			if err == io.EOF {
 				err = io.ErrUnexpectedEOF
 			}
-			return nil, err
+			return err
 		}
 	}
-	return d.img, nil
+	return nil
 }
 
 // DecodeConfig returns the color model and dimensions of a PNG image without
@@ -1002,6 +1353,12 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 			break
 		}
 	}
+	return d.config(), nil
+}
+
+// config returns the color model and dimensions of the image from the IHDR
+// and PLTE chunks.
+func (d *decoder) config() image.Config {
 	var cm color.Model
 	switch d.cb {
 	case cbG1, cbG2, cbG4, cbG8:
@@ -1027,7 +1384,7 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 		ColorModel: cm,
 		Width:      d.width,
 		Height:     d.height,
-	}, nil
+	}
 }
 
 func init() {
@@ -7,6 +7,7 @@ package png
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
@@ -571,6 +572,77 @@ func TestMultipletRNSChunks(t *testing.T) {
 	}
 }
 
+func TestDecodeAllStatic(t *testing.T) {
+	f, err := os.Open("testdata/pngsuite/basn3p04.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, err := DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := readPNG("testdata/pngsuite/basn3p04.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Image) != 1 || a.LoopCount != 1 || a.Default != nil {
+		t.Fatalf("got %d frames, loop count %d and default image %v, want 1, 1 and nil", len(a.Image), a.LoopCount, a.Default)
+	}
+	if !reflect.DeepEqual(a.Image[0], m) {
+		t.Errorf("frame differs from the image")
+	}
+	if a.Config.Width != 32 || a.Config.Height != 32 || !reflect.DeepEqual(a.Config.ColorModel, m.ColorModel()) {
+		t.Errorf("bad config %v", a.Config)
+	}
+}
+
+// splitChunks returns the chunks of the PNG image b, with their length,
+// type and CRC.
+func splitChunks(b []byte) [][]byte {
+	var chunks [][]byte
+	for b = b[len(pngHeader):]; len(b) > 0; {
+		n := 12 + int(binary.BigEndian.Uint32(b))
+		chunks = append(chunks, b[:n])
+		b = b[n:]
+	}
+	return chunks
+}
+
+func TestDecodeAllErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := EncodeAll(&b, testAnimations()[0]); err != nil {
+		t.Fatal(err)
+	}
+	// IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND
+	chunks := splitChunks(append([]byte(nil), b.Bytes()...))
+	for _, order := range [][]int{
+		// A missing frame.
+		{0, 1, 2, 3, 4, 5, 8},
+		// A frame without data.
+		{0, 1, 2, 3, 4, 5, 6, 8},
+		// A missing sequence number.
+		{0, 1, 2, 3, 6, 7, 8},
+		// The acTL chunk after the IDAT chunk.
+		{0, 2, 3, 1, 4, 5, 6, 7, 8},
+		// An fdAT chunk without fcTL chunk.
+		{0, 1, 2, 3, 5, 4, 6, 7, 8},
+	} {
+		b.Reset()
+		b.WriteString(pngHeader)
+		for _, i := range order {
+			b.Write(chunks[i])
+		}
+		if _, err := DecodeAll(bytes.NewReader(b.Bytes())); err == nil {
+			t.Errorf("chunks %v: DecodeAll succeeded", order)
+		}
+		// Decode ignores the chunks of the animation.
+		if _, err := Decode(bytes.NewReader(b.Bytes())); err != nil {
+			t.Errorf("chunks %v: Decode: %v", order, err)
+		}
+	}
+}
+
 func TestUnknownChunkLengthUnderflow(t *testing.T) {
 	data := []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0xff, 0xff,
 		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x06, 0xf4, 0x7c, 0x55, 0x04, 0x1a,
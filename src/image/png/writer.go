@@ -6,6 +6,7 @@ package png
 
 import (
 	"bufio"
+	"bytes"
 	"compress/zlib"
 	"encoding/binary"
 	"hash/crc32"
@@ -13,6 +14,7 @@ import (
 	"image/color"
 	"io"
 	"strconv"
+	"time"
 )
 
 // Encoder configures encoding PNG images.
@@ -22,6 +24,11 @@ type Encoder struct {
 	// BufferPool optionally specifies a buffer pool to get temporary
 	// EncoderBuffers when encoding an image.
 	BufferPool EncoderBufferPool
+
+	// ICCProfile optionally specifies an ICC profile, such as the one
+	// returned by DecodeWithProfile or color.Profile.Bytes, to embed in
+	// an iCCP chunk. It describes the color space of the image.
+	ICCProfile []byte
 }
 
 // EncoderBufferPool is an interface for getting and returning temporary
@@ -49,6 +56,13 @@ type encoder struct {
 	zw      *zlib.Writer
 	zwLevel int
 	bw      *bufio.Writer
+
+	// seq is the sequence number of the next fcTL or fdAT chunk of an
+	// animation, and fdat is whether to write the image data in fdAT
+	// chunks rather than IDAT chunks.
+	seq     uint32
+	fdat    bool
+	fdatBuf []byte
 }
 
 type CompressionLevel int
@@ -122,10 +136,9 @@ func (e *encoder) writeChunk(b []byte, name string) {
 	_, e.err = e.w.Write(e.footer[:4])
 }
 
-func (e *encoder) writeIHDR() {
-	b := e.m.Bounds()
-	binary.BigEndian.PutUint32(e.tmp[0:4], uint32(b.Dx()))
-	binary.BigEndian.PutUint32(e.tmp[4:8], uint32(b.Dy()))
+func (e *encoder) writeIHDR(width, height int) {
+	binary.BigEndian.PutUint32(e.tmp[0:4], uint32(width))
+	binary.BigEndian.PutUint32(e.tmp[4:8], uint32(height))
 	// Set bit depth and color type.
 	switch e.cb {
 	case cbG8:
@@ -165,6 +178,24 @@ func (e *encoder) writeIHDR() {
 	e.writeChunk(e.tmp[:13], "IHDR")
 }
 
+func (e *encoder) writeiCCP(profile []byte) {
+	var b bytes.Buffer
+	b.WriteString("ICC profile")
+	b.WriteByte(0) // null separator
+	b.WriteByte(0) // zlib compression method
+	zw, err := zlib.NewWriterLevel(&b, levelToZlib(e.enc.CompressionLevel))
+	if err != nil {
+		e.err = err
+		return
+	}
+	zw.Write(profile)
+	if err := zw.Close(); err != nil {
+		e.err = err
+		return
+	}
+	e.writeChunk(b.Bytes(), "iCCP")
+}
+
 func (e *encoder) writePLTEAndTRNS(p color.Palette) {
 	if len(p) < 1 || len(p) > 256 {
 		e.err = FormatError("bad palette length: " + strconv.Itoa(len(p)))
@@ -194,7 +225,17 @@ func (e *encoder) writePLTEAndTRNS(p color.Palette) {
 // This method should only be called from writeIDATs (via writeImage).
 // No other code should treat an encoder as an io.Writer.
 func (e *encoder) Write(b []byte) (int, error) {
-	e.writeChunk(b, "IDAT")
+	if e.fdat {
+		// An fdAT chunk holds a sequence number, then the same data as an
+		// IDAT chunk.
+		e.fdatBuf = append(e.fdatBuf[:0], 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.fdatBuf, e.seq)
+		e.fdatBuf = append(e.fdatBuf, b...)
+		e.seq++
+		e.writeChunk(e.fdatBuf, "fdAT")
+	} else {
+		e.writeChunk(b, "IDAT")
+	}
 	if e.err != nil {
 		return 0, e.err
 	}
@@ -567,10 +608,41 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
 	// spec section 11.2.2 says that zero is invalid. Excessively large images are
 	// also rejected.
 	mw, mh := int64(m.Bounds().Dx()), int64(m.Bounds().Dy())
+	if err := checkSize(mw, mh); err != nil {
+		return err
+	}
+
+	e := enc.newEncoder(w)
+	if enc.BufferPool != nil {
+		defer enc.BufferPool.Put((*EncoderBuffer)(e))
+	}
+	e.m = m
+
+	var pal color.Palette
+	e.cb, pal = colorType(m, func() bool { return opaque(m) })
+
+	_, e.err = io.WriteString(w, pngHeader)
+	e.writeIHDR(m.Bounds().Dx(), m.Bounds().Dy())
+	if enc.ICCProfile != nil {
+		e.writeiCCP(enc.ICCProfile)
+	}
+	if pal != nil {
+		e.writePLTEAndTRNS(pal)
+	}
+	e.writeIDATs()
+	e.writeIEND()
+	return e.err
+}
+
+func checkSize(mw, mh int64) error {
 	if mw <= 0 || mh <= 0 || mw >= 1<<32 || mh >= 1<<32 {
 		return FormatError("invalid image size: " + strconv.FormatInt(mw, 10) + "x" + strconv.FormatInt(mh, 10))
 	}
+	return nil
+}
 
+// newEncoder returns an encoder for w, from the buffer pool if any.
+func (enc *Encoder) newEncoder(w io.Writer) *encoder {
 	var e *encoder
 	if enc.BufferPool != nil {
 		buffer := enc.BufferPool.Get()
@@ -580,14 +652,17 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
 	if e == nil {
 		e = &encoder{}
 	}
-	if enc.BufferPool != nil {
-		defer enc.BufferPool.Put((*EncoderBuffer)(e))
-	}
-
 	e.enc = enc
 	e.w = w
-	e.m = m
+	e.seq = 0
+	e.fdat = false
+	return e
+}
 
+// colorType returns the combination of color type and bit depth to encode
+// m with, and its palette if it is paletted. The opaque function reports
+// whether the image is fully opaque, which is not needed for all images.
+func colorType(m image.Image, opaque func() bool) (int, color.Palette) {
 	var pal color.Palette
 	// cbP8 encoding needs PalettedImage's ColorIndexAt method.
 	if _, ok := m.(image.PalettedImage); ok {
@@ -595,41 +670,191 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
 	}
 	if pal != nil {
 		if len(pal) <= 2 {
-			e.cb = cbP1
+			return cbP1, pal
 		} else if len(pal) <= 4 {
-			e.cb = cbP2
+			return cbP2, pal
 		} else if len(pal) <= 16 {
-			e.cb = cbP4
-		} else {
-			e.cb = cbP8
+			return cbP4, pal
 		}
-	} else {
-		switch m.ColorModel() {
-		case color.GrayModel:
-			e.cb = cbG8
-		case color.Gray16Model:
-			e.cb = cbG16
-		case color.RGBAModel, color.NRGBAModel, color.AlphaModel:
-			if opaque(m) {
-				e.cb = cbTC8
-			} else {
-				e.cb = cbTCA8
-			}
-		default:
-			if opaque(m) {
-				e.cb = cbTC16
-			} else {
-				e.cb = cbTCA16
-			}
+		return cbP8, pal
+	}
+	switch m.ColorModel() {
+	case color.GrayModel:
+		return cbG8, nil
+	case color.Gray16Model:
+		return cbG16, nil
+	case color.RGBAModel, color.NRGBAModel, color.AlphaModel:
+		if opaque() {
+			return cbTC8, nil
 		}
+		return cbTCA8, nil
+	}
+	if opaque() {
+		return cbTC16, nil
 	}
+	return cbTCA16, nil
+}
+
+// EncodeAll writes the animation a to w in APNG format.
+func EncodeAll(w io.Writer, a *APNG) error {
+	var e Encoder
+	return e.EncodeAll(w, a)
+}
+
+// EncodeAll writes the animation a to w in APNG format. All the frames,
+// and the default image if any, must have the same color model, and are
+// encoded with the color type and bit depth that Encode would choose for
+// the first frame.
+func (enc *Encoder) EncodeAll(w io.Writer, a *APNG) error {
+	if len(a.Image) == 0 {
+		return FormatError("no frames to encode")
+	}
+	if len(a.Image) != len(a.Delay) {
+		return FormatError("mismatched image and delay lengths")
+	}
+	if a.Dispose != nil && len(a.Image) != len(a.Dispose) {
+		return FormatError("mismatched image and dispose lengths")
+	}
+	if a.Blend != nil && len(a.Image) != len(a.Blend) {
+		return FormatError("mismatched image and blend lengths")
+	}
+	if a.LoopCount < 0 || a.LoopCount > 1<<31-1 {
+		return FormatError("invalid loop count: " + strconv.Itoa(a.LoopCount))
+	}
+
+	mw, mh := int64(a.Config.Width), int64(a.Config.Height)
+	if mw == 0 && mh == 0 {
+		p := a.Image[0].Bounds().Max
+		mw, mh = int64(p.X), int64(p.Y)
+	}
+	if err := checkSize(mw, mh); err != nil {
+		return err
+	}
+	canvas := image.Rect(0, 0, int(mw), int(mh))
+
+	images := a.Image
+	if a.Default != nil {
+		if a.Default.Bounds().Size() != canvas.Size() {
+			return FormatError("default image size does not match the canvas")
+		}
+		images = append([]image.Image{a.Default}, images...)
+	} else if a.Image[0].Bounds() != canvas {
+		return FormatError("first frame bounds do not match the canvas")
+	}
+	for i, m := range a.Image {
+		if b := m.Bounds(); b.Empty() || !b.In(canvas) {
+			return FormatError("frame bounds outside the canvas")
+		}
+		if d := a.Delay[i]; d < 0 || d > 0xffff*time.Second {
+			return FormatError("invalid delay: " + d.String())
+		}
+		if a.Dispose != nil && a.Dispose[i] > DisposeOpPrevious {
+			return FormatError("invalid dispose operation: " + strconv.Itoa(int(a.Dispose[i])))
+		}
+		if a.Blend != nil && a.Blend[i] > BlendOpOver {
+			return FormatError("invalid blend operation: " + strconv.Itoa(int(a.Blend[i])))
+		}
+	}
+	for _, m := range images {
+		if !sameModel(m, images[0]) {
+			return FormatError("frames with different color models")
+		}
+	}
+
+	e := enc.newEncoder(w)
+	if enc.BufferPool != nil {
+		defer enc.BufferPool.Put((*EncoderBuffer)(e))
+	}
+	var pal color.Palette
+	e.cb, pal = colorType(a.Image[0], func() bool {
+		for _, m := range images {
+			if !opaque(m) {
+				return false
+			}
+		}
+		return true
+	})
 
 	_, e.err = io.WriteString(w, pngHeader)
-	e.writeIHDR()
+	e.writeIHDR(canvas.Dx(), canvas.Dy())
+	if enc.ICCProfile != nil {
+		e.writeiCCP(enc.ICCProfile)
+	}
 	if pal != nil {
 		e.writePLTEAndTRNS(pal)
 	}
-	e.writeIDATs()
+	e.writeacTL(len(a.Image), a.LoopCount)
+	if a.Default != nil {
+		e.m = a.Default
+		e.writeIDATs()
+	}
+	for i, m := range a.Image {
+		var dispose, blend byte
+		if a.Dispose != nil {
+			dispose = a.Dispose[i]
+		}
+		if a.Blend != nil {
+			blend = a.Blend[i]
+		}
+		e.writefcTL(m.Bounds(), a.Delay[i], dispose, blend)
+		// The data of the first frame is that of the default image, in
+		// IDAT chunks, unless there is another default image.
+		e.fdat = i > 0 || a.Default != nil
+		e.m = m
+		e.writeIDATs()
+	}
 	e.writeIEND()
 	return e.err
 }
+
+// sameModel returns whether the images m0 and m1 have the same color
+// model, and are both paletted images or not.
+func sameModel(m0, m1 image.Image) bool {
+	p0, ok0 := m0.ColorModel().(color.Palette)
+	p1, ok1 := m1.ColorModel().(color.Palette)
+	if ok0 || ok1 {
+		_, pi0 := m0.(image.PalettedImage)
+		_, pi1 := m1.(image.PalettedImage)
+		if !ok0 || !ok1 || pi0 != pi1 || len(p0) != len(p1) {
+			return false
+		}
+		for i := range p0 {
+			r0, g0, b0, a0 := p0[i].RGBA()
+			r1, g1, b1, a1 := p1[i].RGBA()
+			if r0 != r1 || g0 != g1 || b0 != b1 || a0 != a1 {
+				return false
+			}
+		}
+		return true
+	}
+	return m0.ColorModel() == m1.ColorModel()
+}
+
+func (e *encoder) writeacTL(numFrames, numPlays int) {
+	binary.BigEndian.PutUint32(e.tmp[0:4], uint32(numFrames))
+	binary.BigEndian.PutUint32(e.tmp[4:8], uint32(numPlays))
+	e.writeChunk(e.tmp[:8], "acTL")
+}
+
+func (e *encoder) writefcTL(b image.Rectangle, delay time.Duration, dispose, blend byte) {
+	// Use the largest denominator that the delay fits with, so that a delay
+	// in milliseconds is exact.
+	var num, den int64
+	for den = 1000; ; den /= 10 {
+		num = (int64(delay)*den + int64(time.Second)/2) / int64(time.Second)
+		if num <= 0xffff {
+			break
+		}
+	}
+	binary.BigEndian.PutUint32(e.tmp[0:4], e.seq)
+	binary.BigEndian.PutUint32(e.tmp[4:8], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(e.tmp[8:12], uint32(b.Dy()))
+	binary.BigEndian.PutUint32(e.tmp[12:16], uint32(b.Min.X))
+	binary.BigEndian.PutUint32(e.tmp[16:20], uint32(b.Min.Y))
+	binary.BigEndian.PutUint16(e.tmp[20:22], uint16(num))
+	binary.BigEndian.PutUint16(e.tmp[22:24], uint16(den))
+	e.tmp[24] = dispose
+	e.tmp[25] = blend
+	e.seq++
+	e.writeChunk(e.tmp[:26], "fcTL")
+}
@@ -12,7 +12,9 @@ import (
 	"image"
 	"image/color"
 	"io"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func diff(m0, m1 image.Image) error {
@@ -227,6 +229,218 @@ func TestSubImage(t *testing.T) {
 	}
 }
 
+func TestICCProfile(t *testing.T) {
+	profile := color.DisplayP3Profile.Bytes()
+	for _, m0 := range []image.Image{
+		image.NewNRGBA(image.Rect(0, 0, 8, 8)),
+		image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Black, color.White}),
+	} {
+		var b bytes.Buffer
+		enc := Encoder{ICCProfile: profile}
+		if err := enc.Encode(&b, m0); err != nil {
+			t.Fatal(err)
+		}
+		m1, p, err := DecodeWithProfile(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, profile) {
+			t.Errorf("%T: profile differs", m0)
+		}
+		if err := diff(m0, m1); err != nil {
+			t.Errorf("%T: %v", m0, err)
+		}
+		// Decode ignores the profile.
+		m1, err = Decode(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := diff(m0, m1); err != nil {
+			t.Errorf("%T: %v", m0, err)
+		}
+	}
+
+	// An image without a profile.
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err := DecodeWithProfile(&b); err != nil || p != nil {
+		t.Errorf("DecodeWithProfile: profile %v, error %v, want nil", p, err)
+	}
+}
+
+func TestICCProfileTooLarge(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 8, 8))
+	for _, tc := range []struct {
+		size    int
+		wantErr bool
+	}{
+		{maxProfileSize, false},
+		{maxProfileSize + 1, true},
+	} {
+		// A profile of zeros compresses to a small iCCP chunk.
+		var b bytes.Buffer
+		enc := Encoder{ICCProfile: make([]byte, tc.size)}
+		if err := enc.Encode(&b, m); err != nil {
+			t.Fatal(err)
+		}
+		_, p, err := DecodeWithProfile(&b)
+		if !tc.wantErr {
+			if err != nil || len(p) != tc.size {
+				t.Errorf("size %d: got profile of %d bytes, error %v", tc.size, len(p), err)
+			}
+			continue
+		}
+		if _, ok := err.(FormatError); !ok {
+			t.Errorf("size %d: got error %v, want FormatError", tc.size, err)
+		}
+	}
+}
+
+// testAnimations returns animations that exercise the features of APNG.
+func testAnimations() []*APNG {
+	nrgba := func(r image.Rectangle, c color.NRGBA) image.Image {
+		m := image.NewNRGBA(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c.R, c.G = uint8(x*16), uint8(y*16)
+				m.SetNRGBA(x, y, c)
+			}
+		}
+		return m
+	}
+	gray16 := func(r image.Rectangle, v uint16) image.Image {
+		m := image.NewGray16(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				m.SetGray16(x, y, color.Gray16{v + uint16(x*y)})
+			}
+		}
+		return m
+	}
+	palette := color.Palette{color.Black, color.White, color.NRGBA{0xff, 0, 0, 0x80}}
+	paletted := func(r image.Rectangle, i uint8) image.Image {
+		m := image.NewPaletted(r, palette)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				m.SetColorIndex(x, y, (i+uint8(x+y))%3)
+			}
+		}
+		return m
+	}
+	return []*APNG{{
+		Image: []image.Image{
+			nrgba(image.Rect(0, 0, 16, 12), color.NRGBA{B: 0x40, A: 0xff}),
+			nrgba(image.Rect(4, 2, 10, 8), color.NRGBA{B: 0x80, A: 0x80}),
+			nrgba(image.Rect(8, 6, 16, 12), color.NRGBA{B: 0xc0, A: 0xff}),
+		},
+		Delay:     []time.Duration{100 * time.Millisecond, 20 * time.Millisecond, 2 * time.Minute},
+		Dispose:   []byte{DisposeOpNone, DisposeOpBackground, DisposeOpPrevious},
+		Blend:     []byte{BlendOpSource, BlendOpOver, BlendOpOver},
+		LoopCount: 3,
+		Config:    image.Config{Width: 16, Height: 12},
+	}, {
+		Image: []image.Image{
+			gray16(image.Rect(1, 1, 5, 5), 0x1234),
+			gray16(image.Rect(0, 0, 8, 8), 0x5678),
+		},
+		Delay:   []time.Duration{time.Second, 1500 * time.Millisecond},
+		Dispose: []byte{DisposeOpNone, DisposeOpNone},
+		Blend:   []byte{BlendOpSource, BlendOpSource},
+		Default: gray16(image.Rect(0, 0, 8, 8), 0),
+		Config:  image.Config{Width: 8, Height: 8},
+	}, {
+		Image: []image.Image{
+			paletted(image.Rect(0, 0, 9, 7), 0),
+			paletted(image.Rect(3, 3, 9, 7), 1),
+		},
+		Delay:     []time.Duration{0, 10 * time.Millisecond},
+		Dispose:   []byte{DisposeOpNone, DisposeOpNone},
+		Blend:     []byte{BlendOpSource, BlendOpOver},
+		LoopCount: 1,
+		Config:    image.Config{Width: 9, Height: 7},
+	}}
+}
+
+func TestEncodeAll(t *testing.T) {
+	for i, a0 := range testAnimations() {
+		var b bytes.Buffer
+		if err := EncodeAll(&b, a0); err != nil {
+			t.Fatalf("animation #%d: EncodeAll: %v", i, err)
+		}
+		a1, err := DecodeAll(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("animation #%d: DecodeAll: %v", i, err)
+		}
+		if len(a1.Image) != len(a0.Image) {
+			t.Fatalf("animation #%d: got %d frames, want %d", i, len(a1.Image), len(a0.Image))
+		}
+		for j := range a0.Image {
+			if got, want := a1.Image[j].Bounds(), a0.Image[j].Bounds(); got != want {
+				t.Errorf("animation #%d, frame #%d: bounds %v, want %v", i, j, got, want)
+			}
+			if err := diff(a0.Image[j], a1.Image[j]); err != nil {
+				t.Errorf("animation #%d, frame #%d: %v", i, j, err)
+			}
+		}
+		if !reflect.DeepEqual(a1.Delay, a0.Delay) || !bytes.Equal(a1.Dispose, a0.Dispose) || !bytes.Equal(a1.Blend, a0.Blend) {
+			t.Errorf("animation #%d: got delays %v, dispose %v and blend %v, want %v, %v and %v",
+				i, a1.Delay, a1.Dispose, a1.Blend, a0.Delay, a0.Dispose, a0.Blend)
+		}
+		if a1.LoopCount != a0.LoopCount || a1.Config.Width != a0.Config.Width || a1.Config.Height != a0.Config.Height {
+			t.Errorf("animation #%d: got loop count %d and size %dx%d, want %d and %dx%d", i,
+				a1.LoopCount, a1.Config.Width, a1.Config.Height, a0.LoopCount, a0.Config.Width, a0.Config.Height)
+		}
+
+		// Decode returns the default image.
+		want := a0.Default
+		if want == nil {
+			want = a0.Image[0]
+		} else if a1.Default == nil {
+			t.Errorf("animation #%d: no default image", i)
+		} else if err := diff(want, a1.Default); err != nil {
+			t.Errorf("animation #%d: default image: %v", i, err)
+		}
+		m, err := Decode(&b)
+		if err != nil {
+			t.Fatalf("animation #%d: Decode: %v", i, err)
+		}
+		if err := diff(want, m); err != nil {
+			t.Errorf("animation #%d: Decode: %v", i, err)
+		}
+	}
+}
+
+func TestEncodeAllErrors(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	sub := m.SubImage(image.Rect(1, 1, 3, 3))
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	d := []time.Duration{0, 0}
+	for i, a := range []*APNG{
+		{},
+		{Image: []image.Image{m, m}, Delay: d[:1]},
+		{Image: []image.Image{m, m}, Delay: d, Dispose: []byte{0}},
+		{Image: []image.Image{m, m}, Delay: d, Blend: []byte{0}},
+		{Image: []image.Image{m, m}, Delay: d, Dispose: []byte{0, DisposeOpPrevious + 1}},
+		{Image: []image.Image{m, m}, Delay: d, Blend: []byte{BlendOpOver + 1, 0}},
+		{Image: []image.Image{m, m}, Delay: []time.Duration{0, -1}},
+		{Image: []image.Image{m, m}, Delay: []time.Duration{0, 0x10000 * time.Second}},
+		{Image: []image.Image{m, m}, Delay: d, LoopCount: -1},
+		// The first frame does not cover the canvas.
+		{Image: []image.Image{sub, m}, Delay: d},
+		{Image: []image.Image{m, m}, Delay: d, Config: image.Config{Width: 5, Height: 5}},
+		{Image: []image.Image{sub, m}, Delay: d, Default: gray, Config: image.Config{Width: 3, Height: 3}},
+		// Frames of different color models.
+		{Image: []image.Image{m, gray}, Delay: d},
+		{Image: []image.Image{m, m}, Delay: d, Default: gray},
+	} {
+		if err := EncodeAll(io.Discard, a); err == nil {
+			t.Errorf("test case #%d: EncodeAll succeeded", i)
+		}
+	}
+}
+
 func BenchmarkEncodeGray(b *testing.B) {
 	img := image.NewGray(image.Rect(0, 0, 640, 480))
 	b.SetBytes(640 * 480 * 1)
@@ -0,0 +1,13 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package webp implements a decoder for WEBP images.
+//
+// It decodes lossy (VP8) and lossless (VP8L) images, in the simple format
+// or in the extended (VP8X) format, which adds an alpha channel to lossy
+// images. Animated images are not supported.
+//
+// WEBP is defined at:
+// https://developers.google.com/speed/webp/docs/riff_container
+package webp
@@ -1317,7 +1317,12 @@ func copyVal(typ *rtype, fl flag, ptr unsafe.Pointer) Value {
 // The arguments to a Call on the returned function should not include
 // a receiver; the returned function will always use v as the receiver.
 // Method panics if i is out of range or if v is a nil interface value.
+//go:noinline
 func (v Value) Method(i int) Value {
+	// Calls of Method are not inlined: the compiler marks each call
+	// so that the linker keeps the methods it can return (see
+	// usemethod in cmd/compile/internal/gc/walk.go), which it
+	// cannot do once the call is inlined.
 	if v.typ == nil {
 		panic(&ValueError{"reflect.Value.Method", Invalid})
 	}
@@ -1349,7 +1354,10 @@ func (v Value) NumMethod() int {
 // The arguments to a Call on the returned function should not include
 // a receiver; the returned function will always use v as the receiver.
 // It returns the zero Value if no method was found.
+//go:noinline
 func (v Value) MethodByName(name string) Value {
+	// Not inlined, for the same reason as Method. If name is a
+	// constant, the linker keeps only the methods of that name.
 	if v.typ == nil {
 		panic(&ValueError{"reflect.Value.MethodByName", Invalid})
 	}
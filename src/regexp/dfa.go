@@ -0,0 +1,979 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regexp
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements a lazily built DFA, used to find the bounds of
+// matches without the cost of the NFA simulation. It follows RE2's DFA,
+// described in https://swtch.com/~rsc/regexp/regexp3.html, except that
+// it runs the rune-based programs of package syntax directly instead of
+// compiling byte-based ones.
+//
+// A DFA state is the list of program instructions that the threads of
+// the NFA continue at after the last rune, in priority order, together
+// with the empty-width flags that hold at the current position. As in
+// the NFA, the alternations, captures and empty-width instructions that
+// follow them are only followed once the next rune is known, so that all
+// the empty-width flags are known too: following some of them early, with
+// only some of the flags, would visit the instructions in another order
+// than the NFA does, and give threads other priorities. States and their
+// transitions are computed as the search needs them and cached with the
+// Regexp, up to a memory budget, for the searches that follow. When the
+// cache is full, it is emptied and the search goes on; if that happens
+// too often, the search gives up and the caller falls back to the NFA.
+//
+// A forward search finds where the leftmost match ends. A search with
+// the DFA of the reversed regexp, anchored at that end, then finds
+// where the match starts, as the leftmost start of any match ending
+// there.
+//
+// The DFA of a Set reports all the matches instead: no thread is cut
+// off, and each state records which expressions matched just before
+// it.
+
+const (
+	// dfaMemBudget is the most memory the states of the DFAs of each
+	// kind of a Regexp or a Set may use.
+	dfaMemBudget = 1 << 20
+
+	// maxDFAInst is the size of the largest program run with a DFA.
+	maxDFAInst = 1 << 14
+
+	// maxClassWork limits the work to compute the rune classes of a
+	// program: the number of rune ranges times the number of
+	// instructions matching runes.
+	maxClassWork = 1 << 20
+
+	// dfaMark separates the threads started at different positions in
+	// the instruction lists of leftmost-longest searches.
+	dfaMark = ^uint32(0)
+
+	// dfaLoop stands for the thread of an unanchored search that
+	// starts matching at the current position, and skips a rune to
+	// start again after it. It has the lowest priority, and a match
+	// cuts it off like any other thread, except in the DFA of a Set.
+	dfaLoop = ^uint32(1)
+)
+
+// Flags of a DFA state, above the empty-width flags.
+const (
+	flagEmpty    = 1<<8 - 1 // empty-width flags that hold at the current position
+	flagMatch    = 1 << 8   // a match ended just before the last rune
+	flagLastWord = 1 << 9   // the last rune was a word character
+)
+
+// A dfaSpec describes a program for a DFA.
+type dfaSpec struct {
+	prog     *syntax.Prog
+	classes  *runeClasses
+	anchored bool // start threads only at the start of the search
+}
+
+func newDFASpec(prog *syntax.Prog, anchored bool) *dfaSpec {
+	if len(prog.Inst) > maxDFAInst {
+		return nil
+	}
+	classes := newRuneClasses(prog)
+	if classes == nil {
+		return nil
+	}
+	return &dfaSpec{prog: prog, classes: classes, anchored: anchored}
+}
+
+// A dfaKind is the kind of search a DFA does.
+type dfaKind int
+
+const (
+	dfaFirst   dfaKind = iota // leftmost-first, with the forward program
+	dfaLongest                // leftmost-longest, with the forward program
+	dfaReverse                // leftmost-longest, with the reverse program
+	dfaAll                    // all matches, for a Set
+	numDFAKinds
+)
+
+// A dfaCache holds the DFAs of a Regexp or a Set, so that the states
+// they compute serve all the searches. A DFA is used by one search at a
+// time, so a search takes an idle DFA of its kind from the cache, or
+// makes a new one if there is none, and puts it back when done. The
+// cache keeps one idle DFA of each kind, and the states of the DFAs of
+// each kind use at most dfaMemBudget bytes.
+type dfaCache struct {
+	fwdOnce sync.Once
+	fwd     *dfaSpec // forward program, or nil if a DFA cannot run it
+	revOnce sync.Once
+	rev     *dfaSpec // reverse program, or nil if a DFA cannot run it
+
+	mem  [numDFAKinds]int32 // memory used by the states, updated atomically
+	mu   sync.Mutex
+	idle [numDFAKinds]*dfa
+}
+
+// get returns a DFA of the given kind that runs spec.
+func (c *dfaCache) get(spec *dfaSpec, kind dfaKind) *dfa {
+	c.mu.Lock()
+	d := c.idle[kind]
+	c.idle[kind] = nil
+	c.mu.Unlock()
+	if d == nil {
+		d = newDFA(spec, kind, &c.mem[kind])
+	}
+	return d
+}
+
+// put returns d to the cache at the end of a search. If the cache has
+// an idle DFA of the same kind already, it drops d and its states.
+func (c *dfaCache) put(d *dfa) {
+	c.mu.Lock()
+	if c.idle[d.kind] == nil {
+		c.idle[d.kind] = d
+		d = nil
+	}
+	c.mu.Unlock()
+	if d != nil {
+		atomic.AddInt32(d.totalMem, -int32(d.mem))
+	}
+}
+
+// dfaSpec returns the spec of re's program, or of its reverse, or nil
+// if a DFA cannot run it. It is computed the first time it is needed.
+func (re *Regexp) dfaSpec(reverse bool) *dfaSpec {
+	c := re.dfa
+	if reverse {
+		c.revOnce.Do(func() {
+			if prog, err := syntax.Compile(reverseRegexp(re.simple)); err == nil {
+				c.rev = newDFASpec(prog, true)
+			}
+		})
+		return c.rev
+	}
+	c.fwdOnce.Do(func() {
+		c.fwd = newDFASpec(re.prog, re.cond&syntax.EmptyBeginText != 0)
+	})
+	return c.fwd
+}
+
+// reverseRegexp returns a regexp that matches the reverse of the texts
+// that re matches.
+func reverseRegexp(re *syntax.Regexp) *syntax.Regexp {
+	rev := *re
+	rev.Sub = make([]*syntax.Regexp, len(re.Sub))
+	for i, sub := range re.Sub {
+		rev.Sub[i] = reverseRegexp(sub)
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		rev.Rune = make([]rune, len(re.Rune))
+		for i, r := range re.Rune {
+			rev.Rune[len(re.Rune)-1-i] = r
+		}
+	case syntax.OpConcat:
+		for i, j := 0, len(rev.Sub)-1; i < j; i, j = i+1, j-1 {
+			rev.Sub[i], rev.Sub[j] = rev.Sub[j], rev.Sub[i]
+		}
+	case syntax.OpBeginLine:
+		rev.Op = syntax.OpEndLine
+	case syntax.OpEndLine:
+		rev.Op = syntax.OpBeginLine
+	case syntax.OpBeginText:
+		rev.Op = syntax.OpEndText
+	case syntax.OpEndText:
+		rev.Op = syntax.OpBeginText
+	}
+	return &rev
+}
+
+// runeClasses partitions the runes into classes that a program cannot
+// tell apart, so that DFA states need one transition per class.
+type runeClasses struct {
+	ascii [utf8.RuneSelf]uint16 // class of each ASCII rune
+	lo    []rune                // start of each range of non-ASCII runes
+	class []uint16              // class of each range
+	rep   []rune                // a rune in each class; the last class is endOfText
+}
+
+func newRuneClasses(prog *syntax.Prog) *runeClasses {
+	// Find the ranges of runes that no instruction splits.
+	bounds := []rune{0, '\n', '\n' + 1, utf8.RuneSelf}
+	var insts []*syntax.Inst
+	word := false
+	for pc := range prog.Inst {
+		i := &prog.Inst[pc]
+		switch i.Op {
+		case syntax.InstRune:
+			insts = append(insts, i)
+			if len(i.Rune) == 1 {
+				r0 := i.Rune[0]
+				bounds = append(bounds, r0, r0+1)
+				if syntax.Flags(i.Arg)&syntax.FoldCase != 0 {
+					for r := unicode.SimpleFold(r0); r != r0; r = unicode.SimpleFold(r) {
+						bounds = append(bounds, r, r+1)
+					}
+				}
+				break
+			}
+			for j := 0; j+1 < len(i.Rune); j += 2 {
+				bounds = append(bounds, i.Rune[j], i.Rune[j+1]+1)
+			}
+		case syntax.InstRune1:
+			insts = append(insts, i)
+			bounds = append(bounds, i.Rune[0], i.Rune[0]+1)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(i.Arg)&(syntax.EmptyWordBoundary|syntax.EmptyNoWordBoundary) != 0 {
+				word = true
+			}
+		}
+	}
+	if word {
+		bounds = append(bounds, '0', '9'+1, 'A', 'Z'+1, '_', '_'+1, 'a', 'z'+1)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	n := 0
+	for _, r := range bounds {
+		if r <= unicode.MaxRune && (n == 0 || r != bounds[n-1]) {
+			bounds[n] = r
+			n++
+		}
+	}
+	bounds = bounds[:n]
+	if len(bounds)*(len(insts)+1) > maxClassWork {
+		return nil
+	}
+
+	// Runes in ranges matched by the same instructions, and alike for
+	// the empty-width flags, are in the same class.
+	c := new(runeClasses)
+	ids := make(map[string]uint16)
+	sig := make([]byte, (len(insts)+2+7)/8)
+	for j, lo := range bounds {
+		for k := range sig {
+			sig[k] = 0
+		}
+		for k, i := range insts {
+			if i.Op == syntax.InstRune1 && lo == i.Rune[0] || i.Op == syntax.InstRune && i.MatchRune(lo) {
+				sig[k/8] |= 1 << (k % 8)
+			}
+		}
+		if k := len(insts); lo == '\n' {
+			sig[k/8] |= 1 << (k % 8)
+		}
+		if k := len(insts) + 1; word && syntax.IsWordChar(lo) {
+			sig[k/8] |= 1 << (k % 8)
+		}
+		id, ok := ids[string(sig)]
+		if !ok {
+			if len(c.rep) == 1<<16-1 {
+				return nil
+			}
+			id = uint16(len(c.rep))
+			ids[string(sig)] = id
+			c.rep = append(c.rep, lo)
+		}
+		if lo >= utf8.RuneSelf {
+			c.lo = append(c.lo, lo)
+			c.class = append(c.class, id)
+			continue
+		}
+		hi := rune(utf8.RuneSelf)
+		if j+1 < len(bounds) && bounds[j+1] < hi {
+			hi = bounds[j+1]
+		}
+		for r := lo; r < hi; r++ {
+			c.ascii[r] = id
+		}
+	}
+	c.rep = append(c.rep, endOfText)
+	return c
+}
+
+// lookup returns the class of r, which is not endOfText.
+func (c *runeClasses) lookup(r rune) int {
+	if r < utf8.RuneSelf {
+		return int(c.ascii[r])
+	}
+	// Find the last range starting at or before r.
+	lo := c.lo
+	i, j := 0, len(lo)
+	for i+1 < j {
+		h := int(uint(i+j) >> 1)
+		if lo[h] <= r {
+			i = h
+		} else {
+			j = h
+		}
+	}
+	return int(c.class[i])
+}
+
+// endOfTextClass returns the class of endOfText.
+func (c *runeClasses) endOfTextClass() int {
+	return len(c.rep) - 1
+}
+
+// A dfaState is a state of a DFA.
+type dfaState struct {
+	insts   []uint32    // instructions in priority order, and marks
+	flag    uint32      // empty-width flags, flagMatch and flagLastWord
+	start   bool        // whether only the thread of an unanchored search is left
+	matches []uint32    // in the DFA of a Set, the expressions that matched
+	next    []*dfaState // next state by rune class, or nil if not computed
+}
+
+// A dfa runs a program, leftmost-first or leftmost-longest, caching the
+// states it computes. It is used by one search at a time.
+type dfa struct {
+	*dfaSpec
+	kind    dfaKind
+	longest bool
+	all     bool // report all matches, for a Set
+
+	states   map[string]*dfaState
+	mem      int          // memory used by states
+	totalMem *int32       // memory used by the states of the DFAs of this kind
+	starts   [6]*dfaState // start states by context
+
+	q0, q1  dfaQueue
+	matches []uint32 // matches of the state being computed, for state
+	key     []byte
+}
+
+func newDFA(spec *dfaSpec, kind dfaKind, totalMem *int32) *dfa {
+	n := len(spec.prog.Inst)
+	return &dfa{
+		dfaSpec:  spec,
+		kind:     kind,
+		longest:  kind == dfaLongest || kind == dfaReverse,
+		all:      kind == dfaAll,
+		states:   make(map[string]*dfaState),
+		totalMem: totalMem,
+		q0:       newDFAQueue(n),
+		q1:       newDFAQueue(n),
+	}
+}
+
+// A dfaQueue is a sparse set of instructions, in insertion order,
+// which can also hold marks.
+type dfaQueue struct {
+	sparse []uint32
+	dense  []uint32
+}
+
+func newDFAQueue(n int) dfaQueue {
+	return dfaQueue{sparse: make([]uint32, n), dense: make([]uint32, 0, n)}
+}
+
+func (q *dfaQueue) contains(pc uint32) bool {
+	j := q.sparse[pc]
+	return j < uint32(len(q.dense)) && q.dense[j] == pc
+}
+
+func (q *dfaQueue) insert(pc uint32) {
+	q.sparse[pc] = uint32(len(q.dense))
+	q.dense = append(q.dense, pc)
+}
+
+func (q *dfaQueue) mark() {
+	q.dense = append(q.dense, dfaMark)
+}
+
+func (q *dfaQueue) clear() {
+	q.dense = q.dense[:0]
+}
+
+// add adds pc to q, along with the instructions reachable from it by
+// empty-width instructions satisfied by flag.
+func (d *dfa) add(q *dfaQueue, pc uint32, flag syntax.EmptyOp) {
+	for pc != 0 && !q.contains(pc) {
+		q.insert(pc)
+		i := &d.prog.Inst[pc]
+		switch i.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			d.add(q, i.Out, flag)
+			pc = i.Arg
+		case syntax.InstNop, syntax.InstCapture:
+			pc = i.Out
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(i.Arg)&^flag != 0 {
+				return
+			}
+			pc = i.Out
+		default:
+			return
+		}
+	}
+}
+
+// state returns the state for the instructions in q and flag, or nil
+// if the cache has no room for it.
+func (d *dfa) state(q *dfaQueue, flag uint32) *dfaState {
+	insts := q.dense
+	if n := len(insts); n > 0 && insts[n-1] == dfaMark {
+		insts = insts[:n-1]
+	}
+	if d.needFlags(insts) == 0 {
+		// The context does not matter.
+		flag &= flagMatch
+	}
+
+	key := append(d.key[:0], byte(flag), byte(flag>>8))
+	for _, pc := range insts {
+		key = append(key, byte(pc), byte(pc>>8), byte(pc>>16), byte(pc>>24))
+	}
+	if len(d.matches) > 0 {
+		key = append(key, 0xff, 0xff, 0xff, 0xff)
+		for _, m := range d.matches {
+			key = append(key, byte(m), byte(m>>8), byte(m>>16), byte(m>>24))
+		}
+	}
+	d.key = key
+	if s := d.states[string(key)]; s != nil {
+		return s
+	}
+	mem := 100 + 2*len(key) + 8*len(d.classes.rep)
+	if atomic.AddInt32(d.totalMem, int32(mem)) > dfaMemBudget {
+		atomic.AddInt32(d.totalMem, -int32(mem))
+		return nil
+	}
+	d.mem += mem
+	s := &dfaState{
+		insts: append([]uint32(nil), insts...),
+		flag:  flag,
+		start: len(insts) == 1 && insts[0] == dfaLoop,
+		next:  make([]*dfaState, len(d.classes.rep)),
+	}
+	if len(d.matches) > 0 {
+		s.matches = append([]uint32(nil), d.matches...)
+	}
+	d.states[string(key)] = s
+	return s
+}
+
+// needFlags returns the empty-width flags that the instructions reachable
+// from insts test.
+func (d *dfa) needFlags(insts []uint32) syntax.EmptyOp {
+	q := &d.q0
+	q.clear()
+	var need syntax.EmptyOp
+	var visit func(pc uint32)
+	visit = func(pc uint32) {
+		for pc != 0 && !q.contains(pc) {
+			q.insert(pc)
+			i := &d.prog.Inst[pc]
+			switch i.Op {
+			case syntax.InstAlt, syntax.InstAltMatch:
+				visit(i.Out)
+				pc = i.Arg
+			case syntax.InstNop, syntax.InstCapture:
+				pc = i.Out
+			case syntax.InstEmptyWidth:
+				need |= syntax.EmptyOp(i.Arg)
+				pc = i.Out
+			default:
+				return
+			}
+		}
+	}
+	for _, pc := range insts {
+		switch pc {
+		case dfaMark:
+		case dfaLoop:
+			visit(uint32(d.prog.Start))
+		default:
+			visit(pc)
+		}
+	}
+	return need
+}
+
+// start returns the start state for a search at a position where the
+// empty-width flags flag hold, after a word character if lastWord is
+// set, or nil if the cache has no room for it.
+func (d *dfa) start(flag syntax.EmptyOp, lastWord bool) *dfaState {
+	i := 0
+	if flag&syntax.EmptyBeginText != 0 {
+		i = 2
+	} else if flag&syntax.EmptyBeginLine != 0 {
+		i = 4
+	}
+	f := uint32(flag)
+	if lastWord {
+		i++
+		f |= flagLastWord
+	}
+	if s := d.starts[i]; s != nil {
+		return s
+	}
+	q := &d.q1
+	q.clear()
+	d.matches = d.matches[:0]
+	if d.anchored {
+		q.insert(uint32(d.prog.Start))
+	} else {
+		q.dense = append(q.dense, dfaLoop)
+	}
+	s := d.state(q, f)
+	if s == nil {
+		return nil
+	}
+	d.starts[i] = s
+	return s
+}
+
+// restore returns the state like s in the cache, after it has been
+// emptied, or nil if the cache has no room for it.
+func (d *dfa) restore(s *dfaState) *dfaState {
+	q := &d.q1
+	q.clear()
+	q.dense = append(q.dense, s.insts...)
+	d.matches = append(d.matches[:0], s.matches...)
+	return d.state(q, s.flag)
+}
+
+// transition computes the next state after s on a rune of the given
+// class, or returns nil if the cache has no room for it.
+func (d *dfa) transition(s *dfaState, class int) *dfaState {
+	r := d.classes.rep[class]
+
+	// Work out the empty-width flags before and after the rune.
+	before := syntax.EmptyOp(s.flag & flagEmpty)
+	var after syntax.EmptyOp
+	if r == '\n' {
+		before |= syntax.EmptyEndLine
+		after |= syntax.EmptyBeginLine
+	}
+	if r == endOfText {
+		before |= syntax.EmptyEndLine | syntax.EmptyEndText
+	}
+	isWord := r != endOfText && syntax.IsWordChar(r)
+	if isWord == (s.flag&flagLastWord != 0) {
+		before |= syntax.EmptyNoWordBoundary
+	} else {
+		before |= syntax.EmptyWordBoundary
+	}
+
+	// Follow the instructions from those of s, now that all the flags
+	// are known, to the ones that match runes, in priority order.
+	q := &d.q0
+	q.clear()
+	for _, pc := range s.insts {
+		switch pc {
+		case dfaMark:
+			q.mark()
+		case dfaLoop:
+			d.add(q, uint32(d.prog.Start), before)
+			q.dense = append(q.dense, dfaLoop)
+		default:
+			d.add(q, pc, before)
+		}
+	}
+
+	// Step over the rune to the instructions of the next state.
+	next := &d.q1
+	next.clear()
+	d.matches = d.matches[:0]
+	matched := false
+Loop:
+	for _, pc := range q.dense {
+		if pc == dfaMark {
+			if matched {
+				break
+			}
+			if n := len(next.dense); n > 0 && next.dense[n-1] != dfaMark {
+				next.mark()
+			}
+			continue
+		}
+		if pc == dfaLoop {
+			if r != endOfText && !matched {
+				// Keep the thread that starts after r.
+				if n := len(next.dense); d.longest && n > 0 && next.dense[n-1] != dfaMark {
+					next.mark()
+				}
+				next.dense = append(next.dense, dfaLoop)
+			}
+			continue
+		}
+		i := &d.prog.Inst[pc]
+		add := false
+		switch i.Op {
+		case syntax.InstMatch:
+			if d.all {
+				d.matches = append(d.matches, i.Arg)
+				break
+			}
+			matched = true
+			if !d.longest {
+				// Threads of lower priority cannot win.
+				break Loop
+			}
+		case syntax.InstRune:
+			add = r != endOfText && i.MatchRune(r)
+		case syntax.InstRune1:
+			add = r == i.Rune[0]
+		case syntax.InstRuneAny:
+			add = r != endOfText
+		case syntax.InstRuneAnyNotNL:
+			add = r != endOfText && r != '\n'
+		}
+		if add && !next.contains(i.Out) {
+			next.insert(i.Out)
+		}
+	}
+
+	flag := uint32(after)
+	if matched {
+		flag |= flagMatch
+	}
+	if isWord {
+		flag |= flagLastWord
+	}
+	ns := d.state(next, flag)
+	if ns == nil {
+		return nil
+	}
+	s.next[class] = ns
+	return ns
+}
+
+// reset empties the cache when it is full at position p of a search.
+// It reports false, giving up on the search, if the cache was emptied
+// too recently, at *lastReset, for the DFA to be worth running.
+func (d *dfa) reset(p int, lastReset *int) bool {
+	if *lastReset >= 0 {
+		n := p - *lastReset
+		if n < 0 {
+			n = -n
+		}
+		if n < 10*len(d.states) {
+			return false
+		}
+	}
+	*lastReset = p
+	d.states = make(map[string]*dfaState)
+	atomic.AddInt32(d.totalMem, -int32(d.mem))
+	d.mem = 0
+	d.starts = [len(d.starts)]*dfaState{}
+	return true
+}
+
+// slowNext is the slow path of a step of the search from s on a rune of
+// the given class at position p, which computes the next state.
+// It returns nil if the search should give up.
+func (d *dfa) slowNext(s *dfaState, class, p int, lastReset *int) *dfaState {
+	if ns := d.transition(s, class); ns != nil {
+		return ns
+	}
+	if !d.reset(p, lastReset) {
+		return nil
+	}
+	if s = d.restore(s); s == nil {
+		return nil
+	}
+	return d.transition(s, class)
+}
+
+// startAt returns the start state for a search at position p of in.
+// It returns nil if the search should give up.
+func (d *dfa) startAt(in dfaInput, p int, reverse bool, lastReset *int) *dfaState {
+	var flag syntax.EmptyOp
+	var lastWord bool
+	switch {
+	case !reverse && p == 0, reverse && p == in.len():
+		flag = syntax.EmptyBeginText | syntax.EmptyBeginLine
+	default:
+		var r rune
+		if reverse {
+			r, _ = in.decode(p)
+		} else {
+			r, _ = in.decodeLast(p)
+		}
+		if r == '\n' {
+			flag = syntax.EmptyBeginLine
+		}
+		lastWord = syntax.IsWordChar(r)
+	}
+	if s := d.start(flag, lastWord); s != nil {
+		return s
+	}
+	if !d.reset(p, lastReset) {
+		return nil
+	}
+	return d.start(flag, lastWord)
+}
+
+// A dfaInput is the text to search, b if non-nil and otherwise s.
+type dfaInput struct {
+	b []byte
+	s string
+}
+
+func (in dfaInput) len() int {
+	if in.b != nil {
+		return len(in.b)
+	}
+	return len(in.s)
+}
+
+func (in dfaInput) at(p int) byte {
+	if in.b != nil {
+		return in.b[p]
+	}
+	return in.s[p]
+}
+
+func (in dfaInput) decode(p int) (rune, int) {
+	if in.b != nil {
+		return utf8.DecodeRune(in.b[p:])
+	}
+	return utf8.DecodeRuneInString(in.s[p:])
+}
+
+func (in dfaInput) decodeLast(p int) (rune, int) {
+	if in.b != nil {
+		return utf8.DecodeLastRune(in.b[:p])
+	}
+	return utf8.DecodeLastRuneInString(in.s[:p])
+}
+
+// decodeLastAfter is like decodeLast, for the text from pos on.
+func (in dfaInput) decodeLastAfter(pos, p int) (rune, int) {
+	if in.b != nil {
+		return utf8.DecodeLastRune(in.b[pos:p])
+	}
+	return utf8.DecodeLastRuneInString(in.s[pos:p])
+}
+
+func (in dfaInput) index(p int, prefix string, prefixBytes []byte) int {
+	if in.b != nil {
+		return bytes.Index(in.b[p:], prefixBytes)
+	}
+	return strings.Index(in.s[p:], prefix)
+}
+
+// classAt returns the class and the width of the rune at position p
+// of in, before its end.
+func (d *dfa) classAt(in dfaInput, p int) (class, width int) {
+	if c := in.at(p); c < utf8.RuneSelf {
+		return int(d.classes.ascii[c]), 1
+	}
+	r, w := in.decode(p)
+	return d.classes.lookup(r), w
+}
+
+// searchForward returns the end of the leftmost match in the text from
+// pos on, or -1 if there is none. If earliest is set, it returns the end
+// of the first match it sees instead. It skips ahead to the occurrences
+// of re's literal prefix, if any, when no match is in progress.
+// ok is false if the search gave up.
+func (d *dfa) searchForward(re *Regexp, in dfaInput, pos int, earliest bool) (end int, ok bool) {
+	lastReset := -1
+	s := d.startAt(in, pos, false, &lastReset)
+	if s == nil {
+		return -1, false
+	}
+	end = -1
+	n := in.len()
+	p := pos
+	for p < n {
+		if s.start && re.prefix != "" {
+			i := in.index(p, re.prefix, re.prefixBytes)
+			if i < 0 {
+				return end, true
+			}
+			if i > 0 {
+				p += i
+				if s = d.startAt(in, p, false, &lastReset); s == nil {
+					return -1, false
+				}
+			}
+		}
+		class, w := d.classAt(in, p)
+		ns := s.next[class]
+		if ns == nil {
+			if ns = d.slowNext(s, class, p, &lastReset); ns == nil {
+				return -1, false
+			}
+		}
+		s = ns
+		if s.flag&flagMatch != 0 {
+			end = p
+			if earliest {
+				return end, true
+			}
+		}
+		if len(s.insts) == 0 {
+			return end, true
+		}
+		p += w
+	}
+
+	// Step past the end of the text, for matches that end there.
+	class := d.classes.endOfTextClass()
+	ns := s.next[class]
+	if ns == nil {
+		if ns = d.slowNext(s, class, p, &lastReset); ns == nil {
+			return -1, false
+		}
+	}
+	if ns.flag&flagMatch != 0 {
+		end = n
+	}
+	return end, true
+}
+
+// searchReverse runs the reverse program backward from end to pos
+// and returns the leftmost position at which it matches, or -1.
+// ok is false if the search gave up.
+func (d *dfa) searchReverse(in dfaInput, pos, end int) (start int, ok bool) {
+	lastReset := -1
+	s := d.startAt(in, end, true, &lastReset)
+	if s == nil {
+		return -1, false
+	}
+	start = -1
+	p := end
+	for p > pos {
+		var class int
+		w := 1
+		if c := in.at(p - 1); c < utf8.RuneSelf {
+			class = int(d.classes.ascii[c])
+		} else {
+			// Decode the runes after pos only, as the forward
+			// search does when pos is inside a rune.
+			var r rune
+			r, w = in.decodeLastAfter(pos, p)
+			class = d.classes.lookup(r)
+		}
+		ns := s.next[class]
+		if ns == nil {
+			if ns = d.slowNext(s, class, p, &lastReset); ns == nil {
+				return -1, false
+			}
+		}
+		s = ns
+		if s.flag&flagMatch != 0 {
+			start = p
+		}
+		if len(s.insts) == 0 {
+			return start, true
+		}
+		p -= w
+	}
+
+	// Step on the rune before pos, for matches that start at pos.
+	class := d.classes.endOfTextClass()
+	if pos > 0 {
+		r, _ := in.decodeLast(pos)
+		class = d.classes.lookup(r)
+	}
+	ns := s.next[class]
+	if ns == nil {
+		if ns = d.slowNext(s, class, p, &lastReset); ns == nil {
+			return -1, false
+		}
+	}
+	if ns.flag&flagMatch != 0 {
+		start = pos
+	}
+	return start, true
+}
+
+// searchSet runs the DFA of a Set over in and sets matched[i] for each
+// expression i that matches. It stops once all of them have matched.
+// It reports false if the search gave up.
+func (d *dfa) searchSet(in dfaInput, matched []bool) bool {
+	left := 0
+	for _, ok := range matched {
+		if !ok {
+			left++
+		}
+	}
+	lastReset := -1
+	s := d.startAt(in, 0, false, &lastReset)
+	if s == nil {
+		return false
+	}
+	n := in.len()
+	for p := 0; left > 0; {
+		class, w := d.classes.endOfTextClass(), 0
+		if p < n {
+			class, w = d.classAt(in, p)
+		}
+		ns := s.next[class]
+		if ns == nil {
+			if ns = d.slowNext(s, class, p, &lastReset); ns == nil {
+				return false
+			}
+		}
+		s = ns
+		for _, m := range s.matches {
+			if !matched[m] {
+				matched[m] = true
+				left--
+			}
+		}
+		if p == n || len(s.insts) == 0 {
+			break
+		}
+		p += w
+	}
+	return true
+}
+
+// doDFA implements re.doExecute for inputs other than io.RuneReaders,
+// when ncap is 0 or 2, using the lazy DFA. ok is false if the DFA
+// cannot run re or gave up on the search, and the caller must use
+// another engine.
+func (re *Regexp) doDFA(b []byte, s string, pos, ncap int, dstCap []int) (_ []int, ok bool) {
+	anchored := re.cond&syntax.EmptyBeginText != 0
+	if re.cond == ^syntax.EmptyOp(0) || anchored && pos != 0 {
+		return nil, true
+	}
+	var revSpec *dfaSpec
+	if ncap > 0 && !anchored {
+		// Check that the reverse search is possible before
+		// doing the forward one.
+		if revSpec = re.dfaSpec(true); revSpec == nil {
+			return nil, false
+		}
+	}
+	spec := re.dfaSpec(false)
+	if spec == nil {
+		return nil, false
+	}
+	kind := dfaFirst
+	if re.longest {
+		kind = dfaLongest
+	}
+	in := dfaInput{b, s}
+	fwd := re.dfa.get(spec, kind)
+	end, ok := fwd.searchForward(re, in, pos, ncap == 0)
+	re.dfa.put(fwd)
+	if !ok || end < 0 {
+		return nil, ok
+	}
+	if ncap == 0 {
+		return dstCap, true
+	}
+	start := pos
+	if revSpec != nil {
+		rev := re.dfa.get(revSpec, dfaReverse)
+		start, ok = rev.searchReverse(in, pos, end)
+		re.dfa.put(rev)
+		if !ok || start < 0 {
+			return nil, false
+		}
+	}
+	return append(dstCap, start, end), true
+}
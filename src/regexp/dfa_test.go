@@ -0,0 +1,311 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regexp
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var dfaRegexps = []string{
+	`a`,
+	`abc`,
+	`a*`,
+	`a+b`,
+	`(a|ab)(c|bcd)`,
+	`(?:(?:ac)*)`,
+	`x*y*z*`,
+	`[a-c]+d`,
+	`(?i)straße`,
+	`(?i)k+`,
+	`[^x]*x`,
+	`.`,
+	`(?s).+`,
+	`☺+`,
+	`[☺-☻]x`,
+	`\b`,
+	`\Bb\B`,
+	`\bfoo\b`,
+	`^`,
+	`$`,
+	`^abc`,
+	`abc$`,
+	`(?m)^b`,
+	`(?m)b$`,
+	`(?m)^$`,
+	`\A\z`,
+	`a\z`,
+	`hello|world`,
+	`error: .* \d+`,
+	`(a+)(b+)?`,
+	`a{3,5}`,
+	`[[:word:]]+`,
+	`(?:(((\b|(a|ab)))?|b*?))+`,
+	`(((?:a)+)?((\B|$)|((?s:.)|(?m:$)))((a|\B))?)*`,
+	`(?:(?:(?:(\B|a))+)+)+`,
+}
+
+var dfaInputs = []string{
+	"",
+	"a",
+	"abcd",
+	"xxabcbcdabcd",
+	"acacab",
+	"aaaaaaaab",
+	"zzyyxx",
+	"STRASSE straße",
+	"kKK",
+	"☺☻x☺",
+	"foo foobar bar foo",
+	"a\nb\nab\n\nba",
+	"hello, world",
+	"error: disk full 42\nerror: none",
+	"\xffa\xfe",
+	"abé",
+	"cbab",
+}
+
+// nfaIndex returns the location of the leftmost match in s from pos on,
+// as found by the NFA.
+func nfaIndex(re *Regexp, s string, pos int) []int {
+	m := re.get()
+	defer re.put(m)
+	i, _ := m.inputs.init(nil, nil, s)
+	m.init(2)
+	if !m.match(i, pos) {
+		return nil
+	}
+	return append([]int(nil), m.matchcap...)
+}
+
+func TestDFA(t *testing.T) {
+	for _, expr := range dfaRegexps {
+		for _, longest := range []bool{false, true} {
+			re := MustCompile(expr)
+			if longest {
+				re.Longest()
+			}
+			for _, s := range dfaInputs {
+				for pos := 0; pos <= len(s); pos++ {
+					want := nfaIndex(re, s, pos)
+					got, ok := re.doDFA(nil, s, pos, 2, nil)
+					if !ok {
+						t.Errorf("%#q longest=%v: DFA gave up on %q", expr, longest, s)
+						continue
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%#q longest=%v: match in %q from %d = %v, want %v", expr, longest, s, pos, got, want)
+					}
+					got, _ = re.doDFA([]byte(s), "", pos, 2, nil)
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%#q longest=%v: match in []byte(%q) from %d = %v, want %v", expr, longest, s, pos, got, want)
+					}
+					if m, _ := re.doDFA(nil, s, pos, 0, arrayNoInts[:0:0]); (m != nil) != (want != nil) {
+						t.Errorf("%#q longest=%v: matched %q from %d = %v, want %v", expr, longest, s, pos, m != nil, want != nil)
+					}
+				}
+			}
+		}
+	}
+}
+
+// randomRegexp returns a random regular expression of the given depth,
+// over a small alphabet, with empty-width assertions in repetitions and
+// alternations, where the priorities of threads are hardest to get right.
+func randomRegexp(r *rand.Rand, depth int) string {
+	atoms := []string{`a`, `b`, `é`, `ab`, `[ab]`, `.`, `(?s:.)`, `\b`, `\B`, `^`, `$`, `(?m:^)`, `(?m:$)`, `\A`, `\z`, ``}
+	if depth == 0 || r.Intn(4) == 0 {
+		return atoms[r.Intn(len(atoms))]
+	}
+	x := randomRegexp(r, depth-1)
+	switch r.Intn(10) {
+	case 0, 1:
+		return x + randomRegexp(r, depth-1)
+	case 2, 3:
+		return `(` + x + `|` + randomRegexp(r, depth-1) + `)`
+	case 4:
+		return `(?:` + x + `)*`
+	case 5:
+		return `(?:` + x + `)+`
+	case 6:
+		return `(` + x + `)?`
+	case 7:
+		return `(?:` + x + `)*?`
+	case 8:
+		return `(?:` + x + `)??`
+	}
+	return `(?:` + x + `){1,2}`
+}
+
+// TestDFARandom compares the matches of the DFA with those of the NFA and
+// the backtracker, for random regexps and inputs.
+func TestDFARandom(t *testing.T) {
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "é", " ", "\n"}
+	for i := 0; i < n; i++ {
+		expr := randomRegexp(r, 4)
+		re, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("%#q: %v", expr, err)
+		}
+		var b strings.Builder
+		for j := r.Intn(6); j > 0; j-- {
+			b.WriteString(alphabet[r.Intn(len(alphabet))])
+		}
+		s := b.String()
+		for _, longest := range []bool{false, true} {
+			if longest {
+				re.Longest()
+			}
+			for pos := 0; pos <= len(s); pos++ {
+				want := nfaIndex(re, s, pos)
+				if len(s) < re.maxBitStateLen {
+					if bt := re.backtrack(nil, s, pos, 2, nil); !reflect.DeepEqual(bt, want) && !(bt == nil && want == nil) {
+						t.Fatalf("%#q longest=%v: backtracker and NFA disagree on %q from %d: %v and %v", expr, longest, s, pos, bt, want)
+					}
+				}
+				got, ok := re.doDFA(nil, s, pos, 2, nil)
+				if ok && !reflect.DeepEqual(got, want) {
+					t.Errorf("%#q longest=%v: match in %q from %d = %v, want %v", expr, longest, s, pos, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDFAFallback(t *testing.T) {
+	// The DFA for this regexp has a state for every combination of
+	// the last 21 letters, so it fills its cache over and over on
+	// random text, and gives up.
+	re := MustCompile(`(a|b)*a(a|b){20}c`)
+	rnd := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < 1<<16; i++ {
+		b.WriteByte("ab"[rnd.Intn(2)])
+	}
+	b.WriteString("c")
+	s := b.String()
+	if _, ok := re.doDFA(nil, s, 0, 2, nil); ok {
+		t.Fatal("DFA did not give up")
+	}
+	want := nfaIndex(re, s, 0)
+	if got := re.FindStringIndex(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindStringIndex = %v, want %v", got, want)
+	}
+}
+
+func TestDFAConcurrent(t *testing.T) {
+	re := MustCompile(`[a-z]+ing\b`)
+	s := strings.Repeat("the quick brown fox is jumping ", 100)
+	want := re.FindAllStringIndex(s, -1)
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < 10; j++ {
+				if got := re.FindAllStringIndex(s, -1); !reflect.DeepEqual(got, want) {
+					t.Error("concurrent matches differ")
+				}
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	// The DFAs of the searches other than the ones kept have given
+	// their memory back.
+	for kind, d := range re.dfa.idle {
+		mem := 0
+		if d != nil {
+			mem = d.mem
+		}
+		if got := int(re.dfa.mem[kind]); got != mem {
+			t.Errorf("DFAs of kind %d use %d bytes, want %d", kind, got, mem)
+		}
+	}
+}
+
+// TestDFACache checks that a Regexp keeps its DFAs from one search to the
+// next, and accounts for the memory of their states.
+func TestDFACache(t *testing.T) {
+	re := MustCompile(`(?i)error [a-z]+: .*`)
+	if !re.MatchString(dfaLog) {
+		t.Fatal("no match")
+	}
+	if allocs := testing.AllocsPerRun(10, func() { re.MatchString(dfaLog) }); allocs != 0 {
+		t.Errorf("MatchString allocated %v times after the first search, want 0", allocs)
+	}
+	re.FindAllStringIndex(dfaLog, -1)
+	for kind, d := range re.dfa.idle {
+		if d == nil {
+			continue
+		}
+		if len(d.states) == 0 {
+			t.Errorf("DFA of kind %d has no states", kind)
+		}
+		if mem := int(re.dfa.mem[kind]); mem != d.mem || mem > dfaMemBudget {
+			t.Errorf("DFA of kind %d: memory %d, want %d, at most %d", kind, mem, d.mem, dfaMemBudget)
+		}
+	}
+	if re.dfa.idle[dfaFirst] == nil || re.dfa.idle[dfaReverse] == nil {
+		t.Error("DFAs not kept")
+	}
+
+	// A copy shares the DFAs.
+	re2 := re.Copy()
+	re2.MatchString(dfaLog)
+	if re2.dfa != re.dfa {
+		t.Error("Copy does not share the DFAs")
+	}
+}
+
+var dfaLog = func() string {
+	var b strings.Builder
+	for i := 0; b.Len() < 1<<20; i++ {
+		b.WriteString("2020-10-19 12:00:00 INFO server: request served in 12ms path=/index.html\n")
+		if i%1000 == 999 {
+			b.WriteString("2020-10-19 12:00:01 ERROR server: connection reset by peer 10.0.0.1\n")
+		}
+	}
+	return b.String()
+}()
+
+func benchmarkDFA(b *testing.B, expr string) {
+	re := MustCompile(expr)
+	b.SetBytes(int64(len(dfaLog)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		re.FindAllStringIndex(dfaLog, -1)
+	}
+}
+
+func BenchmarkDFALiteralPrefix(b *testing.B) { benchmarkDFA(b, `ERROR [a-z]+: .*`) }
+func BenchmarkDFAClass(b *testing.B)         { benchmarkDFA(b, `\d+\.\d+\.\d+\.\d+`) }
+func BenchmarkDFAAlternation(b *testing.B)   { benchmarkDFA(b, `(?i)error|warn(ing)?|fatal`) }
+
+// BenchmarkDFAManyRegexps matches lines against many regexps in turn, as a
+// log scanner does, so that each search uses a DFA other than the last.
+func BenchmarkDFAManyRegexps(b *testing.B) {
+	lines := strings.SplitAfter(dfaLog[:1<<12], "\n")
+	for _, n := range []int{1, 16, 17, 32, 64} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			res := make([]*Regexp, n)
+			for i := range res {
+				res[i] = MustCompile(fmt.Sprintf(`(INFO|ERROR) server: [a-z ]+%d[0-9]*(ms|\.\d+)`, i))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res[i%n].FindStringIndex(lines[i%len(lines)])
+			}
+		})
+	}
+}
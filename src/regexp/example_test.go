@@ -431,3 +431,23 @@ func ExampleRegexp_FindAllIndex() {
 	// [[1 3]]
 	// [[1 3] [4 6]]
 }
+
+func ExampleSet_MatchString() {
+	set := regexp.MustCompileSet([]string{
+		`^GET `,
+		`^POST `,
+		`/api/`,
+		`\.(png|jpg)\b`,
+	})
+	for _, req := range []string{
+		"GET /api/users",
+		"POST /upload/cat.png",
+		"DELETE /",
+	} {
+		fmt.Println(set.MatchString(req))
+	}
+	// Output:
+	// [0 2]
+	// [1 3]
+	// []
+}
@@ -528,6 +528,11 @@ func (re *Regexp) doExecute(r io.RuneReader, b []byte, s string, pos int, ncap i
 		return nil
 	}
 
+	if r == nil && (ncap == 0 || ncap == 2) {
+		if a, ok := re.doDFA(b, s, pos, ncap, dstCap); ok {
+			return a
+		}
+	}
 	if re.onepass != nil {
 		return re.doOnePass(r, b, s, pos, ncap, dstCap)
 	}
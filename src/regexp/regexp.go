@@ -80,9 +80,10 @@ import (
 // A Regexp is safe for concurrent use by multiple goroutines,
 // except for configuration methods, such as Longest.
 type Regexp struct {
-	expr           string       // as passed to Compile
-	prog           *syntax.Prog // compiled program
-	onepass        *onePassProg // onepass program or nil
+	expr           string         // as passed to Compile
+	simple         *syntax.Regexp // simplified regexp, for the reverse DFA
+	prog           *syntax.Prog   // compiled program
+	onepass        *onePassProg   // onepass program or nil
 	numSubexp      int
 	maxBitStateLen int
 	subexpNames    []string
@@ -95,6 +96,7 @@ type Regexp struct {
 	prefixComplete bool           // prefix is the entire regexp
 	cond           syntax.EmptyOp // empty-width conditions required at start of match
 	minInputLen    int            // minimum length of the input in bytes
+	dfa            *dfaCache      // DFAs, shared by copies
 
 	// This field can be modified by the Longest method,
 	// but it is otherwise read-only.
@@ -185,6 +187,7 @@ func compile(expr string, mode syntax.Flags, longest bool) (*Regexp, error) {
 	}
 	regexp := &Regexp{
 		expr:        expr,
+		simple:      re,
 		prog:        prog,
 		onepass:     compileOnePass(prog),
 		numSubexp:   maxCap,
@@ -193,6 +196,7 @@ func compile(expr string, mode syntax.Flags, longest bool) (*Regexp, error) {
 		longest:     longest,
 		matchcap:    matchcap,
 		minInputLen: minInputLen(re),
+		dfa:         new(dfaCache),
 	}
 	if regexp.onepass == nil {
 		regexp.prefix, regexp.prefixComplete = prog.Prefix()
@@ -762,9 +766,10 @@ func (re *Regexp) pad(a []int) []int {
 }
 
 // allMatches calls deliver at most n times
-// with the location of successive matches in the input text.
+// with the location of successive matches in the input text,
+// including ncap submatch positions.
 // The input text is b if non-nil, otherwise s.
-func (re *Regexp) allMatches(s string, b []byte, n int, deliver func([]int)) {
+func (re *Regexp) allMatches(s string, b []byte, n, ncap int, deliver func([]int)) {
 	var end int
 	if b == nil {
 		end = len(s)
@@ -773,7 +778,7 @@ func (re *Regexp) allMatches(s string, b []byte, n int, deliver func([]int)) {
 	}
 
 	for pos, i, prevMatchEnd := 0, 0, -1; i < n && pos <= end; {
-		matches := re.doExecute(nil, b, s, pos, re.prog.NumCap, nil)
+		matches := re.doExecute(nil, b, s, pos, ncap, nil)
 		if len(matches) == 0 {
 			break
 		}
@@ -1078,7 +1083,7 @@ func (re *Regexp) FindAll(b []byte, n int) [][]byte {
 		n = len(b) + 1
 	}
 	var result [][]byte
-	re.allMatches("", b, n, func(match []int) {
+	re.allMatches("", b, n, 2, func(match []int) {
 		if result == nil {
 			result = make([][]byte, 0, startSize)
 		}
@@ -1096,7 +1101,7 @@ func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
 		n = len(b) + 1
 	}
 	var result [][]int
-	re.allMatches("", b, n, func(match []int) {
+	re.allMatches("", b, n, 2, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
@@ -1114,7 +1119,7 @@ func (re *Regexp) FindAllString(s string, n int) []string {
 		n = len(s) + 1
 	}
 	var result []string
-	re.allMatches(s, nil, n, func(match []int) {
+	re.allMatches(s, nil, n, 2, func(match []int) {
 		if result == nil {
 			result = make([]string, 0, startSize)
 		}
@@ -1132,7 +1137,7 @@ func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
 		n = len(s) + 1
 	}
 	var result [][]int
-	re.allMatches(s, nil, n, func(match []int) {
+	re.allMatches(s, nil, n, 2, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
@@ -1150,7 +1155,7 @@ func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
 		n = len(b) + 1
 	}
 	var result [][][]byte
-	re.allMatches("", b, n, func(match []int) {
+	re.allMatches("", b, n, re.prog.NumCap, func(match []int) {
 		if result == nil {
 			result = make([][][]byte, 0, startSize)
 		}
@@ -1174,7 +1179,7 @@ func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
 		n = len(b) + 1
 	}
 	var result [][]int
-	re.allMatches("", b, n, func(match []int) {
+	re.allMatches("", b, n, re.prog.NumCap, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
@@ -1192,7 +1197,7 @@ func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
 		n = len(s) + 1
 	}
 	var result [][]string
-	re.allMatches(s, nil, n, func(match []int) {
+	re.allMatches(s, nil, n, re.prog.NumCap, func(match []int) {
 		if result == nil {
 			result = make([][]string, 0, startSize)
 		}
@@ -1217,7 +1222,7 @@ func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
 		n = len(s) + 1
 	}
 	var result [][]int
-	re.allMatches(s, nil, n, func(match []int) {
+	re.allMatches(s, nil, n, re.prog.NumCap, func(match []int) {
 		if result == nil {
 			result = make([][]int, 0, startSize)
 		}
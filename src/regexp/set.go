@@ -0,0 +1,294 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regexp
+
+import (
+	"io"
+	"regexp/syntax"
+	"sync"
+)
+
+// A Set is a set of regular expressions that are matched against a
+// text all at once. Matching a Set reports which of its expressions
+// match the text, in a single pass over it, as if by calling the
+// corresponding Match method of each expression compiled on its own.
+//
+// A Set is safe for concurrent use by multiple goroutines.
+type Set struct {
+	exprs []string
+	prog  *syntax.Prog // program matching any expression
+	cond  syntax.EmptyOp
+	pool  sync.Pool // of *setMachine
+	dfa   dfaCache  // DFAs
+}
+
+// CompileSet parses a set of regular expressions and returns, if
+// successful, a Set that can be used to match against text. The
+// expressions are numbered by their index in exprs. As with Compile,
+// they use Perl syntax and leftmost-first semantics.
+func CompileSet(exprs []string) (*Set, error) {
+	subs := make([]*syntax.Regexp, len(exprs))
+	for i, expr := range exprs {
+		re, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, err
+		}
+		// The set does not report submatches, so the parentheses
+		// are free to mark which expression matched.
+		subs[i] = &syntax.Regexp{
+			Op:  syntax.OpCapture,
+			Cap: i + 1,
+			Sub: []*syntax.Regexp{stripCaptures(re).Simplify()},
+		}
+	}
+	prog, err := syntax.Compile(&syntax.Regexp{Op: syntax.OpAlternate, Sub: subs})
+	if err != nil {
+		return nil, err
+	}
+	// Turn the end of the parentheses around each expression into
+	// a match of that expression, with its index as argument.
+	for pc := range prog.Inst {
+		i := &prog.Inst[pc]
+		if i.Op != syntax.InstCapture {
+			continue
+		}
+		if i.Arg < 2 || i.Arg%2 == 0 {
+			i.Op = syntax.InstNop
+			i.Arg = 0
+		} else {
+			i.Op = syntax.InstMatch
+			i.Out = 0
+			i.Arg = i.Arg/2 - 1
+		}
+	}
+	prog.NumCap = 0
+	if len(exprs) == 0 {
+		exprs = nil
+	}
+	s := &Set{
+		exprs: append([]string(nil), exprs...),
+		prog:  prog,
+		cond:  prog.StartCond(),
+	}
+	return s, nil
+}
+
+// MustCompileSet is like CompileSet but panics if an expression
+// cannot be parsed. It simplifies safe initialization of global
+// variables holding sets of compiled regular expressions.
+func MustCompileSet(exprs []string) *Set {
+	s, err := CompileSet(exprs)
+	if err != nil {
+		panic(`regexp: CompileSet: ` + err.Error())
+	}
+	return s
+}
+
+// stripCaptures replaces the capturing groups of re by their contents.
+func stripCaptures(re *syntax.Regexp) *syntax.Regexp {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	for i, sub := range re.Sub {
+		re.Sub[i] = stripCaptures(sub)
+	}
+	return re
+}
+
+// Len returns the number of expressions in the set.
+func (s *Set) Len() int {
+	return len(s.exprs)
+}
+
+// Expr returns the source text of the i'th expression in the set.
+func (s *Set) Expr(i int) string {
+	return s.exprs[i]
+}
+
+// Match reports the indexes of the expressions in the set that match
+// the byte slice b, in increasing order, or nil if none of them does.
+func (s *Set) Match(b []byte) []int {
+	return s.doMatch(nil, b, "")
+}
+
+// MatchString reports the indexes of the expressions in the set that
+// match the string str, in increasing order, or nil if none of them
+// does.
+func (s *Set) MatchString(str string) []int {
+	return s.doMatch(nil, nil, str)
+}
+
+// MatchReader reports the indexes of the expressions in the set that
+// match the text read from the RuneReader, in increasing order, or nil
+// if none of them does. It stops reading once all the expressions
+// have matched.
+func (s *Set) MatchReader(r io.RuneReader) []int {
+	return s.doMatch(r, nil, "")
+}
+
+// A setMachine holds the state of an NFA simulation for a Set. Unlike
+// a machine, it keeps no threads: each instruction on its queues only
+// needs to be run once, and a match of an expression does not cut off
+// the threads of the others.
+type setMachine struct {
+	p        *syntax.Prog
+	q0, q1   queue
+	matched  []bool // matched[i] reports whether expression i matched
+	nmatched int
+
+	inputs inputs
+}
+
+func (s *Set) get() *setMachine {
+	m, _ := s.pool.Get().(*setMachine)
+	if m == nil {
+		n := len(s.prog.Inst)
+		m = &setMachine{
+			p:       s.prog,
+			q0:      queue{make([]uint32, n), make([]entry, 0, n)},
+			q1:      queue{make([]uint32, n), make([]entry, 0, n)},
+			matched: make([]bool, len(s.exprs)),
+		}
+	}
+	return m
+}
+
+func (s *Set) put(m *setMachine) {
+	m.inputs.clear()
+	m.q0.dense = m.q0.dense[:0]
+	m.q1.dense = m.q1.dense[:0]
+	for i := range m.matched {
+		m.matched[i] = false
+	}
+	m.nmatched = 0
+	s.pool.Put(m)
+}
+
+func (s *Set) doMatch(ir io.RuneReader, ib []byte, is string) []int {
+	if s.cond == ^syntax.EmptyOp(0) {
+		return nil
+	}
+	m := s.get()
+	defer s.put(m)
+	if ir == nil && s.doDFA(ib, is, m.matched) {
+		return m.result()
+	}
+	for i := range m.matched {
+		m.matched[i] = false
+	}
+	i, _ := m.inputs.init(ir, ib, is)
+	runq, nextq := &m.q0, &m.q1
+	pos := 0
+	r, width := i.step(pos)
+	r1, width1 := endOfText, 0
+	if r != endOfText {
+		r1, width1 = i.step(pos + width)
+	}
+	flag := newLazyFlag(-1, r)
+	for m.nmatched < len(m.matched) {
+		if pos == 0 || s.cond&syntax.EmptyBeginText == 0 {
+			m.add(runq, uint32(m.p.Start), &flag)
+		} else if len(runq.dense) == 0 {
+			// Only anchored expressions are left.
+			break
+		}
+		flag = newLazyFlag(r, r1)
+		m.step(runq, nextq, r, &flag)
+		if width == 0 {
+			break
+		}
+		pos += width
+		r, width = r1, width1
+		if r != endOfText {
+			r1, width1 = i.step(pos + width)
+		}
+		runq, nextq = nextq, runq
+	}
+	return m.result()
+}
+
+// doDFA matches s with a DFA, setting matched[i] for each expression
+// i that matches. It reports false if the DFA could not do the search.
+func (s *Set) doDFA(b []byte, str string, matched []bool) bool {
+	s.dfa.fwdOnce.Do(func() {
+		s.dfa.fwd = newDFASpec(s.prog, s.cond&syntax.EmptyBeginText != 0)
+	})
+	if s.dfa.fwd == nil {
+		return false
+	}
+	d := s.dfa.get(s.dfa.fwd, dfaAll)
+	defer s.dfa.put(d)
+	return d.searchSet(dfaInput{b, str}, matched)
+}
+
+// result returns the indexes of the expressions that matched.
+func (m *setMachine) result() []int {
+	var matches []int
+	for i, ok := range m.matched {
+		if ok {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// step runs the instructions on runq on the rune c (which may be
+// endOfText), adding the instructions that follow to nextq.
+// nextCond gives the setting for the empty-width flags after c.
+func (m *setMachine) step(runq, nextq *queue, c rune, nextCond *lazyFlag) {
+	for _, d := range runq.dense {
+		i := &m.p.Inst[d.pc]
+		add := false
+		switch i.Op {
+		case syntax.InstRune:
+			add = i.MatchRune(c)
+		case syntax.InstRune1:
+			add = c == i.Rune[0]
+		case syntax.InstRuneAny:
+			add = c != endOfText
+		case syntax.InstRuneAnyNotNL:
+			add = c != endOfText && c != '\n'
+		}
+		if add {
+			m.add(nextq, i.Out, nextCond)
+		}
+	}
+	runq.dense = runq.dense[:0]
+}
+
+// add adds pc to q, unless q already holds it, along with all the
+// instructions reachable from pc by following empty-width conditions
+// satisfied by cond. It records the matches it reaches.
+func (m *setMachine) add(q *queue, pc uint32, cond *lazyFlag) {
+	for pc != 0 {
+		if j := q.sparse[pc]; j < uint32(len(q.dense)) && q.dense[j].pc == pc {
+			return
+		}
+		q.sparse[pc] = uint32(len(q.dense))
+		q.dense = append(q.dense, entry{pc: pc})
+
+		i := &m.p.Inst[pc]
+		switch i.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			m.add(q, i.Out, cond)
+			pc = i.Arg
+		case syntax.InstEmptyWidth:
+			if !cond.match(syntax.EmptyOp(i.Arg)) {
+				return
+			}
+			pc = i.Out
+		case syntax.InstNop:
+			pc = i.Out
+		case syntax.InstMatch:
+			if !m.matched[i.Arg] {
+				m.matched[i.Arg] = true
+				m.nmatched++
+			}
+			return
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regexp
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	exprs := append([]string{`^$`, `\Aa`, `(a)(b)?`, `x|`}, dfaRegexps...)
+	set := MustCompileSet(exprs)
+	if set.Len() != len(exprs) || set.Expr(2) != `(a)(b)?` {
+		t.Fatalf("Len, Expr = %d, %#q", set.Len(), set.Expr(2))
+	}
+	for _, s := range append(dfaInputs, "x", "\n", "ba") {
+		var want []int
+		for i, expr := range exprs {
+			if MustCompile(expr).MatchString(s) {
+				want = append(want, i)
+			}
+		}
+		if got := set.MatchString(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("MatchString(%q) = %v, want %v", s, got, want)
+		}
+		if got := set.Match([]byte(s)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Match(%q) = %v, want %v", s, got, want)
+		}
+		if got := set.MatchReader(strings.NewReader(s)); !reflect.DeepEqual(got, want) {
+			t.Errorf("MatchReader(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestSetAnchored(t *testing.T) {
+	set := MustCompileSet([]string{`^abc`, `\Ab`})
+	for _, tc := range []struct {
+		s    string
+		want []int
+	}{
+		{"abcd", []int{0}},
+		{"bcd", []int{1}},
+		{"xabc", nil},
+	} {
+		if got := set.MatchString(tc.s); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("MatchString(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestSetFallback(t *testing.T) {
+	// The DFA gives up on this set, as in TestDFAFallback.
+	set := MustCompileSet([]string{`(a|b)*a(a|b){20}c`, `bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb`, `x`})
+	rnd := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < 1<<16; i++ {
+		b.WriteByte("ab"[rnd.Intn(2)])
+	}
+	b.WriteString("c")
+	if set.doDFA(nil, b.String(), make([]bool, set.Len())) {
+		t.Fatal("DFA did not give up")
+	}
+	if got := set.MatchString(b.String()); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("MatchString = %v, want [0]", got)
+	}
+}
+
+func TestSetEmpty(t *testing.T) {
+	set := MustCompileSet(nil)
+	if got := set.MatchString("abc"); got != nil {
+		t.Errorf("MatchString = %v, want nil", got)
+	}
+	set = MustCompileSet([]string{`a\bb`})
+	if got := set.MatchString("ab"); got != nil {
+		t.Errorf("MatchString = %v, want nil", got)
+	}
+}
+
+func TestCompileSetError(t *testing.T) {
+	_, err := CompileSet([]string{`a`, `b(`})
+	if err == nil || !strings.Contains(err.Error(), "missing closing )") {
+		t.Errorf("CompileSet error = %v", err)
+	}
+}
+
+// The reader is only read as far as needed.
+func TestSetMatchReaderStops(t *testing.T) {
+	set := MustCompileSet([]string{`a`, `b`})
+	r := strings.NewReader("xxabxxxxxx")
+	if got := set.MatchReader(r); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("MatchReader = %v, want [0 1]", got)
+	}
+	if r.Len() == 0 {
+		t.Error("MatchReader read all of its input")
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	var exprs []string
+	for i := 0; i < 100; i++ {
+		exprs = append(exprs, fmt.Sprintf(`path=/api/v%d/[a-z]+ `, i))
+	}
+	set := MustCompileSet(exprs)
+	b.SetBytes(int64(len(dfaLog)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.MatchString(dfaLog)
+	}
+}
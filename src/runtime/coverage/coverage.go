@@ -0,0 +1,169 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage writes coverage data from programs built with
+// 'go build -cover'.
+//
+// A program built with -cover writes its coverage counters to the
+// directory named by the GOCOVERDIR environment variable when it exits,
+// either by returning from main.main or by calling os.Exit.
+// Programs that do not exit in that way, such as long-running servers,
+// can use WriteCountersDir or WriteCounters to write the counters at
+// a time of their choosing, and ClearCounters to reset them.
+//
+// Counter data is written in the text format produced by
+// 'go test -coverprofile', one file per write, named
+// covcounters.<pid>.<nanoseconds>. Use 'go tool covdata' to merge the
+// files in one or more directories, or to convert them into a single
+// profile for use with 'go tool cover'.
+//
+// In a program that was not built with -cover, WriteCountersDir,
+// WriteCounters and ClearCounters return an error.
+package coverage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A file holds the coverage counters of one instrumented source file.
+type file struct {
+	name    string
+	counts  []uint32
+	pos     []uint32 // start line, end line, end col<<16 | start col
+	numStmt []uint16
+}
+
+var (
+	mu    sync.Mutex // serializes writes and resets of the counters
+	mode  string
+	files []*file
+)
+
+func runtime_addExitHook(f func()) // implemented in runtime
+
+func init() {
+	runtime_addExitHook(writeOnExit)
+}
+
+// RegisterFile records the coverage counters for the source file name,
+// instrumented with the given coverage mode.
+// NOTE: This function is called by code generated by the cover tool
+// and may change. It is not covered by the Go 1 compatibility guidelines.
+func RegisterFile(coverMode, name string, counts []uint32, pos []uint32, numStmt []uint16) {
+	mode = coverMode
+	files = append(files, &file{name: name, counts: counts, pos: pos, numStmt: numStmt})
+}
+
+var errNoCoverage = errors.New("coverage: program not built with -cover")
+
+// WriteCountersDir writes the current values of the coverage counters
+// to a new file in dir, which must already exist.
+func WriteCountersDir(dir string) error {
+	if mode == "" {
+		return errNoCoverage
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := "covcounters." + strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	// Write to a temporary file first, so that a concurrent reader
+	// (such as go tool covdata) never sees a partial file.
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = writeCounters(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// WriteCounters writes the current values of the coverage counters
+// to w, in the text profile format used by 'go test -coverprofile'.
+func WriteCounters(w io.Writer) error {
+	if mode == "" {
+		return errNoCoverage
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return writeCounters(w)
+}
+
+func writeCounters(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("mode: " + mode + "\n")
+	var buf []byte
+	for _, f := range files {
+		for i := range f.counts {
+			line0, line1 := f.pos[3*i], f.pos[3*i+1]
+			col0, col1 := f.pos[3*i+2]&0xFFFF, f.pos[3*i+2]>>16
+			buf = append(buf[:0], f.name...)
+			buf = append(buf, ':')
+			buf = strconv.AppendUint(buf, uint64(line0), 10)
+			buf = append(buf, '.')
+			buf = strconv.AppendUint(buf, uint64(col0), 10)
+			buf = append(buf, ',')
+			buf = strconv.AppendUint(buf, uint64(line1), 10)
+			buf = append(buf, '.')
+			buf = strconv.AppendUint(buf, uint64(col1), 10)
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(f.numStmt[i]), 10)
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(atomic.LoadUint32(&f.counts[i])), 10) // For -covermode=atomic.
+			buf = append(buf, '\n')
+			bw.Write(buf)
+		}
+	}
+	return bw.Flush()
+}
+
+// ClearCounters resets all coverage counters to zero.
+// In a program built with -covermode=set or -covermode=count,
+// updates to the counters made concurrently with ClearCounters
+// may be lost; use -covermode=atomic if that matters.
+func ClearCounters() error {
+	if mode == "" {
+		return errNoCoverage
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, f := range files {
+		for i := range f.counts {
+			atomic.StoreUint32(&f.counts[i], 0)
+		}
+	}
+	return nil
+}
+
+// writeOnExit writes the coverage counters to $GOCOVERDIR
+// when the program exits.
+func writeOnExit() {
+	if mode == "" {
+		return
+	}
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		os.Stderr.WriteString("warning: GOCOVERDIR not set, no coverage data emitted\n")
+		return
+	}
+	if err := WriteCountersDir(dir); err != nil {
+		os.Stderr.WriteString("warning: writing coverage data: " + err.Error() + "\n")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// register replaces the registered files by a single instrumented file
+// with two blocks, and returns the counters of that file. The previous
+// state is restored at the end of the test.
+func register(t *testing.T) []uint32 {
+	oldMode, oldFiles := mode, files
+	t.Cleanup(func() { mode, files = oldMode, oldFiles })
+	mode, files = "", nil
+
+	counts := []uint32{3, 4}
+	pos := []uint32{
+		10, 12, 2<<16 | 14,
+		13, 13, 20<<16 | 5,
+	}
+	RegisterFile("count", "example.com/p/p.go", counts, pos, []uint16{2, 1})
+	return counts
+}
+
+const wantProfile = `mode: count
+example.com/p/p.go:10.14,12.2 2 3
+example.com/p/p.go:13.5,13.20 1 4
+`
+
+func TestNotInstrumented(t *testing.T) {
+	oldMode, oldFiles := mode, files
+	defer func() { mode, files = oldMode, oldFiles }()
+	mode, files = "", nil
+
+	if err := WriteCounters(new(bytes.Buffer)); err != errNoCoverage {
+		t.Errorf("WriteCounters: got %v, want %v", err, errNoCoverage)
+	}
+	if err := WriteCountersDir(t.TempDir()); err != errNoCoverage {
+		t.Errorf("WriteCountersDir: got %v, want %v", err, errNoCoverage)
+	}
+	if err := ClearCounters(); err != errNoCoverage {
+		t.Errorf("ClearCounters: got %v, want %v", err, errNoCoverage)
+	}
+}
+
+func TestWriteCounters(t *testing.T) {
+	register(t)
+	var buf bytes.Buffer
+	if err := WriteCounters(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != wantProfile {
+		t.Errorf("WriteCounters wrote:\n%s\nwant:\n%s", got, wantProfile)
+	}
+}
+
+func TestWriteCountersDir(t *testing.T) {
+	register(t)
+	dir := t.TempDir()
+	if err := WriteCountersDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	got := readDir(t, dir)
+	if len(got) != 1 || got[0] != wantProfile {
+		t.Errorf("WriteCountersDir wrote %q, want one file with %q", got, wantProfile)
+	}
+
+	if err := WriteCountersDir(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("WriteCountersDir to a missing directory succeeded")
+	}
+}
+
+// readDir returns the contents of the counter files in dir, and checks
+// that no temporary files are left behind.
+func readDir(t *testing.T, dir string) []string {
+	t.Helper()
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []string
+	for _, fi := range fis {
+		if !strings.HasPrefix(fi.Name(), "covcounters.") {
+			t.Errorf("unexpected file %s", fi.Name())
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, string(b))
+	}
+	return data
+}
+
+func TestClearCounters(t *testing.T) {
+	counts := register(t)
+
+	// Clear the counters while they are being written: every profile
+	// written has either all the old or all the new counter values.
+	var wg sync.WaitGroup
+	errc := make(chan error, 10)
+	var mu sync.Mutex
+	var profiles []string
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if err := WriteCounters(&buf); err != nil {
+				errc <- err
+				return
+			}
+			mu.Lock()
+			profiles = append(profiles, buf.String())
+			mu.Unlock()
+		}()
+	}
+	if err := ClearCounters(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+
+	cleared := strings.NewReplacer(" 2 3\n", " 2 0\n", " 1 4\n", " 1 0\n").Replace(wantProfile)
+	for _, p := range profiles {
+		if p != wantProfile && p != cleared {
+			t.Errorf("WriteCounters wrote:\n%s\nwant:\n%s\nor:\n%s", p, wantProfile, cleared)
+		}
+	}
+	if counts[0] != 0 || counts[1] != 0 {
+		t.Errorf("counters after ClearCounters = %v, want [0 0]", counts)
+	}
+}
+
+func TestWriteOnExit(t *testing.T) {
+	register(t)
+	dir := t.TempDir()
+	defer os.Setenv("GOCOVERDIR", os.Getenv("GOCOVERDIR"))
+	os.Setenv("GOCOVERDIR", dir)
+	writeOnExit()
+	got := readDir(t, dir)
+	if len(got) != 1 || got[0] != wantProfile {
+		t.Errorf("writeOnExit wrote %q, want one file with %q", got, wantProfile)
+	}
+}
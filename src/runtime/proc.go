@@ -223,6 +223,7 @@ func main() {
 	}
 	fn := main_main // make an indirect call, as the linker doesn't know the address of the main package when laying down the runtime
 	fn()
+	runExitHooks()
 	if raceenabled {
 		racefini()
 	}
@@ -251,14 +252,44 @@ func main() {
 	}
 }
 
-// os_beforeExit is called from os.Exit(0).
+// os_beforeExit is called from os.Exit.
 //go:linkname os_beforeExit os.runtime_beforeExit
-func os_beforeExit() {
-	if raceenabled {
+func os_beforeExit(exitCode int) {
+	runExitHooks()
+	if exitCode == 0 && raceenabled {
 		racefini()
 	}
 }
 
+// exitHooks holds functions to be run when the program exits,
+// either by returning from main.main or by calling os.Exit.
+// Hooks are added during package initialization, which is
+// single-threaded, so no locking is needed.
+var exitHooks struct {
+	hooks   []func()
+	running bool
+}
+
+// coverage_addExitHook is called from runtime/coverage
+// to arrange for coverage counters to be written at exit.
+//go:linkname coverage_addExitHook runtime/coverage.runtime_addExitHook
+func coverage_addExitHook(f func()) {
+	exitHooks.hooks = append(exitHooks.hooks, f)
+}
+
+// runExitHooks runs the exit hooks, in the order they were added.
+// An exit hook that itself exits the program does not cause
+// the hooks to be run again.
+func runExitHooks() {
+	if exitHooks.running {
+		return
+	}
+	exitHooks.running = true
+	for _, f := range exitHooks.hooks {
+		f()
+	}
+}
+
 // start forcegc helper goroutine
 func init() {
 	go forcegchelper()
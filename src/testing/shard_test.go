@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"reflect"
+)
+
+func TestParseShard(t *T) {
+	for _, tc := range []struct {
+		s    string
+		i, n int
+		ok   bool
+	}{
+		{"0/1", 0, 1, true},
+		{"2/3", 2, 3, true},
+		{"3/3", 0, 0, false},
+		{"-1/3", 0, 0, false},
+		{"0/0", 0, 0, false},
+		{"1", 0, 0, false},
+		{"a/2", 0, 0, false},
+		{"1/b", 0, 0, false},
+		{"", 0, 0, false},
+	} {
+		i, n, err := parseShard(tc.s)
+		if ok := err == nil; ok != tc.ok || i != tc.i || n != tc.n {
+			t.Errorf("parseShard(%q) = %d, %d, %v; want %d, %d, ok=%v", tc.s, i, n, err, tc.i, tc.n, tc.ok)
+		}
+	}
+}
+
+func TestShardTests(t *T) {
+	var tests []InternalTest
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		tests = append(tests, InternalTest{Name: name})
+	}
+	names := func(tests []InternalTest) []string {
+		var s []string
+		for _, test := range tests {
+			s = append(s, test.Name)
+		}
+		return s
+	}
+
+	want := [][]string{{"A", "D"}, {"B", "E"}, {"C"}}
+	for i := range want {
+		if got := names(shardTests(tests, i, 3)); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("shardTests(tests, %d, 3) = %v; want %v", i, got, want[i])
+		}
+	}
+}
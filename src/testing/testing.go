@@ -242,6 +242,7 @@ import (
 	"fmt"
 	"internal/race"
 	"io"
+	"math/rand"
 	"os"
 	"runtime"
 	"runtime/debug"
@@ -299,6 +300,8 @@ func Init() {
 	cpuListStr = flag.String("test.cpu", "", "comma-separated `list` of cpu counts to run each test with")
 	parallel = flag.Int("test.parallel", runtime.GOMAXPROCS(0), "run at most `n` tests in parallel")
 	testlog = flag.String("test.testlogfile", "", "write test action log to `file` (for use only by cmd/go)")
+	shuffle = flag.String("test.shuffle", "off", "randomize the execution order of tests and benchmarks")
+	shard = flag.String("test.shard", "", "run only the tests and examples in shard `i/n`")
 
 	initBenchmarkFlags()
 }
@@ -326,6 +329,8 @@ var (
 	cpuListStr           *string
 	parallel             *int
 	testlog              *string
+	shuffle              *string
+	shard                *string
 
 	haveExamples bool // are there examples?
 
@@ -1403,25 +1408,61 @@ func (m *M) Run() (code int) {
 		return
 	}
 
+	tests, benchmarks, examples := m.tests, m.benchmarks, m.examples
+	if *shard != "" {
+		i, n, err := parseShard(*shard)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "testing:", err)
+			flag.Usage()
+			m.exitCode = 2
+			return
+		}
+		tests, examples = shardTests(tests, i, n), shardExamples(examples, i, n)
+	}
+
 	if len(*matchList) != 0 {
-		listTests(m.deps.MatchString, m.tests, m.benchmarks, m.examples)
+		listTests(m.deps.MatchString, tests, benchmarks, examples)
 		m.exitCode = 0
 		return
 	}
 
+	if *shuffle != "off" {
+		var seed int64
+		var err error
+		if *shuffle == "on" {
+			seed = time.Now().UnixNano()
+		} else {
+			seed, err = strconv.ParseInt(*shuffle, 10, 64)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, `testing: -shuffle should be "off", "on", or a valid integer:`, err)
+				flag.Usage()
+				m.exitCode = 2
+				return
+			}
+		}
+		// Print the seed so that a failing order can be reproduced
+		// with -test.shuffle=seed.
+		fmt.Println("-test.shuffle", seed)
+		rng := rand.New(rand.NewSource(seed))
+		tests = append([]InternalTest(nil), tests...)
+		rng.Shuffle(len(tests), func(i, j int) { tests[i], tests[j] = tests[j], tests[i] })
+		benchmarks = append([]InternalBenchmark(nil), benchmarks...)
+		rng.Shuffle(len(benchmarks), func(i, j int) { benchmarks[i], benchmarks[j] = benchmarks[j], benchmarks[i] })
+	}
+
 	parseCpuList()
 
 	m.before()
 	defer m.after()
 	deadline := m.startAlarm()
-	haveExamples = len(m.examples) > 0
-	testRan, testOk := runTests(m.deps.MatchString, m.tests, deadline)
-	exampleRan, exampleOk := runExamples(m.deps.MatchString, m.examples)
+	haveExamples = len(examples) > 0
+	testRan, testOk := runTests(m.deps.MatchString, tests, deadline)
+	exampleRan, exampleOk := runExamples(m.deps.MatchString, examples)
 	m.stopAlarm()
 	if !testRan && !exampleRan && *matchBenchmarks == "" {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 	}
-	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, m.benchmarks) || race.Errors() > 0 {
+	if !testOk || !exampleOk || !runBenchmarks(m.deps.ImportPath(), m.deps.MatchString, benchmarks) || race.Errors() > 0 {
 		fmt.Println("FAIL")
 		m.exitCode = 1
 		return
@@ -1449,6 +1490,47 @@ func (t *T) report() {
 	}
 }
 
+// parseShard parses a -test.shard value of the form i/n,
+// where 0 <= i < n.
+func parseShard(s string) (i, n int, err error) {
+	slash := strings.IndexByte(s, '/')
+	if slash >= 0 {
+		i, err = strconv.Atoi(s[:slash])
+		if err == nil {
+			n, err = strconv.Atoi(s[slash+1:])
+		}
+	}
+	if slash < 0 || err != nil || n < 1 || i < 0 || i >= n {
+		return 0, 0, fmt.Errorf("invalid -test.shard %q: must be i/n with 0 <= i < n", s)
+	}
+	return i, n, nil
+}
+
+// shardTests returns the tests in shard i of n.
+// Tests are assigned to shards round-robin, in source order,
+// so that every test binary built from the same sources agrees
+// on the assignment.
+func shardTests(tests []InternalTest, i, n int) []InternalTest {
+	var shard []InternalTest
+	for k, test := range tests {
+		if k%n == i {
+			shard = append(shard, test)
+		}
+	}
+	return shard
+}
+
+// shardExamples is like shardTests, for examples.
+func shardExamples(examples []InternalExample, i, n int) []InternalExample {
+	var shard []InternalExample
+	for k, example := range examples {
+		if k%n == i {
+			shard = append(shard, example)
+		}
+	}
+	return shard
+}
+
 func listTests(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) {
 	if _, err := matchString(*matchList, "non-empty"); err != nil {
 		fmt.Fprintf(os.Stderr, "testing: invalid regexp in -test.list (%q): %s\n", *matchList, err)
@@ -0,0 +1,111 @@
+// asmcheck
+
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codegen
+
+// This file contains code generation tests related to bounds
+// check elimination in loops.
+
+// ------------------ //
+//      Strides       //
+// ------------------ //
+
+func Stride2(a []int) (s int) {
+	for i := 0; i < len(a); i += 2 {
+		// amd64:-".*panicIndex"
+		s += a[i]
+	}
+	return
+}
+
+func Stride4Bytes(b []byte) (s int) {
+	for i := 0; i < len(b); i += 4 {
+		// amd64:-".*panicIndex"
+		s += int(b[i])
+	}
+	return
+}
+
+func StrideDown(a []int) (s int) {
+	for i := len(a) - 1; i >= 0; i -= 3 {
+		// amd64:-".*panicIndex"
+		s += a[i]
+	}
+	return
+}
+
+// ------------------ //
+//   Offset indexes   //
+// ------------------ //
+
+func Pairs(b []byte) (s int) {
+	for i := 0; i+1 < len(b); i += 2 {
+		// amd64:-".*panicIndex"
+		s += int(b[i]) - int(b[i+1])
+	}
+	return
+}
+
+func Quads(b []uint32) (s uint32) {
+	for i := 0; i+3 < len(b); i += 4 {
+		// amd64:-".*panicIndex"
+		s += b[i] ^ b[i+1] ^ b[i+2] ^ b[i+3]
+	}
+	return
+}
+
+func Deltas(b []byte) (s int) {
+	for i := 0; i < len(b)-1; i++ {
+		// amd64:-".*panicIndex"
+		s += int(b[i+1] - b[i])
+	}
+	return
+}
+
+func Shifted(a []int) (s int) {
+	if len(a) == 0 {
+		return
+	}
+	b := a[1:]
+	for i := range b {
+		// amd64:-".*panicIndex"
+		s += a[i+1] - b[i]
+	}
+	return
+}
+
+// ------------------ //
+//   Derived lengths  //
+// ------------------ //
+
+func EqualLen(a, b []int) (s int) {
+	if len(a) != len(b) {
+		return
+	}
+	for i := range a {
+		// amd64:-".*panicIndex"
+		s += a[i] * b[i]
+	}
+	return
+}
+
+func Resliced(a, b []int) (s int) {
+	b = b[:len(a)]
+	for i := range a {
+		// amd64:-".*panicIndex"
+		s += a[i] * b[i]
+	}
+	return
+}
+
+func Made(a []int) []int {
+	b := make([]int, len(a))
+	for i := range a {
+		// amd64:-".*panicIndex"
+		b[i] = a[i] * 2
+	}
+	return b
+}
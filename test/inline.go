@@ -58,7 +58,7 @@ func _() int { // ERROR "can inline _"
 var somethingWrong error
 
 // local closures can be inlined
-func l(x, y int) (int, int, error) {
+func l(x, y int) (int, int, error) { // ERROR "can inline l"
 	e := func(err error) (int, int, error) { // ERROR "can inline l.func1" "func literal does not escape" "leaking param: err to result"
 		return 0, 0, err
 	}
@@ -98,11 +98,11 @@ func o() int {
 	return foo()
 }
 
-func p() int {
+func p() int { // ERROR "can inline p"
 	return func() int { return 42 }() // ERROR "can inline p.func1" "inlining call to p.func1"
 }
 
-func q(x int) int {
+func q(x int) int { // ERROR "can inline q"
 	foo := func() int { return x * 2 } // ERROR "can inline q.func1" "func literal does not escape"
 	return foo()                       // ERROR "inlining call to q.func1"
 }
@@ -119,7 +119,7 @@ func r(z int) int {
 	return foo(42) + bar(42) // ERROR "inlining call to r.func1"
 }
 
-func s0(x int) int {
+func s0(x int) int { // ERROR "can inline s0"
 	foo := func() { // ERROR "can inline s0.func1" "func literal does not escape"
 		x = x + 1
 	}
@@ -127,7 +127,7 @@ func s0(x int) int {
 	return x
 }
 
-func s1(x int) int {
+func s1(x int) int { // ERROR "can inline s1"
 	foo := func() int { // ERROR "can inline s1.func1" "func literal does not escape"
 		return x
 	}
@@ -135,8 +135,69 @@ func s1(x int) int {
 	return foo() // ERROR "inlining call to s1.func1"
 }
 
-// can't currently inline functions with a break statement
-func switchBreak(x, y int) int {
+// Functions containing closures can be inlined. The copied closure
+// belongs to the caller, where calls to it can be inlined in turn.
+func s2(x int) func() int { // ERROR "can inline s2" "moved to heap: x"
+	return func() int { // ERROR "can inline s2.func1" "can inline s3.func1" "func literal escapes"
+		x++
+		return x
+	}
+}
+
+func s3(x int) int {
+	f := s2(x) // ERROR "inlining call to s2" "func literal does not escape"
+	f()        // ERROR "inlining call to s3.func1"
+	return f() // ERROR "inlining call to s3.func1"
+}
+
+func s4(s []int, f func(int) int) int { // ERROR "can inline s4" "s does not escape" "f does not escape"
+	n := 0
+	for _, v := range s {
+		n += f(v)
+	}
+	return n
+}
+
+func s5(s []int, k int) int { // ERROR "s does not escape"
+	return s4(s, func(v int) int { return v * k }) // ERROR "inlining call to s4" "can inline s5.func1" "inlining call to s5.func1" "func literal does not escape"
+}
+
+// Functions containing select and go statements can be inlined.
+func select1(c chan int) int { // ERROR "can inline select1" "c does not escape"
+	select {
+	case v := <-c:
+		return v
+	default:
+		return 0
+	}
+}
+
+func select2(c chan int) int { // ERROR "can inline select2" "c does not escape"
+	return select1(c) + select1(c) // ERROR "inlining call to select1"
+}
+
+func go1(c chan int, v int) { // ERROR "can inline go1" "leaking param: c"
+	go func() { // ERROR "can inline go1.func1" "can inline go2.func1" "func literal escapes"
+		c <- v
+	}()
+}
+
+func go2(c chan int) { // ERROR "can inline go2" "leaking param: c"
+	go1(c, 1) // ERROR "inlining call to go1" "func literal escapes"
+}
+
+// A closure may defer calls, since it remains a function of its own.
+func defer1(x int) func() { // ERROR "can inline defer1"
+	return func() { // ERROR "func literal escapes"
+		defer gd1(x)
+	}
+}
+
+func defer2() func() {
+	return defer1(1) // ERROR "inlining call to defer1" "func literal escapes"
+}
+
+func switchBreak(x, y int) int { // ERROR "can inline switchBreak"
 	var n int
 	switch x {
 	case 0:
@@ -217,8 +278,7 @@ func for1(fn func() bool) { // ERROR "can inline for1" "fn does not escape"
 	}
 }
 
-// BAD: for2 should be inlineable too.
-func for2(fn func() bool) { // ERROR "fn does not escape"
+func for2(fn func() bool) { // ERROR "can inline for2" "fn does not escape"
 Loop:
 	for {
 		if fn() {
@@ -229,6 +289,41 @@ Loop:
 	}
 }
 
+func for3(fn func() bool) { // ERROR "fn does not escape"
+	for2(fn) // ERROR "inlining call to for2"
+	for2(fn) // ERROR "inlining call to for2"
+}
+
+// Make sure we can inline range loops, labeled or not.
+func range1(s []int) int { // ERROR "can inline range1" "s does not escape"
+	sum := 0
+	for _, x := range s {
+		sum += x
+	}
+	return sum
+}
+
+func range2(m map[string]int, key string) bool { // ERROR "can inline range2" "m does not escape" "key does not escape"
+Outer:
+	for k := range m {
+		switch {
+		case k == key:
+			break Outer
+		case k == "":
+			continue Outer
+		}
+		return true
+	}
+	return false
+}
+
+func range3(s []int, m map[string]int) int { // ERROR "can inline range3" "s does not escape" "m does not escape"
+	if range2(m, "") { // ERROR "inlining call to range2"
+		return 0
+	}
+	return range1(s) + range1(s) // ERROR "inlining call to range1"
+}
+
 // Issue #18493 - make sure we can do inlining of functions with a method value
 type T1 struct{}
 
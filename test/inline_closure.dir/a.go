@@ -0,0 +1,119 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+func Counter(x int) func() int {
+	return func() int {
+		x++
+		return x
+	}
+}
+
+func Apply(s []int, f func(int) int) int {
+	n := 0
+	for _, v := range s {
+		n += f(v)
+	}
+	return n
+}
+
+func Scale(s []int, k int) int {
+	return Apply(s, func(v int) int { return v * k })
+}
+
+func Nested(x int) func() int {
+	return func() int {
+		return func() int {
+			x++
+			return x
+		}()
+	}
+}
+
+func Abs(x int) (r int) {
+	f := func() int {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	r = f()
+	return
+}
+
+func Send(c chan int, v int) {
+	go func() {
+		c <- v
+	}()
+}
+
+func send(c chan int, v int) {
+	c <- v
+}
+
+func Spawn(c chan int, v int) {
+	go send(c, v)
+}
+
+func Recv(c chan int) int {
+	select {
+	case v := <-c:
+		return v
+	default:
+		return -1
+	}
+}
+
+func Sign(x int) int {
+	f := func(_ bool, v int) int {
+		if v < 0 {
+			return -1
+		}
+		return 1
+	}
+	return f(true, x)
+}
+
+// The functions below inline the ones above.
+
+func Count(x, n int) int {
+	f := Counter(x)
+	for i := 0; i < n-1; i++ {
+		f()
+	}
+	return f()
+}
+
+func Counters(n int) []func() int {
+	var fs []func() int
+	for i := 0; i < n; i++ {
+		fs = append(fs, Counter(i*10))
+	}
+	return fs
+}
+
+func Scales(s []int) int {
+	return Scale(s, 2) + Scale(s, 3)
+}
+
+func Nesteds(x int) int {
+	f := Nested(x)
+	f()
+	return f()
+}
+
+func Abses(x, y int) int {
+	return Abs(x) + Abs(y)
+}
+
+func SendRecv(v int) int {
+	c := make(chan int)
+	Send(c, v)
+	return <-c + Recv(c)
+}
+
+func Signs(x, y int) int {
+	return Sign(x) + Sign(y)
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "./a"
+
+func check(name string, got, want int) {
+	if got != want {
+		panic(name + " failed")
+	}
+}
+
+func main() {
+	f := a.Counter(1)
+	f()
+	check("Counter", f(), 3)
+	check("Count", a.Count(5, 3), 8)
+	for i, f := range a.Counters(3) {
+		f()
+		check("Counters", f(), i*10+2)
+	}
+
+	s := []int{1, 2, 3}
+	check("Scale", a.Scale(s, 4), 24)
+	check("Scales", a.Scales(s), 30)
+
+	g := a.Nested(7)
+	g()
+	check("Nested", g(), 9)
+	check("Nesteds", a.Nesteds(7), 9)
+
+	check("Abs", a.Abs(-4), 4)
+	check("Abses", a.Abses(-4, 5), 9)
+	check("Sign", a.Sign(-4), -1)
+	check("Signs", a.Signs(-4, 5), 0)
+
+	c := make(chan int)
+	a.Send(c, 6)
+	check("Send", <-c, 6)
+	a.Spawn(c, 8)
+	check("Spawn", <-c, 8)
+	check("Recv", a.Recv(c), -1)
+	check("SendRecv", a.SendRecv(6), 5)
+}
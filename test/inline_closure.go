@@ -0,0 +1,11 @@
+// rundir
+
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test that functions containing closures behave correctly
+// when inlined, and that they can be called from other packages,
+// which cannot inline them.
+
+package ignored
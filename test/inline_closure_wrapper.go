@@ -0,0 +1,36 @@
+// run -gcflags -l=4
+
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test that the pointer receiver wrapper of a method whose body
+// calls a function containing a closure compiles and works.
+// Wrappers are generated too late to inline such functions.
+
+package main
+
+type IntSlice []int
+
+//go:noinline
+func find(n int, f func(int) bool) int {
+	for i := 0; i < n; i++ {
+		if f(i) {
+			return i
+		}
+	}
+	return n
+}
+
+func search(a []int, x int) int {
+	return find(len(a), func(i int) bool { return a[i] >= x })
+}
+
+func (p IntSlice) Search(x int) int { return search(p, x) }
+
+func main() {
+	var s interface{ Search(int) int } = &IntSlice{1, 3, 5}
+	if got := s.Search(4); got != 2 {
+		panic(got)
+	}
+}
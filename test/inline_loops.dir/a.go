@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package a
+
+func Sum(s []int) int {
+	sum := 0
+	for _, x := range s {
+		sum += x
+	}
+	return sum
+}
+
+// FirstNeg returns the index of the first negative element of s, or -1.
+func FirstNeg(s []int) int {
+	idx := -1
+Loop:
+	for i, x := range s {
+		switch {
+		case x >= 0:
+			continue Loop
+		default:
+			idx = i
+			break Loop
+		}
+	}
+	return idx
+}
+
+// Count returns the number of keys of m with a positive value.
+func Count(m map[string]int) int {
+	n := 0
+	for _, v := range m {
+		if v > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Pairs returns the number of pairs x, y in s with x+y == 0,
+// stopping at the first zero.
+func Pairs(s []int) int {
+	n := 0
+Outer:
+	for i := range s {
+		for j := i + 1; j < len(s); j++ {
+			if s[j] == 0 {
+				break Outer
+			}
+			if s[i]+s[j] == 0 {
+				n++
+				continue Outer
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "./a"
+
+func check(name string, got, want int) {
+	if got != want {
+		panic(name + " failed")
+	}
+}
+
+func main() {
+	s := []int{1, 2, -3, 4, -5}
+	check("Sum", a.Sum(s), -1)
+	check("FirstNeg", a.FirstNeg(s), 2)
+	check("FirstNeg2", a.FirstNeg(s[3:]), 1)
+	check("FirstNeg3", a.FirstNeg(nil), -1)
+	check("Count", a.Count(map[string]int{"a": 1, "b": -1, "c": 2}), 2)
+	check("Pairs", a.Pairs([]int{1, -1, 2, -2, 0, 3, -3}), 1)
+	check("Pairs2", a.Pairs([]int{1, 2, -1, -2}), 2)
+
+	// Inline the same labeled loop twice in one function.
+	check("twice", a.FirstNeg(s)+a.FirstNeg(s[1:]), 3)
+}
@@ -0,0 +1,11 @@
+// rundir
+
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test that functions containing range loops and labeled
+// control statements behave correctly when inlined,
+// both within and across packages.
+
+package ignored
@@ -0,0 +1,91 @@
+// errorcheck
+
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Test the go:noalloc directive.
+
+package p
+
+type T struct{ a, b int }
+
+var (
+	sink  *T
+	sinkI interface{}
+	sinkS []int
+)
+
+//go:noalloc
+func ok(p *T, s []int) int {
+	t := T{1, 2}
+	q := &t
+	return q.a + p.b + len(s)
+}
+
+//go:noalloc
+func new1() {
+	sink = &T{} // ERROR "heap allocation \(new object\) in go:noalloc function new1"
+}
+
+//go:noalloc
+func new2(x int) *int { // ERROR "heap allocation \(new object\) in go:noalloc function new2"
+	return &x
+}
+
+//go:noalloc
+func append1(s []int) []int {
+	return append(s, 1) // ERROR "heap allocation \(append\)"
+}
+
+//go:noalloc
+func concat(a, b string) string {
+	return a + b // ERROR "heap allocation \(string concatenation\)"
+}
+
+//go:noalloc
+func iface(x int) {
+	sinkI = x // ERROR "heap allocation \(conversion to interface\)"
+}
+
+//go:noalloc
+func make1(n int) {
+	sinkS = make([]int, n) // ERROR "heap allocation \(make slice\)"
+}
+
+//go:noalloc
+func map1(m map[int]int) {
+	m[1] = 2 // ERROR "heap allocation \(map assignment\)"
+}
+
+//go:noalloc
+func go1() {
+	go ok(nil, nil) // ERROR "heap allocation \(go statement\)"
+}
+
+func small(n int) []int {
+	return make([]int, n)
+}
+
+//go:noalloc
+func inlined(n int) {
+	sinkS = small(n) // ERROR "heap allocation \(make slice\)"
+}
+
+// Every allocation is reported.
+//go:noalloc
+func twice(s []int) {
+	sink = &T{}          // ERROR "heap allocation \(new object\) in go:noalloc function twice"
+	sinkS = append(s, 1) // ERROR "heap allocation \(append\) in go:noalloc function twice"
+}
+
+//go:noinline
+func big(n int) []int {
+	return make([]int, n)
+}
+
+// Calls to functions that are not inlined are not checked.
+//go:noalloc
+func notInlined(n int) {
+	sinkS = big(n)
+}
@@ -764,8 +764,8 @@ func indexGT0(b []byte, n int) {
 func unrollUpExcl(a []int) int {
 	var i, x int
 	for i = 0; i < len(a)-1; i += 2 { // ERROR "Induction variable: limits \[0,\?\), increment 2$"
-		x += a[i] // ERROR "Proved IsInBounds$"
-		x += a[i+1]
+		x += a[i]   // ERROR "Proved IsInBounds$"
+		x += a[i+1] // ERROR "Proved IsInBounds$"
 	}
 	if i == len(a)-1 {
 		x += a[i]
@@ -777,8 +777,8 @@ func unrollUpExcl(a []int) int {
 func unrollUpIncl(a []int) int {
 	var i, x int
 	for i = 0; i <= len(a)-2; i += 2 { // ERROR "Induction variable: limits \[0,\?\], increment 2$"
-		x += a[i]
-		x += a[i+1]
+		x += a[i]   // ERROR "Proved IsInBounds$"
+		x += a[i+1] // ERROR "Proved IsInBounds$"
 	}
 	if i == len(a)-1 {
 		x += a[i]
@@ -825,12 +825,13 @@ func unrollDownInclStep(a []int) int {
 	return x
 }
 
-// Not an induction variable (step too large)
+// Induction variable with a step larger than the unrolling.
+// It cannot overflow, because len(a) is bounded.
 func unrollExclStepTooLarge(a []int) int {
 	var i, x int
-	for i = 0; i < len(a)-1; i += 3 {
-		x += a[i]
-		x += a[i+1]
+	for i = 0; i < len(a)-1; i += 3 { // ERROR "Induction variable: limits \[0,\?\), increment 3$"
+		x += a[i]   // ERROR "Proved IsInBounds$"
+		x += a[i+1] // ERROR "Proved IsInBounds$"
 	}
 	if i == len(a)-1 {
 		x += a[i]
@@ -838,12 +839,13 @@ func unrollExclStepTooLarge(a []int) int {
 	return x
 }
 
-// Not an induction variable (step too large)
+// Induction variable with a step larger than the unrolling.
+// It cannot overflow, because len(a) is bounded.
 func unrollInclStepTooLarge(a []int) int {
 	var i, x int
-	for i = 0; i <= len(a)-2; i += 3 {
-		x += a[i]
-		x += a[i+1]
+	for i = 0; i <= len(a)-2; i += 3 { // ERROR "Induction variable: limits \[0,\?\], increment 3$"
+		x += a[i]   // ERROR "Proved IsInBounds$"
+		x += a[i+1] // ERROR "Proved IsInBounds$"
 	}
 	if i == len(a)-1 {
 		x += a[i]